@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+)
+
+// alertEvent is something about a station worth bothering somebody about.
+// With -alerts, these are the only output, so cron only sends mail when it matters.
+type alertEvent struct {
+	Station string `json:"station"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// String formats the event as a one-liner, ala "Ponce Inlet: frost: Frost likely..."
+func (event alertEvent) String() string {
+	return fmt.Sprintf("%s: %s: %s", event.Station, event.Kind, event.Message)
+}
+
+// PrintAlerts shows each alert event on its own line
+func PrintAlerts(alerts []alertEvent) {
+	for _, event := range alerts {
+		fmt.Println(event)
+	}
+}
+
+// PrintAlertsJSON shows the alert events as one JSON array, and like PrintAlerts nothing
+// at all when there are none
+func PrintAlertsJSON(alerts []alertEvent) {
+	if len(alerts) == 0 {
+		return
+	}
+	jdata, err := marshalJSON(alerts)
+	if err != nil {
+		logError("Cannot marshal the alerts", err)
+		return
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
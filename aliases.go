@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stationAlias is the config's friendly name for a station, ala "Beachside" for
+// fswndaytonabch, if it has one
+func (config *configSettings) stationAlias(station string) (alias string, ok bool) {
+	alias, ok = config.Aliases[stationHandle(station)]
+	return alias, ok
+}
+
+// resolveStation turns an alias back into its station handle, ignoring case. Anything
+// that is not an alias is left as it is.
+func (config *configSettings) resolveStation(name string) string {
+	for handle, alias := range config.Aliases {
+		if strings.EqualFold(alias, strings.TrimSpace(name)) {
+			return handle
+		}
+	}
+	return name
+}
+
+// applyAliases puts the aliases in place of the station names the API gave, in the data
+// and the units, so every output shows them
+func applyAliases(config *configSettings, dataArr []WeatherData, unitArr []WeatherUnits) {
+	for i := range dataArr {
+		if alias, ok := config.stationAlias(dataArr[i].Station[0]); ok {
+			dataArr[i].Station[1], unitArr[i].Station[1] = alias, alias
+		}
+	}
+}
+
+// pickStations finds the configured stations by handle or alias. Anything else with an
+// @domain is taken as it is, and anything without is an error.
+func pickStations(c *configSettings, names []string) (picked []string, err error) {
+	for _, name := range names {
+		handle := stationHandle(c.resolveStation(strings.TrimSpace(name)))
+		found := false
+		for _, station := range c.Stations {
+			if strings.EqualFold(stationHandle(station), handle) {
+				picked, found = append(picked, station), true
+				break
+			}
+		}
+		switch {
+		case found:
+		case strings.Contains(name, "@"):
+			picked = append(picked, strings.TrimSpace(name))
+		default:
+			return nil, fmt.Errorf("station %q is not in the config", name)
+		}
+	}
+	return picked, nil
+}
+
+// selectStations keeps just the stations in the list, ala "ponceinlet,Beachside", see
+// pickStations
+func selectStations(c *configSettings, list string) (err error) {
+	if list == "" {
+		return nil
+	}
+	c.Stations, err = pickStations(c, strings.Split(list, ","))
+	return err
+}
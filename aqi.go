@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AirQuality is what a station's air quality sensors report, with the EPA AQI worked
+// out from the worst of them. Ozone is in ppm, the particulates in µg/m³.
+type AirQuality struct {
+	PM25      float64 `json:"pm25,omitempty"`
+	PM10      float64 `json:"pm10,omitempty"`
+	Ozone     float64 `json:"ozone,omitempty"`
+	AQI       int     `json:"aqi"`
+	Pollutant string  `json:"pollutant"`
+	Category  string  `json:"category"`
+	Color     string  `json:"color"`
+}
+
+// aqiBreakpoint maps a pollutant concentration range onto an AQI range
+type aqiBreakpoint struct {
+	low, high       float64
+	aqiLow, aqiHigh int
+}
+
+// EPA breakpoints, PM2.5 as revised in 2024. The stations give the current reading, not
+// the 24 hour or 8 hour average the AQI is defined on, so this is a nowcast at best.
+var (
+	aqiPM25 = []aqiBreakpoint{{0, 9.0, 0, 50}, {9.1, 35.4, 51, 100}, {35.5, 55.4, 101, 150},
+		{55.5, 125.4, 151, 200}, {125.5, 225.4, 201, 300}, {225.5, 325.4, 301, 500}}
+	aqiPM10 = []aqiBreakpoint{{0, 54, 0, 50}, {55, 154, 51, 100}, {155, 254, 101, 150},
+		{255, 354, 151, 200}, {355, 424, 201, 300}, {425, 604, 301, 500}}
+	// 8 hour ozone, then the top of the 1 hour table for the really bad days
+	aqiOzone = []aqiBreakpoint{{0, 0.054, 0, 50}, {0.055, 0.070, 51, 100}, {0.071, 0.085, 101, 150},
+		{0.086, 0.105, 151, 200}, {0.106, 0.200, 201, 300}, {0.201, 0.504, 301, 400}, {0.505, 0.604, 401, 500}}
+)
+
+// aqiCategories are the EPA category names and colors, by the top of their AQI range
+var aqiCategories = []struct {
+	top         int
+	name, color string
+}{
+	{50, "Good", "#00e400"},
+	{100, "Moderate", "#ffff00"},
+	{150, "Unhealthy for Sensitive Groups", "#ff7e00"},
+	{200, "Unhealthy", "#ff0000"},
+	{300, "Very Unhealthy", "#8f3f97"},
+	{math.MaxInt32, "Hazardous", "#7e0023"},
+}
+
+// aqiIndex interpolates the AQI for a concentration, truncated to the table's precision.
+// Off the top of the table is 500.
+func aqiIndex(concentration, precision float64, table []aqiBreakpoint) int {
+	concentration = math.Floor(concentration/precision) * precision
+	for _, bp := range table {
+		if concentration <= bp.high+precision/2 {
+			if concentration < bp.low {
+				concentration = bp.low
+			}
+			return int(math.Round(float64(bp.aqiHigh-bp.aqiLow)/(bp.high-bp.low)*(concentration-bp.low))) + bp.aqiLow
+		}
+	}
+	return 500
+}
+
+// aqiCategory names an AQI and gives its color
+func aqiCategory(aqi int) (name, color string) {
+	for _, category := range aqiCategories {
+		if aqi <= category.top {
+			return category.name, category.color
+		}
+	}
+	return "", ""
+}
+
+// airQualitySensor says which pollutant a sensor type measures, if any, ala "PM2.5"
+// for "PM 2.5 Concentration"
+func airQualitySensor(sensorType string) string {
+	sensorType = strings.ReplaceAll(strings.ToLower(sensorType), " ", "")
+	switch {
+	case strings.Contains(sensorType, "pm2.5") || strings.Contains(sensorType, "pm25"):
+		return "PM2.5"
+	case strings.Contains(sensorType, "pm10"):
+		return "PM10"
+	case strings.Contains(sensorType, "ozone") || strings.HasPrefix(sensorType, "o3"):
+		return "Ozone"
+	}
+	return ""
+}
+
+// addAirQuality files an air quality reading and works the AQI out again. Ozone in
+// ppb is taken down to ppm.
+func (data *WeatherData) addAirQuality(reading *ReadingInfo) {
+	if data.AirQuality == nil {
+		data.AirQuality = &AirQuality{}
+	}
+	value, _ := strconv.ParseFloat(reading.Value, 64)
+	switch airQualitySensor(reading.SensorType) {
+	case "PM2.5":
+		data.AirQuality.PM25 = value
+	case "PM10":
+		data.AirQuality.PM10 = value
+	case "Ozone":
+		if strings.Contains(strings.ToLower(reading.UnitSymbol), "ppb") {
+			value /= 1000
+		}
+		data.AirQuality.Ozone = value
+	}
+	data.AirQuality.calculate()
+}
+
+// calculate takes the AQI from the worst pollutant
+func (aq *AirQuality) calculate() {
+	aq.AQI, aq.Pollutant = 0, ""
+	for _, sub := range []struct {
+		name          string
+		concentration float64
+		precision     float64
+		table         []aqiBreakpoint
+	}{
+		{"PM2.5", aq.PM25, 0.1, aqiPM25},
+		{"PM10", aq.PM10, 1, aqiPM10},
+		{"Ozone", aq.Ozone, 0.001, aqiOzone},
+	} {
+		if sub.concentration <= 0 {
+			continue
+		}
+		if index := aqiIndex(sub.concentration, sub.precision, sub.table); aq.Pollutant == "" || index > aq.AQI {
+			aq.AQI, aq.Pollutant = index, sub.name
+		}
+	}
+	aq.Category, aq.Color = aqiCategory(aq.AQI)
+}
+
+// String describes the air, ala "AQI 42 Good (PM2.5)"
+func (aq *AirQuality) String() string {
+	return fmt.Sprintf("AQI %d %s (%s)", aq.AQI, aq.Category, aq.Pollutant)
+}
+
+// readings lists the concentrations the station has, ala "PM2.5 10.2µg/m³, ozone 0.031ppm"
+func (aq *AirQuality) readings() string {
+	var parts []string
+	if aq.PM25 > 0 {
+		parts = append(parts, fmt.Sprintf("PM2.5 %.1fµg/m³", aq.PM25))
+	}
+	if aq.PM10 > 0 {
+		parts = append(parts, fmt.Sprintf("PM10 %.0fµg/m³", aq.PM10))
+	}
+	if aq.Ozone > 0 {
+		parts = append(parts, fmt.Sprintf("ozone %.3fppm", aq.Ozone))
+	}
+	return strings.Join(parts, ", ")
+}
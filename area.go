@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+
+	"github.com/loraxipam/compassrose"
+)
+
+// AreaConditions rolls a bunch of stations up into one picture of the area. Everything is
+// in the first station's units, the others being converted to match. The [3] arrays are
+// mean, minimum and maximum.
+type AreaConditions struct {
+	Stations    []string   `json:"stations"`
+	Temperature [3]float64 `json:"temp"`
+	Dewpoint    [3]float64 `json:"dewpoint"`
+	Humidity    [3]float64 `json:"humidity"`
+	Windspeed   [3]float64 `json:"windspeed"`
+	Gust        float64    `json:"gust"`
+	WindDir     float64    `json:"winddir"`
+	Pressure    [3]float64 `json:"pressure"`
+	RainRate    [3]float64 `json:"rainrate"`
+	Units       AreaUnits  `json:"units"`
+}
+
+// AreaUnits are the measurement units for AreaConditions values
+type AreaUnits struct {
+	Temperature string `json:"temp"`
+	Windspeed   string `json:"windspeed"`
+	Pressure    string `json:"pressure"`
+	RainRate    string `json:"rainrate"`
+}
+
+// spread works out the mean, minimum and maximum of some values
+func spread(values []float64) (stats [3]float64) {
+	if len(values) == 0 {
+		return stats
+	}
+	stats[1], stats[2] = values[0], values[0]
+	for _, v := range values {
+		stats[0] += v
+		stats[1] = math.Min(stats[1], v)
+		stats[2] = math.Max(stats[2], v)
+	}
+	stats[0] /= float64(len(values))
+	return stats
+}
+
+// AggregateArea combines the stations into area conditions. The wind direction is the
+// direction of the average wind vector, so 350° and 10° make North, not South.
+func AggregateArea(dataArr []WeatherData, unitArr []WeatherUnits) (area AreaConditions) {
+	if len(dataArr) == 0 {
+		return area
+	}
+	// Work on copies, since harmonizing converts in place
+	data := append([]WeatherData(nil), dataArr...)
+	units := append([]WeatherUnits(nil), unitArr...)
+	harmonizeUnits(data, units)
+
+	var temps, dewpoints, humidities, winds, pressures, rates []float64
+	var east, north float64
+	for i := range data {
+		area.Stations = append(area.Stations, data[i].Station[0])
+		temps = append(temps, data[i].Temperature[0])
+		dewpoints = append(dewpoints, data[i].Temperature[1])
+		humidities = append(humidities, data[i].Humidity)
+		winds = append(winds, data[i].Windspeed[0])
+		pressures = append(pressures, data[i].Pressure)
+		rates = append(rates, data[i].Rain[1])
+		area.Gust = math.Max(area.Gust, data[i].Windspeed[1])
+		direction := data[i].Windspeed[2] * math.Pi / 180
+		east += data[i].Windspeed[0] * math.Sin(direction)
+		north += data[i].Windspeed[0] * math.Cos(direction)
+	}
+	area.Temperature = spread(temps)
+	area.Dewpoint = spread(dewpoints)
+	area.Humidity = spread(humidities)
+	area.Windspeed = spread(winds)
+	area.Pressure = spread(pressures)
+	area.RainRate = spread(rates)
+	area.WindDir = math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+
+	area.Units = AreaUnits{
+		Temperature: units[0].Temperature[0],
+		Windspeed:   units[0].Windspeed[0],
+		Pressure:    units[0].Pressure,
+		RainRate:    units[0].Rain[1],
+	}
+	return area
+}
+
+// PrintAreaConditions shows the area rollup like a station, with the spread in brackets
+func (area *AreaConditions) PrintAreaConditions() {
+	u := &area.Units
+	temp := html.UnescapeString(u.Temperature)
+	_, heading := compassrose.DegreeToHeading(float32(area.WindDir), compassrose.SixteenPoints, true)
+	fmt.Printf("Area (%d stations)\n", len(area.Stations))
+	fmt.Printf(" T: %.1f%s [%.1f-%.1f] DP: %.1f%s [%.1f-%.1f] H: %.1f%% [%.0f-%.0f]\n", area.Temperature[0], temp, area.Temperature[1], area.Temperature[2], area.Dewpoint[0], temp, area.Dewpoint[1], area.Dewpoint[2], area.Humidity[0], area.Humidity[1], area.Humidity[2])
+	fmt.Printf(" P: %.3f%s [%.3f-%.3f]\n", area.Pressure[0], u.Pressure, area.Pressure[1], area.Pressure[2])
+	fmt.Printf(" W: %.1f%s [%.1f-%.1f] %.1f%s max gust, %.0f° %s\n", area.Windspeed[0], u.Windspeed, area.Windspeed[1], area.Windspeed[2], area.Gust, u.Windspeed, area.WindDir, heading)
+	fmt.Printf(" R: %.2f%s [%.2f-%.2f]\n", area.RainRate[0], u.RainRate, area.RainRate[1], area.RainRate[2])
+}
+
+// PrintAreaConditionsLite shows the area rollup with just the means and extremes
+func (area *AreaConditions) PrintAreaConditionsLite() {
+	fmt.Println("Area", len(area.Stations), "stations")
+	fmt.Println(" ", " T:", strconv.FormatFloat(area.Temperature[0], 'f', 1, 64), strconv.FormatFloat(area.Temperature[1], 'f', 1, 64), strconv.FormatFloat(area.Temperature[2], 'f', 1, 64), "H:", strconv.FormatFloat(area.Humidity[0], 'f', 0, 64))
+	fmt.Println(" ", " P:", strconv.FormatFloat(area.Pressure[0], 'f', 3, 64))
+	fmt.Println(" ", " W:", strconv.FormatFloat(area.Windspeed[0], 'f', 1, 64), strconv.FormatFloat(area.Gust, 'f', 1, 64), "gust", "("+strconv.FormatFloat(area.WindDir, 'f', 0, 64)+"°)")
+	fmt.Println(" ", " R:", strconv.FormatFloat(area.RainRate[2], 'f', 2, 64), "max rate")
+}
+
+// PrintAreaConditionsJSON shows the area rollup as one line of JSON
+func (area *AreaConditions) PrintAreaConditionsJSON() {
+	jdata, err := marshalJSON(area)
+	if err != nil {
+		logError("Cannot marshal area conditions", err)
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
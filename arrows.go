@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// Radius of the -windrose compass, in rows; columns are doubled to look round
+	windCompassRadius = 4
+	// Gusts this strong (m/s) reach the rim of the compass
+	windCompassFullGust = 20.0
+)
+
+// windArrows point the way the wind blows, starting with north and going clockwise
+var windArrows = []rune("↑↗→↘↓↙←↖")
+
+// windArrow is the arrow for a wind from this direction, pointing downwind, ala "←" for
+// an east wind. A vane reading below zero is taken round the compass; none at all has no arrow.
+func windArrow(degrees float64) string {
+	downwind := math.Mod(math.Mod(degrees+180, 360)+360, 360)
+	if math.IsNaN(downwind) {
+		return ""
+	}
+	return string(windArrows[int((downwind+22.5)/45)%len(windArrows)])
+}
+
+// windCompass draws a small compass rose. The wind's direction is marked on the rim,
+// where it comes from, and the gust runs from the middle downwind, longer the stronger
+// it blows.
+func windCompass(direction, gust float64) []string {
+	rows, cols := 2*windCompassRadius+1, 4*windCompassRadius+1
+	grid := make([][]rune, rows)
+	for y := range grid {
+		grid[y] = []rune(strings.Repeat(" ", cols))
+	}
+	at := func(degrees, radius float64) (x, y int) {
+		radians := degrees * math.Pi / 180
+		return windCompassRadius*2 + int(math.Round(2*radius*math.Sin(radians))),
+			windCompassRadius - int(math.Round(radius*math.Cos(radians)))
+	}
+
+	for degrees := 0.0; degrees < 360; degrees += 10 {
+		x, y := at(degrees, windCompassRadius)
+		grid[y][x] = '·'
+	}
+	for i, letter := range "NESW" {
+		x, y := at(float64(i*90), windCompassRadius)
+		grid[y][x] = letter
+	}
+
+	length := windCompassRadius * math.Min(1, gust/windCompassFullGust)
+	for r := 1.0; r < length; r++ {
+		x, y := at(direction+180, r)
+		grid[y][x] = '∙'
+	}
+	if length >= 1 {
+		x, y := at(direction+180, math.Round(length))
+		grid[y][x] = []rune(windArrow(direction))[0]
+	}
+	x, y := at(direction, windCompassRadius)
+	grid[y][x] = '●'
+	grid[windCompassRadius][windCompassRadius*2] = '+'
+
+	lines := make([]string, rows)
+	for y := range grid {
+		lines[y] = strings.TrimRight(string(grid[y]), " ")
+	}
+	return lines
+}
+
+// PrintWindCompass shows the station's wind on a small compass rose
+func (data *WeatherData) PrintWindCompass(units *WeatherUnits) {
+	fmt.Printf("   From %.0f° %s, %.1f%s gusting %.1f%s\n", data.Windspeed[2], data.Wind[0], data.Windspeed[0], units.Windspeed[0], data.Windspeed[1], units.Windspeed[1])
+	for _, line := range windCompass(data.Windspeed[2], toMetersPerSecond(data.Windspeed[1], units.Windspeed[1])) {
+		fmt.Println("   " + line)
+	}
+}
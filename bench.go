@@ -0,0 +1,191 @@
+package main
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/loraxipam/weatherstem-cli/weatherapi"
+)
+
+func init() {
+	subcommands["bench"] = benchCommand
+}
+
+// benchStats is how one endpoint, or one station, did over the rounds
+type benchStats struct {
+	Name      string
+	Calls     int
+	Failed    int
+	Latencies []time.Duration
+}
+
+// percentile is the latency that p of the calls came in under, by nearest rank
+func (stats *benchStats) percentile(p float64) time.Duration {
+	if len(stats.Latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), stats.Latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// tally counts a call, timed when it answered at all
+func (stats *benchStats) tally(took time.Duration, timed, failed bool) {
+	stats.Calls++
+	if failed {
+		stats.Failed++
+	}
+	if timed {
+		stats.Latencies = append(stats.Latencies, took)
+	}
+}
+
+// print is one line of the report. Stations asked about along with the rest have no
+// latency of their own, only whether they came back.
+func (stats *benchStats) print(indent string) {
+	rate := 0.0
+	if stats.Calls > 0 {
+		rate = 100 * float64(stats.Failed) / float64(stats.Calls)
+	}
+	fmt.Printf("%-44s %5d %5d %4.0f%%", indent+redact(stats.Name), stats.Calls, stats.Failed, rate)
+	if len(stats.Latencies) > 0 {
+		for _, p := range []float64{0.5, 0.9, 0.99, 1} {
+			fmt.Printf(" %8s", stats.percentile(p).Round(time.Millisecond))
+		}
+	}
+	fmt.Println()
+}
+
+// benchCall asks an endpoint about the stations once, reading and decoding all it sends,
+// and says which stations came back as an error or not at all
+func benchCall(c *configSettings, endpoint apiEndpoint, stations []string) (failed map[string]bool, err error) {
+	body, err := openEndpoint(c, endpoint.URL, endpoint.Key, stations)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	results, err := faultReader(body)
+	if err != nil {
+		return nil, err
+	}
+	answered := make(map[string]bool)
+	err = weatherapi.Stream(results, func(result stdjson.RawMessage) error {
+		info, _, err := weatherapi.DecodeStation(result)
+		if err == nil {
+			answered[info.WeatherStation.Handle] = true
+		}
+		return nil
+	})
+	if err != nil && len(answered) == 0 {
+		return nil, fmt.Errorf("%w: %v", errNotStations, err)
+	}
+	failed = make(map[string]bool)
+	for _, station := range stations {
+		failed[stationHandle(station)] = !answered[stationHandle(station)]
+	}
+	return failed, nil
+}
+
+// benchCommand calls each endpoint over and over and reports how fast and how reliably it
+// answered, ala 'weatherstem bench -n 20', to pick the quickest domain for stations that
+// are on several and a polling interval the API keeps up with. Each call is timed from
+// asking to the last station decoded. With -each every station is asked about on its own,
+// to time the stations one by one. Every call counts against the API quota.
+func benchCommand(c *configSettings, args []string) (err error) {
+	var (
+		rounds int
+		wait   time.Duration
+		each   bool
+	)
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	flags.IntVar(&rounds, "n", 10, "Calls to make to each endpoint, or each station with -each")
+	flags.DurationVar(&wait, "wait", time.Second, "Pause between rounds, to go easy on the API")
+	flags.BoolVar(&each, "each", false, "Ask about each station on its own, timing them one by one")
+	flags.Parse(args)
+
+	if rounds < 1 {
+		return fmt.Errorf("Cannot bench %d rounds.", rounds)
+	}
+	endpoints := c.splitByEndpoint()
+	if len(endpoints) == 0 {
+		return errors.New("No stations in the config to bench with")
+	}
+
+	// What gets called each round: the endpoints with all their stations, or each station
+	type benchTarget struct {
+		index    int
+		endpoint apiEndpoint
+		stations []string
+		stats    *benchStats
+	}
+	var targets []benchTarget
+	endpointStats := make([]*benchStats, len(endpoints))
+	stationStats := make([][]*benchStats, len(endpoints))
+	for i, endpoint := range endpoints {
+		endpointStats[i] = &benchStats{Name: endpoint.URL}
+		for _, station := range endpoint.Stations {
+			stats := &benchStats{Name: stationHandle(station)}
+			stationStats[i] = append(stationStats[i], stats)
+			if each {
+				targets = append(targets, benchTarget{index: i, endpoint: endpoint, stations: []string{station}, stats: stats})
+			}
+		}
+		if !each {
+			targets = append(targets, benchTarget{index: i, endpoint: endpoint, stations: endpoint.Stations, stats: endpointStats[i]})
+		}
+	}
+	logInfo(fmt.Sprintf("Benching with %d API calls, %d rounds of %d", rounds*len(targets), rounds, len(targets)))
+
+	var quota errQuota
+	for round := 0; round < rounds; round++ {
+		if round > 0 {
+			time.Sleep(wait)
+		}
+		for _, target := range targets {
+			start := time.Now()
+			failed, err := benchCall(c, target.endpoint, target.stations)
+			took := time.Since(start)
+			if errors.As(err, &quota) {
+				logWarn("Stopping the bench early.", err)
+				round = rounds
+				break
+			}
+			if err != nil {
+				logDebug("Call to", target.endpoint.URL, "failed.", err)
+			}
+			bad := err != nil
+			for _, station := range target.stations {
+				bad = bad || failed[stationHandle(station)]
+			}
+			target.stats.tally(took, err == nil, bad)
+
+			// The endpoint adds up its stations' calls, or the stations are tallied from
+			// the endpoint's
+			if each {
+				endpointStats[target.index].tally(took, err == nil, bad)
+				continue
+			}
+			for j, station := range target.stations {
+				stationStats[target.index][j].tally(0, false, err != nil || failed[stationHandle(station)])
+			}
+		}
+	}
+
+	fmt.Printf("%-44s %5s %5s %5s %8s %8s %8s %8s\n", "endpoint / station", "calls", "fails", "rate", "p50", "p90", "p99", "max")
+	for i := range endpoints {
+		endpointStats[i].print("")
+		for _, stats := range stationStats[i] {
+			stats.print("  ")
+		}
+	}
+	return nil
+}
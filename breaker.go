@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// circuitBreaker keeps a dead API from being hammered at the full polling rate. After
+// threshold failed polls in a row it opens, and the server only probes the API now and
+// then, backing off from the polling interval up to maxBackoff. One good poll closes it.
+type circuitBreaker struct {
+	threshold  int
+	maxBackoff time.Duration
+	failures   int
+	lastError  string
+	nextPoll   time.Time
+}
+
+// pollStatus is what /api/status says about the API
+type pollStatus struct {
+	State     string    `json:"state"`
+	Polled    time.Time `json:"polled"`
+	Failures  int       `json:"failures"`
+	LastError string    `json:"last_error,omitempty"`
+	NextPoll  time.Time `json:"next_poll"`
+	// Stations the last good poll could not get
+	Failed []stationError `json:"failed_stations,omitempty"`
+}
+
+// open says whether the breaker has tripped
+func (cb *circuitBreaker) open() bool {
+	return cb.threshold > 0 && cb.failures >= cb.threshold
+}
+
+// wait is how long until the next poll. Once open, it doubles with each failed probe.
+func (cb *circuitBreaker) wait(interval time.Duration) time.Duration {
+	if !cb.open() {
+		return interval
+	}
+	wait := interval * 2
+	for i := cb.threshold; i < cb.failures && wait < cb.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > cb.maxBackoff {
+		wait = cb.maxBackoff
+	}
+	return wait
+}
+
+// record notes how a poll went, and logs when the breaker opens or closes. Failures
+// are only logged until it opens; after that, each probe gets a line.
+func (cb *circuitBreaker) record(err error, interval time.Duration, now time.Time) {
+	if err == nil {
+		if cb.open() {
+			logInfo(fmt.Sprintf("API is back after %d failed polls, polling every %v again", cb.failures, interval))
+		}
+		cb.failures, cb.lastError = 0, ""
+		cb.nextPoll = now.Add(interval)
+		return
+	}
+	wasOpen := cb.open()
+	cb.failures++
+	cb.lastError = redact(err.Error())
+	wait := cb.wait(interval)
+	cb.nextPoll = now.Add(wait)
+	switch {
+	case cb.open() && !wasOpen:
+		logWarn(fmt.Sprintf("API failed %d polls in a row, serving old data and probing again in %v.", cb.failures, wait), err)
+	case wasOpen:
+		logWarn(fmt.Sprintf("API probe failed, next in %v.", wait), err)
+	default:
+		logWarn("Poll failed.", err)
+	}
+}
+
+// status describes the API as the server sees it, with only the failed stations the
+// token may see
+func (ws *weatherServer) status(token *accessToken) pollStatus {
+	status := pollStatus{
+		State:     "ok",
+		Polled:    ws.polled,
+		Failures:  ws.breaker.failures,
+		LastError: ws.breaker.lastError,
+		NextPoll:  ws.breaker.nextPoll,
+	}
+	for _, failed := range ws.failed {
+		if token == nil || token.allowsStation(failed.Station) {
+			status.Failed = append(status.Failed, failed)
+		}
+	}
+	if ws.breaker.open() {
+		status.State = "degraded"
+	}
+	return status
+}
+
+// handleStatus serves /api/status, whether the data is fresh or the API is down
+func (ws *weatherServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	token, ok := ws.authorize(w, r)
+	if !ok {
+		return
+	}
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	writeJSON(w, ws.status(token))
+}
+
+// markDegraded adds a Warning header to every answer while the breaker is open, so
+// clients can tell the data is not being refreshed
+func (ws *weatherServer) markDegraded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.mutex.RLock()
+		degraded := ws.breaker.open()
+		ws.mutex.RUnlock()
+		if degraded {
+			w.Header().Set("Warning", `110 weatherstem "Response is Stale"`)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pollForever polls the API on the interval, or less often while the breaker is open,
+// until stopped
+func (ws *weatherServer) pollForever() {
+	for {
+		ws.mutex.RLock()
+		wait := ws.breaker.wait(ws.interval)
+		ws.mutex.RUnlock()
+		select {
+		case <-time.After(wait):
+		case <-ws.stop:
+			return
+		}
+		ws.poll()
+	}
+}
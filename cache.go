@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// cachedResponse is the last good API response, kept so a failed call can fall back on
+// it. It only counts for the same API and the same stations it was asked about.
+type cachedResponse struct {
+	Fetched  time.Time       `json:"fetched"`
+	URL      string          `json:"api_url"`
+	Stations []string        `json:"stations"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// cacheFile is where the response is kept, "cache" in the config or the user's cache
+// directory, ala ~/.cache/weatherstem/api.json
+func cacheFile(c *configSettings) string {
+	if c.Cache != "" {
+		return expandHome(c.Cache)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "weatherstem", "api.json")
+}
+
+// readCachedResponse gets the cached response, if there is one for this config
+func readCachedResponse(c *configSettings) (cached cachedResponse, ok bool) {
+	filename := cacheFile(c)
+	if filename == "" {
+		return cached, false
+	}
+	cacheJSON, err := ioutil.ReadFile(filename)
+	if err != nil || json.Unmarshal(cacheJSON, &cached) != nil {
+		return cached, false
+	}
+	if cached.URL != redact(c.URL) || strings.Join(cached.Stations, ",") != strings.Join(c.Stations, ",") {
+		return cached, false
+	}
+	return cached, len(cached.Body) > 0
+}
+
+// writeCachedResponse keeps a good response
+func writeCachedResponse(c *configSettings, body []byte, fetched time.Time) (err error) {
+	filename := cacheFile(c)
+	if filename == "" {
+		return nil
+	}
+	cacheJSON, err := json.Marshal(cachedResponse{Fetched: fetched, URL: redact(c.URL), Stations: c.Stations, Body: redactPayload(body)})
+	if err != nil {
+		return err
+	}
+	return replaceFile(filename, cacheJSON)
+}
+
+// replaceFile writes the file by way of a temporary one, so a reader never sees half of it.
+// The directory is made if need be.
+func replaceFile(filename string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filename)
+}
+
+// fetchWeatherInfo gets the API results by way of the cache. A cached response younger
+// than ttl is used without calling the API at all. When the call fails, or answers with
+// something that is not JSON, the stale cached response is used however old it is.
+// With noCache the cache is neither read nor written.
+func fetchWeatherInfo(c *configSettings, ttl time.Duration, noCache bool) (weatherBytes []byte, fetched time.Time, stale bool, err error) {
+	now := time.Now()
+	if noCache {
+		weatherBytes, err = getWeatherInfoFromWeb(c)
+		return weatherBytes, now, false, err
+	}
+
+	cached, haveCache := readCachedResponse(c)
+	if haveCache && ttl > 0 && now.Sub(cached.Fetched) < ttl {
+		return cached.Body, cached.Fetched, false, nil
+	}
+
+	weatherBytes, err = getWeatherInfoFromWeb(c)
+	if err == nil && json.Valid(weatherBytes) {
+		if cacheErr := writeCachedResponse(c, weatherBytes, now); cacheErr != nil {
+			logWarn("Cannot cache API results.", cacheErr)
+		}
+		return weatherBytes, now, false, nil
+	}
+	// A refused key should be heard, not papered over
+	if !haveCache || errors.Is(err, errAPIAuth) {
+		return weatherBytes, now, false, err
+	}
+
+	if err != nil {
+		logWarn("Call to API failed.", err)
+	} else {
+		logWarn("API results are not JSON.")
+	}
+	logWarn("Using cached results from", now.Sub(cached.Fetched).Round(time.Second), "ago.")
+	return cached.Body, cached.Fetched, true, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Rising air cools about 125 m for each °C of spread before it condenses, Espy's rule
+const cloudBasePerDegree = 125.0
+
+// CloudBase is the estimated base of cumulus clouds above the station, the lifting
+// condensation level, in both feet and meters since pilots use either
+type CloudBase struct {
+	Feet   float64 `json:"ft"`
+	Meters float64 `json:"m"`
+}
+
+// String is the cloud base to the nearest hundred feet and ten meters, ala "4500ft (1370m) AGL"
+func (base *CloudBase) String() string {
+	return fmt.Sprintf("%.0fft (%.0fm) AGL", base.Feet, base.Meters)
+}
+
+// EstimateCloudBase works out the lifting condensation level from the spread between the
+// temperature and dewpoint. Saturated air, ala fog, has its base on the ground.
+func EstimateCloudBase(data *WeatherData, units *WeatherUnits) (base CloudBase) {
+	spread := toCelsius(data.Temperature[0], units.Temperature[0]) - toCelsius(data.Temperature[1], units.Temperature[1])
+	meters := cloudBasePerDegree * math.Max(0, spread)
+	base.Meters = math.Round(meters/10) * 10
+	base.Feet = math.Round(meters/0.3048/100) * 100
+	return base
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// Wind chills (°F) for the NWS advisory and warning, unless the config says otherwise
+	coldDefaultAdvisory = -15.0
+	coldDefaultWarning  = -25.0
+	// Frostbite further off than this (minutes) is no worry for anyone dressed for outside
+	frostbiteHorizon = 60
+)
+
+// coldLevels are the wind chills (°F) where the cold gets dangerous, like the WBGT
+// flags the other way round. Offices in the north set them lower, ala -25 and -35.
+type coldLevels struct {
+	Advisory float64 `json:"advisory"`
+	Warning  float64 `json:"warning"`
+}
+
+// coldLevels are the config's, with the NWS defaults for any it leaves out
+func (config *configSettings) coldLevels() coldLevels {
+	levels := config.ColdLevels
+	if levels.Advisory == 0 {
+		levels.Advisory = coldDefaultAdvisory
+	}
+	if levels.Warning == 0 {
+		levels.Warning = coldDefaultWarning
+	}
+	return levels
+}
+
+// ColdStress is the wind chill at a station, in its temperature unit, the level it has
+// reached and how soon exposed skin freezes. Frostbite is zero when it is more than
+// frostbiteHorizon minutes off.
+type ColdStress struct {
+	WindChill float64 `json:"windchill"`
+	Level     string  `json:"level"`
+	Frostbite int     `json:"frostbite_minutes,omitempty"`
+}
+
+// String is the level and the frostbite time, ala "Cold Weather Advisory, frostbite in 25 min"
+func (cold *ColdStress) String() string {
+	name := map[string]string{"none": "No cold advisory", "advisory": "Cold Weather Advisory", "warning": "Extreme Cold Warning"}[cold.Level]
+	if cold.Frostbite == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s, frostbite in %d min", name, cold.Frostbite)
+}
+
+// frostbiteMinutes is how long until exposed skin freezes for the most susceptible, the
+// Tikuisis and Osczevski model Environment Canada uses. Air above -4.8°C never gets there.
+func frostbiteMinutes(celsius, kmh float64) (minutes float64, ok bool) {
+	if celsius >= -4.8 {
+		return 0, false
+	}
+	return (-24.5*(0.667*kmh+4.8) + 2111) * math.Pow(-4.8-celsius, -1.668), true
+}
+
+// AssessCold works out the cold stress for a station from its wind chill and wind
+func AssessCold(config *configSettings, data *WeatherData, units *WeatherUnits) (cold ColdStress) {
+	levels := config.coldLevels()
+	windchill, _ := convertUnit(data.Temperature[3], units.Temperature[3], "°F")
+	cold.WindChill = data.Temperature[3]
+	switch {
+	case windchill <= levels.Warning:
+		cold.Level = "warning"
+	case windchill <= levels.Advisory:
+		cold.Level = "advisory"
+	default:
+		cold.Level = "none"
+	}
+	kmh, _ := convertUnit(data.Windspeed[0], units.Windspeed[0], "km/h")
+	if minutes, ok := frostbiteMinutes(toCelsius(data.Temperature[0], units.Temperature[0]), kmh); ok && minutes <= frostbiteHorizon {
+		cold.Frostbite = int(math.Max(2, math.Round(minutes)))
+	}
+	return cold
+}
+
+// coldAlert turns a cold advisory or warning into an alert event
+func coldAlert(data *WeatherData, units *WeatherUnits) (event alertEvent, raised bool) {
+	if data.Cold == nil || data.Cold.Level == "none" {
+		return event, false
+	}
+	return alertEvent{
+		Station: data.Station[1],
+		Kind:    "cold",
+		Message: fmt.Sprintf("%s, wind chill %.0f%s", data.Cold, data.Cold.WindChill, units.Temperature[3]),
+	}, true
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"strings"
+)
+
+// StationComparison lines two stations up side by side, B minus A, ala the sea breeze
+// gradient between an inland and a coastal station. B is in A's units.
+type StationComparison struct {
+	Stations [2]string       `json:"stations"`
+	Rows     []comparisonRow `json:"rows"`
+}
+
+type comparisonRow struct {
+	Name  string     `json:"name"`
+	Unit  string     `json:"unit"`
+	Value [2]float64 `json:"value"`
+	Delta float64    `json:"delta"`
+}
+
+// findStation looks a station up by handle or name, ignoring case and any @domain
+func findStation(dataArr []WeatherData, station string) int {
+	station = strings.ToLower(stationHandle(strings.TrimSpace(station)))
+	for i := range dataArr {
+		if strings.ToLower(dataArr[i].Station[0]) == station || strings.ToLower(dataArr[i].Station[1]) == station {
+			return i
+		}
+	}
+	return -1
+}
+
+// CompareStations works out the differences between two stations, field by field
+func CompareStations(dataA, dataB *WeatherData, unitsA, unitsB *WeatherUnits) (comparison StationComparison) {
+	// Work on copies, since harmonizing converts in place
+	data := []WeatherData{*dataA, *dataB}
+	units := []WeatherUnits{*unitsA, *unitsB}
+	harmonizeUnits(data, units)
+	a, b, u := &data[0], &data[1], &units[0]
+
+	comparison.Stations = [2]string{a.Station[1], b.Station[1]}
+	add := func(name, unit string, valueA, valueB float64) {
+		comparison.Rows = append(comparison.Rows, comparisonRow{name, html.UnescapeString(unit), [2]float64{valueA, valueB}, math.Round((valueB-valueA)*1e4) / 1e4})
+	}
+	add("Temperature", u.Temperature[0], a.Temperature[0], b.Temperature[0])
+	add("Dewpoint", u.Temperature[1], a.Temperature[1], b.Temperature[1])
+	add("Wet bulb", u.Temperature[2], a.Temperature[2], b.Temperature[2])
+	add("Wind chill", u.Temperature[3], a.Temperature[3], b.Temperature[3])
+	add("Heat index", u.Temperature[4], a.Temperature[4], b.Temperature[4])
+	add("Humidity", "%", a.Humidity, b.Humidity)
+	add("Wind", u.Windspeed[0], a.Windspeed[0], b.Windspeed[0])
+	add("Gust", u.Windspeed[1], a.Windspeed[1], b.Windspeed[1])
+	add("Direction", "°", a.Windspeed[2], b.Windspeed[2])
+	// The short way round the compass
+	direction := &comparison.Rows[len(comparison.Rows)-1]
+	direction.Delta = math.Mod(direction.Delta+540, 360) - 180
+	add("Pressure", u.Pressure, a.Pressure, b.Pressure)
+	add("Rain", u.Rain[0], a.Rain[0], b.Rain[0])
+	add("Rain rate", u.Rain[1], a.Rain[1], b.Rain[1])
+	add("Solar", u.Sun[0], a.Sun[0], b.Sun[0])
+	add("UV", u.Sun[1], a.Sun[1], b.Sun[1])
+	add("Distance", u.StationDist, a.StationDist, b.StationDist)
+	return comparison
+}
+
+// PrintStationComparison shows the two stations in columns. Differences stand out in
+// bold on a terminal.
+func (comparison *StationComparison) PrintStationComparison() {
+	bold, plain := "", ""
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		bold, plain = "\033[1m", "\033[0m"
+	}
+	fmt.Printf("%-12s %14s %14s %10s\n", "", comparison.Stations[0], comparison.Stations[1], "Δ")
+	for _, row := range comparison.Rows {
+		delta := fmt.Sprintf("%+10.2f", row.Delta)
+		if math.Abs(row.Delta) >= 0.005 {
+			delta = bold + delta + plain
+		}
+		fmt.Printf("%-12s %14.2f %14.2f %s %s\n", row.Name, row.Value[0], row.Value[1], delta, row.Unit)
+	}
+}
+
+// PrintStationComparisonJSON shows the comparison as one line of JSON
+func (comparison *StationComparison) PrintStationComparisonJSON() {
+	jdata, err := marshalJSON(comparison)
+	if err != nil {
+		logError("Cannot marshal station comparison", err)
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/loraxipam/compassrose"
+	haversine "github.com/loraxipam/havers2"
+)
+
+// compassSettings say how wind directions are named. Points is how fine the rose is,
+// 4 to 32. Style is "mariner" for the old Mediterranean winds, ala "Levante", "full" for
+// "East Northeast" or "abbrev" for "ENE". Language translates the full and abbreviated
+// names; the mariners only ever spoke Italian.
+type compassSettings struct {
+	Points   int    `json:"points,omitempty"`
+	Style    string `json:"style,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// compass is how this run names directions, see settleCompass
+var compass = compassSettings{Points: 16, Style: "mariner", Language: "en"}
+
+// compassLevels are the compassrose levels for each number of points
+var compassLevels = map[int]int{
+	2:  compassrose.TwoPoints,
+	4:  compassrose.FourPoints,
+	8:  compassrose.EightPoints,
+	16: compassrose.SixteenPoints,
+	32: compassrose.ThirtyTwoPoints,
+}
+
+// compassStyles are the ways to name a direction
+var compassStyles = []string{"mariner", "full", "abbrev"}
+
+// compassLanguage is how one language names the 16 points. Letters swaps the English
+// initials in the abbreviations, ala W for O(este), and By joins the 32 point names,
+// ala "Norte cuarta al Este".
+type compassLanguage struct {
+	Names   [16]string
+	Letters *strings.Replacer
+	By      string
+}
+
+// compassLanguages are the translations, English being what compassrose speaks itself
+var compassLanguages = map[string]*compassLanguage{
+	"en": nil,
+	"es": {
+		[16]string{"Norte", "Nornoreste", "Noreste", "Estenoreste", "Este", "Estesureste", "Sureste", "Sursureste",
+			"Sur", "Sursuroeste", "Suroeste", "Oestesuroeste", "Oeste", "Oestenoroeste", "Noroeste", "Nornoroeste"},
+		strings.NewReplacer("W", "O"), "cuarta al",
+	},
+	"fr": {
+		[16]string{"Nord", "Nord-Nord-Est", "Nord-Est", "Est-Nord-Est", "Est", "Est-Sud-Est", "Sud-Est", "Sud-Sud-Est",
+			"Sud", "Sud-Sud-Ouest", "Sud-Ouest", "Ouest-Sud-Ouest", "Ouest", "Ouest-Nord-Ouest", "Nord-Ouest", "Nord-Nord-Ouest"},
+		strings.NewReplacer("W", "O"), "quart",
+	},
+	"de": {
+		[16]string{"Nord", "Nordnordost", "Nordost", "Ostnordost", "Ost", "Ostsüdost", "Südost", "Südsüdost",
+			"Süd", "Südsüdwest", "Südwest", "Westsüdwest", "West", "Westnordwest", "Nordwest", "Nordnordwest"},
+		strings.NewReplacer("E", "O"), "zu",
+	},
+	"it": {
+		[16]string{"Nord", "Nord-Nord-Est", "Nord-Est", "Est-Nord-Est", "Est", "Est-Sud-Est", "Sud-Est", "Sud-Sud-Est",
+			"Sud", "Sud-Sud-Ovest", "Sud-Ovest", "Ovest-Sud-Ovest", "Ovest", "Ovest-Nord-Ovest", "Nord-Ovest", "Nord-Nord-Ovest"},
+		strings.NewReplacer("W", "O"), "quarta a",
+	},
+	"pt": {
+		[16]string{"Norte", "Nor-nordeste", "Nordeste", "Lés-nordeste", "Leste", "Lés-sudeste", "Sudeste", "Su-sudeste",
+			"Sul", "Su-sudoeste", "Sudoeste", "Oés-sudoeste", "Oeste", "Oés-noroeste", "Noroeste", "Nor-noroeste"},
+		strings.NewReplacer("E", "L", "W", "O"), "quarta a",
+	},
+}
+
+// settleCompass works out this run's compass from the config, then the flags on top.
+// The -rose flag is shorthand for the full style.
+func settleCompass(config compassSettings, points int, style, language string, rose bool) error {
+	if rose {
+		compass.Style = "full"
+	}
+	for _, settings := range []compassSettings{config, {points, style, language}} {
+		if settings.Points != 0 {
+			compass.Points = settings.Points
+		}
+		if settings.Style != "" {
+			compass.Style = settings.Style
+		}
+		if settings.Language != "" {
+			compass.Language = settings.Language
+		}
+	}
+	if _, ok := compassLevels[compass.Points]; !ok {
+		return fmt.Errorf("rose precision is 2, 4, 8, 16 or 32 points, not %d", compass.Points)
+	}
+	if !contains(compassStyles, compass.Style) {
+		return fmt.Errorf("rose style is %s, not %q", strings.Join(compassStyles, ", "), compass.Style)
+	}
+	if _, ok := compassLanguages[compass.Language]; !ok {
+		return fmt.Errorf("rose language is one of %v, not %q", compassLanguageNames(), compass.Language)
+	}
+	return nil
+}
+
+// contains says whether the list has the string
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// compassLanguageNames lists the languages in order
+func compassLanguageNames() (names []string) {
+	for name := range compassLanguages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// heading names a direction the way this run's compass says, ala "ENE", "Greco Levante".
+// The rose indexes by the degrees, so a wild vane reading is taken round to it first, and
+// one that is no number at all has no heading.
+func heading(degrees float64) (short, name string) {
+	degrees = math.Mod(degrees, 360)
+	if math.IsNaN(degrees) {
+		return "", ""
+	}
+	short, name = compassrose.DegreeToHeading(float32(degrees), compassLevels[compass.Points], compass.Style != "mariner")
+	if compass.Style == "mariner" {
+		return short, name
+	}
+	language := compassLanguages[compass.Language]
+	if language != nil {
+		name = language.name(short)
+		short = language.Letters.Replace(short)
+	}
+	if compass.Style == "abbrev" {
+		name = short
+	}
+	return short, name
+}
+
+// name translates an English abbreviation, ala "NbE", to a full name
+func (language *compassLanguage) name(short string) string {
+	index := func(abbreviation string) int {
+		for i := range language.Names {
+			if compassrose.CompassRose[i*2][0] == abbreviation {
+				return i
+			}
+		}
+		return 0
+	}
+	if parts := strings.SplitN(short, "b", 2); len(parts) == 2 {
+		return language.Names[index(parts[0])] + " " + language.By + " " + language.Names[index(parts[1])]
+	}
+	return language.Names[index(short)]
+}
+
+// initialBearing is the compass bearing, whole degrees true, to set out on from one spot
+// to get to another along the great circle
+func initialBearing(from, to haversine.Coord) float64 {
+	lat1, lat2 := from.Lat*math.Pi/180, to.Lat*math.Pi/180
+	dLon := (to.Lon - from.Lon) * math.Pi / 180
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return math.Mod(math.Round(math.Atan2(y, x)*180/math.Pi)+360, 360)
+}
+
+// bearingHeading names the way to a station from me, ala "NE", or nothing for a station
+// right here
+func (data *WeatherData) bearingHeading() string {
+	if data.StationDist == 0 {
+		return ""
+	}
+	short, _ := heading(data.StationBearing)
+	return short
+}
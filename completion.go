@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+func init() {
+	// Registered here, since it lists the subcommands itself
+	subcommands["completion"] = completionCommand
+}
+
+// completionFlag is a command line flag as the completion scripts see it
+type completionFlag struct {
+	Name       string
+	Usage      string
+	TakesValue bool
+	// The values it takes, if there are only a few
+	Values []string
+	// Whether it takes a file name
+	File bool
+}
+
+// completionValues are the flags with only a few possible values
+var completionValues = map[string][]string{
+	"json-units":       {"separate", "inline"},
+	"log-format":       {"text", "json"},
+	"statusbar-policy": {"nearest-fresh", "always-nearest"},
+}
+
+// completionFiles are the flags which take a file name
+var completionFiles = map[string]bool{
+	"exec":     true,
+	"log-file": true,
+	"rss":      true,
+}
+
+// completionStations are the flags, the subcommands' too, which take station handles.
+// Those come from the config at completion time.
+var completionStations = []string{"compare", "station"}
+
+// completionData is what the script templates are filled in with
+type completionData struct {
+	Flags        []completionFlag
+	StationFlags []string
+	Subcommands  []string
+}
+
+// gatherCompletions lists the visible flags and the subcommands
+func gatherCompletions() (data completionData) {
+	flag.VisitAll(func(f *flag.Flag) {
+		if hiddenFlags[f.Name] {
+			return
+		}
+		boolFlag, isBool := f.Value.(interface{ IsBoolFlag() bool })
+		data.Flags = append(data.Flags, completionFlag{
+			Name:       f.Name,
+			Usage:      f.Usage,
+			TakesValue: !isBool || !boolFlag.IsBoolFlag(),
+			Values:     completionValues[f.Name],
+			File:       completionFiles[f.Name],
+		})
+	})
+	data.StationFlags = completionStations
+	for name := range subcommands {
+		data.Subcommands = append(data.Subcommands, name)
+	}
+	sort.Strings(data.Subcommands)
+	return data
+}
+
+var completionFuncs = template.FuncMap{
+	"join": strings.Join,
+	// quote for the single quoted strings of every shell here
+	"quote":   func(s string) string { return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'" },
+	"psquote": func(s string) string { return "'" + strings.Replace(s, "'", "''", -1) + "'" },
+}
+
+// The scripts ask 'weatherstem completion stations' for the station handles each time, so
+// they keep up with the config without being generated again
+var completionTemplates = map[string]string{
+	"bash": `# bash completion for weatherstem, ala: source <(weatherstem completion bash)
+_weatherstem() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    case "$prev" in
+{{- range .StationFlags}}
+        -{{.}}) COMPREPLY=( $(compgen -W "$(weatherstem completion stations 2>/dev/null)" -- "$cur") ); return ;;
+{{- end}}
+{{- range .Flags}}{{if .Values}}
+        -{{.Name}}) COMPREPLY=( $(compgen -W "{{join .Values " "}}" -- "$cur") ); return ;;
+{{- else if .File}}
+        -{{.Name}}) COMPREPLY=( $(compgen -f -- "$cur") ); return ;;
+{{- else if .TakesValue}}
+        -{{.Name}}) return ;;
+{{- end}}{{end}}
+    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=( $(compgen -W "{{range .Flags}}-{{.Name}} {{end}}" -- "$cur") )
+    else
+        COMPREPLY=( $(compgen -W "{{join .Subcommands " "}}" -- "$cur") )
+    fi
+}
+complete -F _weatherstem weatherstem
+`,
+	"zsh": `#compdef weatherstem
+# zsh completion for weatherstem, ala: source <(weatherstem completion zsh)
+_weatherstem() {
+    case "${words[CURRENT-1]}" in
+{{- range .StationFlags}}
+        -{{.}}) compadd -- ${(f)"$(weatherstem completion stations 2>/dev/null)"}; return ;;
+{{- end}}
+{{- range .Flags}}{{if .Values}}
+        -{{.Name}}) compadd -- {{join .Values " "}}; return ;;
+{{- else if .File}}
+        -{{.Name}}) _files; return ;;
+{{- else if .TakesValue}}
+        -{{.Name}}) return ;;
+{{- end}}{{end}}
+    esac
+    if [[ "$PREFIX" == -* ]]; then
+        local -a flags
+        flags=({{range .Flags}}
+            {{quote (print "-" .Name ":" .Usage)}}{{end}}
+        )
+        _describe -o flag flags
+    else
+        compadd -- {{join .Subcommands " "}}
+    fi
+}
+compdef _weatherstem weatherstem
+`,
+	"fish": `# fish completion for weatherstem, ala: weatherstem completion fish | source
+complete -c weatherstem -f
+complete -c weatherstem -n __fish_use_subcommand -a {{quote (join .Subcommands " ")}}
+{{- range .Flags}}
+complete -c weatherstem -o {{.Name}} -d {{quote .Usage}}
+{{- if .Values}} -x -a {{quote (join .Values " ")}}
+{{- else if .File}} -r -F
+{{- else if .TakesValue}} -x
+{{- end}}{{end}}
+{{- range .StationFlags}}
+complete -c weatherstem -o {{.}} -x -a '(weatherstem completion stations 2>/dev/null)'
+{{- end}}
+`,
+	"powershell": `# PowerShell completion for weatherstem, ala: weatherstem completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName weatherstem -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    $prev = if ($wordToComplete) { $words[-2] } else { $words[-1] }
+    $candidates = switch ($prev) {
+{{- range .StationFlags}}
+        '-{{.}}' { @(weatherstem completion stations 2>$null) }
+{{- end}}
+{{- range .Flags}}{{if .Values}}
+        '-{{.Name}}' { @({{range $i, $v := .Values}}{{if $i}}, {{end}}{{psquote $v}}{{end}}) }
+{{- end}}{{end}}
+        default {
+            if ($wordToComplete -like '-*') {
+                @({{range $i, $f := .Flags}}{{if $i}}, {{end}}'-{{$f.Name}}'{{end}})
+            } else {
+                @({{range $i, $s := .Subcommands}}{{if $i}}, {{end}}{{psquote $s}}{{end}})
+            }
+        }
+    }
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+}
+
+// completionCommand writes a completion script for a shell, ala 'weatherstem completion bash',
+// or, for those scripts, the configured station handles with 'weatherstem completion stations'
+func completionCommand(config *configSettings, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("Usage: weatherstem completion bash|zsh|fish|powershell")
+	}
+	if args[0] == "stations" {
+		for _, station := range config.Stations {
+			fmt.Println(stationHandle(station))
+		}
+		return nil
+	}
+	script, ok := completionTemplates[args[0]]
+	if !ok {
+		return fmt.Errorf("No completion for %s, only bash, zsh, fish and powershell", args[0])
+	}
+	return template.Must(template.New(args[0]).Funcs(completionFuncs).Parse(script)).Execute(os.Stdout, gatherCompletions())
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	json "github.com/json-iterator/go"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// The API keys can be kept encrypted in the config, for those who keep their dotfiles in
+// a public repo, ala "api_key": "enc:v1:3q2+7w...". 'weatherstem config encrypt' does it
+// and 'weatherstem config decrypt' undoes it. The key is AES-256-GCM sealed with one made
+// from a passphrase by scrypt. The passphrase comes from $WEATHERSTEM_PASSPHRASE, else the
+// OS keyring, else a prompt, so cron and serve want one of the first two.
+
+func init() {
+	// Registered for the usage and completions; main runs it before loading the config
+	subcommands["config"] = configCommand
+}
+
+const (
+	// encryptedPrefix marks an encrypted key, the version being the scrypt cost and cipher
+	encryptedPrefix = "enc:v1:"
+	// passphraseVariable is where a passphrase is found first
+	passphraseVariable = "WEATHERSTEM_PASSPHRASE"
+	// keyringService is what the passphrase is kept under in the OS keyring
+	keyringService = "weatherstem-cli"
+	saltSize       = 16
+)
+
+// passphrase is asked for once a run, however many keys there are
+var passphrase string
+
+// isEncrypted says whether a key in the config is encrypted
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// sealingKey is the AES key the passphrase and salt make
+func sealingKey(secret string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(secret), salt, 1<<15, 8, 1, 32)
+}
+
+// encryptValue seals a key, ala enc:v1:<base64 of salt, nonce and sealed key>
+func encryptValue(value, secret string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := sealingKey(secret, salt)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(append(salt, nonce...), nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue opens a sealed key. The wrong passphrase and a mangled value look the same.
+func decryptValue(value, secret string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < saltSize {
+		return "", errors.New("the encrypted api_key is mangled")
+	}
+	key, err := sealingKey(secret, sealed[:saltSize])
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	sealed = sealed[saltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("the encrypted api_key is mangled")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("Cannot decrypt the api_key, wrong passphrase?")
+	}
+	return string(plain), nil
+}
+
+// keyringCommand is how this OS keeps a secret, by way of its own tool: secret-tool on
+// Linux and the BSDs, security on macOS
+func keyringCommand(store bool) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if store {
+			// security takes the secret as -w's value, added by storeKeyringPassphrase
+			return exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", keyringService, "-w"), nil
+		}
+		return exec.Command("security", "find-generic-password", "-s", keyringService, "-a", keyringService, "-w"), nil
+	case "windows", "plan9":
+		return nil, fmt.Errorf("No keyring on %s, use $%s", runtime.GOOS, passphraseVariable)
+	default:
+		if store {
+			return exec.Command("secret-tool", "store", "--label=weatherstem-cli config passphrase", "service", keyringService), nil
+		}
+		return exec.Command("secret-tool", "lookup", "service", keyringService), nil
+	}
+}
+
+// keyringPassphrase gets the passphrase from the OS keyring, if it is kept there
+func keyringPassphrase() (string, bool) {
+	cmd, err := keyringCommand(false)
+	if err != nil {
+		return "", false
+	}
+	out, err := cmd.Output()
+	secret := strings.TrimRight(string(out), "\r\n")
+	return secret, err == nil && secret != ""
+}
+
+// storeKeyringPassphrase keeps the passphrase in the OS keyring
+func storeKeyringPassphrase(secret string) error {
+	cmd, err := keyringCommand(true)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "darwin" {
+		cmd.Args = append(cmd.Args, secret)
+	} else {
+		cmd.Stdin = strings.NewReader(secret)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Cannot keep the passphrase in the keyring: %v %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// promptPassphrase asks for the passphrase on the terminal, twice when it is a new one
+func promptPassphrase(confirm bool) (string, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("The api_key is encrypted: set $%s, keep the passphrase in the keyring with 'weatherstem config encrypt -keyring', or run from a terminal", passphraseVariable)
+	}
+	fmt.Fprint(os.Stderr, tr("Config passphrase: "))
+	secret, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if len(secret) == 0 {
+		return "", errors.New("No passphrase given")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, tr("Again: "))
+		again, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(secret, again) {
+			return "", errors.New("The passphrases do not match")
+		}
+	}
+	return string(secret), nil
+}
+
+// getPassphrase finds the passphrase: the environment, the keyring or the terminal
+func getPassphrase(confirm bool) (secret string, err error) {
+	if passphrase != "" {
+		return passphrase, nil
+	}
+	secret, found := os.Getenv(passphraseVariable), false
+	if secret == "" {
+		secret, found = keyringPassphrase()
+		if found {
+			logDebug("Using the config passphrase from the keyring")
+		}
+	}
+	if secret == "" {
+		if secret, err = promptPassphrase(confirm); err != nil {
+			return "", err
+		}
+	}
+	passphrase = secret
+	addSecrets(passphrase)
+	return passphrase, nil
+}
+
+// decryptKeys opens the config's encrypted keys as it loads, asking for the passphrase
+// only if there is one to open
+func (config *configSettings) decryptKeys() (err error) {
+	keys := []*string{&config.Key}
+	for i := range config.Endpoints {
+		keys = append(keys, &config.Endpoints[i].Key)
+	}
+	for _, key := range keys {
+		if !isEncrypted(*key) {
+			continue
+		}
+		secret, err := getPassphrase(false)
+		if err != nil {
+			return err
+		}
+		if *key, err = decryptValue(*key, secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plainJSON quotes a string the way it is most likely written by hand, without escaping
+// &, < and >
+var plainJSON = json.Config{}.Froze()
+
+// rekeyConfigFile rewrites the api_key values in the config file with change, leaving the
+// rest of it as it was written. Only the keys' strings are touched. A config kept as a
+// symlink, ala into a dotfiles repo, is rewritten where it points, leaving the link be.
+func rekeyConfigFile(filename string, change func(value string) (string, bool, error)) (changed int, err error) {
+	if filename, err = filepath.EvalSymlinks(filename); err != nil {
+		return 0, err
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	var keys struct {
+		Key       string `json:"api_key"`
+		Endpoints []struct {
+			Key string `json:"api_key"`
+		} `json:"endpoints"`
+	}
+	if err = json.Unmarshal(content, &keys); err != nil {
+		return 0, fmt.Errorf("Cannot unmarshal config %s: %w", filename, err)
+	}
+	values := []string{keys.Key}
+	for _, endpoint := range keys.Endpoints {
+		values = append(values, endpoint.Key)
+	}
+	done := make(map[string]bool)
+	for _, value := range values {
+		if value == "" || done[value] {
+			continue
+		}
+		done[value] = true
+		replacement, ok, err := change(value)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		// The key is found however its string was escaped, and changed only if found
+		var quoted []byte
+		for _, marshal := range []func(interface{}) ([]byte, error){plainJSON.Marshal, json.Marshal} {
+			if written, _ := marshal(value); bytes.Contains(content, written) {
+				quoted = written
+				break
+			}
+		}
+		if quoted == nil {
+			return 0, fmt.Errorf("Cannot find an api_key's string as written in %s, change it by hand", filename)
+		}
+		requoted, _ := plainJSON.Marshal(replacement)
+		content = bytes.ReplaceAll(content, quoted, requoted)
+		changed++
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, replaceFile(filename, content)
+}
+
+// configFile is the config file to work on: -file, or the one a run would use
+func configFile(given string) (string, error) {
+	if given != "" {
+		return expandHome(given), nil
+	}
+	for _, filename := range configFiles() {
+		if _, err := os.Stat(filename); err == nil {
+			return filename, nil
+		}
+	}
+	return "", fmt.Errorf("No config file in %s", strings.Join(configFiles(), ", "))
+}
+
+// configCommand encrypts or decrypts the api_key values in the config file, ala
+// 'weatherstem config encrypt' before committing your dotfiles. With -keyring, encrypt
+// makes up a passphrase and keeps it in the OS keyring, so nothing need be typed.
+func configCommand(_ *configSettings, args []string) (err error) {
+	var (
+		given   string
+		keyring bool
+	)
+	flags := flag.NewFlagSet("config", flag.ExitOnError)
+	flags.StringVar(&given, "file", "", "Config file to change, the one a run would use if not given")
+	flags.BoolVar(&keyring, "keyring", false, "Make up a passphrase and keep it in the OS keyring, for encrypt")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: weatherstem config [-file weatherstem.json] [-keyring] encrypt|decrypt")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || (flags.Arg(0) != "encrypt" && flags.Arg(0) != "decrypt") {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	filename, err := configFile(given)
+	if err != nil {
+		return err
+	}
+	var changed int
+	if flags.Arg(0) == "encrypt" {
+		// A made up passphrase only goes in the keyring once the keys are sealed with it,
+		// and never over one that keys in the file are already sealed with
+		var original []byte
+		if keyring {
+			if original, err = ioutil.ReadFile(filename); err != nil {
+				return err
+			}
+			made := make([]byte, 32)
+			if _, err = rand.Read(made); err != nil {
+				return err
+			}
+			passphrase = base64.StdEncoding.EncodeToString(made)
+		}
+		secret, err := getPassphrase(true)
+		if err != nil {
+			return err
+		}
+		changed, err = rekeyConfigFile(filename, func(value string) (string, bool, error) {
+			if isEncrypted(value) && keyring {
+				return value, false, errors.New("Some api_key values are encrypted already, with another passphrase; decrypt them before encrypting with -keyring")
+			}
+			if isEncrypted(value) {
+				return value, false, nil
+			}
+			sealed, err := encryptValue(value, secret)
+			return sealed, err == nil, err
+		})
+		if err != nil {
+			return err
+		}
+		if keyring && changed > 0 {
+			if err = storeKeyringPassphrase(secret); err != nil {
+				// Without the passphrase kept, the keys would be lost, so put them back
+				target, linkErr := filepath.EvalSymlinks(filename)
+				if linkErr != nil {
+					target = filename
+				}
+				if restoreErr := replaceFile(target, original); restoreErr != nil {
+					return fmt.Errorf("%v, and cannot put back %s: %v", err, filename, restoreErr)
+				}
+				return err
+			}
+		}
+	} else {
+		changed, err = rekeyConfigFile(filename, func(value string) (string, bool, error) {
+			if !isEncrypted(value) {
+				return value, false, nil
+			}
+			secret, err := getPassphrase(false)
+			if err != nil {
+				return value, false, err
+			}
+			plain, err := decryptValue(value, secret)
+			return plain, err == nil, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	done := "Encrypted"
+	if flags.Arg(0) == "decrypt" {
+		done = "Decrypted"
+	}
+	logInfo(fmt.Sprintf("%s %d api_key values in %s", done, changed, filename))
+	return nil
+}
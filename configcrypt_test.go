@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptValueRoundTrip(t *testing.T) {
+	for _, key := range []string{"polyshazbotmicrofish", "ab&cd<ef>", "ключ 🔑", ""} {
+		sealed, err := encryptValue(key, "pass phrase")
+		if err != nil {
+			t.Fatalf("encryptValue(%q) = %v", key, err)
+		}
+		if !isEncrypted(sealed) || (key != "" && strings.Contains(sealed, key)) {
+			t.Errorf("encryptValue(%q) = %q, not sealed", key, sealed)
+		}
+		again, _ := encryptValue(key, "pass phrase")
+		if again == sealed {
+			t.Errorf("encryptValue(%q) sealed the same twice, the salt and nonce are not fresh", key)
+		}
+		plain, err := decryptValue(sealed, "pass phrase")
+		if err != nil || plain != key {
+			t.Errorf("decryptValue(encryptValue(%q)) = %q, %v", key, plain, err)
+		}
+		if _, err = decryptValue(sealed, "wrong phrase"); err == nil {
+			t.Errorf("decryptValue(%q) opened with the wrong passphrase", key)
+		}
+	}
+	for _, mangled := range []string{"enc:v1:not base64!", "enc:v1:c2hvcnQ=", "enc:v1:"} {
+		if _, err := decryptValue(mangled, "pass phrase"); err == nil {
+			t.Errorf("decryptValue(%q) opened a mangled value", mangled)
+		}
+	}
+}
+
+func TestRekeyConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configcrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := `{"version": "3.0",
+  "api_key": "ab&cd<ef",
+  "stations": ["ponceinlet@volusia.weatherstem.com"],
+  "endpoints": [{"api_url": "https://flagler.weatherstem.com/api", "api_key": "otherKey"},
+                {"api_url": "https://leon.weatherstem.com/api", "api_key": "ab&cd<ef"}]}
+`
+	dotfile := filepath.Join(dir, "dotfiles.json")
+	if err = ioutil.WriteFile(dotfile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "weatherstem.json")
+	if err = os.Symlink(dotfile, link); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := rekeyConfigFile(link, func(value string) (string, bool, error) {
+		sealed, err := encryptValue(value, "pass phrase")
+		return sealed, err == nil, err
+	})
+	if err != nil || changed != 2 {
+		t.Fatalf("encrypting changed %d keys, %v, want 2", changed, err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != dotfile {
+		t.Errorf("the symlink was replaced, %q %v", target, err)
+	}
+	sealed, _ := ioutil.ReadFile(dotfile)
+	if strings.Contains(string(sealed), "otherKey") || strings.Contains(string(sealed), "ab&cd") {
+		t.Errorf("a key was left in the clear:\n%s", sealed)
+	}
+	if !strings.Contains(string(sealed), `"stations": ["ponceinlet@volusia.weatherstem.com"],`) {
+		t.Errorf("the rest of the config was rewritten:\n%s", sealed)
+	}
+
+	changed, err = rekeyConfigFile(link, func(value string) (string, bool, error) {
+		plain, err := decryptValue(value, "pass phrase")
+		return plain, err == nil, err
+	})
+	if err != nil || changed != 2 {
+		t.Fatalf("decrypting changed %d keys, %v, want 2", changed, err)
+	}
+	if restored, _ := ioutil.ReadFile(dotfile); string(restored) != config {
+		t.Errorf("decrypting did not restore the config:\n%s", restored)
+	}
+
+	// A key written with an escape neither way of quoting makes cannot be found
+	if err = ioutil.WriteFile(dotfile, []byte(`{"api_key": "ab\u0041cd"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = rekeyConfigFile(link, func(value string) (string, bool, error) { return "x", true, nil })
+	if err == nil || changed != 0 {
+		t.Errorf("rekeying a key it cannot find changed %d, %v, want an error", changed, err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// HTTP tracing, for when the API answers with something that will not unmarshal and
+// nobody can say what. -debug-http logs every call the shared client makes: the request
+// body with the secrets blanked out, see redact, the status, how long it took and how big
+// it was. -debug-http-dir also keeps the payloads, the request blanked out the same way
+// and the response as it came, only unzipped, ala 'weatherstem -debug-http-dir /tmp/wsdump' leaves
+// 20201015-101500-1-volusia.weatherstem.com.request.json and .response.json
+var (
+	debugHTTP    bool
+	debugHTTPDir string
+)
+
+// debugHTTPCalls numbers the calls, so the dumps of one call sort together
+var debugHTTPCalls int64
+
+// tracingTransport logs, and maybe dumps, each call on its way through to the real one
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+// traceName is where a call's payloads go, by when, which call and to whom
+func traceName(call int64, request *http.Request, what string) string {
+	return filepath.Join(debugHTTPDir, fmt.Sprintf("%s-%d-%s.%s", time.Now().Format("20060102-150405"), call, request.URL.Hostname(), what))
+}
+
+// dumpPayload writes one payload, only warning when it cannot; the call goes on
+func dumpPayload(filename string, payload []byte) {
+	if err := ioutil.WriteFile(filename, payload, 0600); err != nil {
+		logWarn("Cannot dump the HTTP payload.", err)
+	}
+}
+
+func (t tracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	call := atomic.AddInt64(&debugHTTPCalls, 1)
+
+	// The body is read here to be logged, so the request passed on gets a copy of it
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		request = request.Clone(request.Context())
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if len(body) > 0 {
+		logInfo(fmt.Sprintf("HTTP #%d %s %s, %d bytes: %s", call, request.Method, request.URL.Redacted(), len(body), body))
+		if debugHTTPDir != "" {
+			dumpPayload(traceName(call, request, "request.json"), redactPayload(body))
+		}
+	} else {
+		logInfo(fmt.Sprintf("HTTP #%d %s %s", call, request.Method, request.URL.Redacted()))
+	}
+
+	start := time.Now()
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		logInfo(fmt.Sprintf("HTTP #%d failed after %s: %v", call, time.Since(start).Round(time.Millisecond), err))
+		return response, err
+	}
+	encoding := response.Header.Get("Content-Encoding")
+	if encoding == "" {
+		encoding = "identity"
+	}
+	logInfo(fmt.Sprintf("HTTP #%d %s %s in %s, %s, %s", call, response.Proto, response.Status, time.Since(start).Round(time.Millisecond), response.Header.Get("Content-Type"), encoding))
+
+	traced := &tracedBody{ReadCloser: response.Body, call: call, start: start}
+	if debugHTTPDir != "" {
+		traced.dump, traced.dumped = dumpStream(traceName(call, request, "response.json"), response.Header.Get("Content-Encoding") == "gzip")
+	}
+	response.Body = traced
+	return response, nil
+}
+
+// dumpStream is where a response is dumped as it is read: the file itself or, when it
+// is gzipped, a pipe to it that unzips on the way. done is closed once it is all written.
+func dumpStream(filename string, zipped bool) (dump io.WriteCloser, done chan struct{}) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		logWarn("Cannot dump the HTTP payload.", err)
+		return nil, nil
+	}
+	done = make(chan struct{})
+	if !zipped {
+		close(done)
+		return file, done
+	}
+	zippedSide, dump := io.Pipe()
+	go func() {
+		defer close(done)
+		defer file.Close()
+		unzipped, err := gzip.NewReader(zippedSide)
+		if err == nil {
+			_, err = io.Copy(file, unzipped)
+		}
+		if err != nil {
+			// What was read of a response cut off still unzips, up to the cut
+			logWarn("Dumping what unzips of the HTTP payload.", err)
+		}
+		// Whatever is still written to the pipe goes nowhere, rather than wait
+		zippedSide.CloseWithError(err)
+	}()
+	return dump, done
+}
+
+// tracedBody counts, and maybe dumps, a response body as it is read, saying how much
+// there was when it is closed. The results are read as they arrive, so only then is the
+// size known. The dump is written as it goes, so a big response is never held whole.
+type tracedBody struct {
+	io.ReadCloser
+	call   int64
+	start  time.Time
+	size   int64
+	dump   io.WriteCloser
+	dumped chan struct{}
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if b.dump != nil {
+		b.dump.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	logInfo(fmt.Sprintf("HTTP #%d read %d bytes in %s", b.call, b.size, time.Since(b.start).Round(time.Millisecond)))
+	if b.dump != nil {
+		b.dump.Close()
+		<-b.dumped
+		b.dump = nil
+	}
+	return b.ReadCloser.Close()
+}
+
+// checkDebugHTTP makes the dump directory, -debug-http-dir implying -debug-http
+func checkDebugHTTP() error {
+	if debugHTTPDir == "" {
+		return nil
+	}
+	debugHTTP = true
+	debugHTTPDir = expandHome(debugHTTPDir)
+	return os.MkdirAll(debugHTTPDir, 0700)
+}
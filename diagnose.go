@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// sensorHealth is how one sensor has been reading. Polls counts the history records
+// (and the current reading) that had a value for it, Zero how many of those were zero.
+type sensorHealth struct {
+	Reading ReadingInfo
+	Missing bool
+	Polls   int
+	Zero    int
+}
+
+// dead says the sensor is not giving anything useful: missing now, or zero every time
+func (health *sensorHealth) dead() bool {
+	return health.Missing || (health.Polls > 0 && health.Zero == health.Polls)
+}
+
+// String says what is wrong with the sensor, ala "zero in all 12 polls"
+func (health *sensorHealth) String() string {
+	switch {
+	case health.Missing:
+		return "missing"
+	case health.Polls == 1:
+		return "zero"
+	default:
+		return fmt.Sprintf("zero in all %d polls", health.Polls)
+	}
+}
+
+// historyValue finds a sensor's reading in a history record, for the targets that keep
+// one number per sensor. The others have no history to go on.
+func historyValue(record *historyRecord, reading *ReadingInfo) (value float64, ok bool) {
+	target := sensorTargetFor(reading.SensorType)
+	if field := sensorFields[target]; field != nil {
+		pointer, _ := field(&record.Data, &record.Units)
+		return *pointer, true
+	}
+	switch target {
+	case "leafwetness":
+		if record.Data.LeafWetness != nil {
+			return record.Data.LeafWetness.Value, true
+		}
+	case "airquality":
+		if aq := record.Data.AirQuality; aq != nil {
+			return map[string]float64{"PM2.5": aq.PM25, "PM10": aq.PM10, "Ozone": aq.Ozone}[airQualitySensor(reading.SensorType)], true
+		}
+	case "extra":
+		if extra, found := record.Data.Extra[reading.SensorType]; found {
+			return extra.Value, true
+		}
+	}
+	return 0, false
+}
+
+// diagnoseStation checks every reading of a station against its history and groups
+// them by transmitter, in the order the transmitters first show up
+func diagnoseStation(info *WeatherInfo, history []historyRecord) (transmitters []string, health map[string][]sensorHealth) {
+	health = make(map[string][]sensorHealth)
+	for _, reading := range info.WeatherRecord.RecordReadings {
+		sensor := sensorHealth{Reading: reading, Missing: reading.Value == ""}
+		if value, err := strconv.ParseFloat(reading.Value, 64); err == nil {
+			sensor.Polls++
+			if value == 0 {
+				sensor.Zero++
+			}
+			for i := range history {
+				if value, ok := historyValue(&history[i], &reading); ok {
+					sensor.Polls++
+					if value == 0 {
+						sensor.Zero++
+					}
+				}
+			}
+		}
+		if _, seen := health[reading.TransmitterID]; !seen {
+			transmitters = append(transmitters, reading.TransmitterID)
+		}
+		health[reading.TransmitterID] = append(health[reading.TransmitterID], sensor)
+	}
+	return transmitters, health
+}
+
+// diagnoseCommand looks for transmitters whose sensors read zero or nothing at all, ala
+// 'weatherstem diagnose -hours 48', for the folks running their own stations
+func diagnoseCommand(config *configSettings, args []string) (err error) {
+	var (
+		hours   int
+		station string
+	)
+	flags := flag.NewFlagSet("diagnose", flag.ExitOnError)
+	flags.IntVar(&hours, "hours", 24, "Hours of history to check the sensors against")
+	flags.StringVar(&station, "station", "", "Only this station, by handle or alias")
+	flags.Parse(args)
+	station = config.resolveStation(station)
+
+	weatherBytes, err := getWeatherInfoFromWeb(config)
+	if err != nil {
+		return fmt.Errorf("Call to API failed. %v", err)
+	}
+	weatherArr, failed, err := unmarshalWeatherInfo(weatherBytes, config.Stations)
+	if err != nil {
+		return fmt.Errorf("Cannot unmarshal API results. %v", err)
+	}
+	logStationErrors(failed)
+
+	var byStation map[string][]historyRecord
+	if config.History.File == "" {
+		logWarn("No history in the config, so only the current readings are checked.")
+	} else {
+		records, err := readHistory(config, time.Now().Add(-time.Duration(hours)*time.Hour))
+		if err != nil {
+			return err
+		}
+		_, byStation = historyByStation(records)
+	}
+
+	for i := range weatherArr {
+		info := &weatherArr[i]
+		if station != "" && info.WeatherStation.Handle != station {
+			continue
+		}
+		history := byStation[info.WeatherStation.Handle]
+		name := info.WeatherStation.Name
+		if alias, ok := config.stationAlias(info.WeatherStation.Handle); ok {
+			name = alias
+		}
+		fmt.Printf("%s (%s), %d polls of history in the last %dh\n", name, info.WeatherStation.Handle, len(history), hours)
+		transmitters, health := diagnoseStation(info, history)
+		for _, transmitter := range transmitters {
+			var dead []sensorHealth
+			for _, sensor := range health[transmitter] {
+				if sensor.dead() {
+					dead = append(dead, sensor)
+				}
+			}
+			sensors := len(health[transmitter])
+			switch {
+			case len(dead) == 0:
+				fmt.Printf("  %-6s ok       %d sensors\n", transmitter, sensors)
+			case len(dead) == sensors:
+				fmt.Printf("  %-6s dead     all %d sensors zero or missing\n", transmitter, sensors)
+			default:
+				fmt.Printf("  %-6s suspect  %d of %d sensors zero or missing\n", transmitter, len(dead), sensors)
+			}
+			for _, sensor := range dead {
+				name := sensor.Reading.Sensor
+				if sensor.Reading.SensorType != name {
+					name += " (" + sensor.Reading.SensorType + ")"
+				}
+				fmt.Printf("         %s: %s\n", name, &sensor)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// diffState is the last run's cooked result, kept for -diff to compare against
+type diffState struct {
+	Time    time.Time       `json:"time"`
+	Records []historyRecord `json:"records"`
+}
+
+// fieldChange is one reading that moved since the last run. Numbers have Before, After
+// and Delta, in the unit of this run, and words, ala the wind direction, From and To.
+type fieldChange struct {
+	Field  string  `json:"field"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"`
+	Unit   string  `json:"unit,omitempty"`
+	From   string  `json:"from,omitempty"`
+	To     string  `json:"to,omitempty"`
+}
+
+// stationDiff is what moved at one station. New stations were not in the last run,
+// and Gone ones are not in this one.
+type stationDiff struct {
+	Handle  string        `json:"station"`
+	Name    string        `json:"name"`
+	New     bool          `json:"new,omitempty"`
+	Gone    bool          `json:"gone,omitempty"`
+	Changes []fieldChange `json:"changes,omitempty"`
+}
+
+// runDiff is everything that moved since the last run, at Since, and that run's records
+type runDiff struct {
+	Since    time.Time     `json:"since"`
+	Stations []stationDiff `json:"stations"`
+	last     []historyRecord
+}
+
+// diffStateFile is where the last run is kept, "state" in the config or next to the
+// cached API response, ala ~/.cache/weatherstem/last.json
+func diffStateFile(c *configSettings) string {
+	if c.State != "" {
+		return expandHome(c.State)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "weatherstem", "last.json")
+}
+
+// diffLastRun compares this run with the one before, then keeps this one for next time.
+// The first run has nothing to compare with, so every station is new.
+func diffLastRun(c *configSettings, now time.Time, dataArr []WeatherData, unitArr []WeatherUnits) (diff runDiff, err error) {
+	filename := diffStateFile(c)
+	if filename == "" {
+		return diff, fmt.Errorf("nowhere to keep the last run, set \"state\" in the config")
+	}
+	var last diffState
+	if stateJSON, err := ioutil.ReadFile(filename); err == nil {
+		if err = json.Unmarshal(stateJSON, &last); err != nil {
+			logWarn("Cannot read the last run, starting afresh.", err)
+		}
+	}
+
+	diff = runDiff{Since: last.Time, Stations: diffStations(last.Records, dataArr, unitArr, c.DiffThresholds), last: last.Records}
+
+	stateJSON, err := json.Marshal(diffState{Time: now, Records: newHistoryRecords(now, dataArr, unitArr)})
+	if err != nil {
+		return diff, err
+	}
+	return diff, replaceFile(filename, stateJSON)
+}
+
+// diffStations lists the stations with something that moved. A number has to move more
+// than its threshold, by metric name, to count. Without one any change counts, bar
+// rounding noise.
+func diffStations(last []historyRecord, dataArr []WeatherData, unitArr []WeatherUnits, thresholds map[string]float64) (changed []stationDiff) {
+	lastByHandle := make(map[string]*historyRecord)
+	for i := range last {
+		lastByHandle[last[i].Data.Station[0]] = &last[i]
+	}
+
+	for i := range dataArr {
+		station := stationDiff{Handle: dataArr[i].Station[0], Name: dataArr[i].Station[1]}
+		before, seen := lastByHandle[station.Handle]
+		delete(lastByHandle, station.Handle)
+		if !seen {
+			station.New = true
+			changed = append(changed, station)
+			continue
+		}
+
+		beforeMetrics := make(map[string]stationMetric)
+		for _, m := range stationMetrics(&before.Data, &before.Units) {
+			beforeMetrics[m.Name] = m
+		}
+		for _, m := range stationMetrics(&dataArr[i], &unitArr[i]) {
+			previous, ok := beforeMetrics[m.Name]
+			if !ok {
+				continue
+			}
+			was, _ := convertUnit(previous.Value, previous.Unit, m.Unit)
+			delta := m.Value - was
+			if m.Name == "winddir" {
+				delta = math.Mod(delta+540, 360) - 180
+			}
+			if math.Abs(delta) < 0.005 || math.Abs(delta) <= thresholds[m.Name] {
+				continue
+			}
+			station.Changes = append(station.Changes, fieldChange{Field: m.Name, Before: was, After: m.Value, Delta: delta, Unit: m.Unit})
+		}
+		for _, words := range []struct{ field, from, to string }{
+			{"wind", before.Data.Wind[0], dataArr[i].Wind[0]},
+			{"ptrend", before.Data.PressureTrend, dataArr[i].PressureTrend},
+		} {
+			if words.from != words.to {
+				station.Changes = append(station.Changes, fieldChange{Field: words.field, From: words.from, To: words.to})
+			}
+		}
+		if len(station.Changes) > 0 {
+			changed = append(changed, station)
+		}
+	}
+
+	for _, rec := range last {
+		if _, gone := lastByHandle[rec.Data.Station[0]]; gone {
+			changed = append(changed, stationDiff{Handle: rec.Data.Station[0], Name: rec.Data.Station[1], Gone: true})
+		}
+	}
+	return changed
+}
+
+// PrintDiff shows only what changed, with the deltas, ala
+// "  temp       75 → 76.2 °F (+1.2)". Nothing changed prints nothing, so cron has
+// nothing to mail.
+func (diff *runDiff) PrintDiff() {
+	for _, station := range diff.Stations {
+		switch {
+		case station.New:
+			fmt.Printf("%s (%s) new\n", station.Name, station.Handle)
+			continue
+		case station.Gone:
+			fmt.Printf("%s (%s) gone\n", station.Name, station.Handle)
+			continue
+		}
+		fmt.Printf("%s (%s) since %s\n", station.Name, station.Handle, diff.Since.Local().Format("2006-01-02 15:04"))
+		for _, change := range station.Changes {
+			if change.From != "" || change.To != "" {
+				fmt.Printf("  %-10s %s → %s\n", change.Field, change.From, change.To)
+				continue
+			}
+			sign := "+"
+			if change.Delta < 0 {
+				sign = ""
+			}
+			fmt.Printf("  %-10s %s → %s %s (%s%s)\n", change.Field, diffNumber(change.Before), diffNumber(change.After), html.UnescapeString(change.Unit), sign, diffNumber(change.Delta))
+		}
+	}
+}
+
+// diffNumber is a value to two decimal places at most, ala 29.92 or 76.2
+func diffNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+// PrintDiffJSON shows what changed as one JSON object, and like PrintDiff nothing at all
+// when nothing did
+func (diff *runDiff) PrintDiffJSON() {
+	if len(diff.Stations) == 0 {
+		return
+	}
+	jdata, err := marshalJSON(diff)
+	if err != nil {
+		logError("Cannot marshal the changes", err)
+		return
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
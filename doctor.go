@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	// Registered for the usage and completions; main runs it before loading the config
+	subcommands["doctor"] = doctorCommand
+}
+
+const (
+	// doctorTimeout is how long each network check may take
+	doctorTimeout = 10 * time.Second
+	// doctorClockSkew is how far off the clock may be from the API's before the cache
+	// ages and the history times are wrong enough to matter
+	doctorClockSkew = time.Minute
+)
+
+// stationPattern is how a station is written since the Aug 2020 API v1, handle@domain,
+// ala "ponceinlet@volusia.weatherstem.com"
+var stationPattern = regexp.MustCompile(`^[a-z0-9_-]+@[a-z0-9-]+(\.[a-z0-9-]+)+$`)
+
+// doctorCheck is one line of the doctor's report
+type doctorCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// doctorReport is the checks in the order they ran
+type doctorReport []doctorCheck
+
+// add notes a check, passed when err is nil, and says whether it passed
+func (report *doctorReport) add(name string, err error, detail string) bool {
+	if err != nil {
+		detail = err.Error()
+	}
+	*report = append(*report, doctorCheck{Name: name, Passed: err == nil, Detail: detail})
+	return err == nil
+}
+
+// doctorConfig finds the config the way every run does, and says which file it was
+func doctorConfig(report *doctorReport) (config configSettings, found bool) {
+	for _, filename := range configFiles() {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			continue
+		}
+		err := config.getConfigSettings(filename)
+		detail := fmt.Sprintf("%s, version %s", filename, config.Version)
+		if config.Version != configSettingsVersion {
+			detail += ", this app wants " + configSettingsVersion
+		}
+		return config, report.add("config", err, detail)
+	}
+	report.add("config", fmt.Errorf("none of %s", strings.Join(configFiles(), ", ")), "")
+	return config, false
+}
+
+// doctorStations checks that the stations are written handle@domain, once each
+func doctorStations(report *doctorReport, config *configSettings) {
+	if len(config.Stations) == 0 {
+		report.add("stations", errors.New("none in the config"), "")
+		return
+	}
+	seen := make(map[string]bool)
+	var bad []string
+	for _, station := range config.Stations {
+		switch {
+		case !stationPattern.MatchString(station):
+			bad = append(bad, fmt.Sprintf("%q is not handle@domain", station))
+		case seen[station]:
+			bad = append(bad, fmt.Sprintf("%q is in twice", station))
+		}
+		seen[station] = true
+	}
+	if len(bad) > 0 {
+		report.add("stations", errors.New(strings.Join(bad, ", ")), "")
+		return
+	}
+	report.add("stations", nil, fmt.Sprintf("%d, all handle@domain", len(config.Stations)))
+}
+
+// doctorReach looks the API's host up, shakes hands with it and compares clocks. Through
+// a proxy the host may not resolve or answer from here, so only the clock is checked then.
+// reached is false when the API could not be asked at all.
+func doctorReach(report *doctorReport, config *configSettings, apiURL string) (reached bool) {
+	where, err := url.Parse(apiURL)
+	if err != nil || where.Hostname() == "" {
+		report.add("api_url", fmt.Errorf("%q is not a URL", apiURL), "")
+		return false
+	}
+	host := where.Hostname()
+	proxy, err := apiProxy(config)
+	if err != nil {
+		report.add("proxy", err, "")
+		return false
+	}
+	request, _ := http.NewRequest(http.MethodHead, apiURL, nil)
+	request.Header.Set("User-Agent", userAgent())
+	through, _ := proxy(request)
+
+	if through == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+		cancel()
+		if !report.add("dns "+host, err, strings.Join(addresses, ", ")) {
+			return false
+		}
+		if where.Scheme == "https" {
+			port := where.Port()
+			if port == "" {
+				port = "443"
+			}
+			conn, err := tls.DialWithDialer(&net.Dialer{Timeout: doctorTimeout}, "tcp", net.JoinHostPort(host, port), &tls.Config{ServerName: host})
+			detail := ""
+			if err == nil {
+				certificate := conn.ConnectionState().PeerCertificates[0]
+				detail = fmt.Sprintf("certificate for %s good until %s", certificate.Subject.CommonName, certificate.NotAfter.Format("2006-01-02"))
+				conn.Close()
+			}
+			if !report.add("tls "+host, err, detail) {
+				return false
+			}
+		}
+	}
+
+	shared, err := httpClient(config)
+	if err != nil {
+		report.add("proxy", err, "")
+		return false
+	}
+	client := *shared
+	client.Timeout = doctorTimeout
+	logDebug("Calling", apiURL)
+	response, err := client.Do(request)
+	if err != nil {
+		report.add("clock", fmt.Errorf("cannot ask %s the time: %v", host, err), "")
+		return false
+	}
+	response.Body.Close()
+	date, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		report.add("clock", fmt.Errorf("%s gave no time to compare with", host), "")
+		return true
+	}
+	skew := time.Since(date).Round(time.Second)
+	if skew > doctorClockSkew || skew < -doctorClockSkew {
+		report.add("clock", fmt.Errorf("%s off from %s's", skew, host), "")
+		return true
+	}
+	report.add("clock", nil, fmt.Sprintf("within %s of %s's", doctorClockSkew, host))
+	return true
+}
+
+// doctorKey asks the endpoint about its first station only, to see the key is taken
+func doctorKey(report *doctorReport, config *configSettings, endpoint apiEndpoint) {
+	name := "api_key " + endpoint.URL
+	answer, err := getEndpointInfo(config, endpoint.URL, endpoint.Key, endpoint.Stations[:1])
+	switch {
+	case errors.Is(err, errAPIAuth):
+		report.add(name, fmt.Errorf("refused, %v", err), "")
+	case err != nil:
+		report.add(name, err, "")
+	case !bytes.HasPrefix(bytes.TrimSpace(answer), []byte("[")):
+		report.add(name, errNotStations, "")
+	default:
+		report.add(name, nil, "taken, asked about "+stationHandle(endpoint.Stations[0]))
+	}
+}
+
+// doctorWritable checks a file the tool keeps can be written: its directory, made if need
+// be as the tool would, takes a new file, and the file itself opens for writing
+func doctorWritable(report *doctorReport, name, filename string) {
+	if filename == "" {
+		report.add(name, errors.New("no cache directory to keep it in"), "")
+		return
+	}
+	dir := filepath.Dir(filename)
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		var probe *os.File
+		if probe, err = ioutil.TempFile(dir, ".doctor-"); err == nil {
+			probe.Close()
+			os.Remove(probe.Name())
+		}
+	}
+	if err == nil {
+		var existing *os.File
+		if existing, err = os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0); err == nil {
+			existing.Close()
+		} else if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	report.add(name, err, filename)
+}
+
+// doctorCommand checks what usually goes wrong for new users, ala 'weatherstem doctor':
+// the config, the stations, reaching the API, the key, the clock and the files it keeps.
+// It finds the config itself, so it runs even when a plain run would not.
+func doctorCommand(_ *configSettings, args []string) (err error) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	flags.Parse(args)
+
+	var report doctorReport
+	config, found := doctorConfig(&report)
+	if found {
+		config.settleEndpoints()
+		doctorStations(&report, &config)
+		endpoints := config.splitByEndpoint()
+		for _, endpoint := range endpoints {
+			if endpoint.URL == "" {
+				report.add("api_url", errors.New("none in the config"), "")
+				continue
+			}
+			if doctorReach(&report, &config, endpoint.URL) {
+				doctorKey(&report, &config, endpoint)
+			}
+		}
+		doctorWritable(&report, "cache", cacheFile(&config))
+		doctorWritable(&report, "station cache", config.stationCacheFile())
+		doctorWritable(&report, "diff state", diffStateFile(&config))
+		doctorWritable(&report, "geocode cache", geocodeFile())
+		if config.History.File != "" {
+			doctorWritable(&report, "history", expandHome(config.History.File))
+		}
+	}
+
+	failed := 0
+	for _, check := range report {
+		mark := "pass"
+		if !check.Passed {
+			mark = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %-16s %s\n", mark, redact(check.Name), redact(check.Detail))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// A reading past this much of its limit, or this close (°C) to a temperature limit, is a caution
+const (
+	droneCautionShare = 0.8
+	droneCautionTemp  = 5.0
+)
+
+// droneLimits are the airframe's limits, winds in m/s and temperatures in °C as the spec
+// sheets give them. Rain says whether it may fly in the rain at all.
+type droneLimits struct {
+	Wind    float64 `json:"wind"`
+	Gust    float64 `json:"gust"`
+	MinTemp float64 `json:"min_temp"`
+	MaxTemp float64 `json:"max_temp"`
+	Rain    bool    `json:"rain"`
+}
+
+// droneLimits are the config's, with those of a typical small quadcopter for any it
+// leaves out. A min_temp of 0 counts as left out; set -0.1 to mean freezing.
+func (config *configSettings) droneLimits() droneLimits {
+	limits := config.Drone
+	if limits.Wind == 0 {
+		limits.Wind = 10
+	}
+	if limits.Gust == 0 {
+		limits.Gust = 12
+	}
+	if limits.MinTemp == 0 {
+		limits.MinTemp = -10
+	}
+	if limits.MaxTemp == 0 {
+		limits.MaxTemp = 40
+	}
+	return limits
+}
+
+// DroneVerdict is GO, CAUTION or NO-GO for a station, and the reading that decided it
+type DroneVerdict struct {
+	Verdict  string `json:"verdict"`
+	Limiting string `json:"limiting"`
+}
+
+// String is the verdict and why, ala "NO-GO, gust 28.0 mph over 26.8 mph"
+func (verdict *DroneVerdict) String() string {
+	return verdict.Verdict + ", " + verdict.Limiting
+}
+
+// AssessDrone checks the wind, gust, rain and temperature against the airframe limits.
+// The worst of them decides, and among equals the one nearest its limit.
+func AssessDrone(config *configSettings, data *WeatherData, units *WeatherUnits) (verdict DroneVerdict) {
+	limits := config.droneLimits()
+	verdicts := [...]string{"GO", "CAUTION", "NO-GO"}
+	worst, share := -1, -1.0
+	check := func(level int, near float64, limiting string) {
+		if level > worst || (level == worst && near > share) {
+			worst, share, verdict.Limiting = level, near, limiting
+		}
+	}
+	byShare := func(share float64) int {
+		switch {
+		case share > 1:
+			return 2
+		case share >= droneCautionShare:
+			return 1
+		}
+		return 0
+	}
+
+	for i, name := range []string{"wind", "gust"} {
+		limit := []float64{limits.Wind, limits.Gust}[i]
+		unit := units.Windspeed[i]
+		speed := toMetersPerSecond(data.Windspeed[i], units.Windspeed[i])
+		inUnit, _ := convertUnit(limit, "m/s", units.Windspeed[i])
+		relation := "under"
+		if speed > limit {
+			relation = "over"
+		}
+		check(byShare(speed/limit), speed/limit, fmt.Sprintf("%s %.1f %s %s %.1f %s", name, data.Windspeed[i], unit, relation, inUnit, unit))
+	}
+
+	if data.Rain[1] > 0 {
+		unit := units.Rain[1]
+		if limits.Rain {
+			check(1, 1, fmt.Sprintf("rain %.2f %s", data.Rain[1], unit))
+		} else {
+			check(2, math.Inf(1), fmt.Sprintf("rain %.2f %s", data.Rain[1], unit))
+		}
+	}
+
+	temp := toCelsius(data.Temperature[0], units.Temperature[0])
+	unit := units.Temperature[0]
+	for _, limit := range []struct {
+		celsius  float64
+		relation string
+		margin   float64
+	}{{limits.MinTemp, "under", temp - limits.MinTemp}, {limits.MaxTemp, "over", limits.MaxTemp - temp}} {
+		if limit.margin >= droneCautionTemp {
+			continue
+		}
+		level, relation := 1, "near"
+		if limit.margin < 0 {
+			level, relation = 2, limit.relation
+		}
+		inUnit := fromCelsius(limit.celsius, units.Temperature[0])
+		check(level, 1-limit.margin/droneCautionTemp, fmt.Sprintf("temperature %.1f%s %s %.1f%s", data.Temperature[0], unit, relation, inUnit, unit))
+	}
+
+	verdict.Verdict = verdicts[worst]
+	return verdict
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpSettings is the optional "smtp" block of the config file, for -email, ala:
+// "smtp": {"host": "smtp.example.com", "port": 587, "username": "crew", "password": "s3cret",
+// "from": "weather@example.com", "format": "html", "when": "alerts"}
+// Format is "text" (the default) or "html". When is "always" (the default), "alerts",
+// which only sends mail if some alert was raised, or "changes", which sends it when
+// something moved since the last run as well, see diffStations.
+type smtpSettings struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from"`
+	Format   string `json:"format,omitempty"`
+	When     string `json:"when,omitempty"`
+}
+
+func init() {
+	registerSink("email", func(target string) (outputSink, error) {
+		if target == "" {
+			return nil, fmt.Errorf("email needs somebody to send to")
+		}
+		return emailSink(strings.Split(target, ",")), nil
+	})
+}
+
+// emailSink mails the report to its addresses
+type emailSink []string
+
+func (to emailSink) Send(config *configSettings, batch *sinkBatch) error {
+	if config.SMTP.When == "changes" && len(batch.Changes) == 0 && len(batch.Alerts) == 0 {
+		return nil
+	}
+	return sendReportEmail(&config.SMTP, to, batch.Data, batch.Units, batch.Orig, batch.Alerts)
+}
+
+// emailReport renders the report for the mail body, alerts first
+func emailReport(settings *smtpSettings, dataArr []WeatherData, unitArr []WeatherUnits, weatherArr []WeatherInfo, alerts []alertEvent) (body []byte, contentType string, err error) {
+	var report bytes.Buffer
+	if settings.Format == "html" {
+		// Mail readers tend not to show data URLs, so the charts go by Content-ID
+		stations := reportStations(dataArr, unitArr, weatherArr)
+		for i := range stations {
+			if stations[i].Chart != "" {
+				stations[i].Chart = template.URL("cid:" + chartContentID(&dataArr[i]))
+			}
+		}
+		err = renderReportHTML(&report, stations)
+		var list bytes.Buffer
+		for _, event := range alerts {
+			fmt.Fprintf(&list, "<li><b>%s</b></li>\n", xmlEscape(event.String()))
+		}
+		if list.Len() > 0 {
+			body = bytes.Replace(report.Bytes(), []byte("<body>\n"), []byte("<body>\n<ul>\n"+list.String()+"</ul>\n"), 1)
+		} else {
+			body = report.Bytes()
+		}
+		if err != nil {
+			return body, "", err
+		}
+		return emailWithCharts(body, dataArr)
+	}
+	for _, event := range alerts {
+		fmt.Fprintln(&report, "ALERT", event)
+	}
+	if len(alerts) > 0 {
+		fmt.Fprintln(&report)
+	}
+	PrintReportMarkdown(&report, dataArr, unitArr, weatherArr)
+	return report.Bytes(), "text/plain; charset=utf-8", nil
+}
+
+// chartContentID names a station's chart in the mail
+func chartContentID(data *WeatherData) string {
+	return "chart-" + data.Station[0] + "@weatherstem"
+}
+
+// emailWithCharts wraps the HTML report up with the charts it refers to, if there are any
+func emailWithCharts(html []byte, dataArr []WeatherData) (body []byte, contentType string, err error) {
+	var message bytes.Buffer
+	related := multipart.NewWriter(&message)
+	var charts []*WeatherData
+	for i := range dataArr {
+		if dataArr[i].chart != nil {
+			charts = append(charts, &dataArr[i])
+		}
+	}
+	if len(charts) == 0 {
+		return html, "text/html; charset=utf-8", nil
+	}
+
+	part, err := related.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}})
+	if err != nil {
+		return nil, "", err
+	}
+	part.Write(html)
+	for _, data := range charts {
+		part, err = related.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"image/png"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<" + chartContentID(data) + ">"},
+			"Content-Disposition":       {"inline; filename=\"" + data.Station[0] + ".png\""},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		encoded := base64.StdEncoding.EncodeToString(data.chart)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	if err = related.Close(); err != nil {
+		return nil, "", err
+	}
+	return message.Bytes(), "multipart/related; boundary=" + related.Boundary(), nil
+}
+
+// sendReportEmail mails the report to everybody in to. STARTTLS is used when the
+// server offers it, and the login only when there is a username.
+func sendReportEmail(settings *smtpSettings, to []string, dataArr []WeatherData, unitArr []WeatherUnits, weatherArr []WeatherInfo, alerts []alertEvent) error {
+	if settings.Host == "" || settings.From == "" {
+		return fmt.Errorf("the config needs an \"smtp\" block with at least host and from")
+	}
+	if settings.When == "alerts" && len(alerts) == 0 {
+		return nil
+	}
+	body, contentType, err := emailReport(settings, dataArr, unitArr, weatherArr, alerts)
+	if err != nil {
+		return err
+	}
+
+	subject := "Weather conditions " + time.Now().Format("2006-01-02 15:04")
+	if len(alerts) > 0 {
+		subject = fmt.Sprintf("%s, %d alert(s)", subject, len(alerts))
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", settings.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: %s\r\n\r\n", contentType)
+	message.Write(bytes.ReplaceAll(bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n")))
+
+	port := settings.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if settings.Username != "" {
+		auth = smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(settings.Host, strconv.Itoa(port)), auth, settings.From, to, message.Bytes())
+}
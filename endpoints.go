@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+
+	json "github.com/json-iterator/go"
+)
+
+// apiEndpoint is one API and the stations to ask it about, for those whose stations are
+// spread over more than one WeatherSTEM domain, ala
+// {"api_url": "https://flagler.weatherstem.com/api", "api_key": "k2", "stations": ["flaglerbeach"]}
+type apiEndpoint struct {
+	URL      string   `json:"api_url"`
+	Key      string   `json:"api_key"`
+	Stations []string `json:"stations"`
+}
+
+// settleEndpoints adds the stations of the other endpoints to the config's, so picking,
+// aliases and the cache see them all. The fetch sends each to its own endpoint again,
+// see endpointFor.
+func (config *configSettings) settleEndpoints() {
+	for _, endpoint := range config.Endpoints {
+		for _, station := range endpoint.Stations {
+			known := false
+			for _, have := range config.Stations {
+				known = known || strings.EqualFold(stationHandle(have), stationHandle(station))
+			}
+			if !known {
+				config.Stations = append(config.Stations, station)
+			}
+		}
+	}
+}
+
+// endpointFor is the endpoint to ask about a station: the first of the config's endpoints
+// to list it, or else the config's own api_url and api_key
+func (config *configSettings) endpointFor(station string) apiEndpoint {
+	for _, endpoint := range config.Endpoints {
+		for _, listed := range endpoint.Stations {
+			if strings.EqualFold(stationHandle(listed), stationHandle(station)) {
+				return endpoint
+			}
+		}
+	}
+	return apiEndpoint{URL: config.URL, Key: config.Key}
+}
+
+// splitByEndpoint sorts the stations out by the endpoint to ask, keeping the endpoints in
+// the order their first station came
+func (config *configSettings) splitByEndpoint() (endpoints []apiEndpoint) {
+	for _, station := range config.Stations {
+		endpoint := config.endpointFor(station)
+		i := 0
+		for i < len(endpoints) && (endpoints[i].URL != endpoint.URL || endpoints[i].Key != endpoint.Key) {
+			i++
+		}
+		if i == len(endpoints) {
+			endpoints = append(endpoints, apiEndpoint{URL: endpoint.URL, Key: endpoint.Key})
+		}
+		endpoints[i].Stations = append(endpoints[i].Stations, station)
+	}
+	return endpoints
+}
+
+// errNotStations is an endpoint answering with something other than a list of stations
+var errNotStations = errors.New("API results are not a JSON array of stations")
+
+// getWeatherInfoFromWeb calls the API about the configured stations. Stations on other
+// endpoints are asked about at the same time, and the answers put together as one. An
+// endpoint that fails only costs its own stations, which come out missing; it takes all of
+// them failing for an error.
+func getWeatherInfoFromWeb(c *configSettings) ([]byte, error) {
+	endpoints := c.splitByEndpoint()
+	switch len(endpoints) {
+	case 0:
+		return getEndpointInfo(c, c.URL, c.Key, c.Stations)
+	case 1:
+		return getEndpointInfo(c, endpoints[0].URL, endpoints[0].Key, endpoints[0].Stations)
+	}
+
+	answers := make([][]byte, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wait sync.WaitGroup
+	for i := range endpoints {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			answers[i], errs[i] = getEndpointInfo(c, endpoints[i].URL, endpoints[i].Key, endpoints[i].Stations)
+			if trimmed := bytes.TrimSpace(answers[i]); errs[i] == nil && !(json.Valid(trimmed) && trimmed[0] == '[') {
+				errs[i] = errNotStations
+			}
+		}(i)
+	}
+	wait.Wait()
+
+	var merged [][]byte
+	for i := range endpoints {
+		if errs[i] != nil {
+			logWarn("Call to", endpoints[i].URL, "failed.", errs[i])
+			continue
+		}
+		inner := bytes.TrimSpace(answers[i])
+		inner = bytes.TrimSpace(inner[1 : len(inner)-1])
+		if len(inner) > 0 {
+			merged = append(merged, inner)
+		}
+	}
+	if len(merged) == 0 {
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return append(append([]byte("["), bytes.Join(merged, []byte(","))...), ']'), nil
+}
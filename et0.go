@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"time"
+)
+
+// dailyWeather is one station's day of history boiled down to what ET0 needs,
+// all in metric
+type dailyWeather struct {
+	Date     time.Time
+	Readings int
+	TempMin  float64 // °C
+	TempMax  float64 // °C
+	Humidity float64 // mean %
+	Wind     float64 // mean m/s
+	Solar    float64 // mean W/m²
+}
+
+// et0Result is the reference evapotranspiration for one day
+type et0Result struct {
+	dailyWeather
+	ET0    float64 // mm/day
+	Method string
+}
+
+// dailyHistory groups a station's history records by local calendar day, oldest first
+func dailyHistory(records []historyRecord) (days []dailyWeather) {
+	for _, rec := range records {
+		local := rec.Time.Local()
+		date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+		temp := toCelsius(rec.Data.Temperature[0], rec.Units.Temperature[0])
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(date) {
+			days = append(days, dailyWeather{Date: date, TempMin: temp, TempMax: temp})
+		}
+		day := &days[len(days)-1]
+		day.Readings++
+		day.TempMin = math.Min(day.TempMin, temp)
+		day.TempMax = math.Max(day.TempMax, temp)
+		// Running means
+		n := float64(day.Readings)
+		day.Humidity += (rec.Data.Humidity - day.Humidity) / n
+		day.Wind += (toMetersPerSecond(rec.Data.Windspeed[0], rec.Units.Windspeed[0]) - day.Wind) / n
+		day.Solar += (rec.Data.Sun[0] - day.Solar) / n
+	}
+	return days
+}
+
+// extraterrestrialRadiation is the daily top-of-atmosphere radiation in MJ/m²/day
+// for a latitude in degrees and day of the year, per FAO-56 equation 21
+func extraterrestrialRadiation(latitude float64, dayOfYear int) float64 {
+	phi := latitude * math.Pi / 180
+	j := float64(dayOfYear)
+	dr := 1 + 0.033*math.Cos(2*math.Pi*j/365)
+	delta := 0.409 * math.Sin(2*math.Pi*j/365-1.39)
+	ws := math.Acos(math.Max(-1, math.Min(1, -math.Tan(phi)*math.Tan(delta))))
+	return 24 * 60 / math.Pi * 0.0820 * dr * (ws*math.Sin(phi)*math.Sin(delta) + math.Cos(phi)*math.Cos(delta)*math.Sin(ws))
+}
+
+// saturationVaporPressure in kPa for a temperature in °C
+func saturationVaporPressure(temp float64) float64 {
+	return 0.6108 * math.Exp(17.27*temp/(temp+237.3))
+}
+
+// hargreavesET0 estimates ET0 in mm/day from temperature alone
+func hargreavesET0(day dailyWeather, ra float64) float64 {
+	mean := (day.TempMax + day.TempMin) / 2
+	return 0.0023 * 0.408 * ra * (mean + 17.8) * math.Sqrt(day.TempMax-day.TempMin)
+}
+
+// penmanMonteithET0 estimates ET0 in mm/day per the FAO-56 daily method. It
+// assumes sea level, the anemometer at 2m and no soil heat flux, which is about
+// as good as a rooftop school station gets anyway.
+func penmanMonteithET0(day dailyWeather, ra float64) float64 {
+	mean := (day.TempMax + day.TempMin) / 2
+	es := (saturationVaporPressure(day.TempMax) + saturationVaporPressure(day.TempMin)) / 2
+	ea := es * day.Humidity / 100
+
+	rs := day.Solar * 0.0864 // W/m² to MJ/m²/day
+	rso := 0.75 * ra
+	ratio := 1.0
+	if rso > 0 {
+		ratio = math.Min(rs/rso, 1.0)
+	}
+	tmaxK := math.Pow(day.TempMax+273.16, 4)
+	tminK := math.Pow(day.TempMin+273.16, 4)
+	rnl := 4.903e-9 * (tmaxK + tminK) / 2 * (0.34 - 0.14*math.Sqrt(ea)) * (1.35*ratio - 0.35)
+	rn := 0.77*rs - rnl
+
+	slope := 4098 * saturationVaporPressure(mean) / math.Pow(mean+237.3, 2)
+	gamma := 0.665e-3 * 101.3
+	return (0.408*slope*rn + gamma*900/(mean+273)*day.Wind*(es-ea)) / (slope + gamma*(1+0.34*day.Wind))
+}
+
+// dailyET0 picks Penman-Monteith when the station has a solar sensor and falls
+// back to Hargreaves when it does not
+func dailyET0(day dailyWeather, latitude float64) (result et0Result) {
+	result.dailyWeather = day
+	ra := extraterrestrialRadiation(latitude, day.Date.YearDay())
+	if day.Solar > 0 && day.Humidity > 0 {
+		result.ET0 = penmanMonteithET0(day, ra)
+		result.Method = "Penman-Monteith"
+	} else {
+		result.ET0 = hargreavesET0(day, ra)
+		result.Method = "Hargreaves"
+	}
+	result.ET0 = math.Max(result.ET0, 0)
+	return result
+}
+
+// et0Command prints the daily reference evapotranspiration for each station from the history file
+func et0Command(config *configSettings, args []string) (err error) {
+	var (
+		days    int
+		station string
+	)
+	flags := flag.NewFlagSet("et0", flag.ExitOnError)
+	flags.IntVar(&days, "days", 7, "Days of history to include")
+	flags.StringVar(&station, "station", "", "Only this station, by handle or alias")
+	flags.Parse(args)
+	station = config.resolveStation(station)
+
+	if config.History.File == "" {
+		return errNoHistory
+	}
+
+	now := time.Now()
+	records, err := readHistory(config, time.Date(now.Year(), now.Month(), now.Day()-days, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+
+	handles, byStation := historyByStation(records)
+	if station != "" {
+		handles = []string{station}
+	}
+
+	for _, handle := range handles {
+		history := byStation[handle]
+		if len(history) == 0 {
+			return fmt.Errorf("No history for station %s in the last %d days", handle, days)
+		}
+		latitude := history[len(history)-1].Data.StationTopo.Lat
+		fmt.Printf("%s (%s)\n", history[0].Data.Station[1], handle)
+		fmt.Printf("  %-10s %7s %7s %8s %8s  %s\n", "date", "Tmin", "Tmax", "ET0", "", "method")
+		for _, day := range dailyHistory(history) {
+			result := dailyET0(day, latitude)
+			partial := ""
+			if day.Date.YearDay() == now.YearDay() && day.Date.Year() == now.Year() {
+				partial = " (so far)"
+			}
+			fmt.Printf("  %-10s %5.1f°C %5.1f°C %6.2fmm %6.3fin  %s%s\n", day.Date.Format("2006-01-02"), result.TempMin, result.TempMax, result.ET0, result.ET0/25.4, result.Method, partial)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+func init() {
+	registerSink("exec", func(target string) (outputSink, error) {
+		return execSink{command: target}, nil
+	})
+	registerSink("exec-once", func(target string) (outputSink, error) {
+		return execSink{command: target, once: true}, nil
+	})
+}
+
+// execSink runs a downstream script, see RunExecHook
+type execSink struct {
+	command string
+	once    bool
+}
+
+func (sink execSink) Send(config *configSettings, batch *sinkBatch) error {
+	if sink.command == "" {
+		return fmt.Errorf("exec needs a command to run")
+	}
+	return RunExecHook(sink.command, sink.once, batch.Data, batch.Units)
+}
+
+// stationEnvironment is a station's cooked values as WS_ environment variables
+func stationEnvironment(data *WeatherData, units *WeatherUnits) []string {
+	number := func(value float64) string { return strconv.FormatFloat(value, 'f', -1, 64) }
+	return []string{
+		"WS_STATION=" + data.Station[0],
+		"WS_NAME=" + data.Station[1],
+		"WS_TIME=" + data.Station[2],
+		"WS_DISTANCE=" + number(data.StationDist),
+		"WS_DISTANCE_UNIT=" + units.StationDist,
+		"WS_BEARING=" + number(data.StationBearing),
+		"WS_TEMP=" + number(data.Temperature[0]),
+		"WS_DEWPOINT=" + number(data.Temperature[1]),
+		"WS_WBGT=" + number(data.Temperature[2]),
+		"WS_WINDCHILL=" + number(data.Temperature[3]),
+		"WS_HEATINDEX=" + number(data.Temperature[4]),
+		"WS_TEMP_UNIT=" + html.UnescapeString(units.Temperature[0]),
+		"WS_HUMIDITY=" + number(data.Humidity),
+		"WS_WIND=" + number(data.Windspeed[0]),
+		"WS_GUST=" + number(data.Windspeed[1]),
+		"WS_WIND_UNIT=" + units.Windspeed[0],
+		"WS_WINDDIR=" + number(data.Windspeed[2]),
+		"WS_HEADING=" + data.Wind[0],
+		"WS_PRESSURE=" + number(data.Pressure),
+		"WS_PRESSURE_UNIT=" + units.Pressure,
+		"WS_PTREND=" + data.PressureTrend,
+		"WS_RAIN=" + number(data.Rain[0]),
+		"WS_RAINRATE=" + number(data.Rain[1]),
+		"WS_RAIN_UNIT=" + units.Rain[0],
+		"WS_SOLAR=" + number(data.Sun[0]),
+		"WS_UV=" + number(data.Sun[1]),
+	}
+}
+
+// structuredOutput is set when stdout carries JSON, YAML and the like, which whatever a
+// hook has to say would spoil, so hooks say it on stderr then
+var structuredOutput bool
+
+// hookStdout is where the hooks and plugin sinks write their output
+func hookStdout() io.Writer {
+	if structuredOutput {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// runHook runs command through the shell with the extra environment and the JSON on stdin.
+// Its output goes wherever ours does.
+func runHook(command string, env []string, stdin []byte) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = hookStdout(), os.Stderr
+	return cmd.Run()
+}
+
+// RunExecHook hands the cooked data to a downstream script. With once, the script runs
+// one time with all the stations as a JSON array on stdin and WS_STATIONS counting them.
+// Otherwise it runs for each station, with its values in WS_ variables and its data and
+// units as JSON on stdin.
+func RunExecHook(command string, once bool, dataArr []WeatherData, unitArr []WeatherUnits) (err error) {
+	docs := stationDocuments(dataArr, unitArr)
+	if once {
+		jdata, err := marshalJSONCompact(docs)
+		if err != nil {
+			return err
+		}
+		return runHook(command, []string{"WS_STATIONS=" + strconv.Itoa(len(dataArr))}, jdata)
+	}
+	for i := range dataArr {
+		jdata, err := marshalJSONCompact(docs[i])
+		if err != nil {
+			return err
+		}
+		if err = runHook(command, stationEnvironment(&dataArr[i], &unitArr[i]), jdata); err != nil {
+			return fmt.Errorf("%s: %v", dataArr[i].Station[0], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+)
+
+// Exit codes, so scripts can tell a bad key from the network being down
+const (
+	exitOK      = 0 // All is well
+	exitFailure = 1 // The API call failed on the way, or anything else went wrong
+	exitUsage   = 2 // Bad flags or arguments
+	exitConfig  = 3 // The config file is missing or broken
+	exitAuth    = 4 // The API turned the key down
+	exitParse   = 5 // The API results made no sense
+	exitStale   = 6 // The API failed, so the output is from the cache
+	exitAlert   = 7 // -alerts found something
+	exitNoGo    = 8 // -drone says NO-GO for a station
+)
+
+// errAPIAuth is the API turning the key down
+var errAPIAuth = errors.New("API key refused")
+
+// exitCodeFor picks the exit code for a failed API call
+func exitCodeFor(err error) int {
+	if errors.Is(err, errAPIAuth) {
+		return exitAuth
+	}
+	return exitFailure
+}
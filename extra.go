@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	json "github.com/json-iterator/go"
+)
+
+// ValueUnit is a reading with its unit, for the sensors PopulateWeatherData has no
+// place for. Readings that are not numbers keep their text.
+type ValueUnit struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+	Text  string  `json:"text,omitempty"`
+}
+
+func init() {
+	// json-iterator's own map encoder trips over newer Go runtimes, and the sensors
+	// should come out in the same order every time anyway
+	json.RegisterTypeEncoderFunc("map[string]main.ValueUnit", func(ptr unsafe.Pointer, stream *json.Stream) {
+		extra := *(*map[string]ValueUnit)(ptr)
+		stream.WriteObjectStart()
+		for i, name := range extraNames(extra) {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			stream.WriteObjectField(name)
+			stream.WriteVal(extra[name])
+		}
+		stream.WriteObjectEnd()
+	}, func(ptr unsafe.Pointer) bool {
+		return len(*(*map[string]ValueUnit)(ptr)) == 0
+	})
+}
+
+// extraNames are the other sensors' names in order
+func extraNames(extra map[string]ValueUnit) (names []string) {
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addExtra keeps a reading of a sensor type we do not know, under its type. A second
+// sensor of the same type goes under its own name too, ala "Soil Moisture (Soil Moisture 2)".
+func (data *WeatherData) addExtra(reading *ReadingInfo) {
+	if data.Extra == nil {
+		data.Extra = make(map[string]ValueUnit)
+	}
+	name := reading.SensorType
+	if name == "" {
+		name = reading.Sensor
+	}
+	if _, taken := data.Extra[name]; taken {
+		name += " (" + reading.Sensor + ")"
+	}
+	extra := ValueUnit{Unit: html.UnescapeString(reading.UnitSymbol)}
+	if value, err := strconv.ParseFloat(reading.Value, 64); err == nil {
+		extra.Value = value
+	} else {
+		extra.Text = reading.Value
+	}
+	data.Extra[name] = extra
+}
+
+// String shows the reading with its unit, ala "32.5cb"
+func (extra ValueUnit) String() string {
+	if extra.Text != "" {
+		return strings.TrimSpace(extra.Text + " " + extra.Unit)
+	}
+	return strconv.FormatFloat(extra.Value, 'f', -1, 64) + extra.Unit
+}
+
+// PrintExtraSensors shows the other sensors, if the station has any
+func (data *WeatherData) PrintExtraSensors() {
+	if len(data.Extra) == 0 {
+		return
+	}
+	fmt.Println(tr(" Other sensors:"))
+	for _, name := range extraNames(data.Extra) {
+		fmt.Printf("   %s: %s\n", name, data.Extra[name])
+	}
+}
+
+// PrintExtraSensorsLite shows the other sensors on one line
+func (data *WeatherData) PrintExtraSensorsLite() {
+	if len(data.Extra) == 0 {
+		return
+	}
+	var readings []string
+	for _, name := range extraNames(data.Extra) {
+		readings = append(readings, name+" "+data.Extra[name].String())
+	}
+	fmt.Println(" ", " O:", strings.Join(readings, ", "))
+}
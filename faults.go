@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Fault injection, for checking that alerting, dashboards and whatnot cope when the
+// API goes sideways. Set with the hidden -inject-fault flag; it works on every call
+// to getWeatherInfoFromWeb, including the serve polls.
+var injectedFault string
+
+// injectableFaults describes each fault that -inject-fault knows
+var injectableFaults = map[string]string{
+	"api-timeout": "the API call times out",
+	"bad-json":    "the API answers with an HTML error page",
+	"partial":     "the API response is cut off halfway",
+}
+
+// hiddenFlags are left out of the usage message
+var hiddenFlags = map[string]bool{
+	"inject-fault": true,
+}
+
+// faultTimeout is a timeout that never went near the network
+type faultTimeout struct{}
+
+func (faultTimeout) Error() string   { return "injected fault: timeout awaiting response" }
+func (faultTimeout) Timeout() bool   { return true }
+func (faultTimeout) Temporary() bool { return true }
+
+// checkInjectedFault makes sure the -inject-fault value is one we know
+func checkInjectedFault() error {
+	if _, known := injectableFaults[injectedFault]; injectedFault != "" && !known {
+		var names []string
+		for name := range injectableFaults {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("Unknown fault %q, try one of %s", injectedFault, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// faultBeforeCall fails the API call before it is made, if that is the injected fault
+func faultBeforeCall(apiURL string) error {
+	if injectedFault == "api-timeout" {
+		return &url.Error{Op: "Post", URL: apiURL, Err: faultTimeout{}}
+	}
+	return nil
+}
+
+// faultAfterCall mangles the API response, if that is the injected fault
+func faultAfterCall(apiResponse []byte) []byte {
+	switch injectedFault {
+	case "bad-json":
+		return []byte("<html><head><title>502 Bad Gateway</title></head><body><h1>502 Bad Gateway</h1></body></html>\n")
+	case "partial":
+		return apiResponse[:len(apiResponse)/2]
+	}
+	return apiResponse
+}
+
+// usage is flag.PrintDefaults without the hidden flags
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+	flag.VisitAll(func(f *flag.Flag) {
+		if hiddenFlags[f.Name] {
+			return
+		}
+		name, usage := flag.UnquoteUsage(f)
+		line := "  -" + f.Name
+		if name != "" {
+			line += " " + name
+		}
+		line += "\n    \t" + strings.Replace(usage, "\n", "\n    \t", -1)
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			line += fmt.Sprintf(" (default %q)", f.DefValue)
+		}
+		fmt.Fprintln(flag.CommandLine.Output(), line)
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// FireWeather is the Fosberg Fire Weather Index for a station and its category
+type FireWeather struct {
+	Index    float64 `json:"index"`
+	Category string  `json:"category"`
+}
+
+// Category floors for the Fosberg index, highest first
+var fireCategories = []struct {
+	floor float64
+	name  string
+}{
+	{50, "extreme"},
+	{30, "very high"},
+	{20, "high"},
+	{10, "moderate"},
+	{0, "low"},
+}
+
+// equilibriumMoisture is the fuel moisture content (%) that fine dead fuels settle
+// to at a temperature in °F and relative humidity in %, per Simard (1968)
+func equilibriumMoisture(tempF, humidity float64) float64 {
+	switch {
+	case humidity < 10:
+		return 0.03229 + 0.281073*humidity - 0.000578*humidity*tempF
+	case humidity <= 50:
+		return 2.22749 + 0.160107*humidity - 0.01478*tempF
+	default:
+		return 21.0606 + 0.005565*humidity*humidity - 0.00035*humidity*tempF - 0.483199*humidity
+	}
+}
+
+// FosbergIndex combines temperature, humidity and wind into the Fosberg Fire
+// Weather Index, from 0 to 100
+func FosbergIndex(data *WeatherData, units *WeatherUnits) (fire FireWeather) {
+	tempF := fromCelsius(toCelsius(data.Temperature[0], units.Temperature[0]), "°F")
+	windMph := toMetersPerSecond(data.Windspeed[0], units.Windspeed[0]) / 0.44704
+
+	m := equilibriumMoisture(tempF, data.Humidity) / 30
+	eta := 1 - 2*m + 1.5*m*m - 0.5*m*m*m
+	fire.Index = math.Max(0, math.Min(100, eta*math.Sqrt(1+windMph*windMph)/0.3002))
+
+	for _, category := range fireCategories {
+		if fire.Index >= category.floor {
+			fire.Category = category.name
+			break
+		}
+	}
+	return fire
+}
+
+// fireAlert turns a very high or extreme fire weather index into an alert event
+func fireAlert(data *WeatherData) (event alertEvent, raised bool) {
+	if data.Fire == nil || data.Fire.Index < 30 {
+		return event, false
+	}
+	return alertEvent{
+		Station: data.Station[1],
+		Kind:    "fire",
+		Message: fmt.Sprintf("Fire weather index %.0f, %s", data.Fire.Index, data.Fire.Category),
+	}, true
+}
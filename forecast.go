@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+const (
+	// Open-Meteo needs no key. "forecast_url" in the config points somewhere else.
+	forecastDefaultURL = "https://api.open-meteo.com"
+	// How many hours ahead -forecast looks
+	forecastHours = 12
+)
+
+// ForecastHour is one hour of the forecast. Sky is the WMO weather code in words.
+type ForecastHour struct {
+	Time          time.Time `json:"time"`
+	Temperature   float64   `json:"temp"`
+	Precipitation int       `json:"precip_chance"`
+	Windspeed     float64   `json:"windspeed"`
+	WindDir       float64   `json:"winddir"`
+	Code          int       `json:"code"`
+	Sky           string    `json:"sky"`
+}
+
+// Forecast is the hourly outlook for my spot, in the units of the stations
+type Forecast struct {
+	Label string  `json:"label"`
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Units struct {
+		Temperature string `json:"temp"`
+		Windspeed   string `json:"windspeed"`
+	} `json:"units"`
+	Hours []ForecastHour `json:"hours"`
+}
+
+// weatherCodes are the WMO weather interpretation codes Open-Meteo uses, in words
+var weatherCodes = map[int]string{
+	0: "Clear", 1: "Mostly clear", 2: "Partly cloudy", 3: "Overcast",
+	45: "Fog", 48: "Freezing fog",
+	51: "Light drizzle", 53: "Drizzle", 55: "Heavy drizzle", 56: "Freezing drizzle", 57: "Freezing drizzle",
+	61: "Light rain", 63: "Rain", 65: "Heavy rain", 66: "Freezing rain", 67: "Freezing rain",
+	71: "Light snow", 73: "Snow", 75: "Heavy snow", 77: "Snow grains",
+	80: "Showers", 81: "Showers", 82: "Violent showers", 85: "Snow showers", 86: "Snow showers",
+	95: "Thunderstorm", 96: "Thunderstorm, hail", 99: "Thunderstorm, hail",
+}
+
+// forecastUnits are Open-Meteo's names for the station's temperature and wind units.
+// Units it has no name for get Celsius and km/h.
+func forecastUnits(units *WeatherUnits) (temperature, windspeed string) {
+	temperature = "celsius"
+	if strings.HasSuffix(strings.ToLower(html.UnescapeString(units.Temperature[0])), "f") {
+		temperature = "fahrenheit"
+	}
+	switch strings.ToLower(units.Windspeed[0]) {
+	case "mph":
+		windspeed = "mph"
+	case "m/s":
+		windspeed = "ms"
+	case "kt", "kts", "knots":
+		windspeed = "kn"
+	default:
+		windspeed = "kmh"
+	}
+	return temperature, windspeed
+}
+
+// forecastReference is the units to ask for the forecast in: the first station's or,
+// with no station to go by, ala the API answering [], the unit system's, see -units
+func forecastReference(system string, unitArr []WeatherUnits) *WeatherUnits {
+	if len(unitArr) > 0 {
+		return &unitArr[0]
+	}
+	var units WeatherUnits
+	if chosen, ok := unitSystems[system]; ok {
+		units.Temperature[0], units.Windspeed[0] = chosen.temperature, chosen.speed
+	}
+	return &units
+}
+
+// fetchForecast gets the next forecastHours hours for the spot from Open-Meteo
+func fetchForecast(c *configSettings, lat, lon float64, units *WeatherUnits) (forecast Forecast, err error) {
+	client, err := httpClient(c)
+	if err != nil {
+		return forecast, err
+	}
+	base := c.ForecastURL
+	if base == "" {
+		base = forecastDefaultURL
+	}
+	temperature, windspeed := forecastUnits(units)
+	forecastURL := fmt.Sprintf("%s/v1/forecast?latitude=%.4f&longitude=%.4f&hourly=temperature_2m,precipitation_probability,wind_speed_10m,wind_direction_10m,weather_code&forecast_hours=%d&timeformat=unixtime&temperature_unit=%s&wind_speed_unit=%s",
+		strings.TrimSuffix(base, "/"), lat, lon, forecastHours, temperature, windspeed)
+
+	request, err := http.NewRequest(http.MethodGet, forecastURL, nil)
+	if err != nil {
+		return forecast, err
+	}
+	request.Header.Set("User-Agent", userAgent())
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	logDebug("Calling", forecastURL)
+	response, err := client.Do(request)
+	if err != nil {
+		return forecast, err
+	}
+	defer response.Body.Close()
+	body, err := readResponse(response)
+	if err != nil {
+		return forecast, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return forecast, fmt.Errorf("Open-Meteo answered %s", response.Status)
+	}
+
+	var answer struct {
+		HourlyUnits struct {
+			Temperature string `json:"temperature_2m"`
+			Windspeed   string `json:"wind_speed_10m"`
+		} `json:"hourly_units"`
+		Hourly struct {
+			Time          []int64   `json:"time"`
+			Temperature   []float64 `json:"temperature_2m"`
+			Precipitation []int     `json:"precipitation_probability"`
+			Windspeed     []float64 `json:"wind_speed_10m"`
+			WindDir       []float64 `json:"wind_direction_10m"`
+			Code          []int     `json:"weather_code"`
+		} `json:"hourly"`
+	}
+	if err = json.Unmarshal(body, &answer); err != nil {
+		return forecast, err
+	}
+	hourly := answer.Hourly
+	if len(hourly.Temperature) < len(hourly.Time) || len(hourly.Precipitation) < len(hourly.Time) ||
+		len(hourly.Windspeed) < len(hourly.Time) || len(hourly.WindDir) < len(hourly.Time) || len(hourly.Code) < len(hourly.Time) {
+		return forecast, fmt.Errorf("Open-Meteo answered with hours missing")
+	}
+
+	forecast = Forecast{Label: "forecast", Lat: lat, Lon: lon}
+	forecast.Units.Temperature = answer.HourlyUnits.Temperature
+	// Open-Meteo spells miles an hour its own way
+	forecast.Units.Windspeed = strings.Replace(answer.HourlyUnits.Windspeed, "mp/h", "mph", 1)
+	for i := range hourly.Time {
+		forecast.Hours = append(forecast.Hours, ForecastHour{
+			Time:          time.Unix(hourly.Time[i], 0),
+			Temperature:   hourly.Temperature[i],
+			Precipitation: hourly.Precipitation[i],
+			Windspeed:     hourly.Windspeed[i],
+			WindDir:       hourly.WindDir[i],
+			Code:          hourly.Code[i],
+			Sky:           weatherCodes[hourly.Code[i]],
+		})
+	}
+	return forecast, nil
+}
+
+// PrintForecast shows the forecast an hour a line, ala
+// " 15:00  84°F  20% rain   9mph ↙ NE  Partly cloudy"
+func (forecast *Forecast) PrintForecast() {
+	fmt.Printf("Forecast for %.2f,%.2f\n", forecast.Lat, forecast.Lon)
+	for _, hour := range forecast.Hours {
+		short, _ := heading(hour.WindDir)
+		fmt.Printf(" %s %4.0f%s %3d%% rain %4.0f%s %s %-3s %s\n", hour.Time.Local().Format("15:04"), hour.Temperature, forecast.Units.Temperature,
+			hour.Precipitation, hour.Windspeed, forecast.Units.Windspeed, windArrow(hour.WindDir), short, hour.Sky)
+	}
+}
+
+// PrintForecastJSON shows the forecast as one line of JSON
+func (forecast *Forecast) PrintForecastJSON() {
+	jdata, err := marshalJSON(forecast)
+	if err != nil {
+		logError("Cannot marshal the forecast", err)
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// Hours of history used for the temperature trend
+	frostTrendHours = 3
+	// Winds under this (m/s) let the ground radiate its heat away
+	frostCalmWind = 2.2
+	// Frost forms on grass and windshields well before the air at 2m hits freezing
+	frostLikelyLow   = 1.0 // °C
+	frostPossibleLow = 3.0 // °C
+)
+
+// FrostRisk is the overnight frost assessment for a station. Low is the guess
+// at the overnight minimum, in the station's temperature unit.
+type FrostRisk struct {
+	Level  string  `json:"level"`
+	Low    float64 `json:"low"`
+	Reason string  `json:"reason"`
+}
+
+// temperatureTrend fits a line through the recent temperatures and returns the slope
+// in °C per hour. It needs at least an hour's worth of readings to say anything.
+func temperatureTrend(records []historyRecord) (perHour float64, ok bool) {
+	if len(records) < 2 || records[len(records)-1].Time.Sub(records[0].Time) < time.Hour {
+		return 0, false
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	start := records[0].Time
+	for _, rec := range records {
+		x := rec.Time.Sub(start).Hours()
+		y := toCelsius(rec.Data.Temperature[0], rec.Units.Temperature[0])
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(records))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
+
+// clearSkyRatio compares the solar sensor to what a clear sky would give at that spot
+// and time. It only knows when the sun is decently up; ok is false otherwise.
+func clearSkyRatio(data *WeatherData, when time.Time) (ratio float64, ok bool) {
+	utc := when.UTC()
+	lat := data.StationTopo.Lat * math.Pi / 180
+	declination := 23.44 * math.Pi / 180 * math.Sin(2*math.Pi*float64(284+utc.YearDay())/365)
+	solarTime := float64(utc.Hour()) + float64(utc.Minute())/60 + data.StationTopo.Lon/15
+	hourAngle := 15 * (solarTime - 12) * math.Pi / 180
+	sinElevation := math.Sin(lat)*math.Sin(declination) + math.Cos(lat)*math.Cos(declination)*math.Cos(hourAngle)
+	if sinElevation < math.Sin(10*math.Pi/180) {
+		return 0, false
+	}
+	// Haurwitz clear sky model
+	clear := 1098 * sinElevation * math.Exp(-0.057/sinElevation)
+	return data.Sun[0] / clear, true
+}
+
+// hoursToDawn is roughly how long the night has left to cool, counting to 6am local
+func hoursToDawn(when time.Time) float64 {
+	local := when.Local()
+	dawn := time.Date(local.Year(), local.Month(), local.Day(), 6, 0, 0, 0, time.Local)
+	if !local.Before(dawn) {
+		dawn = dawn.AddDate(0, 0, 1)
+	}
+	return dawn.Sub(local).Hours()
+}
+
+// AssessFrost guesses the overnight low from the dewpoint and the recent trend, then
+// bumps the risk around for wind and cloud. Overnight lows tend to bottom out near the
+// evening dewpoint, since condensing dew gives back heat.
+func AssessFrost(data *WeatherData, units *WeatherUnits, recent []historyRecord, when time.Time) (risk FrostRisk) {
+	temp := toCelsius(data.Temperature[0], units.Temperature[0])
+	dewpoint := toCelsius(data.Temperature[1], units.Temperature[1])
+	wind := toMetersPerSecond(data.Windspeed[0], units.Windspeed[0])
+
+	low := dewpoint
+	reason := fmt.Sprintf("dewpoint spread %.1f°C", temp-dewpoint)
+	if rate, ok := temperatureTrend(recent); ok && rate < 0 {
+		low = math.Max(temp+rate*hoursToDawn(when), dewpoint-2)
+		reason += fmt.Sprintf(", cooling %.1f°C/h", -rate)
+	}
+	low = math.Min(low, temp)
+
+	// 0 is unlikely, 1 is possible, 2 is likely
+	level := 0
+	if low <= frostLikelyLow {
+		level = 2
+	} else if low <= frostPossibleLow {
+		level = 1
+	}
+	if wind >= frostCalmWind {
+		level--
+		reason += ", breezy"
+	} else {
+		reason += ", calm"
+	}
+	if ratio, ok := clearSkyRatio(data, when); ok && ratio < 0.5 {
+		level--
+		reason += ", cloudy"
+	}
+
+	risk.Level = [...]string{"unlikely", "possible", "likely"}[int(math.Max(0, float64(level)))]
+	risk.Reason = reason
+	risk.Low = fromCelsius(low, units.Temperature[0])
+	return risk
+}
+
+// frostAlert turns a likely frost into an alert event
+func frostAlert(data *WeatherData, units *WeatherUnits) (event alertEvent, raised bool) {
+	if data.Frost == nil || data.Frost.Level != "likely" {
+		return event, false
+	}
+	return alertEvent{
+		Station: data.Station[1],
+		Kind:    "frost",
+		Message: fmt.Sprintf("Frost likely tonight, low near %.0f%s (%s)", data.Frost.Low, units.Temperature[0], data.Frost.Reason),
+	}, true
+}
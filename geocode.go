@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	json "github.com/json-iterator/go"
+	haversine "github.com/loraxipam/havers2"
+)
+
+// geocodeDefaultURL is OpenStreetMap's Nominatim. "geocode_url" in the config points at
+// another Nominatim, ala one of your own.
+const geocodeDefaultURL = "https://nominatim.openstreetmap.org"
+
+// meSettings is where I am, "me" in the config: the coordinates, or an address to look
+// them up from, ala {"address": "123 Ocean Ave, Daytona Beach FL"}. Coordinates win.
+type meSettings struct {
+	haversine.Coord
+	Address string `json:"address,omitempty"`
+}
+
+// geocodedAddress is an address Nominatim has found, as the geocode cache keeps it
+type geocodedAddress struct {
+	Address string  `json:"address"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Found   string  `json:"found"`
+}
+
+// geocodeFile is where the found addresses are kept, next to the cached API response
+func geocodeFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "weatherstem", "geocode.json")
+}
+
+// geocode finds an address, once. Nominatim asks for no more than a call a second and
+// the address is not going anywhere, so what it says is cached for good.
+func geocode(c *configSettings, address string) (lat, lon float64, err error) {
+	address = strings.TrimSpace(address)
+	var cached []geocodedAddress
+	filename := geocodeFile()
+	if cacheJSON, err := ioutil.ReadFile(filename); err == nil {
+		json.Unmarshal(cacheJSON, &cached)
+	}
+	for _, place := range cached {
+		if strings.EqualFold(place.Address, address) {
+			return place.Lat, place.Lon, nil
+		}
+	}
+
+	client, err := httpClient(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	base := c.GeocodeURL
+	if base == "" {
+		base = geocodeDefaultURL
+	}
+	geocodeURL := strings.TrimSuffix(base, "/") + "/search?format=json&limit=1&q=" + url.QueryEscape(address)
+
+	request, err := http.NewRequest(http.MethodGet, geocodeURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	request.Header.Set("User-Agent", userAgent())
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	logDebug("Calling", geocodeURL)
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer response.Body.Close()
+	body, err := readResponse(response)
+	if err != nil {
+		return 0, 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("Nominatim answered %s", response.Status)
+	}
+
+	// Nominatim has the coordinates as strings
+	var places []struct {
+		Lat  string `json:"lat"`
+		Lon  string `json:"lon"`
+		Name string `json:"display_name"`
+	}
+	if err = json.Unmarshal(body, &places); err != nil {
+		return 0, 0, err
+	}
+	if len(places) == 0 {
+		return 0, 0, fmt.Errorf("Nominatim cannot find %q", address)
+	}
+	lat, err = strconv.ParseFloat(places[0].Lat, 64)
+	if err == nil {
+		lon, err = strconv.ParseFloat(places[0].Lon, 64)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("Nominatim gave no coordinates for %q: %v", address, err)
+	}
+	logInfo(fmt.Sprintf("Found %q at %.4f,%.4f, %s", address, lat, lon, places[0].Name))
+
+	if filename != "" {
+		cached = append(cached, geocodedAddress{Address: address, Lat: lat, Lon: lon, Found: places[0].Name})
+		cacheJSON, err := json.Marshal(cached)
+		if err == nil {
+			err = replaceFile(filename, cacheJSON)
+		}
+		if err != nil {
+			logWarn("Cannot cache the address.", err)
+		}
+	}
+	return lat, lon, nil
+}
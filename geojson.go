@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"html"
+)
+
+// GeoJSON types, just enough for a FeatureCollection of Points
+type geoFeatureCollection struct {
+	Type     string       `json:"type"`
+	Features []geoFeature `json:"features"`
+}
+
+type geoFeature struct {
+	Type       string        `json:"type"`
+	Geometry   geoPoint      `json:"geometry"`
+	Properties geoProperties `json:"properties"`
+}
+
+type geoPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// geoProperties are a station's readings, flat, with their units alongside
+type geoProperties struct {
+	Handle        string  `json:"handle"`
+	Name          string  `json:"name"`
+	Time          string  `json:"time"`
+	Temp          float64 `json:"temp"`
+	TempUnit      string  `json:"temp_unit"`
+	Dewpoint      float64 `json:"dewpoint"`
+	WBGT          float64 `json:"wbgt"`
+	Humidity      float64 `json:"humidity"`
+	Windspeed     float64 `json:"windspeed"`
+	Gust          float64 `json:"gust"`
+	WindUnit      string  `json:"wind_unit"`
+	WindDir       float64 `json:"winddir"`
+	Wind          string  `json:"wind"`
+	Pressure      float64 `json:"pressure"`
+	PressureUnit  string  `json:"pressure_unit"`
+	PressureTrend string  `json:"ptrend"`
+	Rain          float64 `json:"rain"`
+	RainRate      float64 `json:"rainrate"`
+	RainUnit      string  `json:"rain_unit"`
+	Solar         float64 `json:"solar"`
+	Distance      float64 `json:"distance"`
+	DistanceUnit  string  `json:"distance_unit"`
+	AQI           *int    `json:"aqi,omitempty"`
+	AQICategory   string  `json:"aqi_category,omitempty"`
+	AQIColor      string  `json:"aqi_color,omitempty"`
+}
+
+// StationsGeoJSON puts each station on the map as a Point, with its current readings
+// as flat properties so Leaflet and QGIS can style on them directly
+func StationsGeoJSON(dataArr []WeatherData, unitArr []WeatherUnits) (collection geoFeatureCollection) {
+	collection = geoFeatureCollection{Type: "FeatureCollection", Features: []geoFeature{}}
+	for i := range dataArr {
+		data, units := &dataArr[i], &unitArr[i]
+		feature := geoFeature{
+			Type:     "Feature",
+			Geometry: geoPoint{Type: "Point", Coordinates: [2]float64{data.StationTopo.Lon, data.StationTopo.Lat}},
+			Properties: geoProperties{
+				Handle:        data.Station[0],
+				Name:          data.Station[1],
+				Time:          data.Station[2],
+				Temp:          data.Temperature[0],
+				TempUnit:      html.UnescapeString(units.Temperature[0]),
+				Dewpoint:      data.Temperature[1],
+				WBGT:          data.Temperature[2],
+				Humidity:      data.Humidity,
+				Windspeed:     data.Windspeed[0],
+				Gust:          data.Windspeed[1],
+				WindUnit:      units.Windspeed[0],
+				WindDir:       data.Windspeed[2],
+				Wind:          data.Wind[0],
+				Pressure:      data.Pressure,
+				PressureUnit:  units.Pressure,
+				PressureTrend: data.PressureTrend,
+				Rain:          data.Rain[0],
+				RainRate:      data.Rain[1],
+				RainUnit:      units.Rain[0],
+				Solar:         data.Sun[0],
+				Distance:      data.StationDist,
+				DistanceUnit:  units.StationDist,
+			},
+		}
+		if data.AirQuality != nil {
+			feature.Properties.AQI = &data.AirQuality.AQI
+			feature.Properties.AQICategory = data.AirQuality.Category
+			feature.Properties.AQIColor = data.AirQuality.Color
+		}
+		collection.Features = append(collection.Features, feature)
+	}
+	return collection
+}
+
+// PrintGeoJSON shows the stations as a GeoJSON FeatureCollection
+func PrintGeoJSON(dataArr []WeatherData, unitArr []WeatherUnits) {
+	jdata, err := marshalJSON(StationsGeoJSON(dataArr, unitArr))
+	if err != nil {
+		logError("Cannot marshal GeoJSON", err)
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
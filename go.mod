@@ -3,7 +3,15 @@ module github.com/loraxipam/weatherstem-cli
 go 1.14
 
 require (
+	github.com/golang/protobuf v1.4.3
 	github.com/json-iterator/go v1.1.10
 	github.com/loraxipam/compassrose v0.0.0-20200519203256-18341d88b08c
 	github.com/loraxipam/havers2 v0.0.0-20200701194559-c3960ce57f89
+	github.com/mattn/go-sqlite3 v1.14.0
+	github.com/segmentio/kafka-go v0.3.7
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/crypto v0.0.0-20190506204251-e1dfcc566284
+	golang.org/x/text v0.3.0
+	google.golang.org/grpc v1.33.2
+	google.golang.org/protobuf v1.25.0
 )
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSink("graphite", func(target string) (outputSink, error) {
+		if target == "" {
+			return nil, fmt.Errorf("graphite needs a host:port")
+		}
+		return graphiteSink(target), nil
+	})
+}
+
+// graphiteSink writes Carbon plaintext lines over TCP, ala
+// weatherstem.ponceinlet.temp 84.2 1602763200
+type graphiteSink string
+
+// graphiteName makes a name safe for a Graphite path
+func graphiteName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ' ', '/', '@':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// graphiteLines are a station's metrics as Carbon plaintext
+func graphiteLines(prefix string, data *WeatherData, units *WeatherUnits, when time.Time) []byte {
+	if reading, ok := readingTime(data); ok {
+		when = reading
+	}
+	var lines bytes.Buffer
+	path := prefix + "." + graphiteName(data.Station[0]) + "."
+	for _, metric := range stationMetrics(data, units) {
+		fmt.Fprintf(&lines, "%s%s %s %d\n", path, metric.Name, strconv.FormatFloat(metric.Value, 'f', -1, 64), when.Unix())
+	}
+	return lines.Bytes()
+}
+
+func (address graphiteSink) Send(config *configSettings, batch *sinkBatch) error {
+	prefix := config.GraphitePrefix
+	if prefix == "" {
+		prefix = "weatherstem"
+	}
+	var lines bytes.Buffer
+	for i := range batch.Data {
+		lines.Write(graphiteLines(prefix, &batch.Data[i], &batch.Units[i], batch.Time))
+	}
+	conn, err := net.DialTimeout("tcp", string(address), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = conn.Write(lines.Bytes())
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	json "github.com/json-iterator/go"
+)
+
+// outputModes are the flags a station group may pick as its way of output
+var outputModes = []string{"json", "ndjson", "json-array", "yaml", "toml", "geojson", "kml", "lite", "table",
+	"markdown", "html", "statusbar", "marine", "heat-report"}
+
+// stationGroup is a named set of stations for one use, and how to show them when the
+// command line does not say. In the config it is either just the stations, ala
+// "coast": ["ponceinlet", "Beachside"], or
+// "coast": {"stations": ["ponceinlet", "Beachside"], "output": "marine"}.
+// Stations are by handle or alias of a configured station, or handle@domain for others.
+type stationGroup struct {
+	Stations []string `json:"stations"`
+	Output   string   `json:"output,omitempty"`
+}
+
+// UnmarshalJSON takes a group as its list of stations, or as the whole thing
+func (group *stationGroup) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &group.Stations); err == nil {
+		return nil
+	}
+	type plain stationGroup
+	return json.Unmarshal(data, (*plain)(group))
+}
+
+// groupNames lists the config's groups in order
+func (config *configSettings) groupNames() (names []string) {
+	for name := range config.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectGroup swaps the configured stations for the group's, and turns its output mode on
+// unless an output flag was given
+func selectGroup(c *configSettings, name string) (err error) {
+	if name == "" {
+		return nil
+	}
+	group, ok := c.Groups[name]
+	if !ok {
+		return fmt.Errorf("group is one of %v, not %q", c.groupNames(), name)
+	}
+	if c.Stations, err = pickStations(c, group.Stations); err != nil {
+		return fmt.Errorf("group %s: %v", name, err)
+	}
+	if group.Output == "" {
+		return nil
+	}
+	chosen := false
+	flag.Visit(func(f *flag.Flag) {
+		for _, mode := range outputModes {
+			chosen = chosen || f.Name == mode
+		}
+	})
+	for _, mode := range outputModes {
+		if mode == group.Output {
+			if !chosen {
+				flag.Set(mode, "true")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("group %s: output is one of %s, not %q", name, strings.Join(outputModes, ", "), group.Output)
+}
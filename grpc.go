@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/loraxipam/weatherstem-cli/weatherpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcWeather serves the weatherpb.Weather service from the polling loop. Tokens work
+// as they do over HTTP, sent as "authorization: Bearer ..." metadata, with the method
+// name, ala /weatherstem.Weather/GetCurrent, as the endpoint.
+type grpcWeather struct {
+	weatherpb.UnimplementedWeatherServer
+	ws *weatherServer
+}
+
+// protoReport converts a station report for the wire
+func protoReport(report *stationReport) *weatherpb.StationReport {
+	data, units := &report.Data, &report.Units
+	return &weatherpb.StationReport{
+		Data: &weatherpb.WeatherData{
+			Label:         data.Label,
+			Station:       data.Station[:],
+			Topo:          &weatherpb.Coord{Lat: data.StationTopo.Lat, Lon: data.StationTopo.Lon},
+			Distance:      data.StationDist,
+			Temperature:   data.Temperature[:],
+			Humidity:      data.Humidity,
+			Windspeed:     data.Windspeed[:],
+			Wind:          data.Wind[:],
+			Pressure:      data.Pressure,
+			PressureTrend: data.PressureTrend,
+			Rain:          data.Rain[:],
+			Sun:           data.Sun[:],
+		},
+		Units: &weatherpb.WeatherUnits{
+			Label:         units.Label,
+			Station:       units.Station[:],
+			Distance:      units.StationDist,
+			Temperature:   units.Temperature[:],
+			Humidity:      units.Humidity,
+			Windspeed:     units.Windspeed[:],
+			Wind:          units.Wind[:],
+			Pressure:      units.Pressure,
+			PressureTrend: units.PressureTrend,
+			Rain:          units.Rain[:],
+			Sun:           units.Sun[:],
+		},
+	}
+}
+
+// authorize finds the caller's token, like weatherServer.authorize does for HTTP
+func (g *grpcWeather) authorize(ctx context.Context, method string) (token *accessToken, err error) {
+	var given string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			given = strings.TrimPrefix(values[0], "Bearer ")
+		}
+	}
+	token, ok := g.ws.findToken(given)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unknown or missing token")
+	}
+	if token != nil && !token.allowsEndpoint(method) {
+		return nil, status.Error(codes.PermissionDenied, "Token not allowed here")
+	}
+	return token, nil
+}
+
+// currentReply is the latest poll, cut down to the stations asked for and allowed
+func (g *grpcWeather) currentReply(token *accessToken, request *weatherpb.CurrentRequest) *weatherpb.CurrentReply {
+	wanted := make(map[string]bool)
+	for _, station := range request.Stations {
+		wanted[stationHandle(station)] = true
+	}
+	g.ws.mutex.RLock()
+	defer g.ws.mutex.RUnlock()
+	reply := &weatherpb.CurrentReply{PolledUnix: g.ws.polled.Unix()}
+	for i := range g.ws.report {
+		handle := g.ws.report[i].Data.Station[0]
+		if token != nil && !token.allowsStation(handle) {
+			continue
+		}
+		if len(wanted) == 0 || wanted[handle] {
+			reply.Stations = append(reply.Stations, protoReport(&g.ws.report[i]))
+		}
+	}
+	return reply
+}
+
+// GetCurrent answers with the latest poll
+func (g *grpcWeather) GetCurrent(ctx context.Context, request *weatherpb.CurrentRequest) (*weatherpb.CurrentReply, error) {
+	token, err := g.authorize(ctx, "/weatherstem.Weather/GetCurrent")
+	if err != nil {
+		return nil, err
+	}
+	return g.currentReply(token, request), nil
+}
+
+// StreamUpdates sends the latest poll, then each new one until the caller hangs up or
+// the server stops
+func (g *grpcWeather) StreamUpdates(request *weatherpb.CurrentRequest, stream weatherpb.Weather_StreamUpdatesServer) error {
+	token, err := g.authorize(stream.Context(), "/weatherstem.Weather/StreamUpdates")
+	if err != nil {
+		return err
+	}
+	for {
+		g.ws.mutex.RLock()
+		updated := g.ws.updated
+		g.ws.mutex.RUnlock()
+		if err = stream.Send(g.currentReply(token, request)); err != nil {
+			return err
+		}
+		select {
+		case <-updated:
+		case <-stream.Context().Done():
+			return nil
+		case <-g.ws.stop:
+			return nil
+		}
+	}
+}
+
+// serveGRPC starts the gRPC service on addr alongside the HTTP one
+func serveGRPC(ws *weatherServer, addr string) (stop func(), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer()
+	weatherpb.RegisterWeatherServer(server, &grpcWeather{ws: ws})
+	go server.Serve(listener)
+
+	// Watchers hang on until they hang up, so they get as long as the HTTP requests do
+	stop = func() {
+		done := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			server.Stop()
+		}
+	}
+	return stop, nil
+}
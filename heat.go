@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// HeatGuidance is what to do at a WBGT flag level, ala a work/rest ratio of "45/15 min"
+// and "3/4 quart an hour" to drink
+type HeatGuidance struct {
+	WorkRest  string `json:"work_rest"`
+	Hydration string `json:"hydration"`
+}
+
+// heatGuidanceDefaults go by the flag levels, see wbgtLevel, for hard practice in full
+// gear. "heat_guidance" in the config replaces any of them with the local table.
+var heatGuidanceDefaults = map[string]HeatGuidance{
+	"normal":  {WorkRest: "No limit, 3 breaks an hour", Hydration: "Drink as you like, 1/2 quart an hour"},
+	"Level 1": {WorkRest: "50/10 min", Hydration: "3/4 quart an hour, water on every break"},
+	"Level 2": {WorkRest: "45/15 min, helmets off on breaks", Hydration: "3/4 quart an hour, water on every break"},
+	"Level 3": {WorkRest: "30/30 min, no pads", Hydration: "1 quart an hour, ice towels on the sideline"},
+	"Level 4": {WorkRest: "20/40 min, or move it indoors", Hydration: "1 quart an hour, no more than 1 1/2"},
+}
+
+// heatGuidance is the config's guidance for a level, or the default for any it leaves out
+func (config *configSettings) heatGuidance(level string) HeatGuidance {
+	if guidance, ok := config.HeatGuidance[level]; ok {
+		return guidance
+	}
+	return heatGuidanceDefaults[level]
+}
+
+// HeatReport is what an athletic trainer wants to know about a station: the WBGT, its
+// flag and what that means for practice, and how hot it got today
+type HeatReport struct {
+	Label     string    `json:"label"`
+	Handle    string    `json:"station"`
+	Name      string    `json:"name"`
+	WBGT      float64   `json:"wbgt"`
+	HeatIndex float64   `json:"heatindex"`
+	Unit      string    `json:"unit"`
+	Level     string    `json:"level"`
+	WorkRest  string    `json:"work_rest"`
+	Hydration string    `json:"hydration"`
+	Peak      float64   `json:"peak_wbgt"`
+	PeakTime  time.Time `json:"peak_time"`
+}
+
+// wbgtFahrenheit is the station's WBGT in °F, which the flag levels are in
+func (data *WeatherData) wbgtFahrenheit(units *WeatherUnits) float64 {
+	fahrenheit, _ := convertUnit(data.Temperature[2], units.Temperature[2], "°F")
+	return fahrenheit
+}
+
+// NewHeatReport works out the heat report for a station. The peak is the highest WBGT
+// since local midnight in its history, or this reading when it is hotter. The flag levels
+// are in °F, so stations in °C are converted to find theirs.
+func NewHeatReport(config *configSettings, data *WeatherData, units *WeatherUnits, records []historyRecord, now time.Time) (report HeatReport) {
+	local := now.Local()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+	fahrenheit := data.wbgtFahrenheit(units)
+	report = HeatReport{
+		Label:     "heatreport",
+		Handle:    data.Station[0],
+		Name:      data.Station[1],
+		WBGT:      data.Temperature[2],
+		HeatIndex: data.Temperature[4],
+		Unit:      units.Temperature[2],
+		Level:     wbgtLevel(fahrenheit),
+		Peak:      data.Temperature[2],
+		PeakTime:  now,
+	}
+	guidance := config.heatGuidance(report.Level)
+	report.WorkRest, report.Hydration = guidance.WorkRest, guidance.Hydration
+	for i := range records {
+		if records[i].Time.Before(midnight) || records[i].Time.After(now) {
+			continue
+		}
+		if wbgt, _ := convertUnit(records[i].Data.Temperature[2], records[i].Units.Temperature[2], units.Temperature[2]); wbgt > report.Peak {
+			report.Peak, report.PeakTime = wbgt, records[i].Time
+		}
+	}
+	return report
+}
+
+// PrintHeatReport shows the heat report a few lines a station, ala
+// "Ponce Inlet (ponceinlet)  WBGT 85.1°F ⚊ Level 1, heat index 92.3°F"
+func (report *HeatReport) PrintHeatReport() {
+	peak, _ := convertUnit(report.Peak, report.Unit, "°F")
+	wbgt, _ := convertUnit(report.WBGT, report.Unit, "°F")
+	fmt.Printf("%s (%s)  WBGT %.1f%s %s %s, heat index %.1f%s\n", report.Name, report.Handle, report.WBGT, report.Unit, WBGTFlag(wbgt), report.Level, report.HeatIndex, report.Unit)
+	fmt.Printf("  Work/rest: %s\n", report.WorkRest)
+	fmt.Printf("  Hydration: %s\n", report.Hydration)
+	fmt.Printf("  Today's peak: WBGT %.1f%s %s at %s\n", report.Peak, report.Unit, WBGTFlag(peak), report.PeakTime.Local().Format("15:04"))
+}
+
+// PrintHeatReportJSON shows the heat report as one line of JSON
+func (report *HeatReport) PrintHeatReportJSON() {
+	jdata, err := marshalJSON(report)
+	if err != nil {
+		logError("Cannot marshal the heat report", err)
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	haversine "github.com/loraxipam/havers2"
+	"github.com/loraxipam/weatherstem-cli/weatherjson"
+)
+
+// Closer than this (km) and a station is simply taken as being here
+const hereSameSpot = 0.05
+
+// InterpolateHere estimates the conditions at my location from the stations around me,
+// weighting each by the inverse square of its distance. Wind is averaged as a vector.
+// The result looks like any other station, called HERE, in the first station's units.
+func InterpolateHere(dataArr []WeatherData, unitArr []WeatherUnits, me haversine.Coord) (here WeatherData, hereUnits WeatherUnits) {
+	if len(dataArr) == 0 {
+		return here, hereUnits
+	}
+	// Work on copies, since harmonizing converts in place
+	data := append([]WeatherData(nil), dataArr...)
+	units := append([]WeatherUnits(nil), unitArr...)
+	harmonizeUnits(data, units)
+
+	weights := make([]float64, len(data))
+	var total float64
+	for i := range data {
+		distance := haversine.DistanceKm(me, data[i].StationTopo)
+		if distance < hereSameSpot {
+			// Right on top of a station, so no need to guess
+			for j := range weights {
+				weights[j] = 0
+			}
+			weights[i], total = 1, 1
+			break
+		}
+		weights[i] = 1 / (distance * distance)
+		total += weights[i]
+	}
+
+	var east, north float64
+	var handles []string
+	for i := range data {
+		if weights[i] > 0 {
+			handles = append(handles, data[i].Station[0])
+		}
+		w := weights[i] / total
+		for t := range here.Temperature {
+			here.Temperature[t] += w * data[i].Temperature[t]
+		}
+		here.Humidity += w * data[i].Humidity
+		here.Pressure += w * data[i].Pressure
+		here.Windspeed[1] += w * data[i].Windspeed[1]
+		here.Rain[1] += w * data[i].Rain[1]
+		direction := data[i].Windspeed[2] * math.Pi / 180
+		east += w * data[i].Windspeed[0] * math.Sin(direction)
+		north += w * data[i].Windspeed[0] * math.Cos(direction)
+	}
+	here.Windspeed[0] = math.Hypot(east, north)
+	here.Windspeed[2] = math.Round(math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360))
+	here.Wind[0], here.Wind[1] = heading(here.Windspeed[2])
+
+	here.Label, here.Schema = "data", weatherjson.Schema
+	here.Station = [3]string{"here", "HERE", time.Now().Format("2006-01-02 15:04:05")}
+	here.StationTopo = me
+	if data[0].Provenance != nil {
+		here.Provenance = &Provenance{
+			Source:          data[0].Provenance.Source,
+			Fetched:         data[0].Provenance.Fetched,
+			Tool:            data[0].Provenance.Tool,
+			Transformations: []string{"inverse-distance weighted from " + strings.Join(handles, ", ")},
+		}
+	}
+	hereUnits = units[0]
+	hereUnits.Station = here.Station
+	return here, hereUnits
+}
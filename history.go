@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// historyRecord is one cooked station observation as the history keeps it, one
+// per station per run, whichever store holds it. See historyStore.
+type historyRecord struct {
+	Schema int          `json:"schema,omitempty"`
+	Time   time.Time    `json:"time"`
+	Data   WeatherData  `json:"data"`
+	Units  WeatherUnits `json:"units"`
+}
+
+// historySchema is the shape of the records written now. Bump it when a change to the
+// cooked data means old records need converting, and teach upgrade how.
+// Schema 0 is the records from before there was a schema, which are the same as 1.
+const historySchema = 1
+
+// upgrade brings a record from an older schema up to this one. Records from a newer
+// weatherstem are refused rather than half read.
+func (rec *historyRecord) upgrade() error {
+	if rec.Schema > historySchema {
+		return fmt.Errorf("history record schema %d is newer than this weatherstem knows (%d)", rec.Schema, historySchema)
+	}
+	rec.Schema = historySchema
+	return nil
+}
+
+// newHistoryRecords are this run's records, one per station
+func newHistoryRecords(when time.Time, dataArr []WeatherData, unitArr []WeatherUnits) (records []historyRecord) {
+	for i := range dataArr {
+		// the UCUM codes follow from the symbols, so history keeps just those, and the
+		// records have a schema of their own
+		data, units := dataArr[i], unitArr[i]
+		data.Schema, units.Schema, units.UCUM = "", "", nil
+		records = append(records, historyRecord{Schema: historySchema, Time: when, Data: data, Units: units})
+	}
+	return records
+}
+
+// errNoHistory is what the history subcommands say when there is nothing to read
+var errNoHistory = errors.New("No history file in config. Add \"history\": \"~/.weatherstem-history.jsonl\" and run a while.")
+
+// expandHome turns a leading "~/" into the user's HOME directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, exists := os.LookupEnv("HOME"); exists {
+			return home + path[1:]
+		}
+	}
+	return path
+}
+
+// historyStore keeps cooked observations between runs. Pick one with "store" in the
+// config's "history" block: "sqlite", "bolt" or "jsonl". Left out, it goes by the file
+// extension, .jsonl for JSON lines, .bolt for BoltDB and SQLite for anything else.
+// JSON lines is the one to use on NFS shares, since the others want file locking.
+type historyStore interface {
+	Insert(records []historyRecord) error
+	Read(since time.Time) ([]historyRecord, error)
+	Compact(policy retentionPolicy) (removed int, err error)
+	Close() error
+}
+
+// openHistory opens the configured history store
+func openHistory(config *configSettings) (historyStore, error) {
+	historyFile := expandHome(config.History.File)
+	kind := config.History.Store
+	if kind == "" {
+		switch filepath.Ext(historyFile) {
+		case ".jsonl", ".json":
+			kind = "jsonl"
+		case ".bolt":
+			kind = "bolt"
+		default:
+			kind = "sqlite"
+		}
+	}
+
+	switch kind {
+	case "jsonl":
+		return &jsonlStore{filename: historyFile}, nil
+	case "bolt":
+		return openBoltStore(historyFile)
+	case "sqlite":
+		return openSQLiteStore(historyFile)
+	default:
+		return nil, fmt.Errorf("Unknown history store %q, use sqlite, bolt or jsonl", kind)
+	}
+}
+
+// appendHistory adds the cooked data for every station to the configured history
+func appendHistory(config *configSettings, when time.Time, dataArr []WeatherData, unitArr []WeatherUnits) (err error) {
+	store, err := openHistory(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	return store.Insert(newHistoryRecords(when, dataArr, unitArr))
+}
+
+// readHistory returns the history records newer than since, oldest first
+func readHistory(config *configSettings, since time.Time) (records []historyRecord, err error) {
+	store, err := openHistory(config)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	return store.Read(since)
+}
+
+// jsonlStore is the flat file history, one JSON record per line
+type jsonlStore struct {
+	filename string
+}
+
+// lock keeps a run appending and another compacting from losing records between them,
+// ala cron and serve on the same file. Where the file system will not lock, ala some NFS
+// mounts, it goes on without, as it always has.
+func (store *jsonlStore) lock() (unlock func()) {
+	unlock, err := lockFile(store.filename + ".lock")
+	if err != nil {
+		logWarn("Cannot lock the history, going on without.", err)
+		return func() {}
+	}
+	return unlock
+}
+
+// Insert adds the records to the end of the file
+func (store *jsonlStore) Insert(records []historyRecord) (err error) {
+	defer store.lock()()
+	writeFile, err := os.OpenFile(store.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer writeFile.Close()
+
+	for i := range records {
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		}
+		if _, err = writeFile.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read scans the whole file for records newer than since.
+// Lines that do not unmarshal are skipped, since a cron job killed mid-write
+// should not make the whole history unusable, and so are records from a newer schema.
+func (store *jsonlStore) Read(since time.Time) (records []historyRecord, err error) {
+	readFile, err := os.Open(store.filename)
+	if err != nil {
+		return nil, err
+	}
+	defer readFile.Close()
+
+	scanner := bufio.NewScanner(readFile)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec historyRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil || rec.upgrade() != nil {
+			continue
+		}
+		if rec.Time.Before(since) {
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	return records, scanner.Err()
+}
+
+// Compact rewrites the file without the records the policy drops, if it drops any.
+// Lines that do not unmarshal stay, as they are none of its business.
+func (store *jsonlStore) Compact(policy retentionPolicy) (removed int, err error) {
+	defer store.lock()()
+	content, err := ioutil.ReadFile(store.filename)
+	if err != nil {
+		return 0, err
+	}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	var times []time.Time
+	var stations []string
+	var records []int
+	for i, line := range lines {
+		var rec struct {
+			Time time.Time `json:"time"`
+			Data struct {
+				Station [3]string `json:"stations"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(line, &rec) == nil {
+			times = append(times, rec.Time)
+			stations = append(stations, rec.Data.Station[0])
+			records = append(records, i)
+		}
+	}
+
+	keep := policy.keeps(times, stations)
+	for i, line := range records {
+		if !keep[i] {
+			lines[line] = nil
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	temp := store.filename + ".tmp"
+	if err = ioutil.WriteFile(temp, bytes.Join(lines, nil), 0644); err != nil {
+		return 0, err
+	}
+	return removed, os.Rename(temp, store.filename)
+}
+
+// Close has nothing to do, since every call opens the file afresh
+func (store *jsonlStore) Close() error {
+	return nil
+}
+
+// historyByStation splits history records up by station handle, keeping the
+// stations in the order they were first seen
+func historyByStation(records []historyRecord) (handles []string, byStation map[string][]historyRecord) {
+	byStation = make(map[string][]historyRecord)
+	for _, rec := range records {
+		handle := rec.Data.Station[0]
+		if _, seen := byStation[handle]; !seen {
+			handles = append(handles, handle)
+		}
+		byStation[handle] = append(byStation[handle], rec)
+	}
+	return handles, byStation
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket holding the history records. Keys are the UTC time then the station handle,
+// so a cursor walks them in time order.
+var boltHistoryBucket = []byte("history")
+
+// boltKeyFormat is a fixed width time layout so keys sort as text
+const boltKeyFormat = "2006-01-02T15:04:05.000000000Z"
+
+// boltStore is the BoltDB history
+type boltStore struct {
+	db *bolt.DB
+}
+
+// openBoltStore opens the BoltDB file, waiting a while if a server has it open
+func openBoltStore(filename string) (*boltStore, error) {
+	db, err := bolt.Open(filename, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+// Insert stores the records, a later one for the same time and station replacing the earlier
+func (store *boltStore) Insert(records []historyRecord) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltHistoryBucket)
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			value, err := json.Marshal(records[i])
+			if err != nil {
+				return err
+			}
+			key := records[i].Time.UTC().Format(boltKeyFormat) + "/" + records[i].Data.Station[0]
+			if err = bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Read walks the records from since onwards
+func (store *boltStore) Read(since time.Time) (records []historyRecord, err error) {
+	err = store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltHistoryBucket)
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Seek([]byte(since.UTC().Format(boltKeyFormat))); key != nil; key, value = cursor.Next() {
+			var rec historyRecord
+			if json.Unmarshal(value, &rec) == nil && rec.upgrade() == nil {
+				records = append(records, rec)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Compact deletes what the policy drops. Only the keys are needed to decide, as they
+// hold the time and the station.
+func (store *boltStore) Compact(policy retentionPolicy) (removed int, err error) {
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltHistoryBucket)
+		if bucket == nil {
+			return nil
+		}
+		var keys [][]byte
+		var times []time.Time
+		var stations []string
+		cursor := bucket.Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			parts := strings.SplitN(string(key), "/", 2)
+			when, err := time.Parse(boltKeyFormat, parts[0])
+			if err != nil || len(parts) < 2 {
+				continue
+			}
+			if !when.Before(policy.Before) && !when.Before(policy.ThinBefore) {
+				break
+			}
+			keys = append(keys, append([]byte(nil), key...))
+			times = append(times, when)
+			stations = append(stations, parts[1])
+		}
+		for i, keep := range policy.keeps(times, stations) {
+			if keep {
+				continue
+			}
+			if err := bucket.Delete(keys[i]); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Close releases the file lock
+func (store *boltStore) Close() error {
+	return store.db.Close()
+}
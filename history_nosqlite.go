@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import "errors"
+
+// openSQLiteStore has no SQLite to open, the driver needing cgo
+func openSQLiteStore(filename string) (historyStore, error) {
+	return nil, errors.New("This weatherstem was built without cgo, so without SQLite. Use a .jsonl or .bolt history file, or \"store\": \"jsonl\" or \"bolt\" in the history block.")
+}
@@ -0,0 +1,108 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	json "github.com/json-iterator/go"
+	// SQLite driver for database/sql
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema keeps each record as JSON, indexed by time and station
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS history (
+	time INTEGER NOT NULL,
+	station TEXT NOT NULL,
+	record TEXT NOT NULL);
+CREATE INDEX IF NOT EXISTS history_time ON history (time);`
+
+// sqliteStore is the SQLite history, the default
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLiteStore opens the database file and makes sure the table is there
+func openSQLiteStore(filename string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", filename+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// Insert adds one row per record in a single transaction
+func (store *sqliteStore) Insert(records []historyRecord) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		record, err := json.Marshal(records[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err = tx.Exec("INSERT INTO history (time, station, record) VALUES (?, ?, ?)", records[i].Time.UnixNano(), records[i].Data.Station[0], string(record)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Read selects the rows from since onwards
+func (store *sqliteStore) Read(since time.Time) (records []historyRecord, err error) {
+	rows, err := store.db.Query("SELECT record FROM history WHERE time >= ? ORDER BY time", since.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record string
+		if err = rows.Scan(&record); err != nil {
+			return nil, err
+		}
+		var rec historyRecord
+		if json.Unmarshal([]byte(record), &rec) == nil && rec.upgrade() == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, rows.Err()
+}
+
+// Compact deletes what the policy drops, the thinning keeping the last row of each
+// station and period
+func (store *sqliteStore) Compact(policy retentionPolicy) (removed int, err error) {
+	var deleted int64
+	if !policy.Before.IsZero() {
+		result, err := store.db.Exec("DELETE FROM history WHERE time < ?", policy.Before.UnixNano())
+		if err != nil {
+			return 0, err
+		}
+		deleted, _ = result.RowsAffected()
+	}
+	if !policy.ThinBefore.IsZero() && policy.Every > 0 {
+		result, err := store.db.Exec(`DELETE FROM history WHERE time < ? AND rowid NOT IN
+			(SELECT MAX(rowid) FROM history WHERE time < ? GROUP BY station, time / ?)`,
+			policy.ThinBefore.UnixNano(), policy.ThinBefore.UnixNano(), int64(policy.Every))
+		if err != nil {
+			return int(deleted), err
+		}
+		thinned, _ := result.RowsAffected()
+		deleted += thinned
+	}
+	return int(deleted), nil
+}
+
+// Close closes the database
+func (store *sqliteStore) Close() error {
+	return store.db.Close()
+}
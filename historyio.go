@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// historyCommand moves the history in and out of the store, ala
+// 'weatherstem history export -format csv -since 7d > week.csv' and
+// 'weatherstem history import week.jsonl'
+func historyCommand(config *configSettings, args []string) error {
+	if config.History.File == "" {
+		return errNoHistory
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return historyExport(config, args[1:])
+		case "import":
+			return historyImport(config, args[1:])
+		}
+	}
+	return fmt.Errorf("history wants export or import, ala 'weatherstem history export -format csv'")
+}
+
+// historyExport writes the history out as JSON lines, CSV or Parquet.
+// JSON lines are whole records, the same as the jsonl store, and import back as they were.
+// CSV and Parquet are the plain numbers in long form, one row per station, time and
+// metric, for spreadsheets and data frames. They import back as just those numbers.
+func historyExport(config *configSettings, args []string) (err error) {
+	var format, station, since, output string
+	flags := flag.NewFlagSet("history export", flag.ExitOnError)
+	flags.StringVar(&format, "format", "json", "Export as json, csv or parquet")
+	flags.StringVar(&station, "station", "", "Only this station, by handle or alias")
+	flags.StringVar(&since, "since", "", "Only this far back, ala 7d or 12h, instead of everything")
+	flags.StringVar(&output, "o", "", "Write to this file instead of stdout")
+	flags.Parse(args)
+	station = config.resolveStation(station)
+
+	var from time.Time
+	if since != "" {
+		back, err := parseRetention(since)
+		if err != nil {
+			return err
+		}
+		from = time.Now().Add(-back)
+	}
+	records, err := readHistory(config, from)
+	if err != nil {
+		return err
+	}
+	if station != "" {
+		_, byStation := historyByStation(records)
+		records = byStation[station]
+	}
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		w = file
+	}
+
+	switch format {
+	case "json":
+		return exportJSON(w, records)
+	case "csv":
+		return exportCSV(w, records)
+	case "parquet":
+		return exportParquet(w, records)
+	default:
+		return fmt.Errorf("Unknown export format %q, use json, csv or parquet", format)
+	}
+}
+
+// exportJSON writes the records one per line
+func exportJSON(w io.Writer, records []historyRecord) error {
+	buffered := bufio.NewWriter(w)
+	for i := range records {
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		}
+		buffered.Write(append(line, '\n'))
+	}
+	return buffered.Flush()
+}
+
+// historyColumns are the long form columns of the CSV and Parquet exports
+var historyColumns = []string{"time", "station", "metric", "value", "unit"}
+
+// exportCSV writes the records in long form, after a comment line with the schema
+func exportCSV(w io.Writer, records []historyRecord) error {
+	fmt.Fprintf(w, "# weatherstem history schema %d\n", historySchema)
+	out := csv.NewWriter(w)
+	out.Write(historyColumns)
+	for i := range records {
+		when := records[i].Time.UTC().Format(time.RFC3339)
+		for _, m := range stationMetrics(&records[i].Data, &records[i].Units) {
+			out.Write([]string{when, records[i].Data.Station[0], m.Name, strconv.FormatFloat(m.Value, 'f', decimals(metricQuantities[m.Name], -1), 64), m.Unit})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// exportParquet writes the records in long form, the schema in the file metadata
+func exportParquet(w io.Writer, records []historyRecord) error {
+	columns := []*parquetColumn{
+		newParquetColumn(historyColumns[0], parquetInt64, parquetTimestampMillis),
+		newParquetColumn(historyColumns[1], parquetByteArray, parquetUTF8),
+		newParquetColumn(historyColumns[2], parquetByteArray, parquetUTF8),
+		newParquetColumn(historyColumns[3], parquetDouble, -1),
+		newParquetColumn(historyColumns[4], parquetByteArray, parquetUTF8),
+	}
+	rows := 0
+	for i := range records {
+		millis := records[i].Time.UnixNano() / int64(time.Millisecond)
+		for _, m := range stationMetrics(&records[i].Data, &records[i].Units) {
+			columns[0].addInt64(millis)
+			columns[1].addString(records[i].Data.Station[0])
+			columns[2].addString(m.Name)
+			columns[3].addDouble(m.Value)
+			columns[4].addString(m.Unit)
+			rows++
+		}
+	}
+	buffered := bufio.NewWriter(w)
+	if err := writeParquet(buffered, columns, rows, "weatherstem.history.schema", strconv.Itoa(historySchema)); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// historyImport adds exported records, or a jsonl store, to the configured store.
+// Records newer than this weatherstem's schema are refused, older ones upgraded.
+func historyImport(config *configSettings, args []string) error {
+	var format string
+	flags := flag.NewFlagSet("history import", flag.ExitOnError)
+	flags.StringVar(&format, "format", "json", "Import json or csv, as history export writes them")
+	flags.Parse(args)
+
+	var records []historyRecord
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, filename := range files {
+		r := io.Reader(os.Stdin)
+		if filename != "-" {
+			file, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+
+		var read []historyRecord
+		var err error
+		switch format {
+		case "json":
+			read, err = importJSON(r)
+		case "csv":
+			read, err = importCSV(r)
+		default:
+			return fmt.Errorf("Unknown import format %q, use json or csv", format)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		records = append(records, read...)
+	}
+
+	store, err := openHistory(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if records, err = newToStore(store, records); err != nil {
+		return err
+	}
+	if err = store.Insert(records); err != nil {
+		return err
+	}
+	logInfo("Imported", len(records), "history records")
+	return nil
+}
+
+// newToStore drops the records the store already has for that time and station, so
+// importing the same file twice does no harm
+func newToStore(store historyStore, records []historyRecord) (fresh []historyRecord, err error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	earliest := records[0].Time
+	for i := range records {
+		if records[i].Time.Before(earliest) {
+			earliest = records[i].Time
+		}
+	}
+	existing, err := store.Read(earliest)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	have := make(map[string]bool)
+	key := func(rec *historyRecord) string {
+		return strconv.FormatInt(rec.Time.UnixNano(), 10) + "/" + rec.Data.Station[0]
+	}
+	for i := range existing {
+		have[key(&existing[i])] = true
+	}
+	for i := range records {
+		if !have[key(&records[i])] {
+			have[key(&records[i])] = true
+			fresh = append(fresh, records[i])
+		}
+	}
+	return fresh, nil
+}
+
+// importJSON reads JSON lines records
+func importJSON(r io.Reader) (records []historyRecord, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var rec historyRecord
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if err = rec.upgrade(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// importCSV reads the long form back into records, one per station and time. Only the
+// metrics with a place in the cooked data, see sensorFields, come back.
+func importCSV(r io.Reader) (records []historyRecord, err error) {
+	buffered := bufio.NewReader(r)
+	if first, _ := buffered.Peek(64); strings.HasPrefix(string(first), "#") {
+		header, _ := buffered.ReadString('\n')
+		var schema int
+		fmt.Sscanf(header, "# weatherstem history schema %d", &schema)
+		if schema > historySchema {
+			return nil, fmt.Errorf("history schema %d is newer than this weatherstem knows (%d)", schema, historySchema)
+		}
+	}
+
+	in := csv.NewReader(buffered)
+	in.FieldsPerRecord = len(historyColumns)
+	if _, err = in.Read(); err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	for {
+		row, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		when, err := time.Parse(time.RFC3339, row[0])
+		if err != nil {
+			return nil, err
+		}
+		field := sensorFields[row[2]]
+		value, err := strconv.ParseFloat(row[3], 64)
+		if field == nil || err != nil {
+			continue
+		}
+
+		key := row[0] + "/" + row[1]
+		i, seen := index[key]
+		if !seen {
+			i = len(records)
+			index[key] = i
+			records = append(records, historyRecord{Schema: historySchema, Time: when})
+			records[i].Data.Station[0], records[i].Units.Station[0] = row[1], row[1]
+		}
+		number, unit := field(&records[i].Data, &records[i].Units)
+		*number, *unit = value, row[4]
+		if row[2] == "winddir" {
+			records[i].Data.Wind[0], records[i].Data.Wind[1] = heading(value)
+		}
+	}
+	return records, nil
+}
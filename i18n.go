@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	locale "golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// language is what this run speaks, and localPrinter writes its numbers, see setLanguage
+var (
+	language     = "en"
+	localPrinter = message.NewPrinter(locale.English)
+)
+
+// messageCatalogs translate the output, keyed by the English. Anything missing stays
+// English, and an empty translation drops the word, ala "ago." where the language puts
+// it in front.
+var messageCatalogs = map[string]map[string]string{
+	"en": {},
+	"es": {
+		" T: %-.1f%s%s DP: %-.1f%s H: %.1f%s\n":                               " T: %-.1f%s%s PR: %-.1f%s H: %.1f%s\n",
+		"WB: %-.1f%s %s WC: %-.1f%s HI: %-.1f%s\n":                            "BH: %-.1f%s %s ST: %-.1f%s IC: %-.1f%s\n",
+		" P: %.3f%s [%.2fmbar] %v%s, %+.3f%s in 3h (%d: %s)\n":                " P: %.3f%s [%.2fmbar] %v%s, %+.3f%s en 3h (%d: %s)\n",
+		" W: %.1f%s%s %.1f%s gust, %v%v %s %s\n":                              " V: %.1f%s%s %.1f%s ráfaga, %v%v %s %s\n",
+		" R: %.2f%s %.2f%s, %.2f 1h %.2f 6h %.2f 24h %.2f%s today\n":          "LL: %.2f%s %.2f%s, %.2f 1h %.2f 6h %.2f 24h %.2f%s hoy\n",
+		" R: %.2f%s %.2f%s\n":                                                 "LL: %.2f%s %.2f%s\n",
+		" S: Wind run %.1f%s today, average %.1f%s, peak gust %.1f%s at %s\n": " S: Recorrido del viento %.1f%s hoy, media %.1f%s, ráfaga máxima %.1f%s a las %s\n",
+		" F: Frost %s, low near %.0f%s (%s)\n":                                " F: Helada %s, mínima cerca de %.0f%s (%s)\n",
+		"DR: Drone %s\n":                                                      "DR: Dron %s\n",
+		"CB: Cloud base %s\n":                                                 "CB: Base de las nubes %s\n",
+		" C: Wind chill %.1f%s, %s\n":                                         " C: Sensación térmica %.1f%s, %s\n",
+		"LW: Leaf wetness %s\n":                                               "LW: Humedad foliar %s\n",
+		"SP: Spraying %s\n":                                                   "SP: Pulverización %s\n",
+		" Other sensors:":                                                     " Otros sensores:",
+		"Steady":                                                              "Estable",
+		"Rising":                                                              "Subiendo",
+		"Falling":                                                             "Bajando",
+		"unlikely":                                                            "improbable",
+		"possible":                                                            "posible",
+		"likely":                                                              "probable",
+		"Current WBGT flags:":                                                 "Banderas WBGT actuales:",
+		"normal":                                                              "normal",
+		"Level":                                                               "Nivel",
+		"Call to API failed.":                                                 "Falló la llamada a la API.",
+		"Using cached results from":                                           "Usando los resultados guardados de hace",
+		"ago.":                                                                "",
+		"Cannot cache API results.":                                           "No se pueden guardar los resultados de la API.",
+		"API results are not JSON.":                                           "Los resultados de la API no son JSON.",
+		"Cannot unmarshal API results.":                                       "No se pueden leer los resultados de la API.",
+		"Cannot record history.":                                              "No se puede guardar el historial.",
+		"Station":                                                             "La estación",
+		"failed.":                                                             "falló.",
+		"Config file not found. It should look like this and be in 'weatherstem.json', either in the current or in your $HOME/.config directory.": "No se encuentra la configuración. Debe ser así y estar en 'weatherstem.json', en el directorio actual o en $HOME/.config.",
+	},
+	"fr": {
+		" T: %-.1f%s%s DP: %-.1f%s H: %.1f%s\n":                               " T: %-.1f%s%s PR: %-.1f%s H: %.1f%s\n",
+		"WB: %-.1f%s %s WC: %-.1f%s HI: %-.1f%s\n":                            "TG: %-.1f%s %s RE: %-.1f%s IC: %-.1f%s\n",
+		" P: %.3f%s [%.2fmbar] %v%s, %+.3f%s in 3h (%d: %s)\n":                " P: %.3f%s [%.2fmbar] %v%s, %+.3f%s en 3h (%d: %s)\n",
+		" W: %.1f%s%s %.1f%s gust, %v%v %s %s\n":                              " V: %.1f%s%s %.1f%s rafale, %v%v %s %s\n",
+		" R: %.2f%s %.2f%s, %.2f 1h %.2f 6h %.2f 24h %.2f%s today\n":          "PL: %.2f%s %.2f%s, %.2f 1h %.2f 6h %.2f 24h %.2f%s aujourd'hui\n",
+		" R: %.2f%s %.2f%s\n":                                                 "PL: %.2f%s %.2f%s\n",
+		" S: Wind run %.1f%s today, average %.1f%s, peak gust %.1f%s at %s\n": " S: Vent parcouru %.1f%s aujourd'hui, moyenne %.1f%s, rafale max %.1f%s à %s\n",
+		" F: Frost %s, low near %.0f%s (%s)\n":                                " F: Gel %s, minimale vers %.0f%s (%s)\n",
+		"CB: Cloud base %s\n":                                                 "CB: Base des nuages %s\n",
+		" C: Wind chill %.1f%s, %s\n":                                         " C: Refroidissement éolien %.1f%s, %s\n",
+		"LW: Leaf wetness %s\n":                                               "LW: Humectation foliaire %s\n",
+		"SP: Spraying %s\n":                                                   "SP: Pulvérisation %s\n",
+		" Other sensors:":                                                     " Autres capteurs :",
+		"Steady":                                                              "Stable",
+		"Rising":                                                              "En hausse",
+		"Falling":                                                             "En baisse",
+		"unlikely":                                                            "peu probable",
+		"possible":                                                            "possible",
+		"likely":                                                              "probable",
+		"Current WBGT flags:":                                                 "Drapeaux WBGT actuels :",
+		"normal":                                                              "normal",
+		"Level":                                                               "Niveau",
+		"Call to API failed.":                                                 "L'appel à l'API a échoué.",
+		"Using cached results from":                                           "Résultats en cache d'il y a",
+		"ago.":                                                                "",
+		"Cannot cache API results.":                                           "Impossible de garder les résultats de l'API.",
+		"API results are not JSON.":                                           "Les résultats de l'API ne sont pas du JSON.",
+		"Cannot unmarshal API results.":                                       "Impossible de lire les résultats de l'API.",
+		"Cannot record history.":                                              "Impossible d'enregistrer l'historique.",
+		"Station":                                                             "La station",
+		"failed.":                                                             "a échoué.",
+		"Config file not found. It should look like this and be in 'weatherstem.json', either in the current or in your $HOME/.config directory.": "Configuration introuvable. Elle ressemble à ceci, dans 'weatherstem.json', dans le répertoire courant ou dans $HOME/.config.",
+	},
+	"de": {
+		" T: %-.1f%s%s DP: %-.1f%s H: %.1f%s\n":                               " T: %-.1f%s%s TP: %-.1f%s F: %.1f%s\n",
+		"WB: %-.1f%s %s WC: %-.1f%s HI: %-.1f%s\n":                            "KT: %-.1f%s %s WC: %-.1f%s HI: %-.1f%s\n",
+		" W: %.1f%s%s %.1f%s gust, %v%v %s %s\n":                              " W: %.1f%s%s %.1f%s Böe, %v%v %s %s\n",
+		" R: %.2f%s %.2f%s, %.2f 1h %.2f 6h %.2f 24h %.2f%s today\n":          " N: %.2f%s %.2f%s, %.2f 1h %.2f 6h %.2f 24h %.2f%s heute\n",
+		" R: %.2f%s %.2f%s\n":                                                 " N: %.2f%s %.2f%s\n",
+		" S: Wind run %.1f%s today, average %.1f%s, peak gust %.1f%s at %s\n": " S: Windweg %.1f%s heute, Mittel %.1f%s, Spitzenböe %.1f%s um %s\n",
+		" F: Frost %s, low near %.0f%s (%s)\n":                                " F: Frost %s, Tiefstwert um %.0f%s (%s)\n",
+		"DR: Drone %s\n":                                                      "DR: Drohne %s\n",
+		"CB: Cloud base %s\n":                                                 "CB: Wolkenuntergrenze %s\n",
+		" C: Wind chill %.1f%s, %s\n":                                         " C: Windchill %.1f%s, %s\n",
+		"LW: Leaf wetness %s\n":                                               "LW: Blattnässe %s\n",
+		"SP: Spraying %s\n":                                                   "SP: Spritzen %s\n",
+		" Other sensors:":                                                     " Weitere Sensoren:",
+		"Steady":                                                              "Gleichbleibend",
+		"Rising":                                                              "Steigend",
+		"Falling":                                                             "Fallend",
+		"unlikely":                                                            "unwahrscheinlich",
+		"possible":                                                            "möglich",
+		"likely":                                                              "wahrscheinlich",
+		"Current WBGT flags:":                                                 "Aktuelle WBGT-Flaggen:",
+		"normal":                                                              "normal",
+		"Level":                                                               "Stufe",
+		"Call to API failed.":                                                 "Der API-Aufruf ist fehlgeschlagen.",
+		"Using cached results from":                                           "Zwischengespeicherte Ergebnisse von vor",
+		"ago.":                                                                "",
+		"Cannot cache API results.":                                           "Die API-Ergebnisse können nicht zwischengespeichert werden.",
+		"API results are not JSON.":                                           "Die API-Ergebnisse sind kein JSON.",
+		"Cannot unmarshal API results.":                                       "Die API-Ergebnisse können nicht gelesen werden.",
+		"Cannot record history.":                                              "Der Verlauf kann nicht gespeichert werden.",
+		"Station":                                                             "Station",
+		"failed.":                                                             "fehlgeschlagen.",
+		"Config file not found. It should look like this and be in 'weatherstem.json', either in the current or in your $HOME/.config directory.": "Konfiguration nicht gefunden. Sie sieht so aus und liegt als 'weatherstem.json' im aktuellen Verzeichnis oder in $HOME/.config.",
+	},
+}
+
+// setLanguage picks the language for this run's output
+func setLanguage(lang string) error {
+	if _, ok := messageCatalogs[lang]; !ok {
+		return fmt.Errorf("language is one of %v, not %q", languageNames(), lang)
+	}
+	language = lang
+	localPrinter = message.NewPrinter(locale.Make(lang))
+	return nil
+}
+
+// languageNames lists the languages in order
+func languageNames() (names []string) {
+	for name := range messageCatalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tr translates a message, or leaves it be when the catalog has nothing for it
+func tr(message string) string {
+	if translated, ok := messageCatalogs[language][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// localNumber formats a float64 the language's way, ala 1.016,25 in German, taking the
+// same verbs, width and precision as a plain float64
+type localNumber float64
+
+// Format puts the number through the verb as the language's printer would
+func (number localNumber) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, localPrinter.Sprintf(numberFormat(f, verb, -1), float64(number)))
+}
+
+// numberFormat is the format a verb was given as, flags, width, precision and all, with
+// the precision swapped for digits unless they are negative
+func numberFormat(f fmt.State, verb rune, digits int) string {
+	format := "%"
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			format += string(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		format += strconv.Itoa(width)
+	}
+	if precision, ok := f.Precision(); ok && digits < 0 {
+		format += "." + strconv.Itoa(precision)
+	} else if digits >= 0 {
+		format += "." + strconv.Itoa(digits)
+	}
+	return format + string(verb)
+}
+
+// printf is fmt.Printf in this run's language: the format is translated and the numbers
+// written the local way. English stays as fmt has it, without thousands separators.
+func printf(format string, args ...interface{}) {
+	for i := range args {
+		if language == "en" {
+			break
+		}
+		if number, ok := args[i].(float64); ok {
+			args[i] = localNumber(number)
+		} else if number, ok := args[i].(measured); ok {
+			number.local = true
+			args[i] = number
+		}
+	}
+	fmt.Printf(tr(format), args...)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"html"
+	"reflect"
+	"sort"
+	"strings"
+
+	json "github.com/json-iterator/go"
+)
+
+// JSON output tweaks from the command line: -pretty indents it, -fields keeps only
+// some keys of the cooked data and units, and -json-units inline puts each unit
+// next to its value
+var (
+	prettyJSON  bool
+	jsonFields  []string
+	inlineUnits bool
+)
+
+// A few friendlier names for the cooked data keys
+var fieldAliases = map[string]string{
+	"station":     "stations",
+	"temperature": "temp",
+	"location":    "topo",
+}
+
+// marshalJSON is json.Marshal, indented for -pretty. The indenting is done afterwards
+// so selected fields get it too.
+func marshalJSON(v interface{}) ([]byte, error) {
+	jdata, err := json.Marshal(v)
+	if err != nil || !prettyJSON {
+		return jdata, err
+	}
+	var indented bytes.Buffer
+	err = stdjson.Indent(&indented, jdata, "", "  ")
+	return indented.Bytes(), err
+}
+
+// weatherDataKeys are the JSON keys of the cooked data, in order
+func weatherDataKeys() (keys []string) {
+	kind := reflect.TypeOf(WeatherData{})
+	for i := 0; i < kind.NumField(); i++ {
+		keys = append(keys, strings.Split(kind.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return keys
+}
+
+// checkFields sets jsonFields from the -fields list, ala "temp,humidity,wind,station"
+func checkFields(list string) error {
+	if list == "" {
+		return nil
+	}
+	known := weatherDataKeys()
+	sort.Strings(known)
+	for _, field := range strings.Split(list, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if alias, ok := fieldAliases[field]; ok {
+			field = alias
+		}
+		if i := sort.SearchStrings(known, field); i == len(known) || known[i] != field {
+			return fmt.Errorf("unknown field %q, try one of %s", field, strings.Join(known, ","))
+		}
+		jsonFields = append(jsonFields, field)
+	}
+	return nil
+}
+
+// selectedKeys are the -fields keys, after the schema which every object keeps so
+// consumers can tell what they are reading
+func selectedKeys() []string {
+	for _, field := range jsonFields {
+		if field == "schema" {
+			return jsonFields
+		}
+	}
+	return append([]string{"schema"}, jsonFields...)
+}
+
+// fieldSelection is some of an object's keys, kept in the order they were asked for
+type fieldSelection struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// MarshalJSON writes the selected keys in order. Keys the object did not have are left out.
+func (selection fieldSelection) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for _, key := range selection.keys {
+		value, ok := selection.values[key]
+		if !ok {
+			continue
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// selectFields cuts v down to the -fields keys, if any were asked for
+func selectFields(v interface{}) interface{} {
+	if len(jsonFields) == 0 {
+		return v
+	}
+	selection := fieldSelection{keys: selectedKeys()}
+	jdata, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(jdata, &selection.values)
+	}
+	if err != nil {
+		logError("Cannot select fields", err)
+		return v
+	}
+	return selection
+}
+
+// valueWithUnit is one quantity with its unit, for -json-units inline
+type valueWithUnit struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// inlineValue pairs up a value and its unit, element by element for arrays. Anything
+// that is not a number with a unit, ala the station names, is left as it was.
+func inlineValue(value, unit json.RawMessage) json.RawMessage {
+	var number float64
+	var unitName string
+	if json.Unmarshal(value, &number) == nil && json.Unmarshal(unit, &unitName) == nil {
+		inlined, _ := json.Marshal(valueWithUnit{number, html.UnescapeString(unitName)})
+		return inlined
+	}
+	var numbers []float64
+	var unitNames []string
+	if json.Unmarshal(value, &numbers) == nil && json.Unmarshal(unit, &unitNames) == nil && len(numbers) == len(unitNames) {
+		inlined := make([]valueWithUnit, len(numbers))
+		for i := range numbers {
+			inlined[i] = valueWithUnit{numbers[i], html.UnescapeString(unitNames[i])}
+		}
+		jdata, _ := json.Marshal(inlined)
+		return jdata
+	}
+	return value
+}
+
+// stationInline is a station's data with each unit next to its value
+func stationInline(data *WeatherData, units *WeatherUnits) interface{} {
+	selection := fieldSelection{keys: weatherDataKeys()}
+	if len(jsonFields) > 0 {
+		selection.keys = selectedKeys()
+	}
+	var unitValues map[string]json.RawMessage
+	jdata, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(jdata, &selection.values)
+	}
+	if err == nil {
+		jdata, err = json.Marshal(units)
+	}
+	if err == nil {
+		err = json.Unmarshal(jdata, &unitValues)
+	}
+	if err != nil {
+		logError("Cannot put units inline", err)
+		return data
+	}
+	for key, value := range selection.values {
+		if unit, ok := unitValues[key]; ok {
+			selection.values[key] = inlineValue(value, unit)
+		}
+	}
+	return selection
+}
+
+// stationDocuments pairs each station's data with its units, one document per station
+func stationDocuments(dataArr []WeatherData, unitArr []WeatherUnits) (docs []interface{}) {
+	for i := range dataArr {
+		if inlineUnits {
+			docs = append(docs, stationInline(&dataArr[i], &unitArr[i]))
+			continue
+		}
+		docs = append(docs, struct {
+			Data  interface{} `json:"data"`
+			Units interface{} `json:"units"`
+		}{selectFields(dataArr[i]), selectFields(unitArr[i])})
+	}
+	return docs
+}
+
+// areaDocument wraps the area conditions so they stand apart from the stations
+func areaDocument(area *AreaConditions) interface{} {
+	return struct {
+		Area *AreaConditions `json:"area"`
+	}{area}
+}
+
+// PrintNDJSON shows one self contained JSON object per line, for log shippers.
+// It stays one line per object even with -pretty.
+func PrintNDJSON(docs []interface{}) {
+	for _, doc := range docs {
+		jdata, err := json.Marshal(doc)
+		if err != nil {
+			logError("Cannot marshal weather data", err)
+			continue
+		}
+		fmt.Printf("%s\n", string(jdata))
+	}
+}
+
+// PrintJSONArray shows all the objects as one JSON array
+func PrintJSONArray(docs []interface{}) {
+	if docs == nil {
+		docs = []interface{}{}
+	}
+	jdata, err := marshalJSON(docs)
+	if err != nil {
+		logError("Cannot marshal weather data", err)
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
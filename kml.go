@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+)
+
+// KML for Google Earth. The balloons are HTML, so they go in CDATA; the template is
+// text/template and the values are XML escaped by hand where they need it.
+var kmlTemplate = template.Must(template.New("kml").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+<name>Weather conditions</name>
+{{range .}}<Placemark>
+<name>{{xml .Name}}</name>
+<description><![CDATA[<b>{{.Name}}</b> ({{.Handle}}) at {{.Time}}<br>
+WBGT {{.WBGT}} {{.Level}}<br>
+<table>
+{{range .Rows}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{range .Cameras}}<a href="{{.ImageURL}}"><img src="{{.ImageURL}}" width="240" alt="{{.Name}}"></a><br>
+{{end}}]]></description>
+<Point><coordinates>{{.Lon}},{{.Lat}},0</coordinates></Point>
+</Placemark>
+{{end}}</Document>
+</kml>
+`))
+
+var kmlNetworkLinkTemplate = template.Must(template.New("networklink").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<NetworkLink>
+<name>Weather conditions</name>
+<Link>
+<href>{{xml .URL}}</href>
+<refreshMode>onInterval</refreshMode>
+<refreshInterval>{{.Seconds}}</refreshInterval>
+</Link>
+</NetworkLink>
+</kml>
+`))
+
+// xmlEscape makes a string safe for XML text
+func xmlEscape(s string) string {
+	return template.HTMLEscapeString(s)
+}
+
+// kmlPlacemark is a reportStation with a spot on the globe
+type kmlPlacemark struct {
+	reportStation
+	Lat, Lon float64
+}
+
+// PrintKML writes the stations as KML placemarks, their balloons showing the current
+// conditions and camera links
+func PrintKML(w io.Writer, dataArr []WeatherData, unitArr []WeatherUnits, weatherArr []WeatherInfo) error {
+	var placemarks []kmlPlacemark
+	for i, station := range reportStations(dataArr, unitArr, weatherArr) {
+		placemarks = append(placemarks, kmlPlacemark{station, dataArr[i].StationTopo.Lat, dataArr[i].StationTopo.Lon})
+	}
+	return kmlTemplate.Execute(w, placemarks)
+}
+
+// PrintKMLNetworkLink writes a KML NetworkLink which has Google Earth fetch url every so often
+func PrintKMLNetworkLink(w io.Writer, url string, refresh time.Duration) error {
+	return kmlNetworkLinkTemplate.Execute(w, struct {
+		URL     string
+		Seconds string
+	}{url, fmt.Sprintf("%.0f", refresh.Seconds())})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Strikes closer than this (km) raise an alert, unless "lightning_radius_km" in the
+// config says otherwise. Ten miles is the usual distance to clear pools and ballfields.
+const lightningDefaultRadius = 16.0
+
+// LightningActivity is what a station's lightning detector has seen lately. Distance is
+// to the nearest strike, in Unit; Last is when the latest one was, as the station says.
+type LightningActivity struct {
+	Strikes  float64 `json:"strikes"`
+	Distance float64 `json:"distance"`
+	Unit     string  `json:"unit"`
+	Last     string  `json:"last,omitempty"`
+}
+
+// isLightningSensor says whether a sensor type is part of a lightning detector
+func isLightningSensor(sensorType string) bool {
+	return strings.Contains(strings.ToLower(sensorType), "lightning")
+}
+
+// addLightning files a lightning detector reading: the strike count, the distance
+// or the time of the last strike
+func (data *WeatherData) addLightning(reading *ReadingInfo) {
+	if data.Lightning == nil {
+		data.Lightning = &LightningActivity{}
+	}
+	sensorType := strings.ToLower(reading.SensorType)
+	switch {
+	case strings.Contains(sensorType, "distance"):
+		data.Lightning.Distance, _ = strconv.ParseFloat(reading.Value, 64)
+		data.Lightning.Unit = html.UnescapeString(reading.UnitSymbol)
+	case strings.Contains(sensorType, "time") || strings.Contains(sensorType, "last"):
+		data.Lightning.Last = reading.Value
+	default:
+		data.Lightning.Strikes, _ = strconv.ParseFloat(reading.Value, 64)
+	}
+}
+
+// lightningRadius is how close a strike has to be for an alert, in km
+func (config *configSettings) lightningRadius() float64 {
+	if config.LightningRadius > 0 {
+		return config.LightningRadius
+	}
+	return lightningDefaultRadius
+}
+
+// lightningAlert raises an alert for strikes within radius km. A strike with no
+// distance is taken to be close.
+func lightningAlert(data *WeatherData, radius float64) (event alertEvent, raised bool) {
+	if data.Lightning == nil || data.Lightning.Strikes <= 0 {
+		return event, false
+	}
+	distance, known := convertUnit(data.Lightning.Distance, data.Lightning.Unit, "km")
+	if known && data.Lightning.Distance > 0 && distance > radius {
+		return event, false
+	}
+	message := fmt.Sprintf("%.0f strikes", data.Lightning.Strikes)
+	if data.Lightning.Distance > 0 {
+		message += fmt.Sprintf(", nearest %.1f%s", data.Lightning.Distance, data.Lightning.Unit)
+	}
+	return alertEvent{Station: data.Station[1], Kind: "lightning", Message: message}, true
+}
+
+// String describes the activity, ala "3 strikes, nearest 4.2mi, last 11:52"
+func (lightning *LightningActivity) String() string {
+	description := fmt.Sprintf("%.0f strikes", lightning.Strikes)
+	if lightning.Distance > 0 {
+		description += fmt.Sprintf(", nearest %.1f%s", lightning.Distance, lightning.Unit)
+	}
+	if lightning.Last != "" {
+		description += ", last " + lightning.Last
+	}
+	return description
+}
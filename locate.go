@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+const (
+	// locateDefaultURL looks the caller's IP address up. "locate_url" in the config points
+	// somewhere else that answers with "lat" and "lon" the same way.
+	locateDefaultURL = "http://ip-api.com/json/"
+	// gpsdDefaultAddress is where gpsd listens unless "gpsd" in the config says otherwise
+	gpsdDefaultAddress = "localhost:2947"
+	// gpsdTimeout is how long to wait for a fix
+	gpsdTimeout = 10 * time.Second
+)
+
+// parseMe reads a location given as "lat,lon", ala "29.13,-80.95"
+func parseMe(text string) (lat, lon float64, err error) {
+	parts := strings.Split(text, ",")
+	if len(parts) == 2 {
+		lat, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err == nil {
+			lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		}
+	}
+	if len(parts) != 2 || err != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("me is lat,lon in degrees, ala 29.13,-80.95, not %q", text)
+	}
+	return lat, lon, nil
+}
+
+// locateByIP asks where the caller's IP address is. It is only as good as the database,
+// the town or so, and a VPN puts you wherever it comes out.
+func locateByIP(c *configSettings) (lat, lon float64, err error) {
+	client, err := httpClient(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	locateURL := c.LocateURL
+	if locateURL == "" {
+		locateURL = locateDefaultURL
+	}
+
+	request, err := http.NewRequest(http.MethodGet, locateURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	request.Header.Set("User-Agent", userAgent())
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	logDebug("Calling", locateURL)
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer response.Body.Close()
+	body, err := readResponse(response)
+	if err != nil {
+		return 0, 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("IP geolocation answered %s", response.Status)
+	}
+
+	var place struct {
+		Status  string   `json:"status"`
+		Message string   `json:"message"`
+		Lat     *float64 `json:"lat"`
+		Lon     *float64 `json:"lon"`
+	}
+	if err = json.Unmarshal(body, &place); err != nil {
+		return 0, 0, err
+	}
+	if place.Lat == nil || place.Lon == nil {
+		return 0, 0, fmt.Errorf("IP geolocation has no location: %s %s", place.Status, place.Message)
+	}
+	return *place.Lat, *place.Lon, nil
+}
+
+// locateByGPSD asks a local gpsd for the position, waiting gpsdTimeout for a 2D fix or better
+func locateByGPSD(c *configSettings) (lat, lon float64, err error) {
+	address := c.GPSD
+	if address == "" {
+		address = gpsdDefaultAddress
+	}
+	logDebug("Asking gpsd at", address)
+	conn, err := net.DialTimeout("tcp", address, gpsdTimeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(gpsdTimeout))
+	if _, err = conn.Write([]byte("?WATCH={\"enable\":true,\"json\":true}\n")); err != nil {
+		return 0, 0, err
+	}
+
+	// gpsd says hello, lists its devices, then reports; TPV reports have the position
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		var report struct {
+			Class string   `json:"class"`
+			Mode  int      `json:"mode"`
+			Lat   *float64 `json:"lat"`
+			Lon   *float64 `json:"lon"`
+		}
+		if json.Unmarshal(lines.Bytes(), &report) != nil || report.Class != "TPV" {
+			continue
+		}
+		if report.Mode >= 2 && report.Lat != nil && report.Lon != nil {
+			return *report.Lat, *report.Lon, nil
+		}
+	}
+	if err = lines.Err(); err == nil {
+		err = fmt.Errorf("gpsd closed without a fix")
+	}
+	return 0, 0, fmt.Errorf("No fix from gpsd at %s: %v", address, err)
+}
+
+// settleMe works out where I am: -me if given, else -me-address, else -locate, else the
+// config's "me", looking its address up when it has no coordinates. With none of them the
+// distances make no sense, and that gets a warning.
+func settleMe(c *configSettings, me, address, locate string) (err error) {
+	var lat, lon float64
+	switch {
+	case me != "":
+		lat, lon, err = parseMe(me)
+	case address != "":
+		lat, lon, err = geocode(c, address)
+	case locate == "ip":
+		lat, lon, err = locateByIP(c)
+	case locate == "gpsd":
+		lat, lon, err = locateByGPSD(c)
+	case locate != "":
+		return fmt.Errorf("locate is ip or gpsd, not %q", locate)
+	case c.Me.Lat == 0 && c.Me.Lon == 0 && c.Me.Address != "":
+		lat, lon, err = geocode(c, c.Me.Address)
+	default:
+		if c.Me.Lat == 0 && c.Me.Lon == 0 {
+			logWarn("No \"me\" in the config, so station distances are from 0,0. Add it, or use -me lat,lon or -locate ip.")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logDebug(fmt.Sprintf("Me at %.4f,%.4f", lat, lon))
+	c.Me.Lat, c.Me.Lon = lat, lon
+	c.Me.Calc()
+	return nil
+}
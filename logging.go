@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// Everything the tool has to say goes to stderr, or -log-file, through here, at one of
+// four levels. -v adds the debug lines, -q leaves only the errors, so a cron job keeps
+// quiet unless something broke. -log-format json gives one JSON object per line, ala
+// {"time":"2020-08-14T10:05:00-04:00","level":"warn","msg":"Using cached results from 5m0s ago."}
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error"}
+
+// logEntry is a line of the JSON log
+type logEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"msg"`
+}
+
+// leveledLogger writes the lines at or above its level
+type leveledLogger struct {
+	mutex  sync.Mutex
+	level  logLevel
+	asJSON bool
+	out    io.Writer
+}
+
+var logger = &leveledLogger{level: levelInfo, out: os.Stderr}
+
+// write logs the values, spaced as log.Println would
+func (l *leveledLogger) write(level logLevel, v ...interface{}) {
+	if level < l.level {
+		return
+	}
+	if !l.asJSON {
+		v = translated(v)
+	}
+	message := redact(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	now := time.Now()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.asJSON {
+		jdata, err := json.Marshal(logEntry{Time: now, Level: levelNames[level], Message: message})
+		if err == nil {
+			l.out.Write(append(jdata, '\n'))
+		}
+		return
+	}
+	fmt.Fprintf(l.out, "%s %s %s\n", now.Format("2006/01/02 15:04:05"), strings.ToUpper(levelNames[level]), message)
+}
+
+// translated are the log values in this run's language, the strings that is. The JSON
+// log stays English for the machines reading it.
+func translated(v []interface{}) (out []interface{}) {
+	for _, value := range v {
+		if message, ok := value.(string); ok {
+			if value = tr(message); value == "" {
+				continue
+			}
+		}
+		out = append(out, value)
+	}
+	return out
+}
+
+// Write takes the lines of anything still using the log package, the libraries mostly
+func (l *leveledLogger) Write(p []byte) (int, error) {
+	l.write(levelInfo, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func logDebug(v ...interface{}) { logger.write(levelDebug, v...) }
+func logInfo(v ...interface{})  { logger.write(levelInfo, v...) }
+func logWarn(v ...interface{})  { logger.write(levelWarn, v...) }
+func logError(v ...interface{}) { logger.write(levelError, v...) }
+
+// setupLogging sets the logger up from the command line flags
+func setupLogging(verbose, quiet bool, format, file string) (err error) {
+	switch {
+	case verbose:
+		logger.level = levelDebug
+	case quiet:
+		logger.level = levelError
+	}
+	switch format {
+	case "text":
+	case "json":
+		logger.asJSON = true
+	default:
+		return fmt.Errorf("log-format is text or json, not %s", format)
+	}
+	if file != "" {
+		logFile, err := os.OpenFile(expandHome(file), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		logger.out = logFile
+	}
+	log.SetFlags(0)
+	log.SetOutput(logger)
+	return nil
+}
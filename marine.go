@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// How far back -marine looks for the wind trend
+	marineTrendWindow = time.Hour
+	// The wind has to move this much (knots) from the hour's average to be a trend
+	marineTrendKnots = 2.0
+)
+
+// MarineReport is the wind as a sailor or surfer wants it: knots, the gust factor, which
+// way it is heading and whether it blows onshore. Shore is empty for stations without a
+// coastline bearing in the config.
+type MarineReport struct {
+	Label       string  `json:"label"`
+	Handle      string  `json:"station"`
+	Name        string  `json:"name"`
+	Wind        float64 `json:"wind_kt"`
+	Gust        float64 `json:"gust_kt"`
+	GustFactor  float64 `json:"gust_factor"`
+	Direction   float64 `json:"direction"`
+	Heading     string  `json:"heading"`
+	Trend       string  `json:"trend"`
+	Shore       string  `json:"shore,omitempty"`
+	Pressure    float64 `json:"pressure_hpa"`
+	PressureDir string  `json:"ptrend"`
+}
+
+// shoreWind says how the wind blows against a coastline whose sea lies at seaward degrees.
+// Wind comes from its direction, so wind from the sea is onshore.
+func shoreWind(direction, seaward float64) string {
+	off := math.Abs(math.Mod(direction-seaward+540, 360) - 180)
+	switch {
+	case off <= 45:
+		return "onshore"
+	case off >= 135:
+		return "offshore"
+	case off <= 90:
+		return "cross-onshore"
+	default:
+		return "cross-offshore"
+	}
+}
+
+// windTrend is rising, falling or steady, the wind now against its average over the
+// window in knots. It is steady when there is no history to say otherwise.
+func windTrend(knots float64, records []historyRecord, recorded time.Time) string {
+	var sum float64
+	var count int
+	for i := range records {
+		if records[i].Time.Before(recorded) && recorded.Sub(records[i].Time) <= marineTrendWindow {
+			speed, _ := convertUnit(records[i].Data.Windspeed[0], records[i].Units.Windspeed[0], "kt")
+			sum += speed
+			count++
+		}
+	}
+	switch {
+	case count == 0:
+		return "steady"
+	case knots-sum/float64(count) >= marineTrendKnots:
+		return "rising"
+	case sum/float64(count)-knots >= marineTrendKnots:
+		return "falling"
+	}
+	return "steady"
+}
+
+// trendArrows draw the trend
+var trendArrows = map[string]string{"rising": "↗", "falling": "↘", "steady": "→"}
+
+// NewMarineReport works out the marine report for a station, with its last hour of history
+// for the trend
+func NewMarineReport(config *configSettings, data *WeatherData, units *WeatherUnits, records []historyRecord, recorded time.Time) (report MarineReport) {
+	report = MarineReport{
+		Label:       "marine",
+		Handle:      data.Station[0],
+		Name:        data.Station[1],
+		Direction:   data.Windspeed[2],
+		PressureDir: data.PressureTrend,
+	}
+	report.Wind, _ = convertUnit(data.Windspeed[0], units.Windspeed[0], "kt")
+	report.Gust, _ = convertUnit(data.Windspeed[1], units.Windspeed[1], "kt")
+	report.Wind, report.Gust = math.Round(report.Wind*10)/10, math.Round(report.Gust*10)/10
+	if report.Wind > 0 {
+		report.GustFactor = math.Round(report.Gust/report.Wind*10) / 10
+	}
+	report.Heading, _ = heading(data.Windspeed[2])
+	report.Trend = windTrend(report.Wind, records, recorded)
+	if seaward, ok := config.Coastline[data.Station[0]]; ok {
+		report.Shore = shoreWind(data.Windspeed[2], seaward)
+	}
+	pressure, _ := convertUnit(data.Pressure, units.Pressure, "hPa")
+	report.Pressure = math.Round(pressure*10) / 10
+	return report
+}
+
+// PrintMarineReport shows the marine report a couple of lines a station, ala
+// "Ponce Inlet (ponceinlet)  8.3 kt E ← gusting 13.2 kt (1.6) ↗ rising, onshore"
+func (report *MarineReport) PrintMarineReport() {
+	fmt.Printf("%s (%s)  %.1f kt %s %s gusting %.1f kt (%.1f) %s %s", report.Name, report.Handle, report.Wind, report.Heading, windArrow(report.Direction),
+		report.Gust, report.GustFactor, trendArrows[report.Trend], report.Trend)
+	if report.Shore != "" {
+		fmt.Printf(", %s", report.Shore)
+	}
+	fmt.Printf("\n  Pressure %.1f hPa %s\n", report.Pressure, report.PressureDir)
+}
+
+// PrintMarineReportJSON shows the marine report as one line of JSON
+func (report *MarineReport) PrintMarineReportJSON() {
+	jdata, err := marshalJSON(report)
+	if err != nil {
+		logError("Cannot marshal the marine report", err)
+	}
+	fmt.Printf("%s\n", string(jdata))
+}
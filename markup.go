@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// YAML and TOML output. Both are written from the JSON, so the keys are the same as
+// everywhere else and stay in the same order.
+
+// markupNode is a JSON value with the object keys kept in order
+type markupNode struct {
+	keys     []string      // object keys, in order
+	children []*markupNode // object values or array elements
+	array    bool
+	scalar   string // JSON text of a string, number, bool or null
+}
+
+func (node *markupNode) isObject() bool { return node.scalar == "" && !node.array }
+
+// parseMarkupNode reads one JSON value from the decoder
+func parseMarkupNode(decoder *stdjson.Decoder) (node *markupNode, err error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	node = &markupNode{}
+	switch t := token.(type) {
+	case stdjson.Delim:
+		node.array = t == '['
+		for decoder.More() {
+			if !node.array {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			child, err := parseMarkupNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+		_, err = decoder.Token()
+	case string:
+		node.scalar = markupString(t)
+	case stdjson.Number:
+		node.scalar = t.String()
+	case bool:
+		node.scalar = fmt.Sprint(t)
+	case nil:
+		node.scalar = "null"
+	}
+	return node, err
+}
+
+// markupString double quotes a string, which YAML and TOML both read like JSON
+func markupString(s string) string {
+	var quoted bytes.Buffer
+	encoder := stdjson.NewEncoder(&quoted)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSpace(quoted.String())
+}
+
+// markupTree turns v into a markupNode tree by way of its JSON
+func markupTree(v interface{}) (*markupNode, error) {
+	jdata, err := marshalJSONCompact(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := stdjson.NewDecoder(bytes.NewReader(jdata))
+	decoder.UseNumber()
+	return parseMarkupNode(decoder)
+}
+
+// marshalJSONCompact is marshalJSON without the -pretty, which means nothing here
+func marshalJSONCompact(v interface{}) ([]byte, error) {
+	pretty := prettyJSON
+	prettyJSON = false
+	defer func() { prettyJSON = pretty }()
+	return marshalJSON(v)
+}
+
+// flowScalars writes an array of scalars on one line, ala [1, 2, 3]
+func flowScalars(node *markupNode) (string, bool) {
+	var values []string
+	for _, child := range node.children {
+		if child.scalar == "" {
+			return "", false
+		}
+		values = append(values, child.scalar)
+	}
+	return "[" + strings.Join(values, ", ") + "]", true
+}
+
+// writeYAML writes node as block YAML at the given indent
+func writeYAML(w io.Writer, node *markupNode, indent string) {
+	for i, child := range node.children {
+		prefix := indent + "- "
+		if node.isObject() {
+			prefix = indent + yamlKey(node.keys[i]) + ":"
+		}
+		switch {
+		case child.scalar != "":
+			fmt.Fprintf(w, "%s %s\n", strings.TrimRight(prefix, " "), child.scalar)
+		case len(child.children) == 0 && child.array:
+			fmt.Fprintf(w, "%s []\n", strings.TrimRight(prefix, " "))
+		case len(child.children) == 0:
+			fmt.Fprintf(w, "%s {}\n", strings.TrimRight(prefix, " "))
+		default:
+			if flow, ok := flowScalars(child); ok && child.array {
+				fmt.Fprintf(w, "%s %s\n", strings.TrimRight(prefix, " "), flow)
+				continue
+			}
+			if node.isObject() {
+				fmt.Fprintln(w, prefix)
+				writeYAML(w, child, indent+"  ")
+				continue
+			}
+			// A list item which is itself a map or list starts on the dash line
+			var nested bytes.Buffer
+			writeYAML(&nested, child, indent+"  ")
+			fmt.Fprint(w, prefix+strings.TrimPrefix(nested.String(), indent+"  "))
+		}
+	}
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// yamlKey quotes a key unless it is plainly safe
+func yamlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return markupString(key)
+}
+
+// tomlInline writes a value on one line, with objects as inline tables. Nulls have
+// no TOML form and are left out.
+func tomlInline(node *markupNode) string {
+	if node.scalar != "" {
+		return node.scalar
+	}
+	var parts []string
+	for i, child := range node.children {
+		if child.scalar == "null" {
+			continue
+		}
+		if node.array {
+			parts = append(parts, tomlInline(child))
+		} else {
+			parts = append(parts, yamlKey(node.keys[i])+" = "+tomlInline(child))
+		}
+	}
+	if node.array {
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// isTableArray says whether node is a non-empty array of objects, ala [[stations]]
+func isTableArray(node *markupNode) bool {
+	if !node.array || len(node.children) == 0 {
+		return false
+	}
+	for _, child := range node.children {
+		if !child.isObject() {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTOML writes the table node at path: its plain keys first, then its sub tables.
+// Only the top level gets [[arrays of tables]]; deeper down they are inline.
+func writeTOML(w io.Writer, node *markupNode, path string) {
+	for i, child := range node.children {
+		if child.scalar == "null" || child.isObject() || (path == "" && isTableArray(child)) {
+			continue
+		}
+		fmt.Fprintf(w, "%s = %s\n", yamlKey(node.keys[i]), tomlInline(child))
+	}
+	for i, child := range node.children {
+		name := yamlKey(node.keys[i])
+		if path != "" {
+			name = path + "." + name
+		}
+		switch {
+		case child.isObject():
+			fmt.Fprintf(w, "\n[%s]\n", name)
+			writeTOML(w, child, name)
+		case path == "" && isTableArray(child):
+			for _, element := range child.children {
+				fmt.Fprintf(w, "\n[[%s]]\n", name)
+				writeTOML(w, element, name)
+			}
+		}
+	}
+}
+
+// markupDocument is what -yaml and -toml write: the stations, and the area if asked for
+type markupDocument struct {
+	Stations []interface{}   `json:"stations"`
+	Area     *AreaConditions `json:"area,omitempty"`
+	Errors   []stationError  `json:"errors,omitempty"`
+}
+
+// PrintYAML writes v as YAML
+func PrintYAML(w io.Writer, v interface{}) error {
+	tree, err := markupTree(v)
+	if err != nil {
+		return err
+	}
+	writeYAML(w, tree, "")
+	return nil
+}
+
+// PrintTOML writes v as TOML. The top has to be an object.
+func PrintTOML(w io.Writer, v interface{}) error {
+	tree, err := markupTree(v)
+	if err != nil {
+		return err
+	}
+	if !tree.isObject() {
+		return fmt.Errorf("TOML needs a table at the top")
+	}
+	writeTOML(w, tree, "")
+	return nil
+}
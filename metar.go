@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+	haversine "github.com/loraxipam/havers2"
+)
+
+// metarDefaultURL is the Aviation Weather Center's data API. "metar_url" in the config
+// points somewhere else.
+const metarDefaultURL = "https://aviationweather.gov"
+
+// metarObservation is the bit of an AWC METAR we compare. Temperatures are °C, wind
+// knots and the altimeter hPa. The direction is "VRB" when the wind is variable.
+type metarObservation struct {
+	ICAO      string      `json:"icaoId"`
+	Name      string      `json:"name"`
+	Raw       string      `json:"rawOb"`
+	Time      int64       `json:"obsTime"`
+	Temp      float64     `json:"temp"`
+	Dewpoint  float64     `json:"dewp"`
+	Direction interface{} `json:"wdir"`
+	Speed     float64     `json:"wspd"`
+	Gust      float64     `json:"wgst"`
+	Altimeter float64     `json:"altim"`
+	Lat       float64     `json:"lat"`
+	Lon       float64     `json:"lon"`
+}
+
+// fetchMETAR gets the latest METAR for an airport, ala KDAB
+func fetchMETAR(c *configSettings, icao string) (metar metarObservation, err error) {
+	client, err := httpClient(c)
+	if err != nil {
+		return metar, err
+	}
+	base := c.METARURL
+	if base == "" {
+		base = metarDefaultURL
+	}
+	metarURL := strings.TrimSuffix(base, "/") + "/api/data/metar?format=json&ids=" + url.QueryEscape(strings.ToUpper(icao))
+
+	request, err := http.NewRequest(http.MethodGet, metarURL, nil)
+	if err != nil {
+		return metar, err
+	}
+	request.Header.Set("User-Agent", userAgent())
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	logDebug("Calling", metarURL)
+	response, err := client.Do(request)
+	if err != nil {
+		return metar, err
+	}
+	defer response.Body.Close()
+	body, err := readResponse(response)
+	if err != nil {
+		return metar, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return metar, fmt.Errorf("aviationweather.gov answered %s", response.Status)
+	}
+
+	var metars []metarObservation
+	if err = json.Unmarshal(body, &metars); err != nil {
+		return metar, err
+	}
+	if len(metars) == 0 {
+		return metar, fmt.Errorf("No METAR for %s", strings.ToUpper(icao))
+	}
+	return metars[0], nil
+}
+
+// cook turns the METAR into a station in the units of another, so the two line up.
+// Humidity comes from the temperature and dewpoint, the Magnus way.
+func (metar *metarObservation) cook(units *WeatherUnits) (data WeatherData) {
+	data.Station = [3]string{metar.ICAO, metar.ICAO, time.Unix(metar.Time, 0).UTC().Format("2006-01-02 15:04:05")}
+	data.StationTopo = haversine.Coord{Lat: metar.Lat, Lon: metar.Lon}
+	data.Temperature[0] = fromCelsius(metar.Temp, units.Temperature[0])
+	data.Temperature[1] = fromCelsius(metar.Dewpoint, units.Temperature[1])
+	magnus := func(celsius float64) float64 { return math.Exp(17.625 * celsius / (243.04 + celsius)) }
+	data.Humidity = math.Round(100 * magnus(metar.Dewpoint) / magnus(metar.Temp))
+	data.Windspeed[0], _ = convertUnit(metar.Speed, "kt", units.Windspeed[0])
+	data.Windspeed[1], _ = convertUnit(math.Max(metar.Gust, metar.Speed), "kt", units.Windspeed[1])
+	if direction, ok := metar.Direction.(float64); ok {
+		data.Windspeed[2] = direction
+	}
+	data.Pressure, _ = convertUnit(metar.Altimeter, "hPa", units.Pressure)
+	return data
+}
+
+// metarRows are the comparison rows a METAR has something for
+var metarRows = map[string]bool{"Temperature": true, "Dewpoint": true, "Humidity": true, "Wind": true, "Gust": true, "Direction": true, "Pressure": true}
+
+// CompareMETAR lines the METAR up against the closest station, station minus METAR,
+// so the deltas say how far the station is off the official reading. With no station
+// that says where it is, there is nothing to compare.
+func CompareMETAR(metar *metarObservation, dataArr []WeatherData, unitArr []WeatherUnits) (comparison StationComparison, closest int, km float64, err error) {
+	airport := haversine.Coord{Lat: metar.Lat, Lon: metar.Lon}
+	airport.Calc()
+	closest, km = -1, math.Inf(1)
+	for i := range dataArr {
+		topo := dataArr[i].StationTopo
+		if topo.Lat == 0 && topo.Lon == 0 {
+			continue
+		}
+		topo.Calc()
+		if distance := haversine.DistanceKm(airport, topo); distance < km {
+			closest, km = i, distance
+		}
+	}
+	if closest < 0 {
+		return comparison, closest, km, errors.New("no station with a position to compare the METAR with")
+	}
+
+	official := metar.cook(&unitArr[closest])
+	comparison = CompareStations(&official, &dataArr[closest], &unitArr[closest], &unitArr[closest])
+	rows := comparison.Rows[:0]
+	for _, row := range comparison.Rows {
+		if metarRows[row.Name] {
+			rows = append(rows, row)
+		}
+	}
+	comparison.Rows = rows
+	return comparison, closest, km, nil
+}
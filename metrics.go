@@ -0,0 +1,39 @@
+package main
+
+import (
+	"html"
+)
+
+// stationMetric is one reading of a station, for the metric outputs
+type stationMetric struct {
+	Name  string
+	Help  string
+	Unit  string
+	Value float64
+}
+
+// stationMetrics lists a station's readings, one metric each
+func stationMetrics(data *WeatherData, units *WeatherUnits) (metrics []stationMetric) {
+	unit := html.UnescapeString
+	metrics = []stationMetric{
+		{"temp", "Air temperature", unit(units.Temperature[0]), data.Temperature[0]},
+		{"dewpoint", "Dewpoint", unit(units.Temperature[1]), data.Temperature[1]},
+		{"wbgt", "Wet bulb globe temperature", unit(units.Temperature[2]), data.Temperature[2]},
+		{"windchill", "Wind chill", unit(units.Temperature[3]), data.Temperature[3]},
+		{"heatindex", "Heat index", unit(units.Temperature[4]), data.Temperature[4]},
+		{"humidity", "Relative humidity", "%", data.Humidity},
+		{"windspeed", "Wind speed", units.Windspeed[0], data.Windspeed[0]},
+		{"gust", "10 minute wind gust", units.Windspeed[1], data.Windspeed[1]},
+		{"winddir", "Wind direction", "°", data.Windspeed[2]},
+		{"pressure", "Barometric pressure", units.Pressure, data.Pressure},
+		{"rain", "Rain gauge", units.Rain[0], data.Rain[0]},
+		{"rainrate", "Rain rate", units.Rain[1], data.Rain[1]},
+		{"solar", "Solar radiation", unit(units.Sun[0]), data.Sun[0]},
+		{"uv", "UV index", units.Sun[1], data.Sun[1]},
+		{"distance", "Distance from me", units.StationDist, data.StationDist},
+	}
+	if data.AirQuality != nil {
+		metrics = append(metrics, stationMetric{"aqi", "EPA air quality index", "", float64(data.AirQuality.AQI)})
+	}
+	return metrics
+}
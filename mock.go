@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	json "github.com/json-iterator/go"
+	"github.com/loraxipam/weatherstem-cli/weatherapi"
+)
+
+func init() {
+	subcommands["mock"] = mockCommand
+}
+
+// mockFixtures are the recorded station results the mock answers with, by handle. Down
+// are those recorded while the station was down, served when -down asks for it.
+type mockFixtures struct {
+	Up   map[string]json.RawMessage
+	Down map[string]json.RawMessage
+}
+
+// downReading is a reading the way the API sends it for a station that is down, the
+// value a number instead of a string when it is one
+type downReading struct {
+	ReadingInfo
+	Value interface{} `json:"value"`
+}
+
+// downRecord is a RecordInfo with its readings gone numeric
+type downRecord struct {
+	RecordInfo
+	RecordReadings []downReading `json:"readings"`
+}
+
+// downInfo is a station result as the API sends it for a station that is down
+type downInfo struct {
+	WeatherRecord  downRecord  `json:"record"`
+	WeatherStation StationInfo `json:"station"`
+}
+
+// mockError is what the mock answers for a station it has nothing recorded for
+type mockError struct {
+	Station struct {
+		Handle string `json:"handle"`
+	} `json:"station"`
+	Error string `json:"error"`
+}
+
+// loadMockFixtures reads every .json and .jsonl file in the directory. A file may hold
+// an API response (an array of station results), one result, a line of JSON a result
+// as -orig writes them, or the response cache, ala ~/.cache/weatherstem/api.json. Files
+// with ".down." in their name, ala ponceinlet.down.json, are down variants.
+func loadMockFixtures(dir string) (fixtures mockFixtures, err error) {
+	fixtures = mockFixtures{Up: make(map[string]json.RawMessage), Down: make(map[string]json.RawMessage)}
+	filenames, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	more, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	filenames = append(filenames, more...)
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return fixtures, err
+		}
+		keep := fixtures.Up
+		if strings.Contains(filepath.Base(filename), ".down.") {
+			keep = fixtures.Down
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		for decoder.More() {
+			var value json.RawMessage
+			if err = decoder.Decode(&value); err != nil {
+				return fixtures, fmt.Errorf("%s: %v", filename, err)
+			}
+			var results []json.RawMessage
+			switch {
+			case bytes.HasPrefix(bytes.TrimSpace(value), []byte("[")):
+				err = json.Unmarshal(value, &results)
+			case json.Get(value, "body").ValueType() == json.ArrayValue:
+				err = json.Unmarshal([]byte(json.Get(value, "body").ToString()), &results)
+			default:
+				results = []json.RawMessage{value}
+			}
+			if err != nil {
+				return fixtures, fmt.Errorf("%s: %v", filename, err)
+			}
+			for _, result := range results {
+				handle := json.Get(result, "station", "handle").ToString()
+				if handle == "" {
+					logWarn("Skipping a result with no station handle in", filename)
+					continue
+				}
+				keep[handle] = result
+			}
+		}
+	}
+	if len(fixtures.Up)+len(fixtures.Down) == 0 {
+		return fixtures, fmt.Errorf("No station results in %s", dir)
+	}
+	return fixtures, nil
+}
+
+// takeDown makes a down variant of a station result, the way the API mangles it: the
+// readings become numbers and the record says since when
+func takeDown(result json.RawMessage) (json.RawMessage, error) {
+	info, _, err := weatherapi.DecodeStation(result)
+	if err != nil {
+		return nil, err
+	}
+	down := downInfo{WeatherRecord: downRecord{RecordInfo: info.WeatherRecord}, WeatherStation: info.WeatherStation}
+	down.WeatherRecord.StationDown = info.WeatherRecord.ReadingsTimestamp
+	for _, reading := range info.WeatherRecord.RecordReadings {
+		var value interface{} = reading.Value
+		if number, err := strconv.ParseFloat(reading.Value, 64); err == nil {
+			value = number
+		}
+		down.WeatherRecord.RecordReadings = append(down.WeatherRecord.RecordReadings, downReading{ReadingInfo: reading, Value: value})
+	}
+	return json.Marshal(down)
+}
+
+// answer is the mock's results for the stations asked about, in the order asked
+func (fixtures *mockFixtures) answer(stations []string, down map[string]bool) (results []json.RawMessage) {
+	for _, station := range stations {
+		handle := stationHandle(station)
+		isDown := down[handle] || down["all"]
+		result, up := fixtures.Up[handle]
+		recorded, downRecorded := fixtures.Down[handle]
+		switch {
+		case (isDown || !up) && downRecorded:
+			result = recorded
+		case isDown && up:
+			if taken, err := takeDown(result); err == nil {
+				result = taken
+			} else {
+				logWarn("Cannot take", handle, "down.", err)
+			}
+		case !up:
+			var missing mockError
+			missing.Station.Handle = handle
+			missing.Error = "No fixture for " + handle
+			result, _ = json.Marshal(missing)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// mockCommand serves recorded API responses the way the WeatherSTEM API does, so the
+// whole client can run without a key or a network, ala 'weatherstem mock -fixtures dir/'
+// with "api_url": "http://localhost:8081/api" in the config. Any path answers.
+func mockCommand(_ *configSettings, args []string) (err error) {
+	var (
+		port          int
+		dir, key, off string
+	)
+	flags := flag.NewFlagSet("mock", flag.ExitOnError)
+	flags.IntVar(&port, "port", 8081, "Port to listen on")
+	flags.StringVar(&dir, "fixtures", "", "Directory of recorded API responses, see the README")
+	flags.StringVar(&key, "key", "", "The only api_key to take, any if not set")
+	flags.StringVar(&off, "down", "", "Stations to answer for as down, comma separated handles or all")
+	flags.Parse(args)
+
+	if dir == "" {
+		return errors.New("The mock needs -fixtures, a directory of recorded API responses")
+	}
+	fixtures, err := loadMockFixtures(dir)
+	if err != nil {
+		return err
+	}
+	down := make(map[string]bool)
+	for _, handle := range strings.Split(off, ",") {
+		if handle = stationHandle(strings.TrimSpace(handle)); handle != "" {
+			down[handle] = true
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST the api_key and stations", http.StatusMethodNotAllowed)
+			return
+		}
+		var asked struct {
+			Key      string   `json:"api_key"`
+			Stations []string `json:"stations"`
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(body, &asked)
+		}
+		if err != nil {
+			http.Error(w, "Cannot read the request", http.StatusBadRequest)
+			return
+		}
+		if key != "" && asked.Key != key {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		logDebug("Mock asked for", strings.Join(asked.Stations, ", "))
+
+		jdata, err := json.Marshal(fixtures.answer(asked.Stations, down))
+		if err != nil {
+			http.Error(w, "Cannot marshal the fixtures", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write(jdata)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zipped := gzip.NewWriter(w)
+		zipped.Write(jdata)
+		zipped.Close()
+	})
+
+	logInfo(fmt.Sprintf("Mocking the API on :%d with %d stations, %d down variants, from %s", port, len(fixtures.Up), len(fixtures.Down), dir))
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+}
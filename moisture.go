@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// Humidity changes smaller than this (% over the tendency window) count as steady
+	humiditySteady = 3.0
+	// Dewpoint changes smaller than this (°C over the tendency window) count as steady
+	dewpointSteady = 0.5
+	// Fog wants the air within this much (°C) of its dewpoint
+	fogSpread = 2.5
+	// A sea breeze brings the dewpoint up at least this much (°C over the window), and
+	// then some, as the damp air off the water comes ashore
+	seaBreezeDewpointRise = 2.0
+)
+
+// MoistureTendency is how the humidity and the dewpoint have moved over the last three
+// hours, worked out from the history like the PressureTendency. Changes are in the
+// station's units. Hint is what the moves suggest, fog or a sea breeze, if anything.
+type MoistureTendency struct {
+	Humidity       string  `json:"humidity"`
+	HumidityChange float64 `json:"humidity_change"`
+	Dewpoint       string  `json:"dewpoint"`
+	DewpointChange float64 `json:"dewpoint_change"`
+	Hint           string  `json:"hint,omitempty"`
+}
+
+// String says it the way the text output wants, ala "humidity rising, +6% in 3h,
+// dewpoint steady, +0.4°F in 3h, fog possible"
+func (tendency *MoistureTendency) String(units *WeatherUnits) string {
+	parts := []string{
+		fmt.Sprintf("humidity %s, %+.0f%% in 3h", tendency.Humidity, tendency.HumidityChange),
+		fmt.Sprintf("dewpoint %s, %+.1f%s in 3h", tendency.Dewpoint, tendency.DewpointChange, units.Temperature[1]),
+	}
+	if tendency.Hint != "" {
+		parts = append(parts, tendency.Hint)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// moistureAt finds the history record closest to a time, if one is close enough and has
+// the humidity
+func moistureAt(records []historyRecord, when time.Time) (rec historyRecord, ok bool) {
+	best := tendencySlop
+	for _, r := range records {
+		gap := r.Time.Sub(when)
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap <= best && r.Data.Humidity != 0 {
+			rec, best, ok = r, gap, true
+		}
+	}
+	return rec, ok
+}
+
+// tendencyWord is rising, falling or steady for a change against what counts as steady
+func tendencyWord(change, steady float64) string {
+	switch {
+	case change >= steady:
+		return "rising"
+	case change <= -steady:
+		return "falling"
+	}
+	return "steady"
+}
+
+// AssessMoistureTendency works out the three hour change in humidity and dewpoint from the
+// station's history, ending at the current reading. ok is false without three hours of
+// history. The air closing in on its dewpoint with the humidity climbing means fog; the
+// dewpoint jumping with the air no warmer means the sea breeze has arrived.
+func AssessMoistureTendency(data *WeatherData, units *WeatherUnits, records []historyRecord, now time.Time) (tendency MoistureTendency, ok bool) {
+	start, ok := moistureAt(records, now.Add(-tendencyWindow))
+	if !ok {
+		return tendency, false
+	}
+
+	tendency.HumidityChange = math.Round((data.Humidity-start.Data.Humidity)*10) / 10
+	tendency.Humidity = tendencyWord(tendency.HumidityChange, humiditySteady)
+
+	// Temperature units have an offset, so differences are taken in °C
+	dewpoint := toCelsius(data.Temperature[1], units.Temperature[1])
+	dewpointRise := dewpoint - toCelsius(start.Data.Temperature[1], start.Units.Temperature[1])
+	warming := toCelsius(data.Temperature[0], units.Temperature[0]) - toCelsius(start.Data.Temperature[0], start.Units.Temperature[0])
+	tendency.Dewpoint = tendencyWord(dewpointRise, dewpointSteady)
+	tendency.DewpointChange = fromCelsius(dewpointRise, units.Temperature[1]) - fromCelsius(0, units.Temperature[1])
+	tendency.DewpointChange = math.Round(tendency.DewpointChange*10) / 10
+
+	switch {
+	case toCelsius(data.Temperature[0], units.Temperature[0])-dewpoint <= fogSpread && tendency.Humidity == "rising":
+		tendency.Hint = "fog possible"
+	case dewpointRise >= seaBreezeDewpointRise && warming <= 0:
+		tendency.Hint = "sea breeze?"
+	}
+	return tendency, true
+}
+
+// moistureArrow draws the humidity or the dewpoint tendency, if there is one, ala " ↗"
+func (data *WeatherData) moistureArrow(humidity bool) string {
+	if data.Moisture == nil {
+		return ""
+	}
+	if humidity {
+		return " " + trendArrows[data.Moisture.Humidity]
+	}
+	return " " + trendArrows[data.Moisture.Dewpoint]
+}
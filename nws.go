@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// nwsDefaultURL is the National Weather Service API. "nws_url" in the config points
+// somewhere else, ala a caching mirror.
+const nwsDefaultURL = "https://api.weather.gov"
+
+// NWSAlert is an active watch, warning or advisory from the National Weather Service
+// covering a station's spot, ala "Tornado Watch until 8 PM"
+type NWSAlert struct {
+	Event    string    `json:"event"`
+	Headline string    `json:"headline"`
+	Severity string    `json:"severity"`
+	Ends     time.Time `json:"ends"`
+}
+
+// String is the event and when it ends, ala "Tornado Watch until 8 PM" or
+// "Flood Warning until Sat 9:30 AM" when that is not today
+func (alert NWSAlert) String() string {
+	if alert.Ends.IsZero() {
+		return alert.Event
+	}
+	ends := alert.Ends.Local()
+	layout := "3:04 PM"
+	if ends.Minute() == 0 {
+		layout = "3 PM"
+	}
+	if now := time.Now(); ends.YearDay() != now.YearDay() || ends.Year() != now.Year() {
+		layout = "Mon " + layout
+	}
+	return alert.Event + " until " + ends.Format(layout)
+}
+
+// nwsAlertsResponse is the bit of the GeoJSON from /alerts/active that we use. Ends is
+// empty for some alerts, which only say when they expire.
+type nwsAlertsResponse struct {
+	Features []struct {
+		Properties struct {
+			Event    string `json:"event"`
+			Headline string `json:"headline"`
+			Severity string `json:"severity"`
+			Ends     string `json:"ends"`
+			Expires  string `json:"expires"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// fetchNWSAlerts asks the NWS for the alerts active at a spot. The NWS covers the US only,
+// and wants a User-Agent it can tell apart.
+func fetchNWSAlerts(c *configSettings, lat, lon float64) (alerts []NWSAlert, err error) {
+	client, err := httpClient(c)
+	if err != nil {
+		return nil, err
+	}
+	base := c.NWSURL
+	if base == "" {
+		base = nwsDefaultURL
+	}
+	alertsURL := fmt.Sprintf("%s/alerts/active?point=%.4f,%.4f", strings.TrimSuffix(base, "/"), lat, lon)
+
+	request, err := http.NewRequest(http.MethodGet, alertsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/geo+json")
+	request.Header.Set("User-Agent", userAgent()+" (github.com/loraxipam/weatherstem-cli)")
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	logDebug("Calling", alertsURL)
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := readResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NWS answered %s", response.Status)
+	}
+
+	var active nwsAlertsResponse
+	if err = json.Unmarshal(body, &active); err != nil {
+		return nil, err
+	}
+	for _, feature := range active.Features {
+		properties := feature.Properties
+		ends := properties.Ends
+		if ends == "" {
+			ends = properties.Expires
+		}
+		alert := NWSAlert{Event: properties.Event, Headline: properties.Headline, Severity: properties.Severity}
+		alert.Ends, _ = time.Parse(time.RFC3339, ends)
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// annotateNWSAlerts adds the active NWS alerts to every station. A station the NWS
+// cannot answer for is logged and left without.
+func annotateNWSAlerts(c *configSettings, dataArr []WeatherData) {
+	for i := range dataArr {
+		alerts, err := fetchNWSAlerts(c, dataArr[i].StationTopo.Lat, dataArr[i].StationTopo.Lon)
+		if err != nil {
+			logWarn("Cannot get the NWS alerts for", dataArr[i].Station[0]+".", err)
+			continue
+		}
+		dataArr[i].NWSAlerts = alerts
+	}
+}
+
+// nwsAlertEvents raises an alert for each severe or extreme NWS alert at the station. The
+// lesser ones, ala a Small Craft Advisory, only show with the station.
+func nwsAlertEvents(data *WeatherData) (events []alertEvent) {
+	for _, alert := range data.NWSAlerts {
+		if alert.Severity == "Severe" || alert.Severity == "Extreme" {
+			events = append(events, alertEvent{Station: data.Station[1], Kind: "nws", Message: alert.String()})
+		}
+	}
+	return events
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	stdjson "encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonSchema describes a Go type as an OpenAPI schema, by way of its JSON tags, so the
+// spec never drifts from what the server actually sends
+func jsonSchema(kind reflect.Type) map[string]interface{} {
+	switch kind.Kind() {
+	case reflect.Ptr:
+		return jsonSchema(kind.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(kind.Elem()), "minItems": kind.Len(), "maxItems": kind.Len()}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(kind.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(kind.Elem())}
+	case reflect.Struct:
+		if kind == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		properties := make(map[string]interface{})
+		for i := 0; i < kind.NumField(); i++ {
+			field := kind.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || field.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchema(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
+
+// openAPIGet describes a GET endpoint answering with schema in the given content types
+func openAPIGet(summary string, schema map[string]interface{}, contentTypes ...string) map[string]interface{} {
+	content := make(map[string]interface{})
+	for _, contentType := range contentTypes {
+		if strings.HasPrefix(contentType, "application/json") {
+			content[contentType] = map[string]interface{}{"schema": schema}
+		} else {
+			content[contentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+	}
+	return map[string]interface{}{"get": map[string]interface{}{
+		"summary": summary,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{"description": "OK", "content": content},
+			"401": map[string]interface{}{"description": "Unknown or missing token"},
+			"403": map[string]interface{}{"description": "Token not allowed here"},
+		},
+	}}
+}
+
+// openAPISpec is the OpenAPI 3 description of the server
+func openAPISpec() map[string]interface{} {
+	ref := func(name string) map[string]interface{} {
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	list := func(name string) map[string]interface{} {
+		return map[string]interface{}{"type": "array", "items": ref(name)}
+	}
+	stations := openAPIGet("Latest cooked data of every station. Ask for CSV or Prometheus text with Accept or ?format=csv|prometheus.",
+		list("StationReport"), "application/json", "text/csv", "text/plain; version=0.0.4")
+	station := openAPIGet("Latest cooked data of one station", ref("StationReport"), "application/json", "text/csv", "text/plain; version=0.0.4")
+	spec := openAPIGet("This document", map[string]interface{}{"type": "object"}, "application/json")
+	spec["get"].(map[string]interface{})["security"] = []interface{}{}
+	station["parameters"] = []interface{}{map[string]interface{}{
+		"name": "handle", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+	}}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "weatherstem serve",
+			"version": toolVersion,
+		},
+		"paths": map[string]interface{}{
+			"/api/stations":          stations,
+			"/api/stations/{handle}": station,
+			"/api/area":              openAPIGet("All the stations rolled up into one", ref("AreaConditions"), "application/json"),
+			"/api/orig":              openAPIGet("The API results as they came", list("WeatherInfo"), "application/json"),
+			"/api/status":            openAPIGet("Whether the API is up, or the data is degraded", ref("PollStatus"), "application/json"),
+			"/report":                openAPIGet("Conditions report", nil, "text/html"),
+			"/kml":                   openAPIGet("Stations as KML placemarks", nil, "application/vnd.google-earth.kml+xml"),
+			"/kml/link":              openAPIGet("KML NetworkLink refreshing /kml", nil, "application/vnd.google-earth.kml+xml"),
+			"/rss":                   openAPIGet("RSS feed of station updates", nil, "application/rss+xml"),
+			"/openapi.json":          spec,
+		},
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"StationReport":  jsonSchema(reflect.TypeOf(stationReport{})),
+				"WeatherData":    jsonSchema(reflect.TypeOf(WeatherData{})),
+				"WeatherUnits":   jsonSchema(reflect.TypeOf(WeatherUnits{})),
+				"AreaConditions": jsonSchema(reflect.TypeOf(AreaConditions{})),
+				"WeatherInfo":    jsonSchema(reflect.TypeOf(WeatherInfo{})),
+				"PollStatus":     jsonSchema(reflect.TypeOf(pollStatus{})),
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"token":  map[string]interface{}{"type": "apiKey", "in": "query", "name": "token"},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"token": []string{}},
+		},
+	}
+}
+
+// handleOpenAPI serves /openapi.json. It is the only endpoint without a token.
+func (ws *weatherServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	// The spec is all maps, which encoding/json handles best
+	jdata, err := stdjson.MarshalIndent(openAPISpec(), "", "  ")
+	if err != nil {
+		http.Error(w, "Cannot marshal the spec", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jdata)
+}
+
+// negotiateFormat picks json, csv or prometheus from ?format or the Accept header
+func negotiateFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(accept, ";")[0])
+		switch mediaType {
+		case "application/json":
+			return "json"
+		case "text/csv":
+			return "csv"
+		case "text/plain", "application/openmetrics-text":
+			return "prometheus"
+		}
+	}
+	return "json"
+}
+
+// metricFamilies are the metrics any of the stations has, in the order they come, and
+// each station's metrics by name. Not every station has every one, ala aqi without an
+// air quality sensor.
+func metricFamilies(report []stationReport) (families []stationMetric, byStation []map[string]stationMetric) {
+	seen := make(map[string]bool)
+	for i := range report {
+		metrics := make(map[string]stationMetric)
+		for _, metric := range stationMetrics(&report[i].Data, &report[i].Units) {
+			metrics[metric.Name] = metric
+			if !seen[metric.Name] {
+				seen[metric.Name] = true
+				families = append(families, metric)
+			}
+		}
+		byStation = append(byStation, metrics)
+	}
+	return families, byStation
+}
+
+// writeStationsCSV sends the stations as CSV, a row each, with the units in columns too.
+// A metric a station does not have is left blank.
+func writeStationsCSV(w http.ResponseWriter, report []stationReport) {
+	var out bytes.Buffer
+	table := csv.NewWriter(&out)
+	families, byStation := metricFamilies(report)
+	header := []string{"station", "name", "time"}
+	for _, family := range families {
+		header = append(header, family.Name, family.Name+"_unit")
+	}
+	table.Write(header)
+	for i := range report {
+		data := &report[i].Data
+		row := []string{data.Station[0], data.Station[1], data.Station[2]}
+		for _, family := range families {
+			metric, ok := byStation[i][family.Name]
+			if !ok {
+				row = append(row, "", "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(metric.Value, 'f', -1, 64), metric.Unit)
+		}
+		table.Write(row)
+	}
+	table.Flush()
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Write(out.Bytes())
+}
+
+// writeStationsPrometheus sends the stations in the Prometheus text format, ala
+// weatherstem_temp{station="ponceinlet",name="Ponce Inlet",unit="°F"} 84.2
+func writeStationsPrometheus(w http.ResponseWriter, report []stationReport) {
+	var out bytes.Buffer
+	if len(report) == 0 {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		return
+	}
+	// Samples have to be grouped by metric, so go metric by metric, leaving out the
+	// stations without one
+	families, byStation := metricFamilies(report)
+	for _, family := range families {
+		fmt.Fprintf(&out, "# HELP weatherstem_%s %s\n# TYPE weatherstem_%s gauge\n", family.Name, family.Help, family.Name)
+		for i := range report {
+			data := &report[i].Data
+			metric, ok := byStation[i][family.Name]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&out, "weatherstem_%s{station=%q,name=%q,unit=%q} %s\n", metric.Name, data.Station[0], data.Station[1], metric.Unit, strconv.FormatFloat(metric.Value, 'f', -1, 64))
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(out.Bytes())
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	registerSink("otlp", func(target string) (outputSink, error) {
+		if target == "" {
+			return nil, fmt.Errorf("otlp needs a collector URL, ala http://localhost:4318")
+		}
+		return otlpSink(target), nil
+	})
+}
+
+// otlpSink sends the readings as OpenTelemetry gauges to a collector, using OTLP over
+// HTTP with the JSON encoding, so no protobuf is needed. Each station is a resource,
+// with its handle, name and domain as attributes. Any "otlp_headers" in the config go
+// along, for vendors who want an API key.
+type otlpSink string
+
+// OTLP JSON, just the parts for gauges
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name  string `json:"name"`
+	Unit  string `json:"unit"`
+	Gauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	} `json:"gauge"`
+}
+
+type otlpDataPoint struct {
+	TimeUnixNano string  `json:"timeUnixNano"`
+	AsDouble     float64 `json:"asDouble"`
+}
+
+func otlpString(key, value string) (attribute otlpAttribute) {
+	attribute.Key = key
+	attribute.Value.StringValue = value
+	return attribute
+}
+
+// otlpStation is one station's readings as a resource full of gauges
+func otlpStation(data *WeatherData, units *WeatherUnits, domain string, when time.Time) (resource otlpResourceMetrics) {
+	if reading, ok := readingTime(data); ok {
+		when = reading
+	}
+	resource.Resource.Attributes = []otlpAttribute{
+		otlpString("service.name", "weatherstem-cli"),
+		otlpString("station.handle", data.Station[0]),
+		otlpString("station.name", data.Station[1]),
+		otlpString("station.domain", domain),
+	}
+	var scope otlpScopeMetrics
+	scope.Scope.Name = "weatherstem-cli"
+	scope.Scope.Version = toolVersion
+	gauge := func(name, unit string, value float64) {
+		metric := otlpMetric{Name: "weatherstem." + name, Unit: html.UnescapeString(unit)}
+		metric.Gauge.DataPoints = []otlpDataPoint{{strconv.FormatInt(when.UnixNano(), 10), value}}
+		scope.Metrics = append(scope.Metrics, metric)
+	}
+	gauge("temperature", units.Temperature[0], data.Temperature[0])
+	gauge("dewpoint", units.Temperature[1], data.Temperature[1])
+	gauge("wbgt", units.Temperature[2], data.Temperature[2])
+	gauge("heat_index", units.Temperature[4], data.Temperature[4])
+	gauge("wind_chill", units.Temperature[3], data.Temperature[3])
+	gauge("humidity", "%", data.Humidity)
+	gauge("wind.speed", units.Windspeed[0], data.Windspeed[0])
+	gauge("wind.gust", units.Windspeed[1], data.Windspeed[1])
+	gauge("wind.direction", "deg", data.Windspeed[2])
+	gauge("pressure", units.Pressure, data.Pressure)
+	gauge("rain", units.Rain[0], data.Rain[0])
+	gauge("rain.rate", units.Rain[1], data.Rain[1])
+	gauge("solar", units.Sun[0], data.Sun[0])
+	gauge("uv", "1", data.Sun[1])
+	resource.ScopeMetrics = []otlpScopeMetrics{scope}
+	return resource
+}
+
+func (endpoint otlpSink) Send(config *configSettings, batch *sinkBatch) error {
+	domains := make(map[string]string)
+	for _, info := range batch.Orig {
+		domains[info.WeatherStation.Handle] = info.WeatherStation.Domain.Handle
+	}
+	var request otlpRequest
+	for i := range batch.Data {
+		request.ResourceMetrics = append(request.ResourceMetrics, otlpStation(&batch.Data[i], &batch.Units[i], domains[batch.Data[i].Station[0]], batch.Time))
+	}
+	jdata, err := marshalJSONCompact(request)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(string(endpoint), "/")
+	if !strings.HasSuffix(url, "/v1/metrics") {
+		url += "/v1/metrics"
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(jdata))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range config.OTLPHeaders {
+		req.Header.Set(name, value)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector said %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Just enough of Apache Parquet to write a flat table: one row group, one plain,
+// uncompressed data page per column, and every column required. The metadata is
+// Thrift in its compact protocol, written by hand below rather than pulling in a
+// Thrift library. See https://github.com/apache/parquet-format for the layout.
+
+// Parquet physical types and converted types used here
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+)
+
+// Thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// parquetColumn is one column, its values already plain encoded
+type parquetColumn struct {
+	name      string
+	kind      int32
+	converted int32 // -1 for none
+	values    bytes.Buffer
+}
+
+// newParquetColumn starts an empty column of that type, ala parquetDouble
+func newParquetColumn(name string, kind, converted int32) *parquetColumn {
+	return &parquetColumn{name: name, kind: kind, converted: converted}
+}
+
+// addInt64 appends a value to an int64 column
+func (column *parquetColumn) addInt64(value int64) {
+	binary.Write(&column.values, binary.LittleEndian, value)
+}
+
+// addDouble appends a value to a double column
+func (column *parquetColumn) addDouble(value float64) {
+	binary.Write(&column.values, binary.LittleEndian, math.Float64bits(value))
+}
+
+// addString appends a value to a byte array column
+func (column *parquetColumn) addString(value string) {
+	binary.Write(&column.values, binary.LittleEndian, uint32(len(value)))
+	column.values.WriteString(value)
+}
+
+// thriftWriter writes Thrift compact protocol structs. Field ids are written as the
+// difference from the last one in the same struct, hence the stack.
+type thriftWriter struct {
+	bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) varint(value uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	t.Write(buf[:binary.PutUvarint(buf[:], value)])
+}
+
+func (t *thriftWriter) zigzag(value int64) {
+	t.varint(uint64(value<<1) ^ uint64(value>>63))
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.WriteByte(kind)
+		t.zigzag(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, value int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(value))
+}
+
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.field(id, thriftI64)
+	t.zigzag(value)
+}
+
+func (t *thriftWriter) str(id int16, value string) {
+	t.field(id, thriftBinary)
+	t.rawString(value)
+}
+
+func (t *thriftWriter) rawString(value string) {
+	t.varint(uint64(len(value)))
+	t.WriteString(value)
+}
+
+// list starts a list field of size elements, which follow without field headers
+func (t *thriftWriter) list(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.WriteByte(byte(size)<<4 | kind)
+	} else {
+		t.WriteByte(0xf0 | kind)
+		t.varint(uint64(size))
+	}
+}
+
+// begin starts a struct, as a field when id is not zero or a list element when it is
+func (t *thriftWriter) begin(id int16) {
+	if id != 0 {
+		t.field(id, thriftStruct)
+	}
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+// end stops the struct begin started
+func (t *thriftWriter) end() {
+	t.WriteByte(0)
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// writeParquet writes the columns, all rows long, as a Parquet file. The keyValues,
+// key then value, go in the file metadata.
+func writeParquet(w io.Writer, columns []*parquetColumn, rows int, keyValues ...string) error {
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(columns))
+	offset := int64(4)
+	if _, err := io.WriteString(w, "PAR1"); err != nil {
+		return err
+	}
+
+	for c, column := range columns {
+		var page thriftWriter
+		page.i32(1, 0) // DATA_PAGE
+		page.i32(2, int32(column.values.Len()))
+		page.i32(3, int32(column.values.Len()))
+		page.begin(5)
+		page.i32(1, int32(rows))
+		page.i32(2, 0) // PLAIN
+		page.i32(3, 3) // RLE, for the levels there are none of
+		page.i32(4, 3)
+		page.end()
+		page.WriteByte(0)
+
+		chunks[c] = chunk{offset: offset, size: int64(page.Len() + column.values.Len())}
+		if _, err := w.Write(page.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.Write(column.values.Bytes()); err != nil {
+			return err
+		}
+		offset += chunks[c].size
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(columns)+1)
+	meta.begin(0)
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.end()
+	for _, column := range columns {
+		meta.begin(0)
+		meta.i32(1, column.kind)
+		meta.i32(3, 0) // REQUIRED
+		meta.str(4, column.name)
+		if column.converted >= 0 {
+			meta.i32(6, column.converted)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(rows))
+
+	meta.list(4, thriftStruct, 1)
+	meta.begin(0)
+	meta.list(1, thriftStruct, len(columns))
+	var total int64
+	for c, column := range columns {
+		meta.begin(0)
+		meta.i64(2, chunks[c].offset)
+		meta.begin(3)
+		meta.i32(1, column.kind)
+		meta.list(2, thriftI32, 2)
+		meta.zigzag(0) // PLAIN
+		meta.zigzag(3) // RLE
+		meta.list(3, thriftBinary, 1)
+		meta.rawString(column.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(rows))
+		meta.i64(6, chunks[c].size)
+		meta.i64(7, chunks[c].size)
+		meta.i64(9, chunks[c].offset)
+		meta.end()
+		meta.end()
+		total += chunks[c].size
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(rows))
+	meta.end()
+
+	if len(keyValues) > 1 {
+		meta.list(5, thriftStruct, len(keyValues)/2)
+		for i := 0; i+1 < len(keyValues); i += 2 {
+			meta.begin(0)
+			meta.str(1, keyValues[i])
+			meta.str(2, keyValues[i+1])
+			meta.end()
+		}
+	}
+	meta.str(6, "weatherstem-cli version "+toolVersion)
+	meta.WriteByte(0)
+
+	if _, err := w.Write(meta.Bytes()); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(meta.Len())); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "PAR1")
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/loraxipam/weatherstem-cli/weatherapi"
+)
+
+// stationError stands in for a station the API could not give us, so one sick station
+// does not take the rest down with it
+type stationError struct {
+	Label   string `json:"label"`
+	Station string `json:"station"`
+	Error   string `json:"error"`
+}
+
+// unmarshalWeatherInfo parses the API results a station at a time, see decodeWeatherInfo.
+// err is only for results that are no JSON array at all.
+func unmarshalWeatherInfo(weatherBytes []byte, stations []string) (weatherArr []WeatherInfo, failed []stationError, err error) {
+	failed, err = decodeWeatherInfo(bytes.NewReader(weatherBytes), stations, func(info WeatherInfo) {
+		weatherArr = append(weatherArr, info)
+	})
+	return weatherArr, failed, err
+}
+
+// decodeWeatherInfo reads the API results as they come, handing each station on to each
+// once it is decoded, see weatherapi.DecodeStation. Stations that do not parse, that come
+// back as an error, or that are missing altogether become stationErrors. Stations that
+// parse with something to put up with, ala numbers for strings while the station is down,
+// get a warning. Results that break off partway cost only the stations after the break.
+// err is only for results that are no JSON array at all.
+func decodeWeatherInfo(results io.Reader, stations []string, each func(info WeatherInfo)) (failed []stationError, err error) {
+	answered := make(map[string]bool)
+	var unnamed []int // where the results with no handle are in failed, by their place
+	count := 0
+	err = weatherapi.Stream(results, func(result stdjson.RawMessage) error {
+		count++
+		info, diagnostics, err := weatherapi.DecodeStation(result)
+		handle := info.WeatherStation.Handle
+		switch {
+		case handle == "":
+			if err == nil {
+				err = errors.New("no station in the result")
+			}
+			unnamed = append(unnamed, len(failed))
+			failed = append(failed, stationError{Label: "error", Station: strconv.Itoa(count), Error: err.Error()})
+		case err != nil:
+			failed = append(failed, stationError{Label: "error", Station: handle, Error: err.Error()})
+		default:
+			if len(diagnostics) > 0 {
+				logWarn("Station", handle, "parsed with fixes:", strings.Join(diagnostics, ", "))
+			}
+			each(info)
+		}
+		answered[handle] = true
+		return nil
+	})
+	if err != nil && count == 0 {
+		return nil, err
+	}
+	if err != nil {
+		logWarn("API results broke off after", count, "stations.", err)
+	}
+
+	// Results with no handle are taken to be the station asked about in their place, when
+	// there are as many results as stations
+	for _, i := range unnamed {
+		place, _ := strconv.Atoi(failed[i].Station)
+		if err == nil && count == len(stations) {
+			failed[i].Station = stationHandle(stations[place-1])
+			answered[failed[i].Station] = true
+		} else {
+			failed[i].Station = fmt.Sprintf("#%d", place)
+		}
+	}
+	for _, station := range stations {
+		if handle := stationHandle(station); !answered[handle] {
+			failed = append(failed, stationError{Label: "error", Station: handle, Error: "missing from the API results"})
+		}
+	}
+	return failed, nil
+}
+
+// logStationErrors says which stations failed
+func logStationErrors(failed []stationError) {
+	for _, f := range failed {
+		logWarn("Station", f.Station, "failed.", f.Error)
+	}
+}
+
+// PrintStationErrorJSON shows a failed station as JSON, in place of its data
+func (f *stationError) PrintStationErrorJSON() {
+	jdata, err := marshalJSON(f)
+	if err != nil {
+		logError("Cannot marshal station error", err)
+		return
+	}
+	fmt.Printf("%s\n", redactPayload(jdata))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// Size of the terminal chart, not counting the axis labels
+	plotHeight   = 12
+	plotMaxWidth = 72
+)
+
+// metricSeries pulls one metric, by its stationMetrics name, out of a station's history
+func metricSeries(records []historyRecord, metric string) (times []time.Time, values []float64, unit string) {
+	for i := range records {
+		for _, m := range stationMetrics(&records[i].Data, &records[i].Units) {
+			if m.Name == metric {
+				times = append(times, records[i].Time)
+				values = append(values, m.Value)
+				unit = m.Unit
+				break
+			}
+		}
+	}
+	return times, values, unit
+}
+
+// metricNames lists what can be plotted, ala "temp, dewpoint, wbgt"
+func metricNames() string {
+	var names []string
+	for _, m := range stationMetrics(&WeatherData{}, &WeatherUnits{}) {
+		names = append(names, m.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// resample squeezes the values into at most width columns, averaging each column
+func resample(values []float64, width int) []float64 {
+	if len(values) <= width {
+		return values
+	}
+	columns := make([]float64, width)
+	for c := range columns {
+		from, to := c*len(values)/width, (c+1)*len(values)/width
+		for _, value := range values[from:to] {
+			columns[c] += value
+		}
+		columns[c] /= float64(to - from)
+	}
+	return columns
+}
+
+// plotLines draws the values as a line chart with box drawing characters, asciigraph
+// style, with the scale down the left
+func plotLines(values []float64) (lines []string) {
+	low, high := values[0], values[0]
+	for _, value := range values {
+		low, high = math.Min(low, value), math.Max(high, value)
+	}
+	if high == low {
+		high, low = high+1, low-1
+	}
+	row := func(value float64) int {
+		return int(math.Round((high - value) / (high - low) * (plotHeight - 1)))
+	}
+
+	grid := make([][]rune, plotHeight)
+	for r := range grid {
+		grid[r] = []rune(strings.Repeat(" ", len(values)))
+	}
+	grid[row(values[0])][0] = '─'
+	for c := 1; c < len(values); c++ {
+		from, to := row(values[c-1]), row(values[c])
+		switch {
+		case from == to:
+			grid[to][c] = '─'
+		case from > to: // going up
+			grid[from][c], grid[to][c] = '╯', '╭'
+			for r := to + 1; r < from; r++ {
+				grid[r][c] = '│'
+			}
+		default: // going down
+			grid[from][c], grid[to][c] = '╮', '╰'
+			for r := from + 1; r < to; r++ {
+				grid[r][c] = '│'
+			}
+		}
+	}
+
+	// Enough decimals for the labels to differ, ala pressure in inHg
+	step := (high - low) / (plotHeight - 1)
+	decimals := int(math.Max(1, math.Min(4, math.Ceil(-math.Log10(step)))))
+	for r := range grid {
+		label := high - float64(r)*step
+		lines = append(lines, fmt.Sprintf("%8.*f ┤%s", decimals, label, strings.TrimRight(string(grid[r]), " ")))
+	}
+	return lines
+}
+
+// plotCommand draws a metric's history in the terminal, one chart per station, ala
+// 'weatherstem plot -station ponceinlet -metric temp -since 24h'
+func plotCommand(config *configSettings, args []string) (err error) {
+	var (
+		station, metric string
+		since           time.Duration
+	)
+	flags := flag.NewFlagSet("plot", flag.ExitOnError)
+	flags.StringVar(&station, "station", "", "Only this station, by handle or alias")
+	flags.StringVar(&metric, "metric", "temp", "What to plot: "+metricNames())
+	flags.DurationVar(&since, "since", 24*time.Hour, "How far back to plot")
+	flags.Parse(args)
+	station = config.resolveStation(station)
+
+	if config.History.File == "" {
+		return errNoHistory
+	}
+	if !strings.Contains(", "+metricNames()+", ", ", "+metric+", ") {
+		return fmt.Errorf("No metric %s, it is one of %s", metric, metricNames())
+	}
+
+	records, err := readHistory(config, time.Now().Add(-since))
+	if err != nil {
+		return err
+	}
+	handles, byStation := historyByStation(records)
+	if station != "" {
+		handles = []string{station}
+	}
+
+	for _, handle := range handles {
+		times, values, unit := metricSeries(byStation[handle], metric)
+		if len(values) < 2 {
+			return fmt.Errorf("Not enough history for station %s in the last %s", handle, since)
+		}
+		fmt.Printf("%s (%s) %s, %s\n", byStation[handle][0].Data.Station[1], handle, metric, unit)
+		columns := resample(values, plotMaxWidth)
+		for _, line := range plotLines(columns) {
+			fmt.Println(line)
+		}
+		start, end := times[0].Local().Format("01-02 15:04"), times[len(times)-1].Local().Format("01-02 15:04")
+		fmt.Printf("%9s└%s\n", "", strings.Repeat("─", len(columns)))
+		fmt.Printf("%10s%s%*s\n", "", start, len(columns)-len(start), end)
+	}
+	return nil
+}
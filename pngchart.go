@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Layout of the PNG charts, in pixels. Each metric gets its own panel, one above the
+// other, as they rarely share a unit.
+const (
+	chartWidth       = 720
+	chartPanelHeight = 140
+	chartMarginLeft  = 72
+	chartMarginRight = 12
+	chartTitleHeight = 28
+	chartPanelGap    = 26
+	chartFooter      = 26
+	chartFontScale   = 2
+)
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartInk        = color.RGBA{40, 40, 40, 255}
+	chartGrid       = color.RGBA{221, 221, 221, 255}
+	chartLines      = []color.RGBA{{33, 113, 181, 255}, {217, 72, 1, 255}, {35, 139, 69, 255}, {106, 81, 163, 255}}
+)
+
+// chartFont is a 3x5 pixel font, just enough for names, numbers, units and times.
+// Lower case is drawn as upper case; anything else as a blank.
+var chartFont = map[rune][5]string{
+	'0': {"###", "# #", "# #", "# #", "###"}, '1': {" # ", "## ", " # ", " # ", "###"},
+	'2': {"###", "  #", "###", "#  ", "###"}, '3': {"###", "  #", " ##", "  #", "###"},
+	'4': {"# #", "# #", "###", "  #", "  #"}, '5': {"###", "#  ", "###", "  #", "###"},
+	'6': {"###", "#  ", "###", "# #", "###"}, '7': {"###", "  #", " # ", " # ", " # "},
+	'8': {"###", "# #", "###", "# #", "###"}, '9': {"###", "# #", "###", "  #", "###"},
+	'A': {" # ", "# #", "###", "# #", "# #"}, 'B': {"## ", "# #", "## ", "# #", "## "},
+	'C': {" ##", "#  ", "#  ", "#  ", " ##"}, 'D': {"## ", "# #", "# #", "# #", "## "},
+	'E': {"###", "#  ", "## ", "#  ", "###"}, 'F': {"###", "#  ", "## ", "#  ", "#  "},
+	'G': {" ##", "#  ", "# #", "# #", " ##"}, 'H': {"# #", "# #", "###", "# #", "# #"},
+	'I': {"###", " # ", " # ", " # ", "###"}, 'J': {"  #", "  #", "  #", "# #", " # "},
+	'K': {"# #", "# #", "## ", "# #", "# #"}, 'L': {"#  ", "#  ", "#  ", "#  ", "###"},
+	'M': {"# #", "###", "###", "# #", "# #"}, 'N': {"## ", "# #", "# #", "# #", "# #"},
+	'O': {" # ", "# #", "# #", "# #", " # "}, 'P': {"## ", "# #", "## ", "#  ", "#  "},
+	'Q': {" # ", "# #", "# #", "## ", " ##"}, 'R': {"## ", "# #", "## ", "# #", "# #"},
+	'S': {" ##", "#  ", " # ", "  #", "## "}, 'T': {"###", " # ", " # ", " # ", " # "},
+	'U': {"# #", "# #", "# #", "# #", "###"}, 'V': {"# #", "# #", "# #", "# #", " # "},
+	'W': {"# #", "# #", "###", "###", "# #"}, 'X': {"# #", "# #", " # ", "# #", "# #"},
+	'Y': {"# #", "# #", " # ", " # ", " # "}, 'Z': {"###", "  #", " # ", "#  ", "###"},
+	'.': {"   ", "   ", "   ", "   ", " # "}, '-': {"   ", "   ", "###", "   ", "   "},
+	':': {"   ", " # ", "   ", " # ", "   "}, '/': {"  #", "  #", " # ", "#  ", "#  "},
+	'%': {"# #", "  #", " # ", "#  ", "# #"}, '°': {"## ", "## ", "   ", "   ", "   "},
+	'(': {" # ", "#  ", "#  ", "#  ", " # "}, ')': {" # ", "  #", "  #", "  #", " # "},
+	'²': {"## ", " # ", "## ", "   ", "   "}, ',': {"   ", "   ", "   ", " # ", "#  "},
+}
+
+// chartCanvas is an image with a few drawing helpers
+type chartCanvas struct {
+	*image.RGBA
+}
+
+// text writes in the chart font with its top left corner at x, y
+func (canvas chartCanvas) text(x, y int, s string, ink color.Color) {
+	for _, r := range strings.ToUpper(s) {
+		for row, bits := range chartFont[r] {
+			for col, bit := range bits {
+				if bit == '#' {
+					draw.Draw(canvas, image.Rect(x+col*chartFontScale, y+row*chartFontScale, x+(col+1)*chartFontScale, y+(row+1)*chartFontScale), image.NewUniform(ink), image.Point{}, draw.Src)
+				}
+			}
+		}
+		x += 4 * chartFontScale
+	}
+}
+
+// textWidth is how wide text comes out, to right align it
+func textWidth(s string) int {
+	return len([]rune(s)) * 4 * chartFontScale
+}
+
+// line draws two pixels thick from one point to the other, per Bresenham
+func (canvas chartCanvas) line(x0, y0, x1, y1 int, ink color.Color) {
+	dx, dy := int(math.Abs(float64(x1-x0))), -int(math.Abs(float64(y1-y0)))
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		canvas.Set(x0, y0, ink)
+		canvas.Set(x0, y0+1, ink)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// RenderChartPNG draws a station's history, one panel per metric (stationMetrics names),
+// over the time from since to now
+func RenderChartPNG(w io.Writer, records []historyRecord, metrics []string, since, now time.Time) error {
+	if len(records) == 0 {
+		return fmt.Errorf("no history to chart")
+	}
+	height := chartTitleHeight + len(metrics)*(chartPanelHeight+chartPanelGap) + chartFooter
+	canvas := chartCanvas{image.NewRGBA(image.Rect(0, 0, chartWidth, height))}
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+	canvas.text(chartMarginLeft, 8, records[0].Data.Station[1], chartInk)
+
+	left, right := chartMarginLeft, chartWidth-chartMarginRight
+	x := func(when time.Time) int {
+		return left + int(float64(right-left)*when.Sub(since).Seconds()/now.Sub(since).Seconds())
+	}
+	for i, metric := range metrics {
+		top := chartTitleHeight + i*(chartPanelHeight+chartPanelGap) + chartPanelGap - 8
+		bottom := top + chartPanelHeight
+		times, values, unit := metricSeries(records, metric)
+		canvas.text(left, top-14, strings.TrimSpace(metric+" "+unit), chartLines[i%len(chartLines)])
+		if len(values) == 0 {
+			continue
+		}
+
+		low, high := values[0], values[0]
+		for _, value := range values {
+			low, high = math.Min(low, value), math.Max(high, value)
+		}
+		if high == low {
+			high, low = high+1, low-1
+		}
+		y := func(value float64) int {
+			return bottom - int(float64(bottom-top)*(value-low)/(high-low))
+		}
+		decimals := int(math.Max(0, math.Min(3, math.Ceil(-math.Log10((high-low)/4))+1)))
+		for g := 0; g <= 4; g++ {
+			value := low + float64(g)*(high-low)/4
+			gy := y(value)
+			draw.Draw(canvas, image.Rect(left, gy, right, gy+1), image.NewUniform(chartGrid), image.Point{}, draw.Src)
+			label := fmt.Sprintf("%.*f", decimals, value)
+			canvas.text(left-8-textWidth(label), gy-5, label, chartInk)
+		}
+		draw.Draw(canvas, image.Rect(left, top, left+1, bottom+1), image.NewUniform(chartInk), image.Point{}, draw.Src)
+
+		for j := 1; j < len(values); j++ {
+			canvas.line(x(times[j-1]), y(values[j-1]), x(times[j]), y(values[j]), chartLines[i%len(chartLines)])
+		}
+	}
+
+	start, end := since.Local().Format("01-02 15:04"), now.Local().Format("01-02 15:04")
+	canvas.text(left, height-chartFooter+8, start, chartInk)
+	canvas.text(right-textWidth(end), height-chartFooter+8, end, chartInk)
+	return png.Encode(w, canvas)
+}
+
+// stationChart renders the station's chart to memory, for the reports to embed
+func stationChart(records []historyRecord, metrics []string, since, now time.Time) ([]byte, error) {
+	var chart bytes.Buffer
+	err := RenderChartPNG(&chart, records, metrics, since, now)
+	return chart.Bytes(), err
+}
+
+// chartStations charts each station's history since then for the reports and, with a
+// file name, writes them out too, ala "daily-ponceinlet.png" when there are several
+func chartStations(config *configSettings, dataArr []WeatherData, metrics []string, since time.Duration, filename string) error {
+	for _, metric := range metrics {
+		if !strings.Contains(", "+metricNames()+", ", ", "+metric+", ") {
+			return fmt.Errorf("No metric %s, it is one of %s", metric, metricNames())
+		}
+	}
+	now := time.Now()
+	records, err := readHistory(config, now.Add(-since))
+	if err != nil {
+		return err
+	}
+	_, byStation := historyByStation(records)
+	for i := range dataArr {
+		handle := dataArr[i].Station[0]
+		if len(byStation[handle]) == 0 {
+			continue
+		}
+		if dataArr[i].chart, err = stationChart(byStation[handle], metrics, now.Add(-since), now); err != nil {
+			return err
+		}
+		if filename == "" {
+			continue
+		}
+		outputFile := filename
+		if len(dataArr) > 1 {
+			ext := filepath.Ext(filename)
+			outputFile = strings.TrimSuffix(filename, ext) + "-" + handle + ext
+		}
+		if err = ioutil.WriteFile(outputFile, dataArr[i].chart, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
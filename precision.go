@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// precisionQuantities are what the config's "precision" and -precision can set the
+// decimals of
+var precisionQuantities = []string{"temp", "humidity", "wind", "pressure", "rain", "solar", "distance"}
+
+// metricQuantities say which quantity each of the stationMetrics is, for the CSV export
+var metricQuantities = map[string]string{
+	"temp": "temp", "dewpoint": "temp", "wbgt": "temp", "windchill": "temp", "heatindex": "temp",
+	"humidity": "humidity", "windspeed": "wind", "gust": "wind", "pressure": "pressure",
+	"rain": "rain", "rainrate": "rain", "solar": "solar", "distance": "distance",
+}
+
+// settlePrecision is how many decimals to show of each quantity: the config's, then
+// -precision's on top, ala "pressure=3,temp=1,wind=0". A quantity missing from it is
+// shown the way each output always has.
+func settlePrecision(config map[string]int, list string) (map[string]int, error) {
+	precision := make(map[string]int)
+	set := func(quantity string, digits int) error {
+		if !contains(precisionQuantities, quantity) {
+			return fmt.Errorf("precision is for %s, not %q", strings.Join(precisionQuantities, ", "), quantity)
+		}
+		if digits < 0 || digits > 6 {
+			return fmt.Errorf("precision of %s is 0 to 6 decimals, not %d", quantity, digits)
+		}
+		precision[quantity] = digits
+		return nil
+	}
+	quantities := make([]string, 0, len(config))
+	for quantity := range config {
+		quantities = append(quantities, quantity)
+	}
+	sort.Strings(quantities)
+	for _, quantity := range quantities {
+		if err := set(quantity, config[quantity]); err != nil {
+			return nil, err
+		}
+	}
+	if list == "" {
+		return precision, nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("precision is quantity=decimals, ala pressure=3,temp=1, not %q", pair)
+		}
+		digits, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("precision of %s is a number of decimals, not %q", parts[0], parts[1])
+		}
+		if err = set(strings.TrimSpace(parts[0]), digits); err != nil {
+			return nil, err
+		}
+	}
+	return precision, nil
+}
+
+// decimals is how many decimals to show of a quantity, or otherwise when none are set
+func decimals(quantity string, otherwise int) int {
+	if digits, ok := inForce().precision[quantity]; ok {
+		return digits
+	}
+	return otherwise
+}
+
+// measured is a number of some quantity, which takes its decimals from the precision
+// when they are set there and from the format when not. printf marks it local to write
+// it the language's way, like any float64.
+type measured struct {
+	quantity string
+	value    float64
+	local    bool
+}
+
+// Format puts the number through the verb with the quantity's decimals. Plain %v stays
+// as fmt has it unless there are decimals to show.
+func (number measured) Format(f fmt.State, verb rune) {
+	digits := decimals(number.quantity, -1)
+	if verb == 'v' && digits >= 0 {
+		verb = 'f'
+	}
+	format := numberFormat(f, verb, digits)
+	if number.local {
+		fmt.Fprint(f, localPrinter.Sprintf(format, number.value))
+	} else {
+		fmt.Fprintf(f, format, number.value)
+	}
+}
+
+// measure marks a value as a number of the quantity, for its decimals to apply
+func measure(quantity string, value float64) measured {
+	return measured{quantity: quantity, value: value}
+}
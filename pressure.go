@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// Pressure tendency is measured over three hours, by convention
+	tendencyWindow = 3 * time.Hour
+	// How far a history record may be from the time we want and still stand in for it
+	tendencySlop = 30 * time.Minute
+	// Changes smaller than this (hPa per half window) count as steady
+	tendencySteady = 0.1
+	// A fall at least this big (hPa in three hours) is "falling quickly", time to batten down
+	tendencyRapidFall = -3.6
+)
+
+// Descriptions of the WMO pressure tendency characteristic codes (code table 0200)
+var tendencyCodes = [...]string{
+	"rising, then falling",
+	"rising, then steady",
+	"rising",
+	"falling or steady, then rising",
+	"steady",
+	"falling, then rising",
+	"falling, then steady",
+	"falling",
+	"steady or rising, then falling",
+}
+
+// PressureTendency is the three hour pressure change worked out from the history,
+// in the station's pressure unit, along with its WMO tendency code
+type PressureTendency struct {
+	Change      float64 `json:"change"`
+	Code        int     `json:"code"`
+	Description string  `json:"description"`
+}
+
+// pressureAt finds the history record closest to a time, if one is close enough
+func pressureAt(records []historyRecord, when time.Time) (rec historyRecord, ok bool) {
+	best := tendencySlop
+	for _, r := range records {
+		gap := r.Time.Sub(when)
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap <= best && r.Data.Pressure != 0 {
+			rec, best, ok = r, gap, true
+		}
+	}
+	return rec, ok
+}
+
+// tendencyCode classifies the two halves of the window per WMO code table 0200
+func tendencyCode(first, second float64) int {
+	net := first + second
+	up1, down1 := first > tendencySteady, first < -tendencySteady
+	up2, down2 := second > tendencySteady, second < -tendencySteady
+
+	switch {
+	case !up1 && !down1 && !up2 && !down2:
+		return 4
+	case up1 && down2 && net >= 0:
+		return 0
+	case down1 && up2 && net <= 0:
+		return 5
+	case net > 0:
+		switch {
+		case up1 && !up2:
+			return 1
+		case !up1 && up2, up2 && second > first:
+			return 3
+		default:
+			return 2
+		}
+	default:
+		switch {
+		case down1 && !down2:
+			return 6
+		case !down1 && down2, down2 && second < first:
+			return 8
+		default:
+			return 7
+		}
+	}
+}
+
+// AssessPressureTendency works out the three hour change from the station's history,
+// ending at the current reading. ok is false without three hours of history.
+func AssessPressureTendency(data *WeatherData, units *WeatherUnits, records []historyRecord, now time.Time) (tendency PressureTendency, ok bool) {
+	start, okStart := pressureAt(records, now.Add(-tendencyWindow))
+	middle, okMiddle := pressureAt(records, now.Add(-tendencyWindow/2))
+	if !okStart || !okMiddle {
+		return tendency, false
+	}
+
+	hPa := func(value float64, unit string) float64 {
+		converted, _ := convertUnit(value, unit, "hPa")
+		return converted
+	}
+	p0 := hPa(start.Data.Pressure, start.Units.Pressure)
+	p1 := hPa(middle.Data.Pressure, middle.Units.Pressure)
+	p2 := hPa(data.Pressure, units.Pressure)
+
+	tendency.Code = tendencyCode(p1-p0, p2-p1)
+	tendency.Description = tendencyCodes[tendency.Code]
+	// Pressure units have no offset, so a difference converts like a value
+	tendency.Change, _ = convertUnit(p2-p0, "hPa", units.Pressure)
+	return tendency, true
+}
+
+// pressureAlert warns when the pressure is falling quickly, which usually means weather
+func pressureAlert(data *WeatherData, units *WeatherUnits) (event alertEvent, raised bool) {
+	if data.PressureTendency == nil {
+		return event, false
+	}
+	hPa, _ := convertUnit(data.PressureTendency.Change, units.Pressure, "hPa")
+	if hPa > tendencyRapidFall {
+		return event, false
+	}
+	return alertEvent{
+		Station: data.Station[1],
+		Kind:    "pressure",
+		Message: fmt.Sprintf("Pressure falling quickly, %.1f hPa in 3 hours. Storm coming?", math.Abs(hPa)),
+	}, true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+)
+
+// Provenance says where a station's cooked data came from and what was done to it on the
+// way, for people who pass the numbers on to citizen science projects which ask.
+type Provenance struct {
+	Source          string    `json:"source"`
+	RecordID        string    `json:"record_id,omitempty"`
+	Fetched         time.Time `json:"fetched"`
+	Tool            string    `json:"tool"`
+	Transformations []string  `json:"transformations"`
+}
+
+// stampProvenance records the source of every cooked station, the API endpoint it was
+// asked of. The data and the raw results line up one for one, as cookWeatherInfo leaves them.
+func stampProvenance(c *configSettings, dataArr []WeatherData, weatherArr []WeatherInfo, fetched time.Time) {
+	for i := range dataArr {
+		dataArr[i].Provenance = &Provenance{
+			Source:          c.endpointFor(dataArr[i].Station[0]).URL,
+			RecordID:        weatherArr[i].WeatherRecord.RecordID,
+			Fetched:         fetched,
+			Tool:            "weatherstem-cli " + toolVersion,
+			Transformations: []string{"cooked from API readings"},
+		}
+	}
+}
+
+// noteTransformation adds a step to the provenance. Copies of the data share the
+// provenance, so it gets a fresh one rather than changing theirs.
+func (data *WeatherData) noteTransformation(step string) {
+	if data.Provenance == nil {
+		return
+	}
+	fresh := *data.Provenance
+	fresh.Transformations = append(append([]string(nil), fresh.Transformations...), step)
+	data.Provenance = &fresh
+}
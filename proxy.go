@@ -0,0 +1,119 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// httpTimeout is the most any one call may take, the response read and all
+	httpTimeout = 30 * time.Second
+	// httpIdlePerHost is how many kept-alive connections to hold for each host. The API
+	// and the camera hosts are asked often enough that a handful saves the handshakes.
+	httpIdlePerHost = 8
+	// httpPerHost caps the connections to any one host, so a daemon with many endpoints
+	// or cameras queues its calls instead of hammering the API
+	httpPerHost = 16
+)
+
+var (
+	sharedClient     *http.Client
+	sharedClientErr  error
+	sharedClientOnce sync.Once
+)
+
+// userAgent tells the API who is calling, ala weatherstem-cli/3.1.0
+func userAgent() string {
+	return "weatherstem-cli/" + toolVersion
+}
+
+// apiProxy picks the proxy for the API calls: "proxy" in the config (or -proxy) for all
+// of them, otherwise HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment
+func apiProxy(c *configSettings) (func(*http.Request) (*url.URL, error), error) {
+	if c.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(c.Proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("Cannot use proxy %q, it should look like http://proxy.example.com:3128", c.Proxy)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// httpClient is the one client every outbound call shares, made on the first call with
+// the config's proxy. Its connections are kept alive and pooled, and HTTP/2 is used where
+// the server has it, so a daemon or many endpoints do not shake hands on every call. A
+// caller wanting a shorter timeout copies it, ala doctor; the copy shares the pool.
+func httpClient(c *configSettings) (*http.Client, error) {
+	sharedClientOnce.Do(func() {
+		proxy, err := apiProxy(c)
+		if err != nil {
+			sharedClientErr = err
+			return
+		}
+		transport := &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          64,
+			MaxIdleConnsPerHost:   httpIdlePerHost,
+			MaxConnsPerHost:       httpPerHost,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: httpTimeout,
+			ExpectContinueTimeout: time.Second,
+		}
+		var roundTripper http.RoundTripper = transport
+		if debugHTTP {
+			roundTripper = tracingTransport{next: transport}
+		}
+		sharedClient = &http.Client{Timeout: httpTimeout, Transport: roundTripper}
+	})
+	return sharedClient, sharedClientErr
+}
+
+// readResponse reads a response body, unzipping it if the server gzipped it. Asking for
+// gzip ourselves means net/http leaves that to us.
+func readResponse(response *http.Response) ([]byte, error) {
+	body, err := openResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// unzippedBody closes the unzipping and the response body under it
+type unzippedBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (u unzippedBody) Close() error {
+	u.Reader.Close()
+	return u.body.Close()
+}
+
+// openResponse is the response body to read as it arrives, unzipped if the server gzipped
+// it. Closing it closes the response body.
+func openResponse(response *http.Response) (io.ReadCloser, error) {
+	if response.Header.Get("Content-Encoding") != "gzip" {
+		return response.Body, nil
+	}
+	unzipped, err := gzip.NewReader(response.Body)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	return unzippedBody{Reader: unzipped, body: response.Body}, nil
+}
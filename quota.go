@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// The API calls are counted in a file next to the cache, so a cron job, a status bar
+// and a server on the same key all draw on the same budget. "quota_per_hour" in the
+// config caps how many calls go out in any hour; left out, the calls are only counted.
+
+// quotaLog is every API call in the last day
+type quotaLog struct {
+	Calls []time.Time `json:"calls"`
+}
+
+// errQuota is what a call over budget gets instead of going out
+type errQuota struct {
+	perHour int
+	retry   time.Duration
+}
+
+func (e errQuota) Error() string {
+	return fmt.Sprintf("API quota of %d calls an hour used up, next call in %v", e.perHour, e.retry.Round(time.Second))
+}
+
+// quotaMutex keeps the endpoints of one run from counting their calls over each other.
+// Other runs on the same key are kept out by locking the quota file's lock file.
+var quotaMutex sync.Mutex
+
+// quotaFile is where the calls are counted
+func quotaFile(c *configSettings) string {
+	filename := cacheFile(c)
+	if filename == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(filename), "quota.json")
+}
+
+// readQuotaLog gets the calls made in the last day. No file means no calls.
+func readQuotaLog(c *configSettings, now time.Time) (calls quotaLog) {
+	quotaJSON, err := ioutil.ReadFile(quotaFile(c))
+	if err != nil || json.Unmarshal(quotaJSON, &calls) != nil {
+		return quotaLog{}
+	}
+	recent := calls.Calls[:0]
+	for _, call := range calls.Calls {
+		if now.Sub(call) < 24*time.Hour {
+			recent = append(recent, call)
+		}
+	}
+	calls.Calls = recent
+	return calls
+}
+
+// since counts the calls made after a time
+func (calls *quotaLog) since(when time.Time) (count int) {
+	for _, call := range calls.Calls {
+		if call.After(when) {
+			count++
+		}
+	}
+	return count
+}
+
+// takeQuota counts an API call about to go out, or says why it cannot. The count is read
+// and written back under the locks, so no call goes uncounted.
+func takeQuota(c *configSettings, now time.Time) (err error) {
+	filename := quotaFile(c)
+	if filename == "" {
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	unlock, err := lockFile(filename + ".lock")
+	if err != nil {
+		return fmt.Errorf("Cannot lock the API quota: %w", err)
+	}
+	defer unlock()
+
+	calls := readQuotaLog(c, now)
+	if c.QuotaPerHour > 0 {
+		lastHour := now.Add(-time.Hour)
+		if used := calls.since(lastHour); used >= c.QuotaPerHour {
+			// The oldest call in the hour has to age out first
+			oldest := calls.Calls[len(calls.Calls)-used]
+			return errQuota{perHour: c.QuotaPerHour, retry: oldest.Sub(lastHour)}
+		}
+	}
+	calls.Calls = append(calls.Calls, now)
+
+	quotaJSON, err := json.Marshal(calls)
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(filename), ".quota-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(quotaJSON); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filename)
+}
+
+// PrintQuotaStatus shows how many API calls went out today and in the last hour
+func PrintQuotaStatus(c *configSettings) {
+	now := time.Now()
+	calls := readQuotaLog(c, now)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	fmt.Printf("API calls today:     %d\n", calls.since(midnight.Add(-time.Nanosecond)))
+	if c.QuotaPerHour > 0 {
+		fmt.Printf("API calls last hour: %d of %d\n", calls.since(now.Add(-time.Hour)), c.QuotaPerHour)
+	} else {
+		fmt.Printf("API calls last hour: %d, no quota set\n", calls.since(now.Add(-time.Hour)))
+	}
+	if len(calls.Calls) > 0 {
+		fmt.Printf("Last call:           %s\n", calls.Calls[len(calls.Calls)-1].Format("2006-01-02 15:04:05"))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+func TestTakeQuota(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	ago := func(minutes ...int) (calls []time.Time) {
+		for _, m := range minutes {
+			calls = append(calls, now.Add(-time.Duration(m)*time.Minute))
+		}
+		return calls
+	}
+	tests := []struct {
+		name    string
+		perHour int
+		calls   []time.Time
+		retry   time.Duration // 0 when the call goes out
+		logged  int           // calls in the log after
+	}{
+		{"no quota counts only", 0, ago(50, 40, 30), 0, 4},
+		{"under quota", 3, ago(50, 40), 0, 3},
+		{"at quota waits for the oldest to age out", 2, ago(50, 40), 10 * time.Minute, 2},
+		{"calls over an hour ago do not count", 2, ago(90, 70, 40), 0, 4},
+		{"calls over a day ago are forgotten", 2, ago(25*60, 40), 0, 2},
+		{"the oldest in the hour, not in the log", 2, ago(120, 59, 1), time.Minute, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "quota")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			config := &configSettings{Cache: filepath.Join(dir, "api.json"), QuotaPerHour: test.perHour}
+			quotaJSON, _ := json.Marshal(quotaLog{Calls: test.calls})
+			if err = ioutil.WriteFile(quotaFile(config), quotaJSON, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err = takeQuota(config, now)
+			var quota errQuota
+			switch {
+			case test.retry == 0 && err != nil:
+				t.Fatalf("takeQuota() = %v, want the call to go out", err)
+			case test.retry != 0 && !errors.As(err, &quota):
+				t.Fatalf("takeQuota() = %v, want errQuota", err)
+			case test.retry != 0 && quota.retry != test.retry:
+				t.Errorf("retry = %v, want %v", quota.retry, test.retry)
+			}
+			if logged := len(readQuotaLog(config, now).Calls); logged != test.logged {
+				t.Errorf("%d calls logged, want %d", logged, test.logged)
+			}
+		})
+	}
+}
+
+func TestTakeQuotaConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := &configSettings{Cache: filepath.Join(dir, "api.json")}
+	now := time.Now()
+	done := make(chan error)
+	for i := 0; i < 20; i++ {
+		go func() { done <- takeQuota(config, now) }()
+	}
+	for i := 0; i < 20; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logged := len(readQuotaLog(config, now).Calls); logged != 20 {
+		t.Errorf("%d calls logged, want 20", logged)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// How much history the rain totals need: a day, plus a reading before it to start from
+const rainHistoryWindow = 25 * time.Hour
+
+// RainAccumulation is the rainfall over recent windows, worked out from the history
+// in the station's rain gauge unit. The API only gives the gauge and the rate.
+type RainAccumulation struct {
+	Hour     float64 `json:"1h"`
+	SixHours float64 `json:"6h"`
+	Day      float64 `json:"24h"`
+	Today    float64 `json:"today"`
+}
+
+// rainSince adds up what the rain gauge collected after since. The gauge resets now
+// and again (midnight, maintenance), so a drop starts a fresh count instead of
+// counting backwards. The last reading before since is the starting point, or the
+// first one after it when there is nothing earlier.
+func rainSince(records []historyRecord, unit string, since time.Time) (total float64) {
+	var last float64
+	started := false
+	for _, rec := range records {
+		gauge, _ := convertUnit(rec.Data.Rain[0], rec.Units.Rain[0], unit)
+		if started && rec.Time.After(since) {
+			if gauge >= last {
+				total += gauge - last
+			} else {
+				total += gauge
+			}
+		}
+		last, started = gauge, true
+	}
+	return total
+}
+
+// AccumulateRain works out the rain totals for a station from its history, oldest first
+func AccumulateRain(units *WeatherUnits, records []historyRecord, now time.Time) (rain RainAccumulation) {
+	local := now.Local()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+	rain.Hour = rainSince(records, units.Rain[0], now.Add(-time.Hour))
+	rain.SixHours = rainSince(records, units.Rain[0], now.Add(-6*time.Hour))
+	rain.Day = rainSince(records, units.Rain[0], now.Add(-24*time.Hour))
+	rain.Today = rainSince(records, units.Rain[0], midnight)
+	return rain
+}
+
+// previousReading is the station's latest record before this run's, at recorded
+func previousReading(records []historyRecord, recorded time.Time) (previous *historyRecord) {
+	for i := range records {
+		if records[i].Time.Before(recorded) && (previous == nil || records[i].Time.After(previous.Time)) {
+			previous = &records[i]
+		}
+	}
+	return previous
+}
+
+// rainEventAlert raises an alert when the rain rate goes from zero to something since
+// the previous reading, or back to zero, ala "Rain began at Ponce Inlet, 0.12 in/hr"
+func rainEventAlert(data *WeatherData, units *WeatherUnits, previous *historyRecord) (event alertEvent, raised bool) {
+	if previous == nil {
+		return event, false
+	}
+	event = alertEvent{Station: data.Station[1], Kind: "rain"}
+	switch was, is := previous.Data.Rain[1] > 0, data.Rain[1] > 0; {
+	case !was && is:
+		event.Message = fmt.Sprintf("Rain began at %s, %.2f %s", data.Station[1], data.Rain[1], units.Rain[1])
+	case was && !is:
+		event.Message = fmt.Sprintf("Rain stopped at %s", data.Station[1])
+	default:
+		return event, false
+	}
+	return event, true
+}
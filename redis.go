@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Without a polling interval to go by, ala from cron, readings live this long
+const redisDefaultTTL = 5 * time.Minute
+
+func init() {
+	registerSink("redis", func(target string) (outputSink, error) {
+		if !strings.Contains(target, "://") {
+			target = "redis://" + target
+		}
+		where, err := url.Parse(target)
+		if err != nil || where.Host == "" {
+			return nil, fmt.Errorf("redis needs an address, ala localhost:6379 or redis://:password@host:6379/2?ttl=10m")
+		}
+		var ttl time.Duration
+		if given := where.Query().Get("ttl"); given != "" {
+			// EX counts whole seconds, and zero or less is refused outright by redis
+			if ttl, err = time.ParseDuration(given); err != nil || ttl < time.Second {
+				return nil, fmt.Errorf("redis ttl %q should be a second or more, ala 90s or 10m", given)
+			}
+		}
+		return &redisSink{where, ttl}, nil
+	})
+}
+
+// redisSink SETs weatherstem:<handle> to each station's cooked JSON, expiring after
+// the polling interval, so home automation can read the last known conditions
+// without asking the API. The ttl parameter overrides that. RESP is simple enough
+// to speak here directly.
+type redisSink struct {
+	where *url.URL
+	ttl   time.Duration
+}
+
+// redisCommand encodes a command as a RESP array of bulk strings
+func redisCommand(writer *bufio.Writer, args ...string) {
+	fmt.Fprintf(writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+// redisReply reads one reply, failing on an error reply
+func redisReply(reader *bufio.Reader) error {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "-"):
+		return fmt.Errorf("redis said %s", line[1:])
+	case strings.HasPrefix(line, "$"):
+		// A bulk string, skip over it
+		if size, _ := strconv.Atoi(line[1:]); size >= 0 {
+			_, err = reader.Discard(size + 2)
+		}
+	}
+	return err
+}
+
+func (sink *redisSink) Send(config *configSettings, batch *sinkBatch) error {
+	ttl := sink.ttl
+	if ttl == 0 {
+		ttl = batch.Interval
+	}
+	if ttl < time.Second {
+		ttl = redisDefaultTTL
+	}
+	keys, messages, err := stationMessages(batch)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", sink.where.Host, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	writer, reader := bufio.NewWriter(conn), bufio.NewReader(conn)
+
+	replies := 0
+	if user := sink.where.User; user != nil {
+		if password, ok := user.Password(); ok && user.Username() != "" {
+			redisCommand(writer, "AUTH", user.Username(), password)
+		} else if ok {
+			redisCommand(writer, "AUTH", password)
+		} else {
+			redisCommand(writer, "AUTH", user.Username())
+		}
+		replies++
+	}
+	if db := strings.Trim(sink.where.Path, "/"); db != "" {
+		redisCommand(writer, "SELECT", db)
+		replies++
+	}
+	seconds := strconv.Itoa(int(ttl.Seconds() + 0.5))
+	for i := range messages {
+		redisCommand(writer, "SET", "weatherstem:"+keys[i], string(messages[i]), "EX", seconds)
+		replies++
+	}
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	for ; replies > 0; replies-- {
+		if err = redisReply(reader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
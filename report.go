@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// wbgtLevel names the WBGT flag level, ala the legend
+func wbgtLevel(temp float64) string {
+	switch {
+	case temp < 82.0:
+		return "normal"
+	case temp < 87.0:
+		return "Level 1"
+	case temp < 90.0:
+		return "Level 2"
+	case temp < 92.0:
+		return "Level 3"
+	default:
+		return "Level 4"
+	}
+}
+
+// reportStation is one station's part of a report, ready to render
+type reportStation struct {
+	Name    string
+	Handle  string
+	Time    string
+	WBGT    string
+	Level   string
+	Rows    [][2]string
+	Cameras []CameraInfo
+	Chart   template.URL
+}
+
+// reportStations gets the stations ready for a report. The cameras only come with
+// the raw API results, so they are matched up by handle.
+func reportStations(dataArr []WeatherData, unitArr []WeatherUnits, weatherArr []WeatherInfo) (stations []reportStation) {
+	cameras := make(map[string][]CameraInfo)
+	for _, info := range weatherArr {
+		cameras[info.WeatherStation.Handle] = info.WeatherStation.Cameras
+	}
+	for i := range dataArr {
+		data, units := &dataArr[i], &unitArr[i]
+		stations = append(stations, reportStation{
+			Name:   data.Station[1],
+			Handle: data.Station[0],
+			Time:   data.Station[2],
+			WBGT:   strings.TrimSpace(fmt.Sprintf("%s %.1f%s", WBGTFlag(data.wbgtFahrenheit(units)), data.Temperature[2], units.Temperature[2])),
+			Level:  wbgtLevel(data.wbgtFahrenheit(units)),
+			Rows: [][2]string{
+				{"Temperature", fmt.Sprintf("%.1f%s", data.Temperature[0], units.Temperature[0])},
+				{"Dewpoint", fmt.Sprintf("%.1f%s", data.Temperature[1], units.Temperature[1])},
+				{"Humidity", fmt.Sprintf("%.0f%%", data.Humidity)},
+				{"Heat index", fmt.Sprintf("%.1f%s", data.Temperature[4], units.Temperature[4])},
+				{"Wind chill", fmt.Sprintf("%.1f%s", data.Temperature[3], units.Temperature[3])},
+				{"Wind", fmt.Sprintf("%.1f%s from %.0f° %s", data.Windspeed[0], units.Windspeed[0], data.Windspeed[2], data.Wind[1])},
+				{"Gust", fmt.Sprintf("%.1f%s", data.Windspeed[1], units.Windspeed[1])},
+				{"Pressure", strings.TrimSpace(fmt.Sprintf("%.3f%s %s", data.Pressure, units.Pressure, data.PressureTrend))},
+				{"Rain", fmt.Sprintf("%.2f%s, %.2f%s", data.Rain[0], units.Rain[0], data.Rain[1], units.Rain[1])},
+				{"Solar", fmt.Sprintf("%.0f%s, UV %.0f", data.Sun[0], units.Sun[0], data.Sun[1])},
+			},
+			Cameras: cameras[data.Station[0]],
+		})
+		if data.chart != nil {
+			stations[len(stations)-1].Chart = chartDataURL(data.chart)
+		}
+		if data.AirQuality != nil {
+			last := &stations[len(stations)-1]
+			last.Rows = append(last.Rows, [2]string{"Air quality", fmt.Sprintf("%s, %s", data.AirQuality, data.AirQuality.readings())})
+		}
+	}
+	return stations
+}
+
+// PrintReportMarkdown writes a conditions report in Markdown, for pasting into a wiki
+func PrintReportMarkdown(w io.Writer, dataArr []WeatherData, unitArr []WeatherUnits, weatherArr []WeatherInfo) {
+	fmt.Fprintf(w, "# Weather conditions %s\n", time.Now().Format("2006-01-02 15:04"))
+	for _, station := range reportStations(dataArr, unitArr, weatherArr) {
+		fmt.Fprintf(w, "\n## %s (%s)\n\n", station.Name, station.Handle)
+		fmt.Fprintf(w, "**WBGT %s** (%s) at %s\n\n", station.WBGT, station.Level, station.Time)
+		fmt.Fprintln(w, "| | |")
+		fmt.Fprintln(w, "|---|---|")
+		for _, row := range station.Rows {
+			fmt.Fprintf(w, "| %s | %s |\n", row[0], row[1])
+		}
+		if len(station.Cameras) > 0 {
+			fmt.Fprintln(w)
+			for _, camera := range station.Cameras {
+				fmt.Fprintf(w, "[![%s](%s)](%s) ", camera.Name, camera.ImageURL, camera.ImageURL)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Weather conditions {{.When}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td { padding: 0.2em 1em; border-bottom: 1px solid #ddd; }
+.badge { padding: 0.2em 0.6em; border-radius: 0.4em; color: white; background: #4a4; }
+.badge.l1 { background: #cc0; color: black; } .badge.l2 { background: #e80; }
+.badge.l3 { background: #d00; } .badge.l4 { background: #000; }
+img { height: 120px; margin: 0.5em 0.5em 0 0; }
+img.chart { height: auto; max-width: 100%; }
+</style>
+</head>
+<body>
+<h1>Weather conditions {{.When}}</h1>
+{{range .Stations}}
+<h2>{{.Name}} ({{.Handle}})</h2>
+<p><span class="badge {{if eq .Level "Level 1"}}l1{{else if eq .Level "Level 2"}}l2{{else if eq .Level "Level 3"}}l3{{else if eq .Level "Level 4"}}l4{{end}}">WBGT {{.WBGT}} {{.Level}}</span> at {{.Time}}</p>
+<table>
+{{range .Rows}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{range .Cameras}}<a href="{{.ImageURL}}"><img src="{{.ImageURL}}" alt="{{.Name}}" title="{{.Name}}"></a>{{end}}
+{{if .Chart}}<p><img class="chart" src="{{.Chart}}" alt="{{.Name}} history"></p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// PrintReportHTML writes a conditions report as a web page
+func PrintReportHTML(w io.Writer, dataArr []WeatherData, unitArr []WeatherUnits, weatherArr []WeatherInfo) error {
+	return renderReportHTML(w, reportStations(dataArr, unitArr, weatherArr))
+}
+
+// renderReportHTML writes the stations, once they are ready, as a web page
+func renderReportHTML(w io.Writer, stations []reportStation) error {
+	return reportTemplate.Execute(w, struct {
+		When     string
+		Stations []reportStation
+	}{time.Now().Format("2006-01-02 15:04"), stations})
+}
+
+// chartDataURL inlines a PNG chart so the report stays one file
+func chartDataURL(chart []byte) template.URL {
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(chart))
+}
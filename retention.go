@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// historySettings is the "history" of the config. It is either just the file, ala
+// "~/.weatherstem-history.db", or a block which can also say how long to keep it:
+// {"file": "~/.weatherstem-history.db", "keep": "90d", "downsample": "5m->1h after 7d"}
+// Keep drops records older than that. Downsample thins the records older than its
+// "after" to one per station per period, the last one in it. The "5m->" part, how often
+// the records come in the first place, is only there to read well and can be left out.
+type historySettings struct {
+	File       string `json:"file"`
+	Store      string `json:"store,omitempty"`
+	Keep       string `json:"keep,omitempty"`
+	Downsample string `json:"downsample,omitempty"`
+}
+
+// UnmarshalJSON takes the file name on its own as well as the whole block
+func (settings *historySettings) UnmarshalJSON(raw []byte) error {
+	if err := json.Unmarshal(raw, &settings.File); err == nil {
+		return nil
+	}
+	type plain historySettings
+	return json.Unmarshal(raw, (*plain)(settings))
+}
+
+// takeOldStore moves the config's "history_store", from before the block had a "store",
+// into the block, so the store is looked up in one place. The two disagreeing is an error.
+func (settings *historySettings) takeOldStore(old string) error {
+	if old == "" {
+		return nil
+	}
+	if settings.Store != "" && settings.Store != old {
+		return fmt.Errorf("history store is %q in the history block but history_store is %q, keep the first", settings.Store, old)
+	}
+	logWarn(`"history_store" is deprecated, use "store" in the "history" block.`)
+	settings.Store = old
+	return nil
+}
+
+// downsamplePattern reads "5m->1h after 7d" or just "1h after 7d"
+var downsamplePattern = regexp.MustCompile(`^\s*(?:(\S+)\s*->\s*)?(\S+)\s+after\s+(\S+)\s*$`)
+
+// parseRetention is time.ParseDuration which also knows days and weeks, ala "90d", "2w"
+func parseRetention(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			count, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("bad duration %q", s)
+			}
+			return time.Duration(count * float64(unit)), nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// retentionPolicy is when records go: all of them before Before, and all but the last
+// per station per Every before ThinBefore. Zero times keep everything.
+type retentionPolicy struct {
+	Before, ThinBefore time.Time
+	Every              time.Duration
+}
+
+// policy works out the retention as of now
+func (settings *historySettings) policy(now time.Time) (policy retentionPolicy, err error) {
+	if settings.Keep != "" {
+		keep, err := parseRetention(settings.Keep)
+		if err != nil {
+			return policy, fmt.Errorf("history keep: %v", err)
+		}
+		policy.Before = now.Add(-keep)
+	}
+	if settings.Downsample != "" {
+		parts := downsamplePattern.FindStringSubmatch(settings.Downsample)
+		if parts == nil {
+			return policy, fmt.Errorf("history downsample is ala \"5m->1h after 7d\", not %q", settings.Downsample)
+		}
+		if policy.Every, err = parseRetention(parts[2]); err != nil {
+			return policy, fmt.Errorf("history downsample: %v", err)
+		}
+		after, err := parseRetention(parts[3])
+		if err != nil {
+			return policy, fmt.Errorf("history downsample: %v", err)
+		}
+		policy.ThinBefore = now.Add(-after)
+	}
+	return policy, nil
+}
+
+// empty says the policy keeps everything
+func (policy *retentionPolicy) empty() bool {
+	return policy.Before.IsZero() && (policy.ThinBefore.IsZero() || policy.Every <= 0)
+}
+
+// keeps says which of the records, given by time and station, the policy keeps
+func (policy *retentionPolicy) keeps(times []time.Time, stations []string) []bool {
+	bucket := func(i int) string {
+		return stations[i] + "/" + strconv.FormatInt(times[i].UnixNano()/int64(policy.Every), 10)
+	}
+	last := make(map[string]int)
+	thinning := !policy.ThinBefore.IsZero() && policy.Every > 0
+	if thinning {
+		for i := range times {
+			if times[i].Before(policy.ThinBefore) {
+				last[bucket(i)] = i
+			}
+		}
+	}
+	keep := make([]bool, len(times))
+	for i := range times {
+		switch {
+		case times[i].Before(policy.Before):
+		case thinning && times[i].Before(policy.ThinBefore):
+			keep[i] = last[bucket(i)] == i
+		default:
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+// compactHistory applies the configured retention to the history store, if there is
+// any, and logs what went
+func compactHistory(config *configSettings, now time.Time) error {
+	policy, err := config.History.policy(now)
+	if err != nil || policy.empty() {
+		return err
+	}
+	store, err := openHistory(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	removed, err := store.Compact(policy)
+	if removed > 0 {
+		logDebug("Compacted history, removing", removed, "records")
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetentionKeeps(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return now.Add(-time.Duration(minutes) * time.Minute) }
+	tests := []struct {
+		name     string
+		policy   retentionPolicy
+		times    []time.Time
+		stations []string
+		want     []bool
+	}{
+		{
+			name:     "empty policy keeps everything",
+			times:    []time.Time{at(600), at(5)},
+			stations: []string{"a", "a"},
+			want:     []bool{true, true},
+		},
+		{
+			name:     "keep drops what is older",
+			policy:   retentionPolicy{Before: at(60)},
+			times:    []time.Time{at(90), at(60), at(30)},
+			stations: []string{"a", "a", "a"},
+			want:     []bool{false, true, true},
+		},
+		{
+			name:     "downsample keeps the last in each period",
+			policy:   retentionPolicy{ThinBefore: at(0), Every: time.Hour},
+			times:    []time.Time{at(190), at(130), at(125), at(55), at(5)},
+			stations: []string{"a", "a", "a", "a", "a"},
+			want:     []bool{true, false, true, false, true},
+		},
+		{
+			name:     "downsample leaves the recent ones be",
+			policy:   retentionPolicy{ThinBefore: at(60), Every: time.Hour},
+			times:    []time.Time{at(130), at(125), at(50), at(45)},
+			stations: []string{"a", "a", "a", "a"},
+			want:     []bool{false, true, true, true},
+		},
+		{
+			name:     "each station is thinned on its own",
+			policy:   retentionPolicy{ThinBefore: at(0), Every: time.Hour},
+			times:    []time.Time{at(130), at(130), at(125), at(125)},
+			stations: []string{"a", "b", "a", "b"},
+			want:     []bool{false, false, true, true},
+		},
+		{
+			name:     "keep and downsample together",
+			policy:   retentionPolicy{Before: at(150), ThinBefore: at(60), Every: time.Hour},
+			times:    []time.Time{at(170), at(130), at(125), at(30)},
+			stations: []string{"a", "a", "a", "a"},
+			want:     []bool{false, false, true, true},
+		},
+		{
+			name:     "no period means no thinning",
+			policy:   retentionPolicy{ThinBefore: at(0)},
+			times:    []time.Time{at(130), at(125)},
+			stations: []string{"a", "a"},
+			want:     []bool{true, true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.keeps(test.times, test.stations); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("keeps() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHistoryPolicy(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		settings historySettings
+		want     retentionPolicy
+		bad      bool
+	}{
+		{historySettings{}, retentionPolicy{}, false},
+		{historySettings{Keep: "90d"}, retentionPolicy{Before: now.AddDate(0, 0, -90)}, false},
+		{historySettings{Keep: "2w", Downsample: "5m->1h after 7d"}, retentionPolicy{Before: now.AddDate(0, 0, -14), ThinBefore: now.AddDate(0, 0, -7), Every: time.Hour}, false},
+		{historySettings{Downsample: "30m after 36h"}, retentionPolicy{ThinBefore: now.Add(-36 * time.Hour), Every: 30 * time.Minute}, false},
+		{historySettings{Keep: "forever"}, retentionPolicy{}, true},
+		{historySettings{Downsample: "hourly"}, retentionPolicy{}, true},
+	}
+	for _, test := range tests {
+		got, err := test.settings.policy(now)
+		if (err != nil) != test.bad {
+			t.Errorf("%+v: policy() error = %v", test.settings, err)
+			continue
+		}
+		if !test.bad && got != test.want {
+			t.Errorf("%+v: policy() = %+v, want %+v", test.settings, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feeds keep this many entries, newest first
+const feedMaxEntries = 50
+
+// RSS 2.0, just enough for a feed reader
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+type rssGUID struct {
+	ID          string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+func init() {
+	registerSink("rss", func(target string) (outputSink, error) {
+		if target == "" {
+			return nil, fmt.Errorf("rss needs a file to write")
+		}
+		return rssSink(target), nil
+	})
+}
+
+// rssSink writes the feed to its file, from the last day of history if there is one
+// or just this batch if not
+type rssSink string
+
+func (filename rssSink) Send(config *configSettings, batch *sinkBatch) error {
+	var records []historyRecord
+	if config.History.File != "" {
+		records, _ = readHistory(config, batch.Time.Add(-24*time.Hour))
+	}
+	if len(records) == 0 {
+		for i := range batch.Data {
+			records = append(records, historyRecord{Time: batch.Time, Data: batch.Data[i], Units: batch.Units[i]})
+		}
+	}
+	return writeRSSFile(string(filename), records)
+}
+
+// conditionsSummary sums a station up in a line, ala a feed entry title
+func conditionsSummary(data *WeatherData, units *WeatherUnits) string {
+	return strings.TrimSpace(fmt.Sprintf("%.1f%s, %.0f%% humidity, wind %.1f%s %s gusting %.1f, %.3f%s %s",
+		data.Temperature[0], units.Temperature[0], data.Humidity,
+		data.Windspeed[0], units.Windspeed[0], data.Wind[0], data.Windspeed[1],
+		data.Pressure, units.Pressure, data.PressureTrend))
+}
+
+// PrintRSS writes an RSS feed with an entry for each station on each poll. The records
+// are the polls, as kept in the history; the newest feedMaxEntries make the feed.
+func PrintRSS(w io.Writer, link string, records []historyRecord) error {
+	records = append([]historyRecord(nil), records...)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.After(records[j].Time) })
+	if len(records) > feedMaxEntries {
+		records = records[:feedMaxEntries]
+	}
+
+	feed := rssFeed{Version: "2.0", Channel: rssChannel{
+		Title:       "WeatherSTEM conditions",
+		Link:        link,
+		Description: "Station conditions on every poll",
+	}}
+	for i := range records {
+		data, units := &records[i].Data, &records[i].Units
+		summary := conditionsSummary(data, units)
+		when, ok := readingTime(data)
+		if !ok {
+			when = records[i].Time
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       data.Station[1] + ": " + summary,
+			Description: fmt.Sprintf("%s (%s) at %s: %s. Rain %.2f%s, %.2f%s.", data.Station[1], data.Station[0], data.Station[2], summary, data.Rain[0], units.Rain[0], data.Rain[1], units.Rain[1]),
+			PubDate:     when.Format(time.RFC1123Z),
+			GUID:        rssGUID{ID: data.Station[0] + "/" + records[i].Time.UTC().Format(time.RFC3339)},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeRSSFile writes the feed to a file, by way of a temporary one so a web server
+// never hands out half a feed
+func writeRSSFile(filename string, records []historyRecord) error {
+	temp, err := ioutil.TempFile(filepath.Dir(filename), ".weatherstem-rss")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if err = PrintRSS(temp, "https://www.weatherstem.com/", records); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filename)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// sampleStations keeps n of the configured stations, picked at random, so a long station
+// list gets covered over a few runs without asking the API for all of them every time.
+// A seed of zero means a different pick each run.
+func sampleStations(c *configSettings, n int, seed int64) {
+	if n <= 0 || n >= len(c.Stations) {
+		return
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	picked := make([]string, 0, n)
+	for _, i := range rand.New(rand.NewSource(seed)).Perm(len(c.Stations))[:n] {
+		picked = append(picked, c.Stations[i])
+	}
+	c.Stations = picked
+}
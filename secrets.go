@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The secrets in the config, the API keys mostly, are noted here as it loads so nothing
+// the tool says or writes down gives them away: the log, errors shown as JSON, the serve
+// health, -debug-http and its dumps, -orig and the response cache the mock takes as
+// fixtures. Wherever one turns up, it comes out as ***.
+
+// secretMask is what a secret comes out as
+const secretMask = "***"
+
+// secretMinimum is the shortest value taken for a secret, so a one letter key does not
+// blank out every word with that letter in it
+const secretMinimum = 4
+
+// apiKeyField is a key as it is sent to the API, ala "api_key":"polyshazbotmicrofish",
+// blanked out however short it is
+var apiKeyField = regexp.MustCompile(`("api_key"\s*:\s*")[^"]*(")`)
+
+// payloadURL is a URL in a payload, which may have a key riding along in it
+var payloadURL = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+var secrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// addSecrets notes values never to give away
+func addSecrets(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, value := range values {
+		if len(value) < secretMinimum {
+			continue
+		}
+		known := false
+		for _, have := range secrets.values {
+			known = known || have == value
+		}
+		if !known {
+			secrets.values = append(secrets.values, value)
+		}
+	}
+}
+
+// registerSecrets notes the config's secrets: the API keys, the SMTP password, the serve
+// tokens and the proxy's password, if it has one
+func (config *configSettings) registerSecrets() {
+	addSecrets(config.Key, config.SMTP.Password)
+	for _, endpoint := range config.Endpoints {
+		addSecrets(endpoint.Key)
+	}
+	for _, token := range config.Serve.Tokens {
+		addSecrets(token.Token)
+	}
+	if proxyURL, err := url.Parse(config.Proxy); err == nil && proxyURL.User != nil {
+		if password, ok := proxyURL.User.Password(); ok {
+			addSecrets(password)
+		}
+	}
+}
+
+// redact masks the secrets in text, ala an error that quotes the request
+func redact(text string) string {
+	text = apiKeyField.ReplaceAllString(text, "${1}"+secretMask+"${2}")
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, value := range secrets.values {
+		text = strings.ReplaceAll(text, value, secretMask)
+	}
+	return text
+}
+
+// redactPayload masks the secrets in a payload about to be written out, ala the cached
+// API response, in its api_key fields and its URLs only. A reading or a name that happens
+// to contain a key comes out as the API sent it.
+func redactPayload(content []byte) []byte {
+	content = apiKeyField.ReplaceAll(content, []byte("${1}"+secretMask+"${2}"))
+	return payloadURL.ReplaceAllFunc(content, func(found []byte) []byte {
+		return []byte(redact(string(found)))
+	})
+}
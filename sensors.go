@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"text/tabwriter"
+)
+
+// sensorTarget files one reading into the cooked data
+type sensorTarget func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo)
+
+// scalarTarget is a sensorTarget for the plain number and unit fields
+func scalarTarget(field func(data *WeatherData, units *WeatherUnits) (*float64, *string)) sensorTarget {
+	return func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		value, unit := field(data, units)
+		*value, _ = strconv.ParseFloat(reading.Value, 64)
+		*unit = reading.UnitSymbol
+	}
+}
+
+// sensorFields are the plain number and unit fields of the cooked data, by target name
+var sensorFields = map[string]func(data *WeatherData, units *WeatherUnits) (*float64, *string){
+	"temp": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Temperature[0], &units.Temperature[0]
+	},
+	"dewpoint": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Temperature[1], &units.Temperature[1]
+	},
+	"wbgt": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Temperature[2], &units.Temperature[2]
+	},
+	"windchill": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Temperature[3], &units.Temperature[3]
+	},
+	"heatindex": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Temperature[4], &units.Temperature[4]
+	},
+	"humidity": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Humidity, &units.Humidity
+	},
+	"windspeed": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Windspeed[0], &units.Windspeed[0]
+	},
+	"gust": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Windspeed[1], &units.Windspeed[1]
+	},
+	"winddir": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Windspeed[2], &units.Windspeed[2]
+	},
+	"pressure": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Pressure, &units.Pressure
+	},
+	"rain": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Rain[0], &units.Rain[0]
+	},
+	"rainrate": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Rain[1], &units.Rain[1]
+	},
+	"solar": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Sun[0], &units.Sun[0]
+	},
+	"uv": func(data *WeatherData, units *WeatherUnits) (*float64, *string) {
+		return &data.Sun[1], &units.Sun[1]
+	},
+}
+
+// sensorTargets are where readings can go, by name, on top of the sensorFields. The
+// config's "sensors" map names these, ala {"Air Temperature": "temp"}.
+var sensorTargets = map[string]sensorTarget{
+	"winddir": func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		data.Windspeed[2], _ = strconv.ParseFloat(reading.Value, 64)
+		units.Windspeed[2] = reading.UnitSymbol
+		data.Wind[0], data.Wind[1] = heading(data.Windspeed[2])
+	},
+	"ptrend": func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		data.PressureTrend = reading.Value
+		units.PressureTrend = reading.UnitSymbol
+	},
+	"soil": func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		data.addSoil(units, reading)
+	},
+	"lightning": func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		data.addLightning(reading)
+	},
+	"airquality": func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		data.addAirQuality(reading)
+	},
+	"leafwetness": func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		data.addLeafWetness(reading)
+	},
+	"extra": func(data *WeatherData, units *WeatherUnits, reading *ReadingInfo) {
+		data.addExtra(reading)
+	},
+}
+
+func init() {
+	for name, field := range sensorFields {
+		if sensorTargets[name] == nil {
+			sensorTargets[name] = scalarTarget(field)
+		}
+	}
+}
+
+// knownSensorTypes maps the API's sensor types to their targets, before the config's
+var knownSensorTypes = map[string]string{
+	"Thermometer":                "temp",
+	"Dewpoint":                   "dewpoint",
+	"Wet Bulb Globe Temperature": "wbgt",
+	"Wind Chill":                 "windchill",
+	"Heat Index":                 "heatindex",
+	"Hygrometer":                 "humidity",
+	"Anemometer":                 "windspeed",
+	"10 Minute Wind Gust":        "gust",
+	"Wind Vane":                  "winddir",
+	"Barometer":                  "pressure",
+	"Barometer Tendency":         "ptrend",
+	"Rain Gauge":                 "rain",
+	"Rain Rate":                  "rainrate",
+	"Solar Radiation Sensor":     "solar",
+	"UV Radiation Sensor":        "uv",
+}
+
+// sensorFamilies catch the sensor types that come in many names, ala "Soil Moisture 6in",
+// for the ones the sensor types in force do not know
+var sensorFamilies = []struct {
+	match  func(sensorType string) bool
+	target string
+}{
+	{isSoilSensor, "soil"},
+	{isLightningSensor, "lightning"},
+	{func(sensorType string) bool { return airQualitySensor(sensorType) != "" }, "airquality"},
+	{isLeafWetnessSensor, "leafwetness"},
+}
+
+// sensorTargetFor names the target for a sensor type. Anything unknown is extra.
+func sensorTargetFor(sensorType string) string {
+	if target, ok := inForce().sensorTypes[sensorType]; ok {
+		return target
+	}
+	for _, family := range sensorFamilies {
+		if family.match(sensorType) {
+			return family.target
+		}
+	}
+	return "extra"
+}
+
+// sensorRegistry is the known sensor types with the config's added, or the known ones
+// moved elsewhere. A target we do not have is an error.
+func sensorRegistry(sensors map[string]string) (map[string]string, error) {
+	registry := make(map[string]string, len(knownSensorTypes)+len(sensors))
+	for sensorType, target := range knownSensorTypes {
+		registry[sensorType] = target
+	}
+	for sensorType, target := range sensors {
+		if sensorTargets[target] == nil {
+			return nil, fmt.Errorf("sensor type %q cannot go to %q, the targets are %v", sensorType, target, sensorTargetNames())
+		}
+		registry[sensorType] = target
+	}
+	return registry, nil
+}
+
+// settledTables are the sensor types and decimals a config settles on. They are made
+// whole before being put in force and never changed after, so serve can swap in a
+// reloaded config's while a poll or a request is reading the old ones.
+type settledTables struct {
+	sensorTypes map[string]string
+	precision   map[string]int
+}
+
+// tablesInForce holds the settledTables this run goes by, the known sensor types and
+// each output's own decimals until a config is settled
+var tablesInForce atomic.Value
+
+func init() {
+	tablesInForce.Store(settledTables{sensorTypes: knownSensorTypes, precision: map[string]int{}})
+}
+
+// inForce is the settledTables this run goes by
+func inForce() settledTables {
+	return tablesInForce.Load().(settledTables)
+}
+
+// useTables puts a settled config's tables in force
+func (config *configSettings) useTables() {
+	tablesInForce.Store(config.tables)
+}
+
+// sensorTargetNames lists the targets in order, for the error message
+func sensorTargetNames() (names []string) {
+	for name := range sensorTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrintSensorReadings lists every reading of every station as the API gave it, with
+// where it ends up in the cooked data, to see what a station really reports
+func PrintSensorReadings(weatherArr []WeatherInfo) {
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, info := range weatherArr {
+		if i > 0 {
+			fmt.Fprintln(table)
+		}
+		fmt.Fprintf(table, "%s (%s) %s\n", info.WeatherStation.Name, info.WeatherStation.Handle, info.WeatherRecord.ReadingsTimestamp)
+		fmt.Fprintln(table, "Sensor\tType\tValue\tUnit\tTransmitter\tCooked as\t")
+		for _, reading := range info.WeatherRecord.RecordReadings {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t\n", reading.Sensor, reading.SensorType, reading.Value, reading.UnitSymbol, reading.TransmitterID, sensorTargetFor(reading.SensorType))
+		}
+	}
+	table.Flush()
+}
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	json "github.com/json-iterator/go"
+	haversine "github.com/loraxipam/havers2"
+)
+
+// serveSettings is the optional "serve" block of the config file, ala:
+// "serve": {"tokens": [{"token": "goAthletics", "stations": ["fieldstation"], "endpoints": ["/api/stations"]},
+// {"token": "staffOnly"}]}
+// With no tokens at all, anybody who can reach the port can read everything.
+type serveSettings struct {
+	Tokens []accessToken `json:"tokens,omitempty"`
+}
+
+// accessToken scopes one bearer token to some stations and endpoints.
+// An empty list means no restriction.
+type accessToken struct {
+	Token     string   `json:"token"`
+	Stations  []string `json:"stations,omitempty"`
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// stationReport is a station's cooked data with its units, as served over HTTP
+type stationReport struct {
+	Data  WeatherData  `json:"data"`
+	Units WeatherUnits `json:"units"`
+}
+
+// weatherServer keeps the latest poll of the API and hands it out over HTTP
+type weatherServer struct {
+	// Swapped under the mutex when the config is reloaded, see settings
+	config *configSettings
+	mutex  sync.RWMutex
+	// Held for the length of a poll, so a shutdown waits out the history and sinks
+	polling sync.Mutex
+	// Held for the length of a reload, so a second SIGHUP waits for the first
+	reloading sync.Mutex
+	// Closed to stop polling
+	stop   chan struct{}
+	orig   []WeatherInfo
+	report []stationReport
+	polled time.Time
+	// Recent polls, for the feed
+	feed []historyRecord
+	// How often the API is polled
+	interval time.Duration
+	// Closed, and replaced, after every poll, for anybody waiting on one
+	updated chan struct{}
+	// Station metadata as of the last poll, by handle, to spot changes
+	stations map[string]StationInfo
+	// Stations missing from the last poll
+	failed []stationError
+	// Whether the API is up, and when to try it next
+	breaker circuitBreaker
+}
+
+// stationHandle strips the "@domain.weatherstem.com" off a configured station ID
+func stationHandle(station string) string {
+	return strings.SplitN(station, "@", 2)[0]
+}
+
+// allowsEndpoint says whether the token may use the endpoint at path
+func (token *accessToken) allowsEndpoint(path string) bool {
+	if len(token.Endpoints) == 0 {
+		return true
+	}
+	for _, endpoint := range token.Endpoints {
+		if path == endpoint || strings.HasPrefix(path, strings.TrimSuffix(endpoint, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsStation says whether the token may see the station with this handle
+func (token *accessToken) allowsStation(handle string) bool {
+	if len(token.Stations) == 0 {
+		return true
+	}
+	for _, station := range token.Stations {
+		if stationHandle(station) == handle {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadConfig loads the config again the way main did, command line and all, for a
+// SIGHUP. main sets it.
+var reloadConfig func() (*configSettings, error)
+
+// settings is the config as of now, which a reload may swap out from under a poll
+func (ws *weatherServer) settings() *configSettings {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	return ws.config
+}
+
+// reload swaps in the config as it is now on disk, keeping the old one if it will not
+// load. The listen addresses, the proxy and the language stay as they were started.
+func (ws *weatherServer) reload() {
+	ws.reloading.Lock()
+	defer ws.reloading.Unlock()
+	sdNotify("RELOADING=1")
+	defer sdNotify("READY=1")
+	if reloadConfig == nil {
+		return
+	}
+	config, err := reloadConfig()
+	if err != nil {
+		logError("Cannot reload the config, keeping the old one.", err)
+		return
+	}
+	ws.mutex.Lock()
+	ws.config = config
+	config.useTables()
+	ws.mutex.Unlock()
+	logInfo("Reloaded the config,", len(config.Stations), "stations")
+}
+
+// findToken looks up a token. With no tokens configured, anything goes, as a nil token.
+// Every token is compared, in constant time, so how long it takes gives none of them away.
+func (ws *weatherServer) findToken(given string) (token *accessToken, ok bool) {
+	config := ws.settings()
+	if len(config.Serve.Tokens) == 0 {
+		return nil, true
+	}
+	if given == "" {
+		return nil, false
+	}
+	for i := range config.Serve.Tokens {
+		if subtle.ConstantTimeCompare([]byte(config.Serve.Tokens[i].Token), []byte(given)) == 1 && token == nil {
+			token = &config.Serve.Tokens[i]
+		}
+	}
+	return token, token != nil
+}
+
+// authorize finds the token for a request, from either an "Authorization: Bearer"
+// header or a "token" query parameter. A nil token means no scoping is configured.
+func (ws *weatherServer) authorize(w http.ResponseWriter, r *http.Request) (token *accessToken, ok bool) {
+	if len(ws.settings().Serve.Tokens) == 0 {
+		return nil, true
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if given == "" {
+		given = r.URL.Query().Get("token")
+	}
+	if token, ok = ws.findToken(given); !ok {
+		http.Error(w, "Unknown or missing token", http.StatusUnauthorized)
+		return nil, false
+	}
+	if !token.allowsEndpoint(r.URL.Path) {
+		http.Error(w, "Token not allowed here", http.StatusForbidden)
+		return nil, false
+	}
+	return token, true
+}
+
+// poll calls the API and swaps in the fresh results. A failed poll keeps the old ones,
+// and counts against the circuit breaker.
+func (ws *weatherServer) poll() {
+	ws.polling.Lock()
+	defer ws.polling.Unlock()
+	config := ws.settings()
+	weatherBytes, err := getWeatherInfoFromWeb(config)
+	if err != nil {
+		ws.pollFailed(fmt.Errorf("Call to API failed. %v", err))
+		return
+	}
+	weatherArr, failed, err := unmarshalWeatherInfo(weatherBytes, config.Stations)
+	if err == nil && len(weatherArr) == 0 && len(failed) > 0 {
+		err = fmt.Errorf("every station failed")
+	}
+	if err != nil {
+		ws.pollFailed(fmt.Errorf("Cannot unmarshal API results. %v", err))
+		return
+	}
+	logStationErrors(failed)
+	ws.noteStationChanges(weatherArr)
+	dataArr, unitArr := cookWeatherInfo(weatherArr, config.Me.Coord, config.Units)
+	applyAliases(config, dataArr, unitArr)
+	AssessUpwind(dataArr, unitArr)
+	now := time.Now()
+	if err = updateStationCache(config, weatherArr, now); err != nil {
+		logWarn("Cannot keep the station cache.", err)
+	}
+	stampProvenance(config, dataArr, weatherArr, now)
+	convertToSystem(config.Units, dataArr, unitArr)
+	if config.History.File != "" {
+		if err = appendHistory(config, now, dataArr, unitArr); err != nil {
+			logError("Cannot record history.", err)
+		}
+		if err = compactHistory(config, now); err != nil {
+			logError("Cannot compact history.", err)
+		}
+	}
+
+	batch := sinkBatch{Time: now, Interval: ws.interval, Data: dataArr, Units: unitArr, Orig: weatherArr}
+	if config.SMTP.When == "changes" {
+		changes, err := diffLastRun(config, now, dataArr, unitArr)
+		if err != nil {
+			logError("Cannot keep the last run.", err)
+		}
+		batch.Changes = changes.Stations
+	}
+	for _, err = range runSinks(config, config.Sinks, &batch) {
+		logError(err)
+	}
+
+	report := make([]stationReport, len(dataArr))
+	for i := range dataArr {
+		report[i] = stationReport{Data: dataArr[i], Units: unitArr[i]}
+	}
+	ws.mutex.Lock()
+	ws.orig, ws.report, ws.polled, ws.failed = weatherArr, report, now, failed
+	ws.breaker.record(nil, ws.interval, now)
+	close(ws.updated)
+	ws.updated = make(chan struct{})
+	for i := range dataArr {
+		ws.feed = append(ws.feed, historyRecord{Time: now, Data: dataArr[i], Units: unitArr[i]})
+	}
+	if len(ws.feed) > feedMaxEntries {
+		ws.feed = ws.feed[len(ws.feed)-feedMaxEntries:]
+	}
+	ws.mutex.Unlock()
+	sdNotify("STATUS=Polled the API at " + now.Format("15:04:05"))
+}
+
+// pollFailed counts a failed poll against the breaker, and tells systemd about it
+func (ws *weatherServer) pollFailed(err error) {
+	ws.mutex.Lock()
+	ws.breaker.record(err, ws.interval, time.Now())
+	serving := "no data yet"
+	if !ws.polled.IsZero() {
+		serving = "serving the data from " + ws.polled.Format("15:04:05")
+	}
+	status := fmt.Sprintf("STATUS=API failed %d polls in a row, %s: %s", ws.breaker.failures, serving, ws.breaker.lastError)
+	ws.mutex.Unlock()
+	sdNotify(status)
+}
+
+// stationChanges describes what is different about a station since the last poll
+func stationChanges(before, after StationInfo) (changes []string) {
+	if before.Name != after.Name {
+		changes = append(changes, fmt.Sprintf("renamed from %q", before.Name))
+	}
+	if before.Latitude != after.Latitude || before.Longitude != after.Longitude {
+		var p, q haversine.Coord
+		p.Lat, _ = strconv.ParseFloat(before.Latitude, 64)
+		p.Lon, _ = strconv.ParseFloat(before.Longitude, 64)
+		q.Lat, _ = strconv.ParseFloat(after.Latitude, 64)
+		q.Lon, _ = strconv.ParseFloat(after.Longitude, 64)
+		p.Calc()
+		q.Calc()
+		changes = append(changes, fmt.Sprintf("moved %.2fkm to %s,%s", haversine.DistanceKm(p, q), after.Latitude, after.Longitude))
+	}
+	cameras := make(map[string]bool)
+	for _, camera := range before.Cameras {
+		cameras[camera.Name] = true
+	}
+	for _, camera := range after.Cameras {
+		if !cameras[camera.Name] {
+			changes = append(changes, fmt.Sprintf("camera %q added", camera.Name))
+		}
+		delete(cameras, camera.Name)
+	}
+	for name := range cameras {
+		changes = append(changes, fmt.Sprintf("camera %q removed", name))
+	}
+	return changes
+}
+
+// noteStationChanges logs any station metadata changes since the last poll.
+// Distances are worked out afresh on every poll, so a moved station is
+// measured from its new spot straight away.
+func (ws *weatherServer) noteStationChanges(weatherArr []WeatherInfo) {
+	stations := make(map[string]StationInfo, len(weatherArr))
+	for _, info := range weatherArr {
+		station := info.WeatherStation
+		stations[station.Handle] = station
+		if before, seen := ws.stations[station.Handle]; seen {
+			for _, change := range stationChanges(before, station) {
+				logInfo(fmt.Sprintf("Station %s (%s) %s", station.Name, station.Handle, change))
+			}
+		} else if ws.stations != nil {
+			logInfo(fmt.Sprintf("Station %s (%s) is new", station.Name, station.Handle))
+		}
+	}
+	ws.stations = stations
+}
+
+// writeJSON sends v as the JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	jdata, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Cannot marshal weather data", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jdata)
+}
+
+// handleStations serves /api/stations for the whole list, or /api/stations/<handle> for one,
+// by its alias too
+func (ws *weatherServer) handleStations(w http.ResponseWriter, r *http.Request) {
+	token, ok := ws.authorize(w, r)
+	if !ok {
+		return
+	}
+	handle := ws.settings().resolveStation(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/stations"), "/"))
+
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	report := []stationReport{}
+	for _, station := range ws.report {
+		if token != nil && !token.allowsStation(station.Data.Station[0]) {
+			continue
+		}
+		if handle == "" || station.Data.Station[0] == handle {
+			report = append(report, station)
+		}
+	}
+	if handle != "" && len(report) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	switch negotiateFormat(r) {
+	case "csv":
+		writeStationsCSV(w, report)
+	case "prometheus":
+		writeStationsPrometheus(w, report)
+	default:
+		if handle == "" {
+			writeJSON(w, report)
+		} else {
+			writeJSON(w, report[0])
+		}
+	}
+}
+
+// handleArea serves /api/area, all the stations the token can see rolled up into one
+func (ws *weatherServer) handleArea(w http.ResponseWriter, r *http.Request) {
+	token, ok := ws.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	var dataArr []WeatherData
+	var unitArr []WeatherUnits
+	for _, station := range ws.report {
+		if token == nil || token.allowsStation(station.Data.Station[0]) {
+			dataArr = append(dataArr, station.Data)
+			unitArr = append(unitArr, station.Units)
+		}
+	}
+	if len(dataArr) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, AggregateArea(dataArr, unitArr))
+}
+
+// handleOrig serves /api/orig, the API results as they came
+func (ws *weatherServer) handleOrig(w http.ResponseWriter, r *http.Request) {
+	token, ok := ws.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	orig := []WeatherInfo{}
+	for _, info := range ws.orig {
+		if token == nil || token.allowsStation(info.WeatherStation.Handle) {
+			orig = append(orig, info)
+		}
+	}
+	writeJSON(w, orig)
+}
+
+// handleReport serves /report, the stations the token can see as a web page
+func (ws *weatherServer) handleReport(w http.ResponseWriter, r *http.Request) {
+	token, ok := ws.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	var dataArr []WeatherData
+	var unitArr []WeatherUnits
+	for _, station := range ws.report {
+		if token == nil || token.allowsStation(station.Data.Station[0]) {
+			dataArr = append(dataArr, station.Data)
+			unitArr = append(unitArr, station.Units)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := PrintReportHTML(w, dataArr, unitArr, ws.orig); err != nil {
+		logError("Cannot write the report.", err)
+	}
+}
+
+// handleKML serves /kml, the stations the token can see as KML placemarks
+func (ws *weatherServer) handleKML(w http.ResponseWriter, r *http.Request) {
+	token, ok := ws.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	var dataArr []WeatherData
+	var unitArr []WeatherUnits
+	for _, station := range ws.report {
+		if token == nil || token.allowsStation(station.Data.Station[0]) {
+			dataArr = append(dataArr, station.Data)
+			unitArr = append(unitArr, station.Units)
+		}
+	}
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	if err := PrintKML(w, dataArr, unitArr, ws.orig); err != nil {
+		logError("Cannot write the KML.", err)
+	}
+}
+
+// requestBase is where the request came in, ala https://weather.example.com, for links
+// back to this server
+func requestBase(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// handleKMLLink serves /kml/link, a NetworkLink for Google Earth which fetches /kml
+// again after every poll. Any token comes along in the link.
+func (ws *weatherServer) handleKMLLink(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ws.authorize(w, r); !ok {
+		return
+	}
+	link := requestBase(r) + "/kml"
+	if r.URL.RawQuery != "" {
+		link += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	if err := PrintKMLNetworkLink(w, link, ws.interval); err != nil {
+		logError("Cannot write the KML.", err)
+	}
+}
+
+// handleRSS serves /rss, a feed of every poll of the stations the token can see
+func (ws *weatherServer) handleRSS(w http.ResponseWriter, r *http.Request) {
+	token, ok := ws.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	var records []historyRecord
+	for _, record := range ws.feed {
+		if token == nil || token.allowsStation(record.Data.Station[0]) {
+			records = append(records, record)
+		}
+	}
+	w.Header().Set("Content-Type", "application/rss+xml")
+	if err := PrintRSS(w, requestBase(r)+"/report", records); err != nil {
+		logError("Cannot write the feed.", err)
+	}
+}
+
+// serveCommand polls the API on an interval and serves the latest results over HTTP
+func serveCommand(config *configSettings, args []string) (err error) {
+	var (
+		addr, grpcAddr       string
+		interval, maxBackoff time.Duration
+		failures             int
+	)
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.StringVar(&addr, "addr", ":8080", "Address to listen on")
+	flags.StringVar(&grpcAddr, "grpc", "", "Address to serve gRPC on too, ala :9000")
+	flags.DurationVar(&interval, "interval", 5*time.Minute, "Time between API polls")
+	flags.IntVar(&failures, "breaker-failures", 3, "Failed polls in a row before backing off, 0 never backs off")
+	flags.DurationVar(&maxBackoff, "breaker-max-backoff", time.Hour, "Longest time between API probes while backed off")
+	flags.Parse(args)
+
+	ws := &weatherServer{config: config, interval: interval, updated: make(chan struct{}), stop: make(chan struct{})}
+	ws.breaker = circuitBreaker{threshold: failures, maxBackoff: maxBackoff}
+	ws.poll()
+	go ws.pollForever()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/stations", ws.handleStations)
+	mux.HandleFunc("/api/stations/", ws.handleStations)
+	mux.HandleFunc("/api/area", ws.handleArea)
+	mux.HandleFunc("/api/orig", ws.handleOrig)
+	mux.HandleFunc("/api/status", ws.handleStatus)
+	mux.HandleFunc("/report", ws.handleReport)
+	mux.HandleFunc("/kml", ws.handleKML)
+	mux.HandleFunc("/kml/link", ws.handleKMLLink)
+	mux.HandleFunc("/rss", ws.handleRSS)
+	mux.HandleFunc("/openapi.json", ws.handleOpenAPI)
+
+	var stopGRPC func()
+	if grpcAddr != "" {
+		if stopGRPC, err = serveGRPC(ws, grpcAddr); err != nil {
+			return err
+		}
+		logInfo("Serving gRPC on", grpcAddr)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: ws.markDegraded(mux)}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(listener) }()
+	logInfo(fmt.Sprintf("Serving weather on %s, polling every %v", addr, interval))
+	sdNotify("READY=1\nSTATUS=Serving weather on " + addr)
+
+	// SIGHUP reloads the config, SIGTERM or ^C stops, and the watchdog is fed meanwhile,
+	// as long as the polls are going, see alive. A reload may look up where we are, so it is
+	// left to run while the rest goes on.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
+	var watchdog <-chan time.Time
+	if every := watchdogInterval(); every > 0 {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+	for {
+		select {
+		case err = <-served:
+			return err
+		case <-watchdog:
+			if ws.alive() {
+				sdNotify("WATCHDOG=1")
+			}
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				go ws.reload()
+				continue
+			}
+			return ws.shutdown(server, stopGRPC)
+		}
+	}
+}
+
+// pollMargin is how far past its time a poll may run before it counts as stuck
+const pollMargin = httpTimeout + time.Minute
+
+// alive says whether the polling is going, well or not: the last poll finished and the
+// next is not overdue. It is what feeds the watchdog, so a server stuck polling gets
+// restarted, while one cut off from the API keeps backing off and serving what it has.
+func (ws *weatherServer) alive() bool {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+	return time.Now().Before(ws.breaker.nextPoll.Add(ws.interval + pollMargin))
+}
+
+// shutdownTimeout is how long the requests being answered get to finish
+const shutdownTimeout = 30 * time.Second
+
+// shutdown stops serving and polling gracefully: the requests being answered finish, and
+// a poll under way finishes too, so its history is written and its sinks sent
+func (ws *weatherServer) shutdown(server *http.Server, stopGRPC func()) error {
+	logInfo("Shutting down.")
+	sdNotify("STOPPING=1")
+	close(ws.stop)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logWarn("Cutting off the requests still being answered.", err)
+		server.Close()
+	}
+	if stopGRPC != nil {
+		stopGRPC()
+	}
+	ws.polling.Lock()
+	ws.polling.Unlock()
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sinkBatch is what every sink gets after a fetch: the cooked stations, the raw API
+// results, any alerts raised and what changed since the last run, when asked.
+// Interval is how often the server polls, or zero.
+type sinkBatch struct {
+	Time     time.Time
+	Interval time.Duration
+	Data     []WeatherData
+	Units    []WeatherUnits
+	Orig     []WeatherInfo
+	Alerts   []alertEvent
+	Changes  []stationDiff
+}
+
+// outputSink sends a batch somewhere: a mailbox, a script, a metrics server
+type outputSink interface {
+	Send(config *configSettings, batch *sinkBatch) error
+}
+
+// sinkFactory makes a sink from its target, ala the address list for "email"
+type sinkFactory func(target string) (outputSink, error)
+
+// sinkRegistry has the sinks built in. Each sink's file registers itself in init().
+var sinkRegistry = map[string]sinkFactory{}
+
+// registerSink adds a built in sink under name
+func registerSink(name string, factory sinkFactory) {
+	sinkRegistry[name] = factory
+}
+
+// sinkSetting picks a sink and its target. They come from the "sinks" list in the
+// config, ala "sinks": [{"name": "graphite", "target": "carbon:2003"}], and from -sink.
+type sinkSetting struct {
+	Name   string `json:"name"`
+	Target string `json:"target,omitempty"`
+}
+
+// sinkFlags collects the repeatable -sink name=target flag
+type sinkFlags []sinkSetting
+
+func (sinks *sinkFlags) String() string {
+	var settings []string
+	for _, sink := range *sinks {
+		settings = append(settings, sink.Name+"="+sink.Target)
+	}
+	return strings.Join(settings, ",")
+}
+
+func (sinks *sinkFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	sink := sinkSetting{Name: parts[0]}
+	if len(parts) == 2 {
+		sink.Target = parts[1]
+	}
+	*sinks = append(*sinks, sink)
+	return nil
+}
+
+// sinkNames lists the built in sinks, for error messages
+func sinkNames() string {
+	var names []string
+	for name := range sinkRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// openSink makes the sink for a setting. A name that is not built in is looked for
+// on the PATH as an external plugin, weatherstem-sink-<name>.
+func openSink(setting sinkSetting) (outputSink, error) {
+	if factory, ok := sinkRegistry[setting.Name]; ok {
+		return factory(setting.Target)
+	}
+	if path, err := exec.LookPath("weatherstem-sink-" + setting.Name); err == nil {
+		return &pluginSink{path: path, target: setting.Target}, nil
+	}
+	return nil, fmt.Errorf("unknown sink %q, try one of %s or put weatherstem-sink-%s on the PATH", setting.Name, sinkNames(), setting.Name)
+}
+
+// runSinks sends the batch to every sink, carrying on past the ones that fail
+func runSinks(config *configSettings, settings []sinkSetting, batch *sinkBatch) (errs []error) {
+	for _, setting := range settings {
+		sink, err := openSink(setting)
+		if err == nil {
+			err = sink.Send(config, batch)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %v", setting.Name, err))
+		}
+	}
+	return errs
+}
+
+// pluginSink is an external program. It gets the target as its argument and the
+// batch as JSON on stdin: {"time": ..., "stations": [{"data": ..., "units": ...}], "alerts": [...]}
+type pluginSink struct {
+	path, target string
+}
+
+func (sink *pluginSink) Send(config *configSettings, batch *sinkBatch) error {
+	alerts := batch.Alerts
+	if alerts == nil {
+		alerts = []alertEvent{}
+	}
+	jdata, err := marshalJSONCompact(struct {
+		Time     time.Time     `json:"time"`
+		Stations []interface{} `json:"stations"`
+		Alerts   []alertEvent  `json:"alerts"`
+	}{batch.Time, stationDocuments(batch.Data, batch.Units), alerts})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(sink.path, sink.target)
+	cmd.Stdin = strings.NewReader(string(jdata))
+	cmd.Stdout, cmd.Stderr = hookStdout(), os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Soil probes come one sensor per depth, with the depth in the sensor name, ala
+// "Soil Temperature 6in" or "Soil Moisture (15 cm)"
+var soilDepth = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(in|cm|mm|")`)
+
+// isSoilSensor says whether a sensor type is one of the soil probes
+func isSoilSensor(sensorType string) bool {
+	return strings.HasPrefix(sensorType, "Soil Temp") || strings.HasPrefix(sensorType, "Soil Moisture")
+}
+
+// addSoil files a soil probe reading by its depth. The soil arrays line up, one entry
+// per depth from the surface down. A depth without one of the readings has 0 there, with
+// no unit. A probe with no depth in its name goes first, with no depth unit.
+func (data *WeatherData) addSoil(units *WeatherUnits, reading *ReadingInfo) {
+	var depth float64
+	var depthUnit string
+	if match := soilDepth.FindStringSubmatch(reading.Sensor); match != nil {
+		depth, _ = strconv.ParseFloat(match[1], 64)
+		depthUnit = strings.Replace(match[2], `"`, "in", 1)
+	}
+
+	i := sort.Search(len(data.SoilDepth), func(i int) bool { return data.SoilDepth[i] >= depth })
+	if i == len(data.SoilDepth) || data.SoilDepth[i] != depth {
+		data.SoilDepth = append(data.SoilDepth[:i:i], append([]float64{depth}, data.SoilDepth[i:]...)...)
+		data.SoilTemp = append(data.SoilTemp[:i:i], append([]float64{0}, data.SoilTemp[i:]...)...)
+		data.SoilMoisture = append(data.SoilMoisture[:i:i], append([]float64{0}, data.SoilMoisture[i:]...)...)
+		units.SoilDepth = append(units.SoilDepth[:i:i], append([]string{depthUnit}, units.SoilDepth[i:]...)...)
+		units.SoilTemp = append(units.SoilTemp[:i:i], append([]string{""}, units.SoilTemp[i:]...)...)
+		units.SoilMoisture = append(units.SoilMoisture[:i:i], append([]string{""}, units.SoilMoisture[i:]...)...)
+	}
+
+	value, _ := strconv.ParseFloat(reading.Value, 64)
+	if strings.HasPrefix(reading.SensorType, "Soil Temp") {
+		data.SoilTemp[i], units.SoilTemp[i] = value, reading.UnitSymbol
+	} else {
+		data.SoilMoisture[i], units.SoilMoisture[i] = value, reading.UnitSymbol
+	}
+}
+
+// soilLevel describes one depth, ala "6in 76.4°F 41cb"
+func soilLevel(data *WeatherData, units *WeatherUnits, i int) string {
+	var parts []string
+	if units.SoilDepth[i] != "" {
+		parts = append(parts, strconv.FormatFloat(data.SoilDepth[i], 'f', -1, 64)+units.SoilDepth[i])
+	}
+	if units.SoilTemp[i] != "" {
+		parts = append(parts, fmt.Sprintf("%.1f%s", data.SoilTemp[i], units.SoilTemp[i]))
+	}
+	if units.SoilMoisture[i] != "" {
+		parts = append(parts, fmt.Sprintf("%.0f%s", data.SoilMoisture[i], units.SoilMoisture[i]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// PrintSoil shows the soil probes from the top down, for -soil
+func (data *WeatherData) PrintSoil(units *WeatherUnits) {
+	if len(data.SoilDepth) == 0 {
+		fmt.Println("SO: No soil probes")
+		return
+	}
+	for i := range data.SoilDepth {
+		fmt.Printf("SO: %s\n", soilLevel(data, units, i))
+	}
+}
+
+// PrintSoilLite shows the soil probes on one line
+func (data *WeatherData) PrintSoilLite(units *WeatherUnits) {
+	var levels []string
+	for i := range data.SoilDepth {
+		levels = append(levels, soilLevel(data, units, i))
+	}
+	fmt.Println(" ", "SO:", strings.Join(levels, ", "))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+)
+
+// Sparklines have at most this many glyphs, each the average of its slice of the hours
+const sparkWidth = 12
+
+// sparkGlyphs go from the lowest to the highest reading in the sparkline
+var sparkGlyphs = []rune("▁▂▃▄▅▆▇█")
+
+// stationSparks are the recent trends of a station, ready to print
+type stationSparks struct {
+	Temp, Pressure, Wind string
+}
+
+// sparkline draws the values, oldest first, from the lowest glyph to the highest.
+// A flat series sits in the middle.
+func sparkline(values []float64) string {
+	if len(values) < 2 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, value := range values {
+		if value < low {
+			low = value
+		}
+		if value > high {
+			high = value
+		}
+	}
+	line := make([]rune, len(values))
+	for i, value := range values {
+		level := len(sparkGlyphs) / 2
+		if high > low {
+			level = int((value - low) / (high - low) * float64(len(sparkGlyphs)-1))
+		}
+		line[i] = sparkGlyphs[level]
+	}
+	return string(line)
+}
+
+// sparkSeries averages a reading over sparkWidth equal slices of the time since then,
+// skipping slices without history
+func sparkSeries(records []historyRecord, since, now time.Time, reading func(rec *historyRecord) float64) (series []float64) {
+	var sums [sparkWidth]float64
+	var counts [sparkWidth]int
+	slice := now.Sub(since) / sparkWidth
+	for i := range records {
+		if records[i].Time.Before(since) || slice <= 0 {
+			continue
+		}
+		bucket := int(records[i].Time.Sub(since) / slice)
+		if bucket >= sparkWidth {
+			bucket = sparkWidth - 1
+		}
+		sums[bucket] += reading(&records[i])
+		counts[bucket]++
+	}
+	for i := range sums {
+		if counts[i] > 0 {
+			series = append(series, sums[i]/float64(counts[i]))
+		}
+	}
+	return series
+}
+
+// newStationSparks draws the temperature, pressure and wind trends since then from the
+// station's history. The shape does not care about the units, so neither does this.
+func newStationSparks(records []historyRecord, since, now time.Time) *stationSparks {
+	return &stationSparks{
+		Temp:     sparkline(sparkSeries(records, since, now, func(rec *historyRecord) float64 { return rec.Data.Temperature[0] })),
+		Pressure: sparkline(sparkSeries(records, since, now, func(rec *historyRecord) float64 { return rec.Data.Pressure })),
+		Wind:     sparkline(sparkSeries(records, since, now, func(rec *historyRecord) float64 { return rec.Data.Windspeed[0] })),
+	}
+}
+
+// spark puts a sparkline after a value, if there is one to put
+func spark(line string) string {
+	if line == "" {
+		return ""
+	}
+	return " " + line
+}
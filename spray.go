@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Leaves count as wet from here up: 8 on the Davis 0-15 scale, or half the sensor
+// for the ones that read in percent
+const (
+	leafWetScale   = 8.0
+	leafWetPercent = 50.0
+)
+
+// SprayConditions is the spraying guidance for a station. DeltaT is the dry bulb less
+// the wet bulb temperature, always in °C as the guidance is.
+type SprayConditions struct {
+	DeltaT   float64 `json:"deltat"`
+	Category string  `json:"category"`
+	Reason   string  `json:"reason"`
+}
+
+// isLeafWetnessSensor says whether a sensor type is a leaf wetness sensor
+func isLeafWetnessSensor(sensorType string) bool {
+	return strings.Contains(strings.ToLower(sensorType), "leaf wetness")
+}
+
+// addLeafWetness keeps the leaf wetness reading, in whatever the sensor reads in
+func (data *WeatherData) addLeafWetness(reading *ReadingInfo) {
+	value, _ := strconv.ParseFloat(reading.Value, 64)
+	data.LeafWetness = &ValueUnit{Value: value, Unit: html.UnescapeString(reading.UnitSymbol)}
+}
+
+// leavesWet says whether the leaf wetness sensor thinks the leaves are wet
+func (data *WeatherData) leavesWet() bool {
+	if data.LeafWetness == nil {
+		return false
+	}
+	if data.LeafWetness.Unit == "%" {
+		return data.LeafWetness.Value >= leafWetPercent
+	}
+	return data.LeafWetness.Value >= leafWetScale
+}
+
+// wetBulb is the psychrometric wet bulb temperature (°C) from the air temperature (°C)
+// and relative humidity (%), per Stull (2011). Good to about ±1°C at sea level.
+func wetBulb(temp, humidity float64) float64 {
+	return temp*math.Atan(0.151977*math.Sqrt(humidity+8.313659)) +
+		math.Atan(temp+humidity) - math.Atan(humidity-1.676331) +
+		0.00391838*math.Pow(humidity, 1.5)*math.Atan(0.023101*humidity) - 4.686035
+}
+
+// AssessSpray sorts the conditions into Good, Marginal or Unsuitable for spraying, ala
+// the usual delta-T chart: 2-8°C is good, under 2 risks drift in an inversion and
+// droplets that stay wet, 8-10 is marginal and over 10 the droplets evaporate before they
+// land. Wet leaves make a good day marginal, as the spray runs off.
+func AssessSpray(data *WeatherData, units *WeatherUnits) (spray SprayConditions) {
+	temp := toCelsius(data.Temperature[0], units.Temperature[0])
+	spray.DeltaT = temp - wetBulb(temp, data.Humidity)
+
+	switch {
+	case spray.DeltaT < 2:
+		spray.Category, spray.Reason = "Marginal", "under 2°C, drift risk"
+	case spray.DeltaT <= 8:
+		spray.Category, spray.Reason = "Good", "2-8°C"
+	case spray.DeltaT <= 10:
+		spray.Category, spray.Reason = "Marginal", "8-10°C, droplets evaporating"
+	default:
+		spray.Category, spray.Reason = "Unsuitable", "over 10°C, droplets evaporate"
+	}
+	if data.leavesWet() {
+		if spray.Category == "Good" {
+			spray.Category = "Marginal"
+		}
+		spray.Reason += ", leaves wet"
+	}
+	return spray
+}
+
+// String describes the conditions, ala "Good, delta-T 4.1°C (2-8°C)"
+func (spray *SprayConditions) String() string {
+	return fmt.Sprintf("%s, delta-T %.1f°C (%s)", spray.Category, spray.DeltaT, spray.Reason)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// How far back the rolling average and the pressure drop look
+	squallWindow = time.Hour
+	// A pressure drop at least this big (hPa) within the window is a squall on the way
+	squallPressureDrop = 1.0
+	// The wind swinging round more than this (degrees) from one poll to the next
+	squallWindShift = 45.0
+	// Light winds wander about, so shifts below this speed (m/s) do not count
+	squallShiftMinSpeed = 2.0
+	// A gust this far (m/s) over the rolling average wind, the WMO's 16 knots for a squall
+	squallGustExcess = 8.0
+)
+
+// pressureDropAlert raises an alert when the pressure fell fast from its high within the
+// window, the sharp dip ahead of a gust front, which the three hour tendency smooths over.
+// The records are the station's last window of history, oldest first.
+func pressureDropAlert(data *WeatherData, units *WeatherUnits, records []historyRecord, recorded time.Time) (event alertEvent, raised bool) {
+	now, _ := convertUnit(data.Pressure, units.Pressure, "hPa")
+	var high float64
+	var since time.Duration
+	for i := range records {
+		if !records[i].Time.Before(recorded) || recorded.Sub(records[i].Time) > squallWindow {
+			continue
+		}
+		if was, _ := convertUnit(records[i].Data.Pressure, records[i].Units.Pressure, "hPa"); was > high {
+			high, since = was, recorded.Sub(records[i].Time)
+		}
+	}
+	if high-now < squallPressureDrop {
+		return event, false
+	}
+	return alertEvent{
+		Station: data.Station[1],
+		Kind:    "squall",
+		Message: fmt.Sprintf("Pressure dropped %.1f hPa in %.0f minutes. Gust front?", high-now, since.Minutes()),
+	}, true
+}
+
+// windShiftAlert raises an alert when the wind swung round more than squallWindShift since
+// the previous reading, as long as there was wind enough, before and after, to mean it
+func windShiftAlert(data *WeatherData, units *WeatherUnits, previous *historyRecord) (event alertEvent, raised bool) {
+	if previous == nil {
+		return event, false
+	}
+	if toMetersPerSecond(previous.Data.Windspeed[0], previous.Units.Windspeed[0]) < squallShiftMinSpeed ||
+		toMetersPerSecond(data.Windspeed[0], units.Windspeed[0]) < squallShiftMinSpeed {
+		return event, false
+	}
+	shift := math.Abs(math.Mod(data.Windspeed[2]-previous.Data.Windspeed[2]+540, 360) - 180)
+	if shift <= squallWindShift {
+		return event, false
+	}
+	from, _ := heading(previous.Data.Windspeed[2])
+	return alertEvent{
+		Station: data.Station[1],
+		Kind:    "squall",
+		Message: fmt.Sprintf("Wind shifted %.0f° from %s to %s", shift, from, data.Wind[0]),
+	}, true
+}
+
+// gustSpikeAlert raises an alert when the gust is squallGustExcess or more over the
+// average wind of the window before this run
+func gustSpikeAlert(data *WeatherData, units *WeatherUnits, records []historyRecord, recorded time.Time) (event alertEvent, raised bool) {
+	var sum float64
+	var count int
+	for i := range records {
+		if records[i].Time.Before(recorded) && recorded.Sub(records[i].Time) <= squallWindow {
+			speed, _ := convertUnit(records[i].Data.Windspeed[0], records[i].Units.Windspeed[0], units.Windspeed[0])
+			sum += speed
+			count++
+		}
+	}
+	if count == 0 {
+		return event, false
+	}
+	average := sum / float64(count)
+	excess := data.Windspeed[1] - average
+	if toMetersPerSecond(excess, units.Windspeed[1]) < squallGustExcess {
+		return event, false
+	}
+	unit := units.Windspeed[1]
+	return alertEvent{
+		Station: data.Station[1],
+		Kind:    "squall",
+		Message: fmt.Sprintf("Gust %.0f %s, %.0f over the hour's average of %.0f %s", data.Windspeed[1], unit, excess, average, unit),
+	}, true
+}
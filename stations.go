@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	json "github.com/json-iterator/go"
+	haversine "github.com/loraxipam/havers2"
+)
+
+// knownStation is what the station cache remembers of a station: what the API last said
+// about it, and when it was last heard from
+type knownStation struct {
+	Station  StationInfo `json:"station"`
+	LastSeen time.Time   `json:"last_seen"`
+	Reading  string      `json:"reading"`
+}
+
+// stationCacheFile is where the stations are kept, "station_cache" in the config or the
+// user's cache directory, ala ~/.cache/weatherstem/stations.json
+func (config *configSettings) stationCacheFile() string {
+	if config.StationCache != "" {
+		return expandHome(config.StationCache)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "weatherstem", "stations.json")
+}
+
+// readStationCache gets the known stations, none when there is no cache yet
+func readStationCache(config *configSettings) (stations []knownStation, err error) {
+	filename := config.stationCacheFile()
+	if filename == "" {
+		return nil, nil
+	}
+	cached, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(cached, &stations)
+	}
+	return stations, err
+}
+
+// updateStationCache files what the API said about each station it answered for, seen at
+// the time of the fetch. Stations it did not answer for are kept as they were.
+func updateStationCache(config *configSettings, weatherArr []WeatherInfo, seen time.Time) error {
+	filename := config.stationCacheFile()
+	if filename == "" {
+		return nil
+	}
+	stations, err := readStationCache(config)
+	if err != nil {
+		logWarn("Cannot read the station cache, starting it afresh.", err)
+	}
+	for _, info := range weatherArr {
+		station := knownStation{Station: info.WeatherStation, LastSeen: seen, Reading: info.WeatherRecord.ReadingsTimestamp}
+		i := sort.Search(len(stations), func(i int) bool { return stations[i].Station.Handle >= station.Station.Handle })
+		switch {
+		case i < len(stations) && stations[i].Station.Handle == station.Station.Handle:
+			// a cached response can be older than what is known already
+			if stations[i].LastSeen.After(seen) {
+				continue
+			}
+			stations[i] = station
+		default:
+			stations = append(stations, knownStation{})
+			copy(stations[i+1:], stations[i:])
+			stations[i] = station
+		}
+	}
+	cacheJSON, err := json.Marshal(stations)
+	if err != nil {
+		return err
+	}
+	return replaceFile(filename, cacheJSON)
+}
+
+// stationsCommand lists the known stations nearest first, from the cache alone so it works
+// offline, ala 'weatherstem stations -km'
+func stationsCommand(config *configSettings, args []string) (err error) {
+	var kilo, mile, outputJSON bool
+	flags := flag.NewFlagSet("stations", flag.ExitOnError)
+	flags.BoolVar(&kilo, "km", false, "Distances in kilometers, whatever the units")
+	flags.BoolVar(&mile, "mi", false, "Distances in miles, whatever the units")
+	flags.BoolVar(&outputJSON, "json", false, "JSON output")
+	flags.Parse(args)
+
+	stations, err := readStationCache(config)
+	if err != nil {
+		return err
+	}
+	if len(stations) == 0 {
+		return fmt.Errorf("No stations known yet. Run weatherstem once with the API reachable.")
+	}
+
+	unit := "NM"
+	if kilo {
+		unit = "km"
+	} else if mile {
+		unit = "mi"
+	} else if config.Units != "" {
+		unit = unitSystems[config.Units].distance
+	}
+	distances := make([]float64, len(stations))
+	for i := range stations {
+		var where haversine.Coord
+		where.Lat, _ = strconv.ParseFloat(stations[i].Station.Latitude, 64)
+		where.Lon, _ = strconv.ParseFloat(stations[i].Station.Longitude, 64)
+		where.Calc()
+		distances[i] = measureDistance(unit, config.Me.Coord, where)
+	}
+	order := make([]int, len(stations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return distances[order[a]] < distances[order[b]] })
+
+	if outputJSON {
+		for _, i := range order {
+			jdata, err := marshalJSON(struct {
+				knownStation
+				Distance float64 `json:"distance"`
+				Unit     string  `json:"unit"`
+			}{stations[i], distances[i], unit})
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(jdata))
+		}
+		return nil
+	}
+
+	now := time.Now()
+	for _, i := range order {
+		station := &stations[i].Station
+		name := station.Name
+		if alias, ok := config.stationAlias(station.Handle); ok {
+			name = alias
+		}
+		fmt.Printf("%-20s %-28s %7.2f%s  %-20s %d cameras  seen %s ago\n", station.Handle, name, distances[i], unit,
+			station.Domain.Handle, len(station.Cameras), now.Sub(stations[i].LastSeen).Round(time.Minute))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"math"
+	"time"
+)
+
+// wbgtThresholds are where the WBGT flag levels start, in °F, see WBGTFlag
+var wbgtThresholds = []float64{82, 87, 90, 92}
+
+// statRange is the low, high and mean of one reading over the history, in Unit
+type statRange struct {
+	Min, Max, Mean float64
+	Unit           string
+	count          int
+}
+
+// add takes in one more value, converting it from its own unit
+func (stat *statRange) add(value float64, unit string) {
+	value, _ = convertUnit(value, unit, stat.Unit)
+	if stat.count == 0 || value < stat.Min {
+		stat.Min = value
+	}
+	if stat.count == 0 || value > stat.Max {
+		stat.Max = value
+	}
+	stat.Mean += (value - stat.Mean) / float64(stat.count+1)
+	stat.count++
+}
+
+// StationStatistics sums up a station's history: the ranges, the peak gust, the rain
+// that fell and how many hours the WBGT spent at or over each of the wbgtThresholds
+type StationStatistics struct {
+	Records     int
+	Temperature statRange
+	Wind        statRange
+	Pressure    statRange
+	PeakGust    float64
+	Rain        float64
+	RainUnit    string
+	WBGTHours   []float64
+}
+
+// AssessStatistics works out the statistics of a station's history, oldest first, since
+// then. Values go into the units of the latest record. Each WBGT counts until the next
+// record, unless that is over windStatsMaxGap away, so gaps in the history are not guessed at.
+func AssessStatistics(records []historyRecord, since time.Time) (stats StationStatistics) {
+	stats.WBGTHours = make([]float64, len(wbgtThresholds))
+	if len(records) == 0 {
+		return stats
+	}
+	latest := &records[len(records)-1].Units
+	stats.Temperature.Unit = html.UnescapeString(latest.Temperature[0])
+	stats.Wind.Unit = latest.Windspeed[0]
+	stats.Pressure.Unit = latest.Pressure
+	stats.RainUnit = latest.Rain[0]
+	stats.Rain = rainSince(records, stats.RainUnit, since)
+
+	for i := range records {
+		rec := &records[i]
+		if rec.Time.Before(since) {
+			continue
+		}
+		stats.Records++
+		stats.Temperature.add(rec.Data.Temperature[0], rec.Units.Temperature[0])
+		stats.Wind.add(rec.Data.Windspeed[0], rec.Units.Windspeed[0])
+		stats.Pressure.add(rec.Data.Pressure, rec.Units.Pressure)
+		gust, _ := convertUnit(rec.Data.Windspeed[1], rec.Units.Windspeed[1], stats.Wind.Unit)
+		stats.PeakGust = math.Max(stats.PeakGust, gust)
+
+		if i+1 < len(records) {
+			if gap := records[i+1].Time.Sub(rec.Time); gap > 0 && gap <= windStatsMaxGap {
+				wbgt, _ := convertUnit(rec.Data.Temperature[2], rec.Units.Temperature[2], "°F")
+				for level, threshold := range wbgtThresholds {
+					if wbgt >= threshold {
+						stats.WBGTHours[level] += gap.Hours()
+					}
+				}
+			}
+		}
+	}
+	return stats
+}
+
+// decimals is enough decimal places to tell the values apart, ala 2 for inHg
+func (stat *statRange) decimals() int {
+	if math.Abs(stat.Max) < 100 && stat.Max-stat.Min < 2 {
+		return 2
+	}
+	return 1
+}
+
+// PrintStatistics shows a station's statistics as a small table
+func (stats *StationStatistics) PrintStatistics(name, handle, since string) {
+	fmt.Printf("%s (%s) last %s, %d records\n", name, handle, since, stats.Records)
+	fmt.Printf("  %-12s %8s %8s %8s\n", "", "min", "max", "mean")
+	for _, row := range []struct {
+		label string
+		stat  *statRange
+	}{{"Temperature", &stats.Temperature}, {"Wind", &stats.Wind}, {"Pressure", &stats.Pressure}} {
+		places := row.stat.decimals()
+		fmt.Printf("  %-12s %8.*f %8.*f %8.*f %s\n", row.label, places, row.stat.Min, places, row.stat.Max, places, row.stat.Mean, row.stat.Unit)
+	}
+	fmt.Printf("  Peak gust %.1f %s, rain %.2f %s\n", stats.PeakGust, stats.Wind.Unit, stats.Rain, stats.RainUnit)
+	fmt.Print("  WBGT hours:")
+	for level, threshold := range wbgtThresholds {
+		fmt.Printf("  %s ≥%.0f°F %.1f", string([]rune("⚊⚌☰⚑")[level]), threshold, stats.WBGTHours[level])
+	}
+	fmt.Println()
+}
+
+// statsCommand sums up the history, ala 'weatherstem stats -station ponceinlet -since 7d'
+func statsCommand(config *configSettings, args []string) (err error) {
+	var station, since string
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	flags.StringVar(&station, "station", "", "Only this station, by handle or alias")
+	flags.StringVar(&since, "since", "7d", "How far back, ala 7d or 12h")
+	flags.Parse(args)
+	station = config.resolveStation(station)
+
+	if config.History.File == "" {
+		return errNoHistory
+	}
+	back, err := parseRetention(since)
+	if err != nil {
+		return err
+	}
+	from := time.Now().Add(-back)
+
+	// An hour early, for the rain gauge reading to count from
+	records, err := readHistory(config, from.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	handles, byStation := historyByStation(records)
+	if station != "" {
+		handles = []string{station}
+	}
+
+	for i, handle := range handles {
+		history := byStation[handle]
+		stats := AssessStatistics(history, from)
+		if stats.Records == 0 {
+			return fmt.Errorf("No history for station %s in the last %s", handle, since)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		stats.PrintStatistics(history[len(history)-1].Data.Station[1], handle, since)
+	}
+	return nil
+}
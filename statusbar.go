@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Readings older than this are stale as far as the status bar is concerned
+const statusbarFresh = 15 * time.Minute
+
+// Layouts the API has been seen to use for the reading time
+var readingTimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339, "2006-01-02T15:04:05"}
+
+// readingTime parses the station's reading time, which comes without a zone and is
+// taken to be local time
+func readingTime(data *WeatherData) (when time.Time, ok bool) {
+	for _, layout := range readingTimeLayouts {
+		if when, err := time.ParseInLocation(layout, data.Station[2], time.Local); err == nil {
+			return when, true
+		}
+	}
+	return when, false
+}
+
+// pickStatusbarStation chooses the station to show. "always-nearest" is just that.
+// "nearest-fresh", the default, takes the nearest station which updated recently, or
+// the most recently updated one if they are all stale.
+func pickStatusbarStation(dataArr []WeatherData, policy string, now time.Time) (pick int) {
+	nearest, nearestFresh, freshest := -1, -1, -1
+	var freshestTime time.Time
+	for i := range dataArr {
+		if nearest < 0 || dataArr[i].StationDist < dataArr[nearest].StationDist {
+			nearest = i
+		}
+		when, ok := readingTime(&dataArr[i])
+		if !ok {
+			continue
+		}
+		if freshest < 0 || when.After(freshestTime) {
+			freshest, freshestTime = i, when
+		}
+		if now.Sub(when) <= statusbarFresh && (nearestFresh < 0 || dataArr[i].StationDist < dataArr[nearestFresh].StationDist) {
+			nearestFresh = i
+		}
+	}
+
+	switch {
+	case policy == "always-nearest":
+		return nearest
+	case nearestFresh >= 0:
+		return nearestFresh
+	case freshest >= 0:
+		return freshest
+	default:
+		return nearest
+	}
+}
+
+// PrintStatusbar shows a station on one short line, for tmux, i3bar and friends.
+// Stations with air quality sensors add the AQI. Cached data gets its age on the end,
+// ala "(12m0s old)".
+func (data *WeatherData) PrintStatusbar(wu *WeatherUnits, age time.Duration) {
+	var stale string
+	if age > 0 {
+		stale = " (" + age.String() + " old)"
+	}
+	var aqi string
+	if data.AirQuality != nil {
+		aqi = fmt.Sprintf(" AQI %d", data.AirQuality.AQI)
+	}
+	fmt.Printf("%s %.0f%s %.0f%% %.0f%s %s%s%s\n", data.Station[1], data.Temperature[0], wu.Temperature[0], data.Humidity, data.Windspeed[0], wu.Windspeed[0], data.Wind[0], aqi, stale)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+func init() {
+	registerSink("kafka", func(target string) (outputSink, error) {
+		slash := strings.LastIndex(target, "/")
+		if slash <= 0 || slash == len(target)-1 {
+			return nil, fmt.Errorf("kafka needs brokers and a topic, ala broker1:9092,broker2:9092/weather")
+		}
+		return &kafkaSink{brokers: strings.Split(target[:slash], ","), topic: target[slash+1:]}, nil
+	})
+	registerSink("nats", func(target string) (outputSink, error) {
+		where, err := url.Parse(target)
+		if err != nil || where.Host == "" || strings.Trim(where.Path, "/") == "" {
+			return nil, fmt.Errorf("nats needs a server and a subject, ala nats://localhost:4222/weather")
+		}
+		return &natsSink{server: where, subject: strings.Trim(where.Path, "/")}, nil
+	})
+}
+
+// stationMessages are each station's data and units as a JSON message, keyed by handle
+func stationMessages(batch *sinkBatch) (keys []string, messages [][]byte, err error) {
+	for i, doc := range stationDocuments(batch.Data, batch.Units) {
+		jdata, err := marshalJSONCompact(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, batch.Data[i].Station[0])
+		messages = append(messages, jdata)
+	}
+	return keys, messages, nil
+}
+
+// kafkaSink publishes each station's cooked JSON to a topic, with the station
+// handle as the key so a station always lands on the same partition
+type kafkaSink struct {
+	brokers []string
+	topic   string
+}
+
+func (sink *kafkaSink) Send(config *configSettings, batch *sinkBatch) error {
+	keys, messages, err := stationMessages(batch)
+	if err != nil {
+		return err
+	}
+	writer := kafka.NewWriter(kafka.WriterConfig{
+		Brokers:  sink.brokers,
+		Topic:    sink.topic,
+		Balancer: &kafka.Hash{},
+	})
+	defer writer.Close()
+	var batchMessages []kafka.Message
+	for i := range messages {
+		batchMessages = append(batchMessages, kafka.Message{Key: []byte(keys[i]), Value: messages[i], Time: batch.Time})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return writer.WriteMessages(ctx, batchMessages...)
+}
+
+// natsSink publishes each station's cooked JSON on <subject>.<handle>. NATS has no
+// message keys, so the handle goes on the end of the subject, and subscribers can
+// take the lot with <subject>.> or pick stations.
+// The client protocol is a few lines of text, so it is spoken here directly.
+type natsSink struct {
+	server  *url.URL
+	subject string
+}
+
+// natsConnect is the CONNECT a NATS client opens with, user and pass or a token from the
+// server URL's user info
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+func (sink *natsSink) Send(config *configSettings, batch *sinkBatch) error {
+	keys, messages, err := stationMessages(batch)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", sink.server.Host, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// The server says hello with INFO first
+	if line, err := reader.ReadString('\n'); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("not a NATS server: %q", strings.TrimSpace(line))
+	}
+	connect := natsConnect{Name: "weatherstem-cli"}
+	if user := sink.server.User; user != nil {
+		if password, ok := user.Password(); ok {
+			connect.User, connect.Pass = user.Username(), password
+		} else {
+			connect.AuthToken = user.Username()
+		}
+	}
+	connectJSON, err := json.Marshal(connect)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(conn)
+	fmt.Fprintf(writer, "CONNECT %s\r\n", connectJSON)
+	for i := range messages {
+		fmt.Fprintf(writer, "PUB %s.%s %d\r\n", sink.subject, strings.ReplaceAll(keys[i], ".", "_"), len(messages[i]))
+		writer.Write(messages[i])
+		writer.WriteString("\r\n")
+	}
+	// A PONG back means the server took it all
+	writer.WriteString("PING\r\n")
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server said %s", strings.TrimSpace(line))
+		case strings.HasPrefix(line, "PING"):
+			writer.WriteString("PONG\r\n")
+			writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// checkStream makes sure -stream comes with nothing that needs every station at once:
+// the table, the area, here, the whole-document outputs, the status bar, -diff, or a sort
+// other than the config's
+func checkStream(stream, wantsAll bool, sortBy string) error {
+	if stream && (wantsAll || (sortBy != "" && sortBy != "config")) {
+		return errors.New("stream shows each station as it comes, so it goes with the text, -lite, -json, -ndjson or -orig output only, in the config's order")
+	}
+	return nil
+}
+
+// faultReader mangles a streamed response the way faultAfterCall does, reading it all
+// first when there is a fault to inject
+func faultReader(body io.Reader) (io.Reader, error) {
+	if injectedFault != "bad-json" && injectedFault != "partial" {
+		return body, nil
+	}
+	whole, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(faultAfterCall(whole)), nil
+}
+
+// streamStations calls the API and shows each station as soon as its result is in, rather
+// than after the whole response, ala 'weatherstem -stream -ndjson' with many stations
+// that have many cameras. Only a station's worth is held at a time, so it does only what
+// takes one station: cooked in the unit system, with aliases and provenance, and no cache,
+// history, sorting, upwind or area. Endpoints are called in turn, and one that fails,
+// even partway through, only costs its stations not yet shown; it takes all of them
+// failing before anything was shown for an error.
+func streamStations(c *configSettings, system string, show func(info *WeatherInfo, data *WeatherData, units *WeatherUnits)) (shown int, failed []stationError, err error) {
+	endpoints := c.splitByEndpoint()
+	if len(endpoints) == 0 {
+		endpoints = []apiEndpoint{{URL: c.URL, Key: c.Key, Stations: c.Stations}}
+	}
+
+	var firstErr error
+	errored := 0
+	for _, endpoint := range endpoints {
+		fetched := time.Now()
+		body, err := openEndpoint(c, endpoint.URL, endpoint.Key, endpoint.Stations)
+		var results io.Reader
+		if err == nil {
+			results, err = faultReader(body)
+		}
+		var stationsFailed []stationError
+		// What is out already cannot fail any more
+		done := make(map[string]bool)
+		if err == nil {
+			stationsFailed, err = decodeWeatherInfo(results, endpoint.Stations, func(info WeatherInfo) {
+				done[info.WeatherStation.Handle] = true
+				weatherArr := []WeatherInfo{info}
+				dataArr, unitArr := cookWeatherInfo(weatherArr, c.Me.Coord, system)
+				applyAliases(c, dataArr, unitArr)
+				stampProvenance(c, dataArr, weatherArr, fetched)
+				convertToSystem(system, dataArr, unitArr)
+				show(&info, &dataArr[0], &unitArr[0])
+				shown++
+			})
+			if err != nil {
+				err = fmt.Errorf("%w: %v", errNotStations, err)
+			}
+		}
+		if body != nil {
+			body.Close()
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			errored++
+			if len(endpoints) > 1 {
+				logWarn("Call to", endpoint.URL, "failed.", err)
+			}
+			for _, failure := range stationsFailed {
+				done[failure.Station] = true
+			}
+			for _, station := range endpoint.Stations {
+				if !done[stationHandle(station)] {
+					stationsFailed = append(stationsFailed, stationError{Label: "error", Station: stationHandle(station), Error: err.Error()})
+				}
+			}
+		}
+		failed = append(failed, stationsFailed...)
+	}
+	if errored == len(endpoints) && shown == 0 {
+		return 0, nil, firstErr
+	}
+	return shown, failed, nil
+}
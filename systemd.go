@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Under systemd, serve says when it is ready, reloading and stopping, and keeps the
+// watchdog fed, ala a unit with
+//
+//	[Service]
+//	Type=notify
+//	ExecStart=/usr/local/bin/weatherstem serve
+//	ExecReload=/bin/kill -HUP $MAINPID
+//	WatchdogSec=60
+//
+// Outside systemd there is no $NOTIFY_SOCKET and all of it does nothing.
+
+// sdNotify sends systemd a state, ala "READY=1", if it is listening
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// An @ is a Linux abstract socket
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		logWarn("Cannot notify systemd.", err)
+		return
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		logWarn("Cannot notify systemd.", err)
+	}
+}
+
+// watchdogInterval is how often to feed systemd's watchdog, half its WatchdogSec, or 0
+// when there is no watchdog on this process
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
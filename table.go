@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// PrintWeatherTable shows the stations as columns and the quantities as rows, which is
+// easier to scan than one block after another when watching several stations
+func PrintWeatherTable(dataArr []WeatherData, unitArr []WeatherUnits) {
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	row := func(name string, cell func(data *WeatherData, units *WeatherUnits) string) {
+		cells := []string{name}
+		for i := range dataArr {
+			cells = append(cells, cell(&dataArr[i], &unitArr[i]))
+		}
+		fmt.Fprintln(table, strings.Join(cells, "\t")+"\t")
+	}
+
+	row("", func(data *WeatherData, units *WeatherUnits) string { return data.Station[1] })
+	row("Temp", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%s", decimals("temp", 1), data.Temperature[0], units.Temperature[0])
+	})
+	row("DP", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%s%s", decimals("temp", 1), data.Temperature[1], units.Temperature[1], data.moistureArrow(false))
+	})
+	row("WBGT", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%s%.*f%s", WBGTFlag(data.wbgtFahrenheit(units)), decimals("temp", 1), data.Temperature[2], units.Temperature[2])
+	})
+	row("Humidity", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%%%s", decimals("humidity", 0), data.Humidity, data.moistureArrow(true))
+	})
+	row("Wind", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%s %s", decimals("wind", 1), data.Windspeed[0], units.Windspeed[0], data.Wind[0])
+	})
+	row("Gust", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%s", decimals("wind", 1), data.Windspeed[1], units.Windspeed[1])
+	})
+	row("Pressure", func(data *WeatherData, units *WeatherUnits) string {
+		return strings.TrimSpace(fmt.Sprintf("%.*f%s %s", decimals("pressure", 3), data.Pressure, units.Pressure, data.PressureTrend))
+	})
+	row("Rain", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%s", decimals("rain", 2), data.Rain[0], units.Rain[0])
+	})
+	row("Rain rate", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%s", decimals("rain", 2), data.Rain[1], units.Rain[1])
+	})
+	row("Solar", func(data *WeatherData, units *WeatherUnits) string {
+		return fmt.Sprintf("%.*f%s", decimals("solar", 0), data.Sun[0], units.Sun[0])
+	})
+	for i := range dataArr {
+		if dataArr[i].AirQuality != nil {
+			row("AQI", func(data *WeatherData, units *WeatherUnits) string {
+				if data.AirQuality == nil {
+					return ""
+				}
+				return fmt.Sprintf("%d %s", data.AirQuality.AQI, data.AirQuality.Category)
+			})
+			break
+		}
+	}
+	for i := range dataArr {
+		if dataArr[i].Upwind != nil {
+			row("Upwind", func(data *WeatherData, units *WeatherUnits) string {
+				if data.Upwind == nil {
+					return ""
+				}
+				if data.Upwind.Most {
+					return fmt.Sprintf("★ %.*f%s", decimals("distance", 2), data.Upwind.Distance, units.StationDist)
+				}
+				return fmt.Sprintf("%.*f%s", decimals("distance", 2), data.Upwind.Distance, units.StationDist)
+			})
+			break
+		}
+	}
+	row("Distance", func(data *WeatherData, units *WeatherUnits) string {
+		return strings.TrimSpace(fmt.Sprintf("%.*f%s %s", decimals("distance", 2), data.StationDist, units.StationDist, data.bearingHeading()))
+	})
+	row("Time", func(data *WeatherData, units *WeatherUnits) string { return data.Station[2] })
+	table.Flush()
+}
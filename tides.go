@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+	haversine "github.com/loraxipam/havers2"
+)
+
+const (
+	// NOAA CO-OPS needs no key. "tides_url" in the config points somewhere else.
+	tidesDefaultURL = "https://api.tidesandcurrents.noaa.gov"
+	// Stations further than this (km) from a tide station are not on the coast
+	tideDefaultDistance = 15.0
+	// The tide station list hardly ever changes, so it is fetched once a week
+	tideStationsMaxAge = 7 * 24 * time.Hour
+	// How far ahead to look for the next high and low, a tide and a bit
+	tideRange = 26
+)
+
+// tideStation is a CO-OPS station with tide predictions
+type tideStation struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lng"`
+}
+
+// TideEvent is a high or low tide, its height above mean lower low water
+type TideEvent struct {
+	Time   time.Time `json:"time"`
+	High   bool      `json:"high"`
+	Height float64   `json:"height"`
+}
+
+// String is the tide and when, ala "High 14:32"
+func (event TideEvent) String() string {
+	kind := "Low"
+	if event.High {
+		kind = "High"
+	}
+	return fmt.Sprintf("%s %s", kind, event.Time.Local().Format("15:04"))
+}
+
+// Tides are the next high and low at the tide station closest to a weather station
+type Tides struct {
+	Station  string      `json:"station"`
+	Name     string      `json:"name"`
+	Distance float64     `json:"distance_km"`
+	Unit     string      `json:"unit"`
+	Next     []TideEvent `json:"next"`
+}
+
+// String is the next tides and where, ala "High 14:32 3.4ft, Low 20:51 0.2ft at Ponce Inlet (8721120)"
+func (tides *Tides) String() string {
+	var next []string
+	for _, event := range tides.Next {
+		next = append(next, fmt.Sprintf("%s %.1f%s", event, event.Height, tides.Unit))
+	}
+	return fmt.Sprintf("%s at %s (%s)", strings.Join(next, ", "), tides.Name, tides.Station)
+}
+
+// tideDistance is how close a tide station has to be for a weather station to get tides, in km
+func (config *configSettings) tideDistance() float64 {
+	if config.TideDistance > 0 {
+		return config.TideDistance
+	}
+	return tideDefaultDistance
+}
+
+// tidesBaseURL is NOAA's, or "tides_url" from the config
+func (config *configSettings) tidesBaseURL() string {
+	if config.TidesURL != "" {
+		return strings.TrimSuffix(config.TidesURL, "/")
+	}
+	return tidesDefaultURL
+}
+
+// tideStationsFile is where the tide station list is kept, next to the cached API response
+func tideStationsFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "weatherstem", "tidestations.json")
+}
+
+// getTides calls CO-OPS and returns the body, or an error for anything but a 200
+func getTides(client *http.Client, tidesURL string) (body []byte, err error) {
+	request, err := http.NewRequest(http.MethodGet, tidesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", userAgent())
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	logDebug("Calling", tidesURL)
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if body, err = readResponse(response); err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NOAA CO-OPS answered %s", response.Status)
+	}
+	return body, nil
+}
+
+// fetchTideStations gets the list of tide prediction stations, from the cache when it
+// is young enough. A stale list beats none when CO-OPS is down.
+func fetchTideStations(c *configSettings, client *http.Client) (stations []tideStation, err error) {
+	var list struct {
+		Stations []tideStation `json:"stations"`
+	}
+	filename := tideStationsFile()
+	cached, cacheErr := ioutil.ReadFile(filename)
+	if info, statErr := os.Stat(filename); cacheErr == nil && statErr == nil && time.Since(info.ModTime()) < tideStationsMaxAge {
+		if json.Unmarshal(cached, &list) == nil && len(list.Stations) > 0 {
+			return list.Stations, nil
+		}
+	}
+
+	body, err := getTides(client, c.tidesBaseURL()+"/mdapi/prod/webapi/stations.json?type=tidepredictions")
+	if err == nil {
+		err = json.Unmarshal(body, &list)
+	}
+	if err != nil {
+		if cacheErr == nil && json.Unmarshal(cached, &list) == nil && len(list.Stations) > 0 {
+			logWarn("Cannot get the tide stations, using the old list.", err)
+			return list.Stations, nil
+		}
+		return nil, err
+	}
+	if filename != "" {
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err == nil {
+			if err := replaceFile(filename, body); err != nil {
+				logWarn("Cannot keep the tide stations.", err)
+			}
+		}
+	}
+	return list.Stations, nil
+}
+
+// nearestTideStation is the tide station closest to the spot, and how far away in km
+func nearestTideStation(stations []tideStation, spot haversine.Coord) (nearest *tideStation, km float64) {
+	spot.Calc()
+	km = math.Inf(1)
+	for i := range stations {
+		there := haversine.Coord{Lat: stations[i].Lat, Lon: stations[i].Lon}
+		there.Calc()
+		if distance := haversine.DistanceKm(spot, there); distance < km {
+			nearest, km = &stations[i], distance
+		}
+	}
+	return nearest, km
+}
+
+// fetchTides gets the next high and low tide at a tide station, in feet for stations
+// in °F and meters for the rest
+func fetchTides(c *configSettings, client *http.Client, station *tideStation, units *WeatherUnits, now time.Time) (tides Tides, err error) {
+	system, unit := "metric", "m"
+	if strings.HasSuffix(strings.ToLower(html.UnescapeString(units.Temperature[0])), "f") {
+		system, unit = "english", "ft"
+	}
+	tidesURL := fmt.Sprintf("%s/api/prod/datagetter?product=predictions&datum=MLLW&interval=hilo&time_zone=gmt&format=json&units=%s&station=%s&begin_date=%s&range=%d",
+		c.tidesBaseURL(), system, station.ID, now.UTC().Format("20060102")+"%20"+now.UTC().Format("15:04"), tideRange)
+	body, err := getTides(client, tidesURL)
+	if err != nil {
+		return tides, err
+	}
+
+	var answer struct {
+		Predictions []struct {
+			Time   string  `json:"t"`
+			Height float64 `json:"v,string"`
+			Type   string  `json:"type"`
+		} `json:"predictions"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err = json.Unmarshal(body, &answer); err != nil {
+		return tides, err
+	}
+	if answer.Error.Message != "" {
+		return tides, fmt.Errorf("NOAA CO-OPS says %s", answer.Error.Message)
+	}
+
+	tides = Tides{Station: station.ID, Name: station.Name, Unit: unit}
+	var high, low bool
+	for _, prediction := range answer.Predictions {
+		when, err := time.Parse("2006-01-02 15:04", prediction.Time)
+		if err != nil || when.Before(now) {
+			continue
+		}
+		event := TideEvent{Time: when, High: strings.HasPrefix(prediction.Type, "H"), Height: prediction.Height}
+		if (event.High && !high) || (!event.High && !low) {
+			tides.Next = append(tides.Next, event)
+			high, low = high || event.High, low || !event.High
+		}
+	}
+	return tides, nil
+}
+
+// annotateTides adds the next high and low tide to every station near enough a tide
+// station. Inland stations are left alone, and one CO-OPS cannot answer for is logged.
+func annotateTides(c *configSettings, dataArr []WeatherData, unitArr []WeatherUnits) {
+	client, err := httpClient(c)
+	if err != nil {
+		logWarn("Cannot get the tides.", err)
+		return
+	}
+	stations, err := fetchTideStations(c, client)
+	if err != nil {
+		logWarn("Cannot get the tide stations.", err)
+		return
+	}
+	// Neighbors often share a tide station, so each is asked once
+	now := time.Now()
+	asked := make(map[string]Tides)
+	for i := range dataArr {
+		nearest, km := nearestTideStation(stations, dataArr[i].StationTopo)
+		if nearest == nil || km > c.tideDistance() {
+			logDebug("No tide station near", dataArr[i].Station[0])
+			continue
+		}
+		key := nearest.ID + " " + unitArr[i].Temperature[0]
+		tides, ok := asked[key]
+		if !ok {
+			if tides, err = fetchTides(c, client, nearest, &unitArr[i], now); err != nil {
+				logWarn("Cannot get the tides for", dataArr[i].Station[0]+".", err)
+				continue
+			}
+			asked[key] = tides
+		}
+		tides.Distance = math.Round(km*10) / 10
+		dataArr[i].Tides = &tides
+	}
+}
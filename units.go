@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+
+	haversine "github.com/loraxipam/havers2"
+	"github.com/loraxipam/weatherstem-cli/weatherjson"
+)
+
+// The API hands back unit symbols as the station reports them, HTML-escaped
+// and all. These helpers get values into metric for the math, and stations
+// onto the same units before anybody compares them.
+
+// unitScale says how to get a unit to the metric base of its dimension:
+// base = value*factor + offset
+type unitScale struct {
+	dimension string
+	factor    float64
+	offset    float64
+}
+
+// knownUnits covers the unit symbols WeatherSTEM stations report, lower cased and unescaped
+var knownUnits = map[string]unitScale{
+	"°f":    {"temperature", 5.0 / 9.0, -32.0 * 5.0 / 9.0},
+	"°c":    {"temperature", 1, 0},
+	"mph":   {"speed", 0.44704, 0},
+	"km/h":  {"speed", 1 / 3.6, 0},
+	"kph":   {"speed", 1 / 3.6, 0},
+	"kmh":   {"speed", 1 / 3.6, 0},
+	"m/s":   {"speed", 1, 0},
+	"kt":    {"speed", 0.514444, 0},
+	"kts":   {"speed", 0.514444, 0},
+	"knots": {"speed", 0.514444, 0},
+	"inhg":  {"pressure", 33.86389, 0},
+	"hpa":   {"pressure", 1, 0},
+	"mb":    {"pressure", 1, 0},
+	"mbar":  {"pressure", 1, 0},
+	"kpa":   {"pressure", 10, 0},
+	"mmhg":  {"pressure", 1.333224, 0},
+	"in":    {"length", 25.4, 0},
+	"mm":    {"length", 1, 0},
+	"cm":    {"length", 10, 0},
+	"km":    {"length", 1e6, 0},
+	"mi":    {"length", 1609344, 0},
+	"in/h":  {"rate", 25.4, 0},
+	"in/hr": {"rate", 25.4, 0},
+	"mm/h":  {"rate", 1, 0},
+	"mm/hr": {"rate", 1, 0},
+}
+
+// lookupUnit finds the scale for a unit symbol as the API spells it
+func lookupUnit(unit string) (scale unitScale, ok bool) {
+	scale, ok = knownUnits[strings.ToLower(strings.TrimSpace(html.UnescapeString(unit)))]
+	return scale, ok
+}
+
+// convertUnit converts a value between two units of the same dimension.
+// ok is false when either unit is unknown or they measure different things.
+func convertUnit(value float64, from, to string) (converted float64, ok bool) {
+	fromScale, fromOK := lookupUnit(from)
+	toScale, toOK := lookupUnit(to)
+	if !fromOK || !toOK || fromScale.dimension != toScale.dimension {
+		return value, false
+	}
+	return (value*fromScale.factor + fromScale.offset - toScale.offset) / toScale.factor, true
+}
+
+// toCelsius converts a temperature in the given unit to degrees Celsius
+func toCelsius(value float64, unit string) float64 {
+	converted, _ := convertUnit(value, unit, "°C")
+	return converted
+}
+
+// fromCelsius converts a temperature in degrees Celsius back to the given unit
+func fromCelsius(value float64, unit string) float64 {
+	converted, _ := convertUnit(value, "°C", unit)
+	return converted
+}
+
+// toMetersPerSecond converts a windspeed in the given unit to meters per second
+func toMetersPerSecond(value float64, unit string) float64 {
+	converted, _ := convertUnit(value, unit, "m/s")
+	return converted
+}
+
+// rawUnits keeps the unit symbols as the API sent them, entities and all, for -raw-units
+var rawUnits bool
+
+// canonicalUnit is how a unit is written once normalized: the UTF-8 symbol people read
+// and its UCUM code, for programs that have to know exactly what it means
+type canonicalUnit struct {
+	symbol string
+	ucum   string
+}
+
+// canonicalUnits are the unit symbols the API sends, lower cased and unescaped, and how
+// they should read. Several spellings of one unit come out as the same symbol.
+var canonicalUnits = map[string]canonicalUnit{
+	"°f":    {"°F", "[degF]"},
+	"°c":    {"°C", "Cel"},
+	"°":     {"°", "deg"},
+	"%":     {"%", "%"},
+	"mph":   {"mph", "[mi_i]/h"},
+	"km/h":  {"km/h", "km/h"},
+	"kph":   {"km/h", "km/h"},
+	"kmh":   {"km/h", "km/h"},
+	"m/s":   {"m/s", "m/s"},
+	"kt":    {"kt", "[kn_i]"},
+	"kts":   {"kt", "[kn_i]"},
+	"knots": {"kt", "[kn_i]"},
+	"inhg":  {"inHg", "[in_i'Hg]"},
+	"hpa":   {"hPa", "hPa"},
+	"mb":    {"mbar", "mbar"},
+	"mbar":  {"mbar", "mbar"},
+	"kpa":   {"kPa", "kPa"},
+	"mmhg":  {"mmHg", "mm[Hg]"},
+	"in":    {"in", "[in_i]"},
+	"mm":    {"mm", "mm"},
+	"cm":    {"cm", "cm"},
+	"km":    {"km", "km"},
+	"mi":    {"mi", "[mi_i]"},
+	"in/h":  {"in/h", "[in_i]/h"},
+	"in/hr": {"in/h", "[in_i]/h"},
+	"mm/h":  {"mm/h", "mm/h"},
+	"mm/hr": {"mm/h", "mm/h"},
+	"w/m²":  {"W/m²", "W/m2"},
+	"w/m2":  {"W/m²", "W/m2"},
+	"cb":    {"cb", "cbar"},
+	"index": {"index", "{index}"},
+}
+
+// normalizeUnit is a unit symbol as it should read and its UCUM code, ala "&deg;F" to
+// "°F" and "[degF]". Units missing from the table are only unescaped, with no code.
+func normalizeUnit(unit string) (symbol, ucum string) {
+	symbol = html.UnescapeString(unit)
+	if canonical, ok := canonicalUnits[strings.ToLower(strings.TrimSpace(symbol))]; ok {
+		return canonical.symbol, canonical.ucum
+	}
+	return symbol, ""
+}
+
+// UnitCodes are the UCUM codes of a station's units, as the library publishes them
+type UnitCodes = weatherjson.UnitCodes
+
+// normalize rewrites the units as canonical symbols and files their UCUM codes, so
+// nothing after parsing sees an HTML entity
+func (units *WeatherUnits) normalize() {
+	codes := &UnitCodes{
+		SoilDepth:    make([]string, len(units.SoilDepth)),
+		SoilTemp:     make([]string, len(units.SoilTemp)),
+		SoilMoisture: make([]string, len(units.SoilMoisture)),
+	}
+	symbols := []*string{&units.Humidity, &units.Pressure}
+	ucums := []*string{&codes.Humidity, &codes.Pressure}
+	for i, array := range [][]string{units.Temperature[:], units.Windspeed[:], units.Rain[:], units.Sun[:], units.SoilDepth, units.SoilTemp, units.SoilMoisture} {
+		codeArray := [][]string{codes.Temperature[:], codes.Windspeed[:], codes.Rain[:], codes.Sun[:], codes.SoilDepth, codes.SoilTemp, codes.SoilMoisture}[i]
+		for j := range array {
+			symbols, ucums = append(symbols, &array[j]), append(ucums, &codeArray[j])
+		}
+	}
+	for i := range symbols {
+		*symbols[i], *ucums[i] = normalizeUnit(*symbols[i])
+	}
+	for _, field := range []*string{&units.StationTopo.Lat, &units.StationTopo.Lon, &units.StationDist, &units.StationBearing, &units.PressureTrend, &units.Wind[0], &units.Wind[1]} {
+		*field, _ = normalizeUnit(*field)
+	}
+	units.UCUM = codes
+}
+
+// harmonizeUnits converts every station onto the first station's units, so the
+// numbers can be compared or combined. It returns a warning for each station it
+// converted. Units it does not know are left alone.
+func harmonizeUnits(dataArr []WeatherData, unitArr []WeatherUnits) (warnings []string) {
+	if len(dataArr) < 2 {
+		return nil
+	}
+	ref := &unitArr[0]
+	for i := 1; i < len(dataArr); i++ {
+		data, units := &dataArr[i], &unitArr[i]
+		quantities := []struct {
+			value *float64
+			unit  *string
+			want  string
+		}{
+			{&data.Temperature[0], &units.Temperature[0], ref.Temperature[0]},
+			{&data.Temperature[1], &units.Temperature[1], ref.Temperature[1]},
+			{&data.Temperature[2], &units.Temperature[2], ref.Temperature[2]},
+			{&data.Temperature[3], &units.Temperature[3], ref.Temperature[3]},
+			{&data.Temperature[4], &units.Temperature[4], ref.Temperature[4]},
+			{&data.Windspeed[0], &units.Windspeed[0], ref.Windspeed[0]},
+			{&data.Windspeed[1], &units.Windspeed[1], ref.Windspeed[1]},
+			{&data.Pressure, &units.Pressure, ref.Pressure},
+			{&data.Rain[0], &units.Rain[0], ref.Rain[0]},
+			{&data.Rain[1], &units.Rain[1], ref.Rain[1]},
+		}
+		var conversions []string
+		for _, q := range quantities {
+			if *q.unit == q.want || *q.unit == "" || q.want == "" {
+				continue
+			}
+			if converted, ok := convertUnit(*q.value, *q.unit, q.want); ok {
+				conversion := html.UnescapeString(*q.unit) + " to " + html.UnescapeString(q.want)
+				if !strings.Contains(strings.Join(conversions, ","), conversion) {
+					conversions = append(conversions, conversion)
+				}
+				*q.value, *q.unit = converted, q.want
+			}
+		}
+		if len(conversions) > 0 {
+			if units.UCUM != nil {
+				units.normalize()
+			}
+			data.noteTransformation("converted " + strings.Join(conversions, ", "))
+			warnings = append(warnings, fmt.Sprintf("%s converted %s to match %s", data.Station[1], strings.Join(conversions, ", "), dataArr[0].Station[1]))
+		}
+	}
+	return warnings
+}
+
+// unitSystem is one set of units to show every station in, for -units. Pressure stays as
+// the station reports it.
+type unitSystem struct {
+	distance    string
+	speed       string
+	temperature string
+	rain        string
+	rate        string
+}
+
+// unitSystemNames are the -units choices, in the order to list them
+var unitSystemNames = []string{"nautical", "metric", "imperial"}
+
+// unitSystems are the sets of units -units picks from
+var unitSystems = map[string]unitSystem{
+	"nautical": {"NM", "kt", "°C", "mm", "mm/h"},
+	"metric":   {"km", "km/h", "°C", "mm", "mm/h"},
+	"imperial": {"mi", "mph", "°F", "in", "in/h"},
+}
+
+// settleUnits works out this run's unit system: -units, or -kilo or -mile as shorthand
+// for metric and imperial, or else the config's. Empty keeps the stations' own units, with
+// the distances in nautical miles. Flags that disagree are an error.
+func settleUnits(config, units string, kilo, mile bool) (string, error) {
+	var shorthand, given string
+	switch {
+	case kilo && mile:
+		return "", fmt.Errorf("-kilo and -mile disagree, pick one")
+	case kilo:
+		shorthand, given = "metric", "-kilo"
+	case mile:
+		shorthand, given = "imperial", "-mile"
+	}
+	if units != "" && shorthand != "" && units != shorthand {
+		return "", fmt.Errorf("-units %s and %s disagree, pick one", units, given)
+	}
+	for _, system := range []string{units, shorthand, config} {
+		if system == "" {
+			continue
+		}
+		if _, ok := unitSystems[system]; !ok {
+			return "", fmt.Errorf("units are %s, not %q", strings.Join(unitSystemNames, ", "), system)
+		}
+		return system, nil
+	}
+	return "", nil
+}
+
+// measureDistance is how far apart two spots are in the unit, NM, km or mi, nautical miles
+// for anything else
+func measureDistance(unit string, from, to haversine.Coord) float64 {
+	switch unit {
+	case "km":
+		return haversine.DistanceKm(from, to)
+	case "mi":
+		return haversine.DistanceMi(from, to)
+	}
+	return haversine.DistanceNM(from, to)
+}
+
+// convertToSystem converts every station's temperatures, wind and rain onto the unit
+// system, to two decimals, noting each conversion in the provenance. Units it does not
+// know are left alone.
+func convertToSystem(name string, dataArr []WeatherData, unitArr []WeatherUnits) {
+	system, ok := unitSystems[name]
+	if !ok {
+		return
+	}
+	for i := range dataArr {
+		data, units := &dataArr[i], &unitArr[i]
+		quantities := []struct {
+			value *float64
+			unit  *string
+			want  string
+		}{
+			{&data.Temperature[0], &units.Temperature[0], system.temperature},
+			{&data.Temperature[1], &units.Temperature[1], system.temperature},
+			{&data.Temperature[2], &units.Temperature[2], system.temperature},
+			{&data.Temperature[3], &units.Temperature[3], system.temperature},
+			{&data.Temperature[4], &units.Temperature[4], system.temperature},
+			{&data.Windspeed[0], &units.Windspeed[0], system.speed},
+			{&data.Windspeed[1], &units.Windspeed[1], system.speed},
+			{&data.Rain[0], &units.Rain[0], system.rain},
+			{&data.Rain[1], &units.Rain[1], system.rate},
+		}
+		var conversions []string
+		for _, q := range quantities {
+			if *q.unit == "" || strings.EqualFold(html.UnescapeString(*q.unit), q.want) {
+				continue
+			}
+			if converted, ok := convertUnit(*q.value, *q.unit, q.want); ok {
+				conversion := html.UnescapeString(*q.unit) + " to " + q.want
+				if !strings.Contains(strings.Join(conversions, ","), conversion) {
+					conversions = append(conversions, conversion)
+				}
+				*q.value, *q.unit = math.Round(converted*100)/100, q.want
+			}
+		}
+		if len(conversions) > 0 {
+			if units.UCUM != nil {
+				units.normalize()
+			}
+			data.noteTransformation("converted " + strings.Join(conversions, ", ") + " for " + name + " units")
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// stationSorts are the orders -sort puts the stations in
+var stationSorts = []string{"config", "name", "distance", "upwind"}
+
+// Upwind is how far toward the wind a station is from me, in the distance units, the wind
+// being the area's as AggregateArea has it. Negative is downwind. The most upwind station
+// is the best guess at what is coming.
+type Upwind struct {
+	Distance float64 `json:"distance"`
+	WindDir  float64 `json:"winddir"`
+	Most     bool    `json:"most,omitempty"`
+}
+
+// way is upwind or downwind
+func (upwind *Upwind) way() string {
+	if upwind.Distance < 0 {
+		return "downwind"
+	}
+	return "upwind"
+}
+
+// AssessUpwind works out how far upwind each station is and marks the most upwind one.
+// The wind blows from its direction, so a station that way from me sees the weather
+// first. When it is calm nothing is upwind and the stations are left alone.
+func AssessUpwind(dataArr []WeatherData, unitArr []WeatherUnits) {
+	area := AggregateArea(dataArr, unitArr)
+	if area.Windspeed[0] == 0 {
+		return
+	}
+	most := -1
+	for i := range dataArr {
+		off := (dataArr[i].StationBearing - area.WindDir) * math.Pi / 180
+		dataArr[i].Upwind = &Upwind{Distance: math.Round(dataArr[i].StationDist*math.Cos(off)*100) / 100, WindDir: math.Round(area.WindDir)}
+		if dataArr[i].Upwind.Distance > 0 && (most < 0 || dataArr[i].Upwind.Distance > dataArr[most].Upwind.Distance) {
+			most = i
+		}
+	}
+	if most >= 0 {
+		dataArr[most].Upwind.Most = true
+	}
+}
+
+// sortStations puts the stations in order, the data and units together: as configured,
+// by name, nearest first or most upwind first. Stations with no upwind go last.
+func sortStations(by string, dataArr []WeatherData, unitArr []WeatherUnits) error {
+	var less func(a, b *WeatherData) bool
+	switch by {
+	case "", "config":
+		return nil
+	case "name":
+		less = func(a, b *WeatherData) bool { return strings.ToLower(a.Station[1]) < strings.ToLower(b.Station[1]) }
+	case "distance":
+		less = func(a, b *WeatherData) bool { return a.StationDist < b.StationDist }
+	case "upwind":
+		less = func(a, b *WeatherData) bool {
+			return a.Upwind != nil && (b.Upwind == nil || a.Upwind.Distance > b.Upwind.Distance)
+		}
+	default:
+		return fmt.Errorf("sort is %s, not %q", strings.Join(stationSorts, ", "), by)
+	}
+	order := make([]int, len(dataArr))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return less(&dataArr[order[i]], &dataArr[order[j]]) })
+	data := append([]WeatherData(nil), dataArr...)
+	units := append([]WeatherUnits(nil), unitArr...)
+	for i, from := range order {
+		dataArr[i], unitArr[i] = data[from], units[from]
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version of this tool, for -version, the provenance and the User-Agent. Releases set them with
+// go build -ldflags "-X main.toolVersion=3.1.0 -X main.toolCommit=$(git rev-parse --short HEAD) -X main.toolBuilt=$(date -u +%FT%TZ)"
+var (
+	toolVersion = "dev"
+	toolCommit  = "unknown"
+	toolBuilt   = "unknown"
+)
+
+func init() {
+	// go install ...@v3.1.0 knows its module version even without the ldflags
+	if info, ok := debug.ReadBuildInfo(); ok && toolVersion == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		toolVersion = info.Main.Version
+	}
+}
+
+// PrintVersion shows the build, and the config file version it reads, for bug reports
+func PrintVersion() {
+	fmt.Printf("weatherstem-cli %s\n", toolVersion)
+	fmt.Printf("  commit:  %s\n", toolCommit)
+	fmt.Printf("  built:   %s\n", toolBuilt)
+	fmt.Printf("  go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("  config:  version %s\n", configSettingsVersion)
+}
@@ -0,0 +1,241 @@
+package weatherapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Stream reads an API response a station result at a time, handing each on as it
+// arrives, so a long response is never all in memory at once and the first stations can
+// be dealt with before the last are in. It stops at the first error, each's or the
+// response's, having handed on every result before it.
+func Stream(r io.Reader, each func(result json.RawMessage) error) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.New("the response is not a JSON array")
+	}
+	for decoder.More() {
+		var result json.RawMessage
+		if err = decoder.Decode(&result); err != nil {
+			return err
+		}
+		if err = each(result); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+// Split cuts an API response into its station results. err is only for a response that
+// is no JSON array, or not all of one.
+func Split(body []byte) (results []json.RawMessage, err error) {
+	err = Stream(bytes.NewReader(body), func(result json.RawMessage) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// diagnostics are what a result had to be let off for, counted, in the order first seen
+type diagnostics struct {
+	counts map[string]int
+	order  []string
+}
+
+// add notes something wrong with the result
+func (d *diagnostics) add(format string, args ...interface{}) {
+	note := fmt.Sprintf(format, args...)
+	if d.counts == nil {
+		d.counts = make(map[string]int)
+	}
+	if d.counts[note] == 0 {
+		d.order = append(d.order, note)
+	}
+	d.counts[note]++
+}
+
+// list says them, ala "value was a number (15 times)"
+func (d *diagnostics) list() (notes []string) {
+	for _, note := range d.order {
+		if d.counts[note] > 1 {
+			note = fmt.Sprintf("%s (%d times)", note, d.counts[note])
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// object gets the object under the key. Missing, null or not an object, it is empty.
+func (d *diagnostics) object(parent map[string]interface{}, key string) map[string]interface{} {
+	switch value := parent[key].(type) {
+	case map[string]interface{}:
+		return value
+	case nil:
+		d.add("no %s", key)
+	default:
+		d.add("%s was not an object", key)
+	}
+	return nil
+}
+
+// array gets the array under the key. Missing, null or not an array, it is empty.
+func (d *diagnostics) array(parent map[string]interface{}, key string) []interface{} {
+	switch value := parent[key].(type) {
+	case []interface{}:
+		return value
+	case nil:
+		d.add("no %s", key)
+	default:
+		d.add("%s was not an array", key)
+	}
+	return nil
+}
+
+// text gets the string under the key. Numbers and booleans are taken as their text, as
+// the API sends them for a station that is down; missing or null is empty.
+func (d *diagnostics) text(parent map[string]interface{}, key string) string {
+	switch value := parent[key].(type) {
+	case string:
+		return value
+	case json.Number:
+		d.add("%s was a number", key)
+		return value.String()
+	case bool:
+		d.add("%s was a boolean", key)
+		return strconv.FormatBool(value)
+	case nil:
+		return ""
+	default:
+		d.add("%s was not a string", key)
+	}
+	return ""
+}
+
+// finite gets the text under the key, blank if it is a number no arithmetic survives,
+// ala "NaN" or "Infinity"
+func (d *diagnostics) finite(parent map[string]interface{}, key string) string {
+	text := d.text(parent, key)
+	if number, err := strconv.ParseFloat(text, 64); (err == nil || errors.Is(err, strconv.ErrRange)) && (math.IsNaN(number) || math.IsInf(number, 0)) {
+		d.add("%s was %s", key, text)
+		return ""
+	}
+	return text
+}
+
+// DecodeStation decodes one station's result from the API, making do with what it gets:
+// numbers and booleans where strings belong are taken as their text, NaN and infinite
+// values are blanked, missing or mistyped parts are left empty. diagnostics say what it
+// made do with, and the station being down. err is for a result that is no JSON object,
+// or that the API sent as an error, in which case info has the handle, if the result did.
+// It never panics, whatever it is given.
+func DecodeStation(result []byte) (info WeatherInfo, notes []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			info, notes, err = WeatherInfo{}, nil, fmt.Errorf("cannot decode the result: %v", r)
+		}
+	}()
+
+	var raw interface{}
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.UseNumber()
+	if err = decoder.Decode(&raw); err != nil {
+		return info, nil, err
+	}
+	root, ok := raw.(map[string]interface{})
+	if !ok {
+		return info, nil, errors.New("the result is not a JSON object")
+	}
+
+	var d diagnostics
+	station := d.object(root, "station")
+	info.WeatherStation = StationInfo{
+		Name:           d.text(station, "name"),
+		Handle:         d.text(station, "handle"),
+		Longitude:      d.finite(station, "lon"),
+		Latitude:       d.finite(station, "lat"),
+		FacebookID:     d.text(station, "facebook"),
+		TwitterID:      d.text(station, "twitter"),
+		WundergroundID: d.text(station, "wunderground"),
+	}
+	if apiError := d.text(root, "error"); apiError != "" {
+		return info, nil, errors.New(apiError)
+	}
+	if domain := d.object(station, "domain"); domain != nil {
+		info.WeatherStation.Domain = DomainInfo{Name: d.text(domain, "name"), Handle: d.text(domain, "handle")}
+	}
+	if _, ok := station["cameras"]; ok {
+		for _, element := range d.array(station, "cameras") {
+			camera, ok := element.(map[string]interface{})
+			if !ok {
+				d.add("a camera was not an object")
+				continue
+			}
+			info.WeatherStation.Cameras = append(info.WeatherStation.Cameras, CameraInfo{ImageURL: d.text(camera, "image"), Name: d.text(camera, "name")})
+		}
+	}
+
+	record := d.object(root, "record")
+	info.WeatherRecord = RecordInfo{
+		LastRainTime:      d.text(record, "last_rain_time"),
+		ReadingsTimestamp: d.text(record, "time"),
+		RecordID:          d.text(record, "id"),
+		RecordTimestamp:   d.text(record, "now"),
+		StationDown:       d.text(record, "down_since"),
+	}
+	if derived, ok := record["derived"]; ok && derived != nil {
+		text := fmt.Sprint(derived)
+		if number, err := strconv.ParseUint(text, 10, 8); err == nil {
+			info.WeatherRecord.RecordDataDerived = uint8(number)
+		} else {
+			d.add("derived was %q", text)
+		}
+	}
+	if hilo, ok := record["hilo"].(map[string]interface{}); ok {
+		info.WeatherRecord.RecordHiLo = HiloInfo{
+			Name:             d.text(hilo, "name"),
+			Minimum:          d.finite(hilo, "min"),
+			Maximum:          d.finite(hilo, "max"),
+			MinimumTimestamp: d.text(hilo, "min_time"),
+			Symbol:           d.text(hilo, "symbol"),
+			MaximumTime:      d.text(hilo, "max_time"),
+			Property:         d.text(hilo, "property"),
+			Type:             d.text(hilo, "type"),
+			Unit:             d.text(hilo, "unit"),
+		}
+	}
+	if record != nil {
+		for _, element := range d.array(record, "readings") {
+			reading, ok := element.(map[string]interface{})
+			if !ok {
+				d.add("a reading was not an object")
+				continue
+			}
+			info.WeatherRecord.RecordReadings = append(info.WeatherRecord.RecordReadings, ReadingInfo{
+				ID:            d.text(reading, "id"),
+				Sensor:        d.text(reading, "sensor"),
+				SensorType:    d.text(reading, "sensor_type"),
+				TransmitterID: d.text(reading, "transmitter"),
+				Unit:          d.text(reading, "unit"),
+				UnitSymbol:    d.text(reading, "unit_symbol"),
+				Value:         d.finite(reading, "value"),
+			})
+		}
+	}
+	if info.WeatherRecord.StationDown != "" {
+		d.add("down since %s", info.WeatherRecord.StationDown)
+	}
+	return info, d.list(), nil
+}
@@ -0,0 +1,32 @@
+//go:build gofuzz
+// +build gofuzz
+
+package weatherapi
+
+// Fuzz is the go-fuzz target for the whole response, ala
+//
+//	go-fuzz-build ./weatherapi && go-fuzz -bin weatherapi-fuzz.zip -workdir fuzz
+//
+// Recorded API responses, ala the mock's fixtures, make a good corpus.
+func Fuzz(data []byte) int {
+	results, err := Split(data)
+	if err != nil {
+		return 0
+	}
+	for _, result := range results {
+		DecodeStation(result)
+	}
+	return 1
+}
+
+// FuzzStation is the go-fuzz target for one station's result, with -func FuzzStation
+func FuzzStation(data []byte) int {
+	info, _, err := DecodeStation(data)
+	if err != nil {
+		return 0
+	}
+	if info.WeatherStation.Handle == "" {
+		return 0
+	}
+	return 1
+}
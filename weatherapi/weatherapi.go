@@ -0,0 +1,86 @@
+// Package weatherapi is the WeatherSTEM API's raw results, and their decoding. The API
+// has its hiccups, numbers where strings belong, NaNs, fields gone missing, so results
+// are decoded a station at a time by DecodeStation, which makes do with what it gets,
+// says what it had to put up with, and never panics. Stream hands the results on as they
+// arrive.
+//
+//	err := weatherapi.Stream(response.Body, func(result json.RawMessage) error {
+//		info, diagnostics, err := weatherapi.DecodeStation(result)
+//		...
+//	})
+package weatherapi
+
+// WeatherInfo struct
+// This is the primary structure for weather data which includes the recording station
+// info as well as the data series
+type WeatherInfo struct {
+	WeatherRecord  RecordInfo  `json:"record"`
+	WeatherStation StationInfo `json:"station"`
+}
+
+// RecordInfo struct
+// Currently (June 2020), weatherSTEM has a formatting problem on the output of the JSON
+// when a station is "down" -- all numeric scalars become numbers instead of the usual
+// string. That used to kill the unmarshalling; DecodeStation takes them as their text.
+type RecordInfo struct {
+	RecordReadings    []ReadingInfo `json:"readings"`
+	LastRainTime      string        `json:"last_rain_time"`
+	ReadingsTimestamp string        `json:"time"`
+	RecordID          string        `json:"id"`
+	RecordHiLo        HiloInfo      `json:"hilo"`
+	RecordTimestamp   string        `json:"now"`
+	RecordDataDerived uint8         `json:"derived"`
+	StationDown       string        `json:"down_since,omitempty"`
+}
+
+// StationInfo struct
+// This is the basic info about the site which recorded the weather data
+type StationInfo struct {
+	Domain         DomainInfo   `json:"domain"`
+	Cameras        []CameraInfo `json:"cameras"`
+	Name           string       `json:"name"`
+	Handle         string       `json:"handle"`
+	Longitude      string       `json:"lon"`
+	Latitude       string       `json:"lat"`
+	FacebookID     string       `json:"facebook"`
+	TwitterID      string       `json:"twitter"`
+	WundergroundID string       `json:"wunderground"`
+}
+
+// ReadingInfo struct describes each measurement
+type ReadingInfo struct {
+	ID            string `json:"id"`
+	Sensor        string `json:"sensor"`
+	SensorType    string `json:"sensor_type"`
+	TransmitterID string `json:"transmitter"`
+	Unit          string `json:"unit"`
+	UnitSymbol    string `json:"unit_symbol"`
+	Value         string `json:"value"`
+}
+
+// HiloInfo This is at least what comes back with Temp info
+// and describes the station's maximum/minimum readings over the latest
+// time window, usually 24 hours
+type HiloInfo struct {
+	Name             string `json:"name"`
+	Minimum          string `json:"min"`
+	Maximum          string `json:"max"`
+	MinimumTimestamp string `json:"min_time"`
+	Symbol           string `json:"symbol"`
+	MaximumTime      string `json:"max_time"`
+	Property         string `json:"property"`
+	Type             string `json:"type"`
+	Unit             string `json:"unit"`
+}
+
+// DomainInfo struct is basically the alias for the individual WeatherSTEM stations
+type DomainInfo struct {
+	Name   string `json:"name"`
+	Handle string `json:"handle"`
+}
+
+// CameraInfo struct describes pointers to recent images from the station camera
+type CameraInfo struct {
+	ImageURL string `json:"image"`
+	Name     string `json:"name"`
+}
@@ -0,0 +1,133 @@
+// Package weatherjson is the cooked JSON of weatherstem-cli, "weatherstem -json", for Go
+// programs that read it. Every data and units object says its Schema, and the fields here
+// keep their names and meaning for as long as it does not change.
+//
+//	var report weatherjson.Report
+//	err := json.Unmarshal(line, &report)
+//
+// The arrays line up as in the README: stations is handle, name and time; temp is
+// temperature, dewpoint, wet bulb globe, wind chill and heat index; windspeed is speed,
+// gust and direction; wind is the short and long heading; rain is gauge and rate; sun is
+// solar and UV.
+package weatherjson
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schema is the version of the cooked JSON. It changes only when a field here does.
+const Schema = "weatherstem-cli/v1"
+
+// Data is a station's cooked readings
+type Data struct {
+	Label         string               `json:"label"`
+	Schema        string               `json:"schema"`
+	Stations      [3]string            `json:"stations"`
+	Topo          Topo                 `json:"topo"`
+	Distance      float64              `json:"distance"`
+	Bearing       float64              `json:"bearing"`
+	Temp          [5]float64           `json:"temp"`
+	Humidity      float64              `json:"humidity"`
+	Windspeed     [3]float64           `json:"windspeed"`
+	Wind          [2]string            `json:"wind"`
+	Pressure      float64              `json:"pressure"`
+	PressureTrend string               `json:"ptrend"`
+	Rain          [2]float64           `json:"rain"`
+	Sun           [2]float64           `json:"sun"`
+	SoilDepth     []float64            `json:"soildepth,omitempty"`
+	SoilTemp      []float64            `json:"soiltemp,omitempty"`
+	SoilMoisture  []float64            `json:"soilmoisture,omitempty"`
+	Provenance    *Provenance          `json:"provenance,omitempty"`
+	Extra         map[string]ValueUnit `json:"extra,omitempty"`
+
+	// The sections the command line flags add, ala -frost or -tides, come as they are.
+	// They are not held to the Schema; decode the ones you use yourself.
+	PressureTendency json.RawMessage `json:"ptendency,omitempty"`
+	Moisture         json.RawMessage `json:"moisture,omitempty"`
+	RainTotals       json.RawMessage `json:"raintotals,omitempty"`
+	WindStats        json.RawMessage `json:"windstats,omitempty"`
+	Frost            json.RawMessage `json:"frost,omitempty"`
+	Fire             json.RawMessage `json:"fire,omitempty"`
+	Cold             json.RawMessage `json:"cold,omitempty"`
+	CloudBase        json.RawMessage `json:"cloudbase,omitempty"`
+	Drone            json.RawMessage `json:"drone,omitempty"`
+	Lightning        json.RawMessage `json:"lightning,omitempty"`
+	AirQuality       json.RawMessage `json:"airquality,omitempty"`
+	NWSAlerts        json.RawMessage `json:"nws_alerts,omitempty"`
+	Tides            json.RawMessage `json:"tides,omitempty"`
+	LeafWetness      json.RawMessage `json:"leafwetness,omitempty"`
+	Spray            json.RawMessage `json:"spray,omitempty"`
+	Upwind           json.RawMessage `json:"upwind,omitempty"`
+}
+
+// Topo is where a station is, in degrees
+type Topo struct {
+	Lat float64 `json:"Lat"`
+	Lon float64 `json:"Lon"`
+}
+
+// Units are the units of the Data values, field for field
+type Units struct {
+	Label         string     `json:"label"`
+	Schema        string     `json:"schema"`
+	Stations      [3]string  `json:"stations"`
+	Topo          UnitTopo   `json:"topo"`
+	Distance      string     `json:"distance"`
+	Bearing       string     `json:"bearing"`
+	Temp          [5]string  `json:"temp"`
+	Humidity      string     `json:"humidity"`
+	Windspeed     [3]string  `json:"windspeed"`
+	Wind          [2]string  `json:"wind"`
+	Pressure      string     `json:"pressure"`
+	PressureTrend string     `json:"ptrend"`
+	Rain          [2]string  `json:"rain"`
+	Sun           [2]string  `json:"sun"`
+	SoilDepth     []string   `json:"soildepth,omitempty"`
+	SoilTemp      []string   `json:"soiltemp,omitempty"`
+	SoilMoisture  []string   `json:"soilmoisture,omitempty"`
+	UCUM          *UnitCodes `json:"ucum,omitempty"`
+}
+
+// UnitTopo are the units of Topo
+type UnitTopo struct {
+	Lat string `json:"Lat"`
+	Lon string `json:"Lon"`
+}
+
+// UnitCodes are the UCUM codes of a station's units, in the same places as Units has
+// their symbols. A unit without a code is left empty.
+type UnitCodes struct {
+	Temperature  [5]string `json:"temp"`
+	Humidity     string    `json:"humidity"`
+	Windspeed    [3]string `json:"windspeed"`
+	Pressure     string    `json:"pressure"`
+	Rain         [2]string `json:"rain"`
+	Sun          [2]string `json:"sun"`
+	SoilDepth    []string  `json:"soildepth,omitempty"`
+	SoilTemp     []string  `json:"soiltemp,omitempty"`
+	SoilMoisture []string  `json:"soilmoisture,omitempty"`
+}
+
+// ValueUnit is a reading the station has no place for in Data, ala "UV-A", with its unit.
+// Text readings have their words in Text.
+type ValueUnit struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+	Text  string  `json:"text,omitempty"`
+}
+
+// Provenance says where a station's data came from and what was done to it on the way
+type Provenance struct {
+	Source          string    `json:"source"`
+	RecordID        string    `json:"record_id,omitempty"`
+	Fetched         time.Time `json:"fetched"`
+	Tool            string    `json:"tool"`
+	Transformations []string  `json:"transformations"`
+}
+
+// Report is a station's data with its units, one of the objects of -ndjson or -json-array
+type Report struct {
+	Data  Data  `json:"data"`
+	Units Units `json:"units"`
+}
@@ -0,0 +1,880 @@
+// gRPC interface of "weatherstem serve -grpc", mirroring WeatherData and WeatherUnits.
+// Regenerate weather.pb.go with protoc-gen-go v1.4:
+//   protoc --go_out=plugins=grpc,paths=source_relative:. weatherpb/weather.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.23.0
+// 	protoc        (unknown)
+// source: weatherpb/weather.proto
+
+package weatherpb
+
+import (
+	context "context"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+type Coord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lat float64 `protobuf:"fixed64,1,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon float64 `protobuf:"fixed64,2,opt,name=lon,proto3" json:"lon,omitempty"`
+}
+
+func (x *Coord) Reset() {
+	*x = Coord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Coord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Coord) ProtoMessage() {}
+
+func (x *Coord) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Coord.ProtoReflect.Descriptor instead.
+func (*Coord) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Coord) GetLat() float64 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *Coord) GetLon() float64 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+// WeatherData is a station's cooked readings. The repeated fields line up with the
+// JSON arrays: station is handle, name and time; temperature is temperature, dewpoint,
+// wet bulb globe, wind chill and heat index; windspeed is speed, gust and direction;
+// wind is the short and long heading; rain is gauge and rate; sun is solar and UV.
+type WeatherData struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Label         string    `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	Station       []string  `protobuf:"bytes,2,rep,name=station,proto3" json:"station,omitempty"`
+	Topo          *Coord    `protobuf:"bytes,3,opt,name=topo,proto3" json:"topo,omitempty"`
+	Distance      float64   `protobuf:"fixed64,4,opt,name=distance,proto3" json:"distance,omitempty"`
+	Temperature   []float64 `protobuf:"fixed64,5,rep,packed,name=temperature,proto3" json:"temperature,omitempty"`
+	Humidity      float64   `protobuf:"fixed64,6,opt,name=humidity,proto3" json:"humidity,omitempty"`
+	Windspeed     []float64 `protobuf:"fixed64,7,rep,packed,name=windspeed,proto3" json:"windspeed,omitempty"`
+	Wind          []string  `protobuf:"bytes,8,rep,name=wind,proto3" json:"wind,omitempty"`
+	Pressure      float64   `protobuf:"fixed64,9,opt,name=pressure,proto3" json:"pressure,omitempty"`
+	PressureTrend string    `protobuf:"bytes,10,opt,name=pressure_trend,json=pressureTrend,proto3" json:"pressure_trend,omitempty"`
+	Rain          []float64 `protobuf:"fixed64,11,rep,packed,name=rain,proto3" json:"rain,omitempty"`
+	Sun           []float64 `protobuf:"fixed64,12,rep,packed,name=sun,proto3" json:"sun,omitempty"`
+}
+
+func (x *WeatherData) Reset() {
+	*x = WeatherData{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WeatherData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherData) ProtoMessage() {}
+
+func (x *WeatherData) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherData.ProtoReflect.Descriptor instead.
+func (*WeatherData) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WeatherData) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *WeatherData) GetStation() []string {
+	if x != nil {
+		return x.Station
+	}
+	return nil
+}
+
+func (x *WeatherData) GetTopo() *Coord {
+	if x != nil {
+		return x.Topo
+	}
+	return nil
+}
+
+func (x *WeatherData) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+func (x *WeatherData) GetTemperature() []float64 {
+	if x != nil {
+		return x.Temperature
+	}
+	return nil
+}
+
+func (x *WeatherData) GetHumidity() float64 {
+	if x != nil {
+		return x.Humidity
+	}
+	return 0
+}
+
+func (x *WeatherData) GetWindspeed() []float64 {
+	if x != nil {
+		return x.Windspeed
+	}
+	return nil
+}
+
+func (x *WeatherData) GetWind() []string {
+	if x != nil {
+		return x.Wind
+	}
+	return nil
+}
+
+func (x *WeatherData) GetPressure() float64 {
+	if x != nil {
+		return x.Pressure
+	}
+	return 0
+}
+
+func (x *WeatherData) GetPressureTrend() string {
+	if x != nil {
+		return x.PressureTrend
+	}
+	return ""
+}
+
+func (x *WeatherData) GetRain() []float64 {
+	if x != nil {
+		return x.Rain
+	}
+	return nil
+}
+
+func (x *WeatherData) GetSun() []float64 {
+	if x != nil {
+		return x.Sun
+	}
+	return nil
+}
+
+// WeatherUnits are the units of the WeatherData values, field for field
+type WeatherUnits struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Label         string   `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	Station       []string `protobuf:"bytes,2,rep,name=station,proto3" json:"station,omitempty"`
+	Distance      string   `protobuf:"bytes,4,opt,name=distance,proto3" json:"distance,omitempty"`
+	Temperature   []string `protobuf:"bytes,5,rep,name=temperature,proto3" json:"temperature,omitempty"`
+	Humidity      string   `protobuf:"bytes,6,opt,name=humidity,proto3" json:"humidity,omitempty"`
+	Windspeed     []string `protobuf:"bytes,7,rep,name=windspeed,proto3" json:"windspeed,omitempty"`
+	Wind          []string `protobuf:"bytes,8,rep,name=wind,proto3" json:"wind,omitempty"`
+	Pressure      string   `protobuf:"bytes,9,opt,name=pressure,proto3" json:"pressure,omitempty"`
+	PressureTrend string   `protobuf:"bytes,10,opt,name=pressure_trend,json=pressureTrend,proto3" json:"pressure_trend,omitempty"`
+	Rain          []string `protobuf:"bytes,11,rep,name=rain,proto3" json:"rain,omitempty"`
+	Sun           []string `protobuf:"bytes,12,rep,name=sun,proto3" json:"sun,omitempty"`
+}
+
+func (x *WeatherUnits) Reset() {
+	*x = WeatherUnits{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WeatherUnits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherUnits) ProtoMessage() {}
+
+func (x *WeatherUnits) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherUnits.ProtoReflect.Descriptor instead.
+func (*WeatherUnits) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WeatherUnits) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *WeatherUnits) GetStation() []string {
+	if x != nil {
+		return x.Station
+	}
+	return nil
+}
+
+func (x *WeatherUnits) GetDistance() string {
+	if x != nil {
+		return x.Distance
+	}
+	return ""
+}
+
+func (x *WeatherUnits) GetTemperature() []string {
+	if x != nil {
+		return x.Temperature
+	}
+	return nil
+}
+
+func (x *WeatherUnits) GetHumidity() string {
+	if x != nil {
+		return x.Humidity
+	}
+	return ""
+}
+
+func (x *WeatherUnits) GetWindspeed() []string {
+	if x != nil {
+		return x.Windspeed
+	}
+	return nil
+}
+
+func (x *WeatherUnits) GetWind() []string {
+	if x != nil {
+		return x.Wind
+	}
+	return nil
+}
+
+func (x *WeatherUnits) GetPressure() string {
+	if x != nil {
+		return x.Pressure
+	}
+	return ""
+}
+
+func (x *WeatherUnits) GetPressureTrend() string {
+	if x != nil {
+		return x.PressureTrend
+	}
+	return ""
+}
+
+func (x *WeatherUnits) GetRain() []string {
+	if x != nil {
+		return x.Rain
+	}
+	return nil
+}
+
+func (x *WeatherUnits) GetSun() []string {
+	if x != nil {
+		return x.Sun
+	}
+	return nil
+}
+
+type StationReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data  *WeatherData  `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Units *WeatherUnits `protobuf:"bytes,2,opt,name=units,proto3" json:"units,omitempty"`
+}
+
+func (x *StationReport) Reset() {
+	*x = StationReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StationReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StationReport) ProtoMessage() {}
+
+func (x *StationReport) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StationReport.ProtoReflect.Descriptor instead.
+func (*StationReport) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StationReport) GetData() *WeatherData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *StationReport) GetUnits() *WeatherUnits {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+// CurrentRequest picks stations by handle. None means all of them.
+type CurrentRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Stations []string `protobuf:"bytes,1,rep,name=stations,proto3" json:"stations,omitempty"`
+}
+
+func (x *CurrentRequest) Reset() {
+	*x = CurrentRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CurrentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CurrentRequest) ProtoMessage() {}
+
+func (x *CurrentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CurrentRequest.ProtoReflect.Descriptor instead.
+func (*CurrentRequest) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CurrentRequest) GetStations() []string {
+	if x != nil {
+		return x.Stations
+	}
+	return nil
+}
+
+type CurrentReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PolledUnix int64            `protobuf:"varint,1,opt,name=polled_unix,json=polledUnix,proto3" json:"polled_unix,omitempty"`
+	Stations   []*StationReport `protobuf:"bytes,2,rep,name=stations,proto3" json:"stations,omitempty"`
+}
+
+func (x *CurrentReply) Reset() {
+	*x = CurrentReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_weatherpb_weather_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CurrentReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CurrentReply) ProtoMessage() {}
+
+func (x *CurrentReply) ProtoReflect() protoreflect.Message {
+	mi := &file_weatherpb_weather_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CurrentReply.ProtoReflect.Descriptor instead.
+func (*CurrentReply) Descriptor() ([]byte, []int) {
+	return file_weatherpb_weather_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CurrentReply) GetPolledUnix() int64 {
+	if x != nil {
+		return x.PolledUnix
+	}
+	return 0
+}
+
+func (x *CurrentReply) GetStations() []*StationReport {
+	if x != nil {
+		return x.Stations
+	}
+	return nil
+}
+
+var File_weatherpb_weather_proto protoreflect.FileDescriptor
+
+var file_weatherpb_weather_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x70, 0x62, 0x2f, 0x77, 0x65, 0x61, 0x74,
+	0x68, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0b, 0x77, 0x65, 0x61, 0x74, 0x68,
+	0x65, 0x72, 0x73, 0x74, 0x65, 0x6d, 0x22, 0x2b, 0x0a, 0x05, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x12,
+	0x10, 0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6c, 0x61,
+	0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03,
+	0x6c, 0x6f, 0x6e, 0x22, 0xda, 0x02, 0x0a, 0x0b, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x44,
+	0x61, 0x74, 0x61, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x74, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x73, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x26, 0x0a, 0x04, 0x74, 0x6f, 0x70, 0x6f, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x12, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x73, 0x74, 0x65, 0x6d, 0x2e,
+	0x43, 0x6f, 0x6f, 0x72, 0x64, 0x52, 0x04, 0x74, 0x6f, 0x70, 0x6f, 0x12, 0x1a, 0x0a, 0x08, 0x64,
+	0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x64,
+	0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x74, 0x65, 0x6d, 0x70, 0x65,
+	0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x05, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0b, 0x74, 0x65,
+	0x6d, 0x70, 0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x75, 0x6d,
+	0x69, 0x64, 0x69, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x68, 0x75, 0x6d,
+	0x69, 0x64, 0x69, 0x74, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x77, 0x69, 0x6e, 0x64, 0x73, 0x70, 0x65,
+	0x65, 0x64, 0x18, 0x07, 0x20, 0x03, 0x28, 0x01, 0x52, 0x09, 0x77, 0x69, 0x6e, 0x64, 0x73, 0x70,
+	0x65, 0x65, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x77, 0x69, 0x6e, 0x64, 0x18, 0x08, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x04, 0x77, 0x69, 0x6e, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x75, 0x72, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x75, 0x72, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72, 0x65, 0x73, 0x73, 0x75, 0x72, 0x65, 0x5f,
+	0x74, 0x72, 0x65, 0x6e, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x70, 0x72, 0x65,
+	0x73, 0x73, 0x75, 0x72, 0x65, 0x54, 0x72, 0x65, 0x6e, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x61,
+	0x69, 0x6e, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x01, 0x52, 0x04, 0x72, 0x61, 0x69, 0x6e, 0x12, 0x10,
+	0x0a, 0x03, 0x73, 0x75, 0x6e, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x01, 0x52, 0x03, 0x73, 0x75, 0x6e,
+	0x22, 0xb3, 0x02, 0x0a, 0x0c, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x55, 0x6e, 0x69, 0x74,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x74, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x20, 0x0a,
+	0x0b, 0x74, 0x65, 0x6d, 0x70, 0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x05, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x0b, 0x74, 0x65, 0x6d, 0x70, 0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x68, 0x75, 0x6d, 0x69, 0x64, 0x69, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x68, 0x75, 0x6d, 0x69, 0x64, 0x69, 0x74, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x77,
+	0x69, 0x6e, 0x64, 0x73, 0x70, 0x65, 0x65, 0x64, 0x18, 0x07, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09,
+	0x77, 0x69, 0x6e, 0x64, 0x73, 0x70, 0x65, 0x65, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x77, 0x69, 0x6e,
+	0x64, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x77, 0x69, 0x6e, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x70, 0x72, 0x65, 0x73, 0x73, 0x75, 0x72, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x70, 0x72, 0x65, 0x73, 0x73, 0x75, 0x72, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72, 0x65,
+	0x73, 0x73, 0x75, 0x72, 0x65, 0x5f, 0x74, 0x72, 0x65, 0x6e, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x75, 0x72, 0x65, 0x54, 0x72, 0x65, 0x6e, 0x64,
+	0x12, 0x12, 0x0a, 0x04, 0x72, 0x61, 0x69, 0x6e, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04,
+	0x72, 0x61, 0x69, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x75, 0x6e, 0x18, 0x0c, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x03, 0x73, 0x75, 0x6e, 0x22, 0x6e, 0x0a, 0x0d, 0x53, 0x74, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x2c, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x73,
+	0x74, 0x65, 0x6d, 0x2e, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x2f, 0x0a, 0x05, 0x75, 0x6e, 0x69, 0x74, 0x73, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x73, 0x74,
+	0x65, 0x6d, 0x2e, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x55, 0x6e, 0x69, 0x74, 0x73, 0x52,
+	0x05, 0x75, 0x6e, 0x69, 0x74, 0x73, 0x22, 0x2c, 0x0a, 0x0e, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x22, 0x67, 0x0a, 0x0c, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x52,
+	0x65, 0x70, 0x6c, 0x79, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x6f, 0x6c, 0x6c, 0x65, 0x64, 0x5f, 0x75,
+	0x6e, 0x69, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x70, 0x6f, 0x6c, 0x6c, 0x65,
+	0x64, 0x55, 0x6e, 0x69, 0x78, 0x12, 0x36, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65,
+	0x72, 0x73, 0x74, 0x65, 0x6d, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70,
+	0x6f, 0x72, 0x74, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x32, 0x9a, 0x01,
+	0x0a, 0x07, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x12, 0x44, 0x0a, 0x0a, 0x47, 0x65, 0x74,
+	0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x12, 0x1b, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65,
+	0x72, 0x73, 0x74, 0x65, 0x6d, 0x2e, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x73, 0x74,
+	0x65, 0x6d, 0x2e, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12,
+	0x49, 0x0a, 0x0d, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x73,
+	0x12, 0x1b, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x73, 0x74, 0x65, 0x6d, 0x2e, 0x43,
+	0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e,
+	0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x73, 0x74, 0x65, 0x6d, 0x2e, 0x43, 0x75, 0x72, 0x72,
+	0x65, 0x6e, 0x74, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x30, 0x01, 0x42, 0x30, 0x5a, 0x2e, 0x67, 0x69,
+	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c, 0x6f, 0x72, 0x61, 0x78, 0x69, 0x70,
+	0x61, 0x6d, 0x2f, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x73, 0x74, 0x65, 0x6d, 0x2d, 0x63,
+	0x6c, 0x69, 0x2f, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_weatherpb_weather_proto_rawDescOnce sync.Once
+	file_weatherpb_weather_proto_rawDescData = file_weatherpb_weather_proto_rawDesc
+)
+
+func file_weatherpb_weather_proto_rawDescGZIP() []byte {
+	file_weatherpb_weather_proto_rawDescOnce.Do(func() {
+		file_weatherpb_weather_proto_rawDescData = protoimpl.X.CompressGZIP(file_weatherpb_weather_proto_rawDescData)
+	})
+	return file_weatherpb_weather_proto_rawDescData
+}
+
+var file_weatherpb_weather_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_weatherpb_weather_proto_goTypes = []interface{}{
+	(*Coord)(nil),          // 0: weatherstem.Coord
+	(*WeatherData)(nil),    // 1: weatherstem.WeatherData
+	(*WeatherUnits)(nil),   // 2: weatherstem.WeatherUnits
+	(*StationReport)(nil),  // 3: weatherstem.StationReport
+	(*CurrentRequest)(nil), // 4: weatherstem.CurrentRequest
+	(*CurrentReply)(nil),   // 5: weatherstem.CurrentReply
+}
+var file_weatherpb_weather_proto_depIdxs = []int32{
+	0, // 0: weatherstem.WeatherData.topo:type_name -> weatherstem.Coord
+	1, // 1: weatherstem.StationReport.data:type_name -> weatherstem.WeatherData
+	2, // 2: weatherstem.StationReport.units:type_name -> weatherstem.WeatherUnits
+	3, // 3: weatherstem.CurrentReply.stations:type_name -> weatherstem.StationReport
+	4, // 4: weatherstem.Weather.GetCurrent:input_type -> weatherstem.CurrentRequest
+	4, // 5: weatherstem.Weather.StreamUpdates:input_type -> weatherstem.CurrentRequest
+	5, // 6: weatherstem.Weather.GetCurrent:output_type -> weatherstem.CurrentReply
+	5, // 7: weatherstem.Weather.StreamUpdates:output_type -> weatherstem.CurrentReply
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_weatherpb_weather_proto_init() }
+func file_weatherpb_weather_proto_init() {
+	if File_weatherpb_weather_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_weatherpb_weather_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Coord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_weatherpb_weather_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WeatherData); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_weatherpb_weather_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WeatherUnits); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_weatherpb_weather_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StationReport); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_weatherpb_weather_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CurrentRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_weatherpb_weather_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CurrentReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_weatherpb_weather_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_weatherpb_weather_proto_goTypes,
+		DependencyIndexes: file_weatherpb_weather_proto_depIdxs,
+		MessageInfos:      file_weatherpb_weather_proto_msgTypes,
+	}.Build()
+	File_weatherpb_weather_proto = out.File
+	file_weatherpb_weather_proto_rawDesc = nil
+	file_weatherpb_weather_proto_goTypes = nil
+	file_weatherpb_weather_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// WeatherClient is the client API for Weather service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type WeatherClient interface {
+	// GetCurrent answers with the latest poll
+	GetCurrent(ctx context.Context, in *CurrentRequest, opts ...grpc.CallOption) (*CurrentReply, error)
+	// StreamUpdates sends the latest poll, then each new one as it comes in
+	StreamUpdates(ctx context.Context, in *CurrentRequest, opts ...grpc.CallOption) (Weather_StreamUpdatesClient, error)
+}
+
+type weatherClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWeatherClient(cc grpc.ClientConnInterface) WeatherClient {
+	return &weatherClient{cc}
+}
+
+func (c *weatherClient) GetCurrent(ctx context.Context, in *CurrentRequest, opts ...grpc.CallOption) (*CurrentReply, error) {
+	out := new(CurrentReply)
+	err := c.cc.Invoke(ctx, "/weatherstem.Weather/GetCurrent", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherClient) StreamUpdates(ctx context.Context, in *CurrentRequest, opts ...grpc.CallOption) (Weather_StreamUpdatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Weather_serviceDesc.Streams[0], "/weatherstem.Weather/StreamUpdates", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &weatherStreamUpdatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Weather_StreamUpdatesClient interface {
+	Recv() (*CurrentReply, error)
+	grpc.ClientStream
+}
+
+type weatherStreamUpdatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *weatherStreamUpdatesClient) Recv() (*CurrentReply, error) {
+	m := new(CurrentReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WeatherServer is the server API for Weather service.
+type WeatherServer interface {
+	// GetCurrent answers with the latest poll
+	GetCurrent(context.Context, *CurrentRequest) (*CurrentReply, error)
+	// StreamUpdates sends the latest poll, then each new one as it comes in
+	StreamUpdates(*CurrentRequest, Weather_StreamUpdatesServer) error
+}
+
+// UnimplementedWeatherServer can be embedded to have forward compatible implementations.
+type UnimplementedWeatherServer struct {
+}
+
+func (*UnimplementedWeatherServer) GetCurrent(context.Context, *CurrentRequest) (*CurrentReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCurrent not implemented")
+}
+func (*UnimplementedWeatherServer) StreamUpdates(*CurrentRequest, Weather_StreamUpdatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUpdates not implemented")
+}
+
+func RegisterWeatherServer(s *grpc.Server, srv WeatherServer) {
+	s.RegisterService(&_Weather_serviceDesc, srv)
+}
+
+func _Weather_GetCurrent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CurrentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServer).GetCurrent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/weatherstem.Weather/GetCurrent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServer).GetCurrent(ctx, req.(*CurrentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Weather_StreamUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CurrentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WeatherServer).StreamUpdates(m, &weatherStreamUpdatesServer{stream})
+}
+
+type Weather_StreamUpdatesServer interface {
+	Send(*CurrentReply) error
+	grpc.ServerStream
+}
+
+type weatherStreamUpdatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *weatherStreamUpdatesServer) Send(m *CurrentReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Weather_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "weatherstem.Weather",
+	HandlerType: (*WeatherServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCurrent",
+			Handler:    _Weather_GetCurrent_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUpdates",
+			Handler:       _Weather_StreamUpdates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "weatherpb/weather.proto",
+}
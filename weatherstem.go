@@ -5,22 +5,23 @@
 package main
 
 import (
-	"crypto/tls"
+	"errors"
 	"flag"
-	"html"
-	"log"
 	"strconv"
 
 	json "github.com/json-iterator/go"
 
-	"github.com/loraxipam/compassrose"
 	haversine "github.com/loraxipam/havers2"
+	"github.com/loraxipam/weatherstem-cli/weatherapi"
+	"github.com/loraxipam/weatherstem-cli/weatherjson"
 
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 const (
@@ -28,42 +29,16 @@ const (
 	configSettingsVersion = "3.0"
 )
 
-// WeatherInfo struct
-// This is the primary structure for weather data which includes the recording station
-// info as well as the data series
-type WeatherInfo struct {
-	WeatherRecord  RecordInfo  `json:"record"`
-	WeatherStation StationInfo `json:"station"`
-}
-
-// RecordInfo struct
-// Currently (June 2020), weatherSTEM has a formatting problem on the output of the JSON
-// when a station is "down" -- all numeric scalars become numbers instead of the usual
-// string. This kills the unmarshalling so expect errors once in a while.
-type RecordInfo struct {
-	RecordReadings    []ReadingInfo `json:"readings"`
-	LastRainTime      string        `json:"last_rain_time"`
-	ReadingsTimestamp string        `json:"time"`
-	RecordID          string        `json:"id"`
-	RecordHiLo        HiloInfo      `json:"hilo"`
-	RecordTimestamp   string        `json:"now"`
-	RecordDataDerived uint8         `json:"derived"`
-	StationDown       string        `json:"down_since,omitempty"`
-}
-
-// StationInfo struct
-// This is the basic info about the site which recorded the weather data
-type StationInfo struct {
-	Domain         DomainInfo   `json:"domain"`
-	Cameras        []CameraInfo `json:"cameras"`
-	Name           string       `json:"name"`
-	Handle         string       `json:"handle"`
-	Longitude      string       `json:"lon"`
-	Latitude       string       `json:"lat"`
-	FacebookID     string       `json:"facebook"`
-	TwitterID      string       `json:"twitter"`
-	WundergroundID string       `json:"wunderground"`
-}
+// The API's raw results, as weatherapi decodes them
+type (
+	WeatherInfo = weatherapi.WeatherInfo
+	RecordInfo  = weatherapi.RecordInfo
+	StationInfo = weatherapi.StationInfo
+	ReadingInfo = weatherapi.ReadingInfo
+	HiloInfo    = weatherapi.HiloInfo
+	DomainInfo  = weatherapi.DomainInfo
+	CameraInfo  = weatherapi.CameraInfo
+)
 
 // WeatherData scalars from API's strings are layed out thusly:
 // "sensor_type": "Thermometer",
@@ -86,76 +61,84 @@ type StationInfo struct {
 // ---------------------------
 // "sensor_type": "Solar Radiation Sensor",
 // "sensor_type": "UV Radiation Sensor"
+// ---------------------------
+// "sensor_type": "Soil Temperature", one per depth
+// "sensor_type": "Soil Moisture", one per depth
+// ---------------------------
+// "sensor_type": "Lightning Strike Count", "Lightning Distance", "Lightning Last Strike"
+// ---------------------------
+// "sensor_type": "PM2.5", "PM10", "Ozone"
+// ---------------------------
+// "sensor_type": "Leaf Wetness"
+// ---------------------------
+// Any other sensor_type goes in Extra, under its name, with its unit, unless the
+// config's "sensors" says where it goes, see sensorRegistry
+// ---------------------------
+// Its JSON is published for Go programs as weatherjson.Data. Change the two together,
+// and weatherjson.Schema when a field there changes.
 type WeatherData struct {
-	Label         string          `json:"label"`
-	Station       [3]string       `json:"stations"`
-	StationTopo   haversine.Coord `json:"topo"`
-	StationDist   float64         `json:"distance"`
-	Temperature   [5]float64      `json:"temp"`
-	Humidity      float64         `json:"humidity"`
-	Windspeed     [3]float64      `json:"windspeed"`
-	Wind          [2]string       `json:"wind"`
-	Pressure      float64         `json:"pressure"`
-	PressureTrend string          `json:"ptrend"`
-	Rain          [2]float64      `json:"rain"`
-	Sun           [2]float64      `json:"sun"`
+	Label            string               `json:"label"`
+	Schema           string               `json:"schema,omitempty"`
+	Station          [3]string            `json:"stations"`
+	StationTopo      haversine.Coord      `json:"topo"`
+	StationDist      float64              `json:"distance"`
+	StationBearing   float64              `json:"bearing"`
+	Temperature      [5]float64           `json:"temp"`
+	Humidity         float64              `json:"humidity"`
+	Windspeed        [3]float64           `json:"windspeed"`
+	Wind             [2]string            `json:"wind"`
+	Pressure         float64              `json:"pressure"`
+	PressureTrend    string               `json:"ptrend"`
+	Rain             [2]float64           `json:"rain"`
+	Sun              [2]float64           `json:"sun"`
+	PressureTendency *PressureTendency    `json:"ptendency,omitempty"`
+	Moisture         *MoistureTendency    `json:"moisture,omitempty"`
+	RainTotals       *RainAccumulation    `json:"raintotals,omitempty"`
+	WindStats        *WindStatistics      `json:"windstats,omitempty"`
+	Frost            *FrostRisk           `json:"frost,omitempty"`
+	Fire             *FireWeather         `json:"fire,omitempty"`
+	Cold             *ColdStress          `json:"cold,omitempty"`
+	CloudBase        *CloudBase           `json:"cloudbase,omitempty"`
+	Drone            *DroneVerdict        `json:"drone,omitempty"`
+	Provenance       *Provenance          `json:"provenance,omitempty"`
+	Lightning        *LightningActivity   `json:"lightning,omitempty"`
+	AirQuality       *AirQuality          `json:"airquality,omitempty"`
+	NWSAlerts        []NWSAlert           `json:"nws_alerts,omitempty"`
+	Tides            *Tides               `json:"tides,omitempty"`
+	LeafWetness      *ValueUnit           `json:"leafwetness,omitempty"`
+	Spray            *SprayConditions     `json:"spray,omitempty"`
+	Upwind           *Upwind              `json:"upwind,omitempty"`
+	SoilDepth        []float64            `json:"soildepth,omitempty"`
+	SoilTemp         []float64            `json:"soiltemp,omitempty"`
+	SoilMoisture     []float64            `json:"soilmoisture,omitempty"`
+	Extra            map[string]ValueUnit `json:"extra,omitempty"`
+	sparks           *stationSparks       // -spark trends, for the text output only
+	chart            []byte               // PNG of the history, for the reports to embed
 }
 
 // WeatherUnits are the corresponding measurement units for WeatherData values
 type WeatherUnits struct {
 	Label       string    `json:"label"`
+	Schema      string    `json:"schema,omitempty"`
 	Station     [3]string `json:"stations"`
 	StationTopo struct {
 		Lat string `json:"Lat"`
 		Lon string `json:"Lon"`
 	} `json:"topo"`
-	StationDist   string    `json:"distance"`
-	Temperature   [5]string `json:"temp"`
-	Humidity      string    `json:"humidity"`
-	Windspeed     [3]string `json:"windspeed"`
-	Wind          [2]string `json:"wind"`
-	Pressure      string    `json:"pressure"`
-	PressureTrend string    `json:"ptrend"`
-	Rain          [2]string `json:"rain"`
-	Sun           [2]string `json:"sun"`
-}
-
-// ReadingInfo struct describes each measurement
-type ReadingInfo struct {
-	ID            string `json:"id"`
-	Sensor        string `json:"sensor"`
-	SensorType    string `json:"sensor_type"`
-	TransmitterID string `json:"transmitter"`
-	Unit          string `json:"unit"`
-	UnitSymbol    string `json:"unit_symbol"`
-	Value         string `json:"value"`
-}
-
-// HiloInfo This is at least what comes back with Temp info
-// and describes the station's maximum/minimum readings over the latest
-// time window, usually 24 hours
-type HiloInfo struct {
-	Name             string `json:"name"`
-	Minimum          string `json:"min"`
-	Maximum          string `json:"max"`
-	MinimumTimestamp string `json:"min_time"`
-	Symbol           string `json:"symbol"`
-	MaximumTime      string `json:"max_time"`
-	Property         string `json:"property"`
-	Type             string `json:"type"`
-	Unit             string `json:"unit"`
-}
-
-// DomainInfo struct is basically the alias for the individual WeatherSTEM stations
-type DomainInfo struct {
-	Name   string `json:"name"`
-	Handle string `json:"handle"`
-}
-
-// CameraInfo struct describes pointers to recent images from the station camera
-type CameraInfo struct {
-	ImageURL string `json:"image"`
-	Name     string `json:"name"`
+	StationDist    string     `json:"distance"`
+	StationBearing string     `json:"bearing"`
+	Temperature    [5]string  `json:"temp"`
+	Humidity       string     `json:"humidity"`
+	Windspeed      [3]string  `json:"windspeed"`
+	Wind           [2]string  `json:"wind"`
+	Pressure       string     `json:"pressure"`
+	PressureTrend  string     `json:"ptrend"`
+	Rain           [2]string  `json:"rain"`
+	Sun            [2]string  `json:"sun"`
+	SoilDepth      []string   `json:"soildepth,omitempty"`
+	SoilTemp       []string   `json:"soiltemp,omitempty"`
+	SoilMoisture   []string   `json:"soilmoisture,omitempty"`
+	UCUM           *UnitCodes `json:"ucum,omitempty"`
 }
 
 // weatherSTEM API user config settings, ala:
@@ -164,21 +147,60 @@ type CameraInfo struct {
 // "api_key": "happy3solar9fly",
 // "stations": ["ponceinlet","fswndaytonabch"]
 // "me": {"lat":29.13,"lon":-80.95}
+// "history": {"file": "~/.weatherstem-history.jsonl", "keep": "90d", "downsample": "5m->1h after 7d"}
+// "serve": {"tokens": [{"token": "s3cret", "stations": ["ponceinlet"]}]}
+// "smtp": {"host": "smtp.example.com", "from": "weather@example.com"}
 // }
 // See weatherstem API page for details.
 // This is version 2. -- Added "Me"
 type configSettings struct {
-	Version  string          `json:"version"`
-	URL      string          `json:"api_url"`
-	Key      string          `json:"api_key"`
-	Stations []string        `json:"stations"`
-	Me       haversine.Coord `json:"me,omitempty"`
+	Version         string                  `json:"version"`                       // config format, "2.0"
+	URL             string                  `json:"api_url"`                       // the API, ala https://<domain>/api
+	Key             string                  `json:"api_key"`                       // your API key
+	Stations        []string                `json:"stations"`                      // station handles to fetch
+	Aliases         map[string]string       `json:"aliases,omitempty"`             // friendlier names for handles, see applyAliases
+	LocateURL       string                  `json:"locate_url,omitempty"`          // where -locate ip looks, see settleMe
+	GeocodeURL      string                  `json:"geocode_url,omitempty"`         // the Nominatim to find Me's address, see geocode
+	GPSD            string                  `json:"gpsd,omitempty"`                // where -locate gpsd looks
+	Endpoints       []apiEndpoint           `json:"endpoints,omitempty"`           // more APIs for stations on other domains
+	Groups          map[string]stationGroup `json:"groups,omitempty"`              // named sets of stations, see selectGroup
+	Me              meSettings              `json:"me,omitempty"`                  // where you are, for distances
+	History         historySettings         `json:"history,omitempty"`             // optional, every run appends there, see historySettings
+	HistoryStore    string                  `json:"history_store,omitempty"`       // deprecated History.Store, see takeOldStore
+	StatusbarPolicy string                  `json:"statusbar_policy,omitempty"`    // which station -statusbar shows, see pickStatusbarStation
+	Serve           serveSettings           `json:"serve,omitempty"`               // optional, see serveSettings
+	SMTP            smtpSettings            `json:"smtp,omitempty"`                // for -email
+	Sinks           []sinkSetting           `json:"sinks,omitempty"`               // get the data after every run, see outputSink
+	GraphitePrefix  string                  `json:"graphite_prefix,omitempty"`     // starts the graphite metric names
+	OTLPHeaders     map[string]string       `json:"otlp_headers,omitempty"`        // go with every otlp request
+	Cache           string                  `json:"cache,omitempty"`               // the last good API response, see fetchWeatherInfo
+	StationCache    string                  `json:"station_cache,omitempty"`       // the stations, see updateStationCache
+	QuotaPerHour    int                     `json:"quota_per_hour,omitempty"`      // caps the API calls, see takeQuota
+	Proxy           string                  `json:"proxy,omitempty"`               // for the API calls, see apiProxy
+	LightningRadius float64                 `json:"lightning_radius_km,omitempty"` // strikes closer than this alert
+	Sensors         map[string]string       `json:"sensors,omitempty"`             // more sensor types, see sensorRegistry
+	Rose            compassSettings         `json:"rose,omitempty"`                // wind direction names
+	State           string                  `json:"state,omitempty"`               // where -diff keeps the last run
+	DiffThresholds  map[string]float64      `json:"diff_thresholds,omitempty"`     // how far each metric moves to count for -diff
+	NWSURL          string                  `json:"nws_url,omitempty"`             // for -nws-alerts
+	ForecastURL     string                  `json:"forecast_url,omitempty"`        // for -forecast
+	METARURL        string                  `json:"metar_url,omitempty"`           // for -compare-metar
+	TidesURL        string                  `json:"tides_url,omitempty"`           // for -tides
+	TideDistance    float64                 `json:"tide_distance_km,omitempty"`    // farthest a tide station can be for -tides
+	HeatGuidance    map[string]HeatGuidance `json:"heat_guidance,omitempty"`       // work/rest and hydration by flag level, for -heat-report
+	ColdLevels      coldLevels              `json:"cold_levels,omitempty"`         // wind chills for -cold
+	Drone           droneLimits             `json:"drone,omitempty"`               // the airframe's limits for -drone
+	Coastline       map[string]float64      `json:"coastline,omitempty"`           // bearing out to sea by handle, for -marine
+	Lang            string                  `json:"lang,omitempty"`                // output language, see setLanguage
+	Units           string                  `json:"units,omitempty"`               // unit system when -units does not say
+	Precision       map[string]int          `json:"precision,omitempty"`           // decimals of each quantity
+	tables          settledTables                                                  // settled from Units and Precision, see settledTables
 }
 
-// PopulateWeatherData accepts the raw result and it returns the converted structured data
-// This is a rather static implementation. Bigger brains do this better.
-func PopulateWeatherData(winfo *WeatherInfo, rose bool) (wdata WeatherData, wunits WeatherUnits) {
-	wdata.Label = "data"
+// PopulateWeatherData accepts the raw result and it returns the converted structured data.
+// Which reading goes where is up to the sensor registry, see sensorTargetFor.
+func PopulateWeatherData(winfo *WeatherInfo) (wdata WeatherData, wunits WeatherUnits) {
+	wdata.Label, wdata.Schema = "data", weatherjson.Schema
 	wdata.Station[0] = winfo.WeatherStation.Handle
 	wdata.Station[1] = winfo.WeatherStation.Name
 	wdata.Station[2] = winfo.WeatherRecord.ReadingsTimestamp
@@ -186,83 +208,63 @@ func PopulateWeatherData(winfo *WeatherInfo, rose bool) (wdata WeatherData, wuni
 	wdata.StationTopo.Lon, _ = strconv.ParseFloat(winfo.WeatherStation.Longitude, 64)
 	wdata.StationTopo.Calc()
 	wdata.StationDist = 2.4
-	wunits.Label = "units"
+	wunits.Label, wunits.Schema = "units", weatherjson.Schema
 	wunits.Station[0] = winfo.WeatherStation.Handle
 	wunits.Station[1] = winfo.WeatherStation.Name
 	wunits.Station[2] = winfo.WeatherRecord.ReadingsTimestamp
 	wunits.StationTopo.Lat = "&deg;"
 	wunits.StationTopo.Lon = "&deg;"
-	// now loop through the readings and do the conversions
+	wunits.StationBearing = "&deg;"
+	// now loop through the readings and file each where the registry says
 	for _, val := range winfo.WeatherRecord.RecordReadings {
-		if val.SensorType == "Thermometer" { // Temps
-			wdata.Temperature[0], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Temperature[0] = val.UnitSymbol
-		} else if val.SensorType == "Dewpoint" {
-			wdata.Temperature[1], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Temperature[1] = val.UnitSymbol
-		} else if val.SensorType == "Wet Bulb Globe Temperature" {
-			wdata.Temperature[2], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Temperature[2] = val.UnitSymbol
-		} else if val.SensorType == "Wind Chill" {
-			wdata.Temperature[3], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Temperature[3] = val.UnitSymbol
-		} else if val.SensorType == "Heat Index" {
-			wdata.Temperature[4], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Temperature[4] = val.UnitSymbol
-		} else if val.SensorType == "Hygrometer" { // Humidity
-			wdata.Humidity, _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Humidity = val.UnitSymbol
-		} else if val.SensorType == "Anemometer" { // Wind
-			wdata.Windspeed[0], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Windspeed[0] = val.UnitSymbol
-		} else if val.SensorType == "10 Minute Wind Gust" {
-			wdata.Windspeed[1], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Windspeed[1] = val.UnitSymbol
-		} else if val.SensorType == "Wind Vane" {
-			wdata.Windspeed[2], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Windspeed[2] = val.UnitSymbol
-			if rose {
-				wdata.Wind[0], wdata.Wind[1] = compassrose.DegreeToHeading(float32(wdata.Windspeed[2]), 3, true)
-			} else {
-				wdata.Wind[0], wdata.Wind[1] = compassrose.DegreeToHeading(float32(wdata.Windspeed[2]), 3, false)
-			}
-		} else if val.SensorType == "Barometer" { // Pressure
-			wdata.Pressure, _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Pressure = val.UnitSymbol
-		} else if val.SensorType == "Barometer Tendency" {
-			wdata.PressureTrend = val.Value
-			wunits.PressureTrend = val.UnitSymbol
-		} else if val.SensorType == "Rain Gauge" { // Rain
-			wdata.Rain[0], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Rain[0] = val.UnitSymbol
-		} else if val.SensorType == "Rain Rate" {
-			wdata.Rain[1], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Rain[1] = val.UnitSymbol
-		} else if val.SensorType == "Solar Radiation Sensor" { // Sun
-			wdata.Sun[0], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Sun[0] = val.UnitSymbol
-		} else if val.SensorType == "UV Radiation Sensor" {
-			wdata.Sun[1], _ = strconv.ParseFloat(val.Value, 64)
-			wunits.Sun[1] = val.UnitSymbol
-		} // else ignore the unknown
+		sensorTargets[sensorTargetFor(val.SensorType)](&wdata, &wunits, &val)
+	}
+	// and straight away get the units off HTML entities, unless -raw-units wants them
+	if !rawUnits {
+		wunits.normalize()
 	}
 
 	return wdata, wunits
 }
 
-// get config settings from the usual suspect files. Look in current directory, HOME or .config
-func findConfigSettings(config *configSettings) (err error) {
-	var usualFiles [3]string
+// cookWeatherInfo converts every station's raw result and works out how far away it is from me,
+// in the unit system's distances, and which way.
+func cookWeatherInfo(weatherArr []WeatherInfo, me haversine.Coord, system string) (dataArr []WeatherData, unitArr []WeatherUnits) {
+	dataArr = make([]WeatherData, len(weatherArr))
+	unitArr = make([]WeatherUnits, len(weatherArr))
+	distanceUnit := "NM"
+	if system != "" {
+		distanceUnit = unitSystems[system].distance
+	}
+	for idx, stationData := range weatherArr {
+		dataArr[idx], unitArr[idx] = PopulateWeatherData(&stationData)
+		dataArr[idx].StationDist = measureDistance(distanceUnit, me, dataArr[idx].StationTopo)
+		unitArr[idx].StationDist = distanceUnit
+		dataArr[idx].StationBearing = initialBearing(me, dataArr[idx].StationTopo)
+	}
+
+	return dataArr, unitArr
+}
+
+// configFiles are the usual suspect files, in the order they are tried: current directory,
+// HOME or .config
+func configFiles() []string {
 	if home, exists := os.LookupEnv("HOME"); exists {
-		usualFiles[0] = "weatherstem.json"
-		usualFiles[1] = home + "/.weatherstem.json"
-		usualFiles[2] = home + "/.config/weatherstem.json"
-	} else {
-		usualFiles[0] = "weatherstem.json"
+		return []string{"weatherstem.json", home + "/.weatherstem.json", home + "/.config/weatherstem.json"}
 	}
-	for _, c := range usualFiles {
+	return []string{"weatherstem.json"}
+}
+
+// get config settings from the usual suspect files
+func findConfigSettings(config *configSettings) (err error) {
+	for _, c := range configFiles() {
 		err = config.getConfigSettings(c)
 		if err == nil {
+			logDebug("Using config", c)
+			return err
+		}
+		// A broken config is worth hearing about, not skipping
+		if !os.IsNotExist(err) {
 			return err
 		}
 	}
@@ -281,7 +283,7 @@ func (config *configSettings) getConfigSettings(inputFile string) (err error) {
 
 	configJSON, err := ioutil.ReadAll(readFile)
 	if err != nil {
-		log.Panicln("Cannot read config", inputFile)
+		return fmt.Errorf("Cannot read config %s: %w", inputFile, err)
 	}
 
 	var configVersion string
@@ -292,33 +294,34 @@ func (config *configSettings) getConfigSettings(inputFile string) (err error) {
 		v1 = strings.Split(v1[1], `"`)
 		configVersion = v1[1]
 	} else {
-		log.Panicln("No version in config file.", inputFile)
+		return fmt.Errorf("No version in config file %s", inputFile)
 	}
 
 	if configVersion == configSettingsVersion {
 		err = json.Unmarshal(configJSON, &config)
 		if err != nil {
-			log.Panicln("Cannot unmarshal config", inputFile)
+			return fmt.Errorf("Cannot unmarshal config %s: %w", inputFile, err)
 		}
 	} else if configVersion <= configSettingsVersion {
-		log.Printf("WARNING: Using a version %s config file in a version %s app.\n", configVersion, configSettingsVersion)
-		log.Printf("Version 2 added your geolocation. Your location could become NYC.\n")
-		log.Printf("Version 3 uses the Aug 2020 API v1 'station@domain.weatherstem.com' syntax.\n")
+		logWarn(fmt.Sprintf("Using a version %s config file in a version %s app.", configVersion, configSettingsVersion))
+		logWarn("Version 2 added your geolocation, \"me\". Without it, use -me or -locate.")
+		logWarn("Version 3 uses the Aug 2020 API v1 'station@domain.weatherstem.com' syntax.")
 		err = json.Unmarshal(configJSON, &config)
 		if err != nil {
-			log.Panicln("Cannot unmarshal config", inputFile)
-		}
-		if config.Me.Lat == 0.0 {
-			config.Me.Lat = 40.7678
-		}
-		if config.Me.Lon == 0.0 {
-			config.Me.Lon = -73.9814
+			return fmt.Errorf("Cannot unmarshal config %s: %w", inputFile, err)
 		}
 	} else {
-		log.Panicf("Config version mismatch, %v should be %v\n", configVersion, configSettingsVersion)
+		return fmt.Errorf("Config version mismatch in %s, %v should be %v", inputFile, configVersion, configSettingsVersion)
 	}
 
+	if err = config.decryptKeys(); err != nil {
+		return err
+	}
+	if err = config.History.takeOldStore(config.HistoryStore); err != nil {
+		return fmt.Errorf("Config %s: %w", inputFile, err)
+	}
 	config.Me.Calc()
+	config.registerSecrets()
 
 	return err
 }
@@ -331,54 +334,93 @@ func getWeatherInfoFromSomeFile(inputFile string) ([]byte, error) {
 	return usualCallBody, err
 }
 
-// get weather data from the web site
-func getWeatherInfoFromWeb(c *configSettings) ([]byte, error) {
-
-	// We need a TLS session
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+// get weather data from the web site, one endpoint's worth, see getWeatherInfoFromWeb
+func getEndpointInfo(c *configSettings, apiURL, key string, stations []string) ([]byte, error) {
+	body, err := openEndpoint(c, apiURL, key, stations)
+	if err != nil {
+		return nil, err
 	}
+	defer body.Close()
+	apiResponse, err := ioutil.ReadAll(body)
+	logDebug("API answered", len(apiResponse), "bytes")
+	return faultAfterCall(apiResponse), err
+}
+
+// openEndpoint calls one endpoint and hands back the results as they arrive, for
+// getEndpointInfo to read whole or streamStations a station at a time. The caller closes it.
+func openEndpoint(c *configSettings, apiURL, key string, stations []string) (io.ReadCloser, error) {
 
-	// We need a client for the TLS session
-	client := &http.Client{Transport: transport}
+	// We need a TLS session, through the proxy if there is one, on the shared client's
+	// kept-alive connections. The results are read as they arrive, however long the API
+	// takes to send them all, so only the connecting and the first byte are timed.
+	shared, err := httpClient(c)
+	if err != nil {
+		return nil, err
+	}
+	client := *shared
+	client.Timeout = 0
 
 	// We need a request URL which we get from our config file's api_url
 	// something like 'https://volusia.weatherstem.com/api'
-	apiURL := c.URL
 	// and the contents of the request. My local station data from the config file's stations array. Je suis hackeur.
-	requestBody := `{"api_key":"` + c.Key + `","stations":["` + strings.Join(c.Stations, `","`) + `"]}`
+	requestBody := `{"api_key":"` + key + `","stations":["` + strings.Join(stations, `","`) + `"]}`
 	// requestBody is sorta like: {"api_key":"polyshazbotmicrofish","stations":["ponceinlet","fswndaytonabch"]}
 
-	body := strings.NewReader(requestBody)
-
-	// Make the call
-	responseBody, err := client.Post(apiURL, "application/json", body)
+	request, err := http.NewRequest(http.MethodPost, apiURL, strings.NewReader(requestBody))
 	if err != nil {
 		return nil, err
 	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", userAgent())
+	request.Header.Set("Accept-Encoding", "gzip")
 
-	// Close the session once we're done
-	defer responseBody.Body.Close()
-
-	// Now parse the result
-	apiResponse, err := ioutil.ReadAll(responseBody.Body)
+	// Make the call, unless we are pretending it failed or we are out of quota
+	if err := faultBeforeCall(apiURL); err != nil {
+		return nil, err
+	}
+	if err := takeQuota(c, time.Now()); err != nil {
+		return nil, err
+	}
+	logDebug("Calling", apiURL, "for", strings.Join(stations, ", "))
+	responseBody, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	logDebug("API answered", responseBody.Status)
+	switch {
+	case responseBody.StatusCode == http.StatusUnauthorized || responseBody.StatusCode == http.StatusForbidden:
+		responseBody.Body.Close()
+		return nil, fmt.Errorf("%w: %s", errAPIAuth, responseBody.Status)
+	case responseBody.StatusCode >= 500:
+		responseBody.Body.Close()
+		return nil, fmt.Errorf("API answered %s", responseBody.Status)
+	}
 
-	return apiResponse, err
+	// The body is read by the caller, unzipped if need be
+	return openResponse(responseBody)
 }
 
 // PrintWeatherDataJSON shows the data and the measurement units for a station
 func (data *WeatherData) PrintWeatherDataJSON(units *WeatherUnits) {
 	var jdata, junits []byte
 	var err error
-	jdata, err = json.Marshal(data)
+	if inlineUnits {
+		jdata, err = marshalJSON(stationInline(data, units))
+		if err != nil {
+			logError("Cannot marshal weather info", err)
+		}
+		fmt.Printf("%s\n", string(jdata))
+		return
+	}
+	jdata, err = marshalJSON(selectFields(data))
 	if err != nil {
-		log.Println("Cannot marshal weather info", err)
+		logError("Cannot marshal weather info", err)
 		// return err
 	}
 
-	junits, err = json.Marshal(units)
+	junits, err = marshalJSON(selectFields(units))
 	if err != nil {
-		log.Println("Cannot marshal unit info", err)
+		logError("Cannot marshal unit info", err)
 		// return err
 	}
 
@@ -387,16 +429,16 @@ func (data *WeatherData) PrintWeatherDataJSON(units *WeatherUnits) {
 }
 
 // PrintWeatherInfoJSON shows the data only for a station. No units.
-func (data *WeatherInfo) PrintWeatherInfoJSON() {
+func PrintWeatherInfoJSON(data *WeatherInfo) {
 	var jdata []byte
 	var err error
-	jdata, err = json.Marshal(data)
+	jdata, err = marshalJSON(data)
 	if err != nil {
-		log.Println("Cannot marshal weather data", err)
+		logError("Cannot marshal weather data", err)
 		// return err
 	}
 
-	fmt.Printf("%s\n", string(jdata))
+	fmt.Printf("%s\n", redactPayload(jdata))
 }
 
 // WBGTFlag returns the "danger" flag for a given wet bulb globe temperature
@@ -418,26 +460,156 @@ func WBGTFlag ( temp float64 ) (flag string) {
 }
 
 // PrintWeatherData shows the (REAL basic) data for a station
-func (data *WeatherData) PrintWeatherData() {
-
-	fmt.Println(data.Station[1], "("+data.Station[0]+")", data.Station[2], data.StationDist)
-	fmt.Println(" ", " T:", data.Temperature[0], "DP:", data.Temperature[1], "H:", data.Humidity)
-	fmt.Println(WBGTFlag(data.Temperature[2]), "WB:", data.Temperature[2], "WC:", data.Temperature[3], "HI:", data.Temperature[4])
-	fmt.Println(" ", " P:", data.Pressure, data.PressureTrend)
-	fmt.Println(" ", " W:", data.Windspeed[0], data.Windspeed[1], "gust", "("+strconv.FormatFloat(data.Windspeed[2], 'f', 0, 64)+"°", data.Wind[1]+")")
-	fmt.Println(" ", " R:", data.Rain[0], "gauge", data.Rain[1], "rate")
+func (data *WeatherData) PrintWeatherData(units *WeatherUnits) {
+
+	fmt.Println(data.Station[1], "("+data.Station[0]+")", data.Station[2], strings.TrimSpace(fmt.Sprint(measure("distance", data.StationDist), " ", data.bearingHeading())))
+	fmt.Println(" ", " T:", measure("temp", data.Temperature[0]), "DP:", measure("temp", data.Temperature[1]), "H:", measure("humidity", data.Humidity))
+	fmt.Println(WBGTFlag(data.wbgtFahrenheit(units)), "WB:", measure("temp", data.Temperature[2]), "WC:", measure("temp", data.Temperature[3]), "HI:", measure("temp", data.Temperature[4]))
+	if data.PressureTendency != nil {
+		fmt.Println(" ", " P:", measure("pressure", data.Pressure), data.PressureTrend, strconv.FormatFloat(data.PressureTendency.Change, 'f', decimals("pressure", 3), 64), "3h", data.PressureTendency.Code)
+	} else {
+		fmt.Println(" ", " P:", measure("pressure", data.Pressure), data.PressureTrend)
+	}
+	if data.Moisture != nil {
+		fmt.Println(strings.TrimRight(fmt.Sprint("   M: ", data.Moisture.Humidity, " ", data.Moisture.HumidityChange, " ", data.Moisture.Dewpoint, " ", data.Moisture.DewpointChange, " ", data.Moisture.Hint), " "))
+	}
+	fmt.Println(" ", " W:", measure("wind", data.Windspeed[0]), measure("wind", data.Windspeed[1]), "gust", "("+strconv.FormatFloat(data.Windspeed[2], 'f', 0, 64)+"°", windArrow(data.Windspeed[2]), data.Wind[1]+")")
+	if data.RainTotals != nil {
+		fmt.Println(" ", " R:", measure("rain", data.Rain[0]), "gauge", measure("rain", data.Rain[1]), "rate", measure("rain", data.RainTotals.Hour), "1h", measure("rain", data.RainTotals.SixHours), "6h", measure("rain", data.RainTotals.Day), "24h", measure("rain", data.RainTotals.Today), "today")
+	} else {
+		fmt.Println(" ", " R:", measure("rain", data.Rain[0]), "gauge", measure("rain", data.Rain[1]), "rate")
+	}
+	if data.WindStats != nil {
+		fmt.Println(" ", " S:", data.WindStats.Run, "run", strconv.FormatFloat(data.WindStats.Average, 'f', 1, 64), "avg", strconv.FormatFloat(data.WindStats.PeakGust, 'f', 1, 64), "peak", data.WindStats.PeakTime.Local().Format("15:04"))
+	}
+	if data.Frost != nil {
+		fmt.Println(" ", " F:", data.Frost.Level, "low", strconv.FormatFloat(data.Frost.Low, 'f', 0, 64))
+	}
+	if data.Fire != nil {
+		fmt.Println(" ", "FW:", strconv.FormatFloat(data.Fire.Index, 'f', 0, 64), data.Fire.Category)
+	}
+	if data.Drone != nil {
+		fmt.Println(" ", "DR:", data.Drone.Verdict)
+	}
+	if data.CloudBase != nil {
+		fmt.Println(" ", "CB:", data.CloudBase.Feet, "ft", data.CloudBase.Meters, "m")
+	}
+	if data.Cold != nil {
+		if data.Cold.Frostbite > 0 {
+			fmt.Println(" ", " C:", data.Cold.Level, "frostbite", data.Cold.Frostbite, "min")
+		} else {
+			fmt.Println(" ", " C:", data.Cold.Level)
+		}
+	}
+	if data.Lightning != nil {
+		fmt.Println(" ", " L:", data.Lightning.Strikes, "strikes", data.Lightning.Distance, data.Lightning.Unit)
+	}
+	if data.AirQuality != nil {
+		fmt.Println(" ", "AQ:", data.AirQuality.AQI, data.AirQuality.Category)
+	}
+	for _, alert := range data.NWSAlerts {
+		fmt.Println(" ", " ⚠", alert)
+	}
+	if data.Tides != nil {
+		fmt.Print("  ", " ~:")
+		for _, event := range data.Tides.Next {
+			fmt.Print(" ", event, " ", strconv.FormatFloat(event.Height, 'f', 1, 64))
+		}
+		fmt.Println()
+	}
+	if data.LeafWetness != nil {
+		fmt.Println(" ", "LW:", data.LeafWetness.Value)
+	}
+	if data.Spray != nil {
+		fmt.Println(" ", "SP:", data.Spray.Category, strconv.FormatFloat(data.Spray.DeltaT, 'f', 1, 64), "dT")
+	}
+	if data.Upwind != nil && data.Upwind.Most {
+		fmt.Println(" ", " U:", data.Upwind.Distance, data.Upwind.way(), "most")
+	}
+	data.PrintExtraSensorsLite()
 }
 
 // PrintWeatherDataUnits shows the data for a station along with its units
 func (data *WeatherData) PrintWeatherDataUnits(wu *WeatherUnits) {
 
 	// Many of the unit strings are HTML-escaped
-	fmt.Printf("%s (%s) %.2f%s %s\n", data.Station[1], data.Station[0], data.StationDist, wu.StationDist, data.Station[2])
-	fmt.Printf(" T: %-.1f%s DP: %-.1f%s H: %.1f%s\n", data.Temperature[0], html.UnescapeString(wu.Temperature[0]), data.Temperature[1], html.UnescapeString(wu.Temperature[1]), data.Humidity, "%")
-	fmt.Printf("WB: %-.1f%s %s WC: %-.1f%s HI: %-.1f%s\n", data.Temperature[2], html.UnescapeString(wu.Temperature[2]), WBGTFlag(data.Temperature[2]),data.Temperature[3], html.UnescapeString(wu.Temperature[3]), data.Temperature[4], html.UnescapeString(wu.Temperature[4]))
-	fmt.Printf(" P: %.3f%s [%.2fmbar] %v\n", data.Pressure, wu.Pressure, data.Pressure*33.86386, data.PressureTrend) // Major assumption here!
-	fmt.Printf(" W: %.1f%s %.1f%s gust, %v%v %s\n", data.Windspeed[0], wu.Windspeed[0], data.Windspeed[1], html.UnescapeString(wu.Windspeed[1]), data.Windspeed[2], html.UnescapeString(wu.Windspeed[2]), data.Wind[1])
-	fmt.Printf(" R: %.2f%s %.2f%s\n", data.Rain[0], wu.Rain[0], data.Rain[1], wu.Rain[1])
+	var sparks stationSparks
+	if data.sparks != nil {
+		sparks = *data.sparks
+	}
+	if bearing := data.bearingHeading(); bearing != "" {
+		printf("%s (%s) %.2f%s %s %s\n", data.Station[1], data.Station[0], measure("distance", data.StationDist), wu.StationDist, bearing, data.Station[2])
+	} else {
+		printf("%s (%s) %.2f%s %s\n", data.Station[1], data.Station[0], measure("distance", data.StationDist), wu.StationDist, data.Station[2])
+	}
+	printf(" T: %-.1f%s%s DP: %-.1f%s H: %.1f%s\n", measure("temp", data.Temperature[0]), wu.Temperature[0], spark(sparks.Temp), measure("temp", data.Temperature[1]), wu.Temperature[1], measure("humidity", data.Humidity), "%")
+	printf("WB: %-.1f%s %s WC: %-.1f%s HI: %-.1f%s\n", measure("temp", data.Temperature[2]), wu.Temperature[2], WBGTFlag(data.wbgtFahrenheit(wu)),measure("temp", data.Temperature[3]), wu.Temperature[3], measure("temp", data.Temperature[4]), wu.Temperature[4])
+	if data.PressureTendency != nil {
+		printf(" P: %.3f%s [%.2fmbar] %v%s, %+.3f%s in 3h (%d: %s)\n", measure("pressure", data.Pressure), wu.Pressure, data.Pressure*33.86386, tr(data.PressureTrend), spark(sparks.Pressure), measure("pressure", data.PressureTendency.Change), wu.Pressure, data.PressureTendency.Code, data.PressureTendency.Description) // Major assumption here!
+	} else {
+		printf(" P: %.3f%s [%.2fmbar] %v%s\n", measure("pressure", data.Pressure), wu.Pressure, data.Pressure*33.86386, tr(data.PressureTrend), spark(sparks.Pressure)) // Major assumption here!
+	}
+	if data.Moisture != nil {
+		printf(" M: %s\n", data.Moisture.String(wu))
+	}
+	printf(" W: %.1f%s%s %.1f%s gust, %v%v %s %s\n", measure("wind", data.Windspeed[0]), wu.Windspeed[0], spark(sparks.Wind), measure("wind", data.Windspeed[1]), wu.Windspeed[1], data.Windspeed[2], wu.Windspeed[2], windArrow(data.Windspeed[2]), data.Wind[1])
+	if data.RainTotals != nil {
+		printf(" R: %.2f%s %.2f%s, %.2f 1h %.2f 6h %.2f 24h %.2f%s today\n", measure("rain", data.Rain[0]), wu.Rain[0], measure("rain", data.Rain[1]), wu.Rain[1], measure("rain", data.RainTotals.Hour), measure("rain", data.RainTotals.SixHours), measure("rain", data.RainTotals.Day), measure("rain", data.RainTotals.Today), wu.Rain[0])
+	} else {
+		printf(" R: %.2f%s %.2f%s\n", measure("rain", data.Rain[0]), wu.Rain[0], measure("rain", data.Rain[1]), wu.Rain[1])
+	}
+	if data.WindStats != nil {
+		printf(" S: Wind run %.1f%s today, average %.1f%s, peak gust %.1f%s at %s\n", data.WindStats.Run, data.WindStats.RunUnit, data.WindStats.Average, wu.Windspeed[0], data.WindStats.PeakGust, wu.Windspeed[1], data.WindStats.PeakTime.Local().Format("15:04"))
+	}
+	if data.Frost != nil {
+		printf(" F: Frost %s, low near %.0f%s (%s)\n", tr(data.Frost.Level), data.Frost.Low, wu.Temperature[0], data.Frost.Reason)
+	}
+	if data.Fire != nil {
+		printf("FW: Fosberg %.0f, %s\n", data.Fire.Index, data.Fire.Category)
+	}
+	if data.Drone != nil {
+		printf("DR: Drone %s\n", data.Drone)
+	}
+	if data.CloudBase != nil {
+		printf("CB: Cloud base %s\n", data.CloudBase)
+	}
+	if data.Cold != nil {
+		printf(" C: Wind chill %.1f%s, %s\n", data.Cold.WindChill, wu.Temperature[3], data.Cold)
+	}
+	if data.Lightning != nil {
+		printf(" L: %s\n", data.Lightning)
+	}
+	if data.AirQuality != nil {
+		printf("AQ: %s, %s\n", data.AirQuality, data.AirQuality.readings())
+	}
+	for _, alert := range data.NWSAlerts {
+		printf(" ⚠ %s\n", alert)
+	}
+	if data.Tides != nil {
+		printf(" ~: %s\n", data.Tides)
+	}
+	if data.LeafWetness != nil {
+		printf("LW: Leaf wetness %s\n", data.LeafWetness)
+	}
+	if data.Spray != nil {
+		printf("SP: Spraying %s\n", data.Spray)
+	}
+	if data.Upwind != nil && data.Upwind.Most {
+		printf(" U: Most upwind, %.2f%s into the %.0f° wind, what it has is on its way\n", measure("distance", data.Upwind.Distance), wu.StationDist, data.Upwind.WindDir)
+	}
+	data.PrintExtraSensors()
+}
+
+// subcommands run instead of the usual weather report, ala 'weatherstem windrose -days 7'
+var subcommands = map[string]func(*configSettings, []string) error{
+	"windrose": windroseCommand,
+	"et0":      et0Command,
+	"serve":    serveCommand,
+	"diagnose": diagnoseCommand,
+	"plot":     plotCommand,
+	"history":  historyCommand,
+	"stats":    statsCommand,
+	"stations": stationsCommand,
 }
 
 // main body function
@@ -447,89 +619,905 @@ func main() {
 		weatherBytes                             []byte			// The API returns a JSON array of stations with their data
 		err                                      error
 		weatherArr                               []WeatherInfo		// The structured API data
+		failed                                   []stationError		// Stations the API could not give us
 		myConfig                                 configSettings		// Your API user info, location and local WeatherSTEM sites
 		outputJSON, outputOrig, rose, kilo, mile, lite bool		// Some command line flags
+		frost, fire, cold, aviation, drone, alertsOnly, normalize, stats bool
+		statusbar, summary, here, table          bool
+		markdown, outputHTML, kml                bool
+		soil, spray, sensors, windCompass        bool
+		ndjson, jsonArray, geojson               bool
+		outputYAML, outputTOML                   bool
+		fields                                   string		// Only these keys of the JSON
+		jsonUnits                                string		// Units in their own document, or inline
+		sortBy                                   string		// What order the stations come in
+		units                                    string		// Which unit system to show
+		decimalPlaces                            string		// How many decimals of what
+		rssFile                                  string		// Where to write the feed
+		email                                    string		// Who gets the report mailed
+		roseStyle, roseLanguage                  string		// How wind directions are named
+		lang                                     string		// What language to speak
+		rosePoints, sparkHours                   int
+		plotPNG, plotMetrics                     string		// Charts of the history
+		plotSince                                time.Duration
+		hook                                     string		// A script to hand the data to
+		hookOnce                                 bool
+		sinks                                    sinkFlags		// Where else the data goes
+		diff                                     bool		// Only what changed since the last run
+		nwsAlerts                                bool		// Watches and warnings from the NWS
+		forecast                                 bool		// What is coming, here
+		tides                                    bool		// High and low water, on the coast
+		graphite, graphitePrefix                 string		// Carbon's host:port and the metric path prefix
+		otlp                                     string		// OpenTelemetry collector URL
+		redis                                    string		// Where to cache the latest readings
+		policy                                   string		// Which station the status bar shows
+		compare                                  string		// Two stations to line up side by side
+		only                                     string		// Just these stations of the config
+		group                                    string		// Or the stations of this group
+		me, meAddress, locate                    string		// Where I am, if not where the config says
+		compareMETAR                             string		// An airport to check the closest station against
+		heatReport                               bool		// Just the heat stress, for coaches
+		marine                                   bool		// Just the wind, for sailors and surfers
+		sample                                   int		// How many stations to ask about
+		seed                                     int64
+		cacheTTL, cacheAge                       time.Duration		// How long to trust the cache, and how old it was
+		noCache, stale, quotaStatus              bool
+		stream                                   bool		// Each station shown as it arrives
+		verbose, quiet                           bool		// How much to log
+		proxy                                    string		// Where the API calls go through
+		version                                  bool
+		logFormat, logFile                       string
+		fetched                                  time.Time
+		alerts                                   []alertEvent		// Things worth waking somebody up for
 	)
 
 	// Get the commandline flags
 	flag.BoolVar(&outputJSON, "json", false, "Output cooked data as JSON")
-	flag.BoolVar(&kilo, "kilo", false, "Output station distances in kilometers")
-	flag.BoolVar(&mile, "mile", false, "Output station distances in statute miles")
+	flag.BoolVar(&ndjson, "ndjson", false, "Output cooked data as one JSON object per station per line")
+	flag.BoolVar(&jsonArray, "json-array", false, "Output cooked data as one JSON array of stations")
+	flag.BoolVar(&prettyJSON, "pretty", false, "Output indented JSON")
+	flag.StringVar(&fields, "fields", "", "Output only these JSON keys, ala temp,humidity,wind,station")
+	flag.StringVar(&jsonUnits, "json-units", "separate", "Units in JSON output: separate or inline")
+	flag.BoolVar(&geojson, "geojson", false, "Output the stations as a GeoJSON FeatureCollection")
+	flag.BoolVar(&outputYAML, "yaml", false, "Output cooked data as YAML")
+	flag.BoolVar(&outputTOML, "toml", false, "Output cooked data as TOML")
+	flag.StringVar(&units, "units", "", "Output everything in these units: nautical, metric or imperial")
+	flag.StringVar(&decimalPlaces, "precision", "", "Decimals to show of each quantity, ala pressure=3,temp=1,wind=0")
+	flag.BoolVar(&kilo, "kilo", false, "Same as -units metric")
+	flag.BoolVar(&mile, "mile", false, "Same as -units imperial")
 	flag.BoolVar(&lite, "lite", false, "Output lightweight cooked data")
 	flag.BoolVar(&outputOrig, "orig", false, "Output original API results")
+	flag.BoolVar(&sensors, "sensors", false, "Output every sensor reading as the API gave it")
 	flag.BoolVar(&rose, "rose", false, "Output boring compass rose directions")
+	flag.IntVar(&rosePoints, "rose-precision", 0, "Compass rose points for wind directions: 4, 8, 16 or 32")
+	flag.StringVar(&roseStyle, "rose-style", "", "Wind direction names: mariner, full or abbrev")
+	flag.BoolVar(&windCompass, "windrose", false, "Output a small compass rose with the wind and gust for each station")
+	flag.BoolVar(&rawUnits, "raw-units", false, "Keep the unit symbols as the API sends them, ala &deg;F")
+	flag.StringVar(&lang, "lang", "", "Output language: en, es, fr or de")
+	flag.StringVar(&roseLanguage, "rose-lang", "", "Wind direction language: en, es, fr, de, it or pt")
+	flag.BoolVar(&frost, "frost", false, "Output overnight frost risk")
+	flag.BoolVar(&spray, "spray", false, "Output spraying conditions from delta-T and leaf wetness")
+	flag.BoolVar(&soil, "soil", false, "Output the soil probes by depth")
+	flag.BoolVar(&fire, "fire", false, "Output Fosberg fire weather index")
+	flag.BoolVar(&aviation, "aviation", false, "Output the estimated cloud base (LCL) in feet and meters")
+	flag.BoolVar(&drone, "drone", false, "Output a drone flight GO, CAUTION or NO-GO per station, exit 8 on any NO-GO")
+	flag.BoolVar(&cold, "cold", false, "Output wind chill advisories and frostbite time")
+	flag.StringVar(&plotPNG, "plot-png", "", "Write a PNG chart of the history to this file (one per station)")
+	flag.StringVar(&plotMetrics, "plot-metrics", "temp,humidity,windspeed", "Readings to chart, ala temp,pressure")
+	flag.DurationVar(&plotSince, "plot-since", 24*time.Hour, "How far back to chart")
+	flag.IntVar(&sparkHours, "spark", 0, "Output sparklines of this many hours of history for temperature, pressure and wind")
+	flag.BoolVar(&stats, "stats", false, "Output today's wind run, average and peak gust from history")
+	flag.BoolVar(&statusbar, "statusbar", false, "Output one station on one line")
+	flag.StringVar(&policy, "statusbar-policy", "", "Status bar station: nearest-fresh or always-nearest")
+	flag.BoolVar(&here, "here", false, "Output an estimate for my location from the nearby stations")
+	flag.StringVar(&compare, "compare", "", "Output two stations side by side, ala stationA,stationB")
+	flag.BoolVar(&marine, "marine", false, "Output only the wind for sailing and surf: knots, gust factor, trend and onshore or offshore")
+	flag.BoolVar(&heatReport, "heat-report", false, "Output only the heat stress: WBGT, flag, work/rest, hydration and today's peak")
+	flag.StringVar(&compareMETAR, "compare-metar", "", "Output the closest station side by side with this airport's METAR, ala KDAB")
+	flag.BoolVar(&table, "table", false, "Output a table with the stations as columns")
+	flag.BoolVar(&markdown, "markdown", false, "Output a Markdown report")
+	flag.BoolVar(&outputHTML, "html", false, "Output an HTML report")
+	flag.BoolVar(&kml, "kml", false, "Output the stations as KML placemarks for Google Earth")
+	flag.StringVar(&rssFile, "rss", "", "Write an RSS feed of recent runs to this file")
+	flag.StringVar(&email, "email", "", "Mail the report to these addresses, ala crew@example.com,boss@example.com")
+	flag.StringVar(&hook, "exec", "", "Run this command for each station, with WS_ variables and the JSON on stdin")
+	flag.BoolVar(&hookOnce, "exec-once", false, "Run the -exec command once, with all the stations on stdin")
+	flag.Var(&sinks, "sink", "Send the data to a sink, ala email=crew@example.com (repeatable)")
+	flag.StringVar(&graphite, "graphite", "", "Send the readings to Graphite, ala carbon.example.com:2003")
+	flag.StringVar(&graphitePrefix, "graphite-prefix", "", "Graphite metric path prefix, weatherstem if not in the config")
+	flag.StringVar(&otlp, "otlp", "", "Send the readings to an OpenTelemetry collector, ala http://localhost:4318")
+	flag.StringVar(&redis, "redis", "", "Cache the readings in Redis, ala localhost:6379")
+	flag.StringVar(&sortBy, "sort", "", "Output the stations in this order: config, name, distance or upwind")
+	flag.BoolVar(&summary, "summary", false, "Output the area as a whole after the stations")
+	flag.BoolVar(&alertsOnly, "alerts", false, "Output only alerts, if any")
+	flag.BoolVar(&diff, "diff", false, "Output only what changed since the last run, if anything")
+	flag.BoolVar(&forecast, "forecast", false, "Output the hourly forecast for my location after the stations")
+	flag.BoolVar(&tides, "tides", false, "Output the next high and low tide for stations near the coast")
+	flag.BoolVar(&nwsAlerts, "nws-alerts", false, "Output the National Weather Service watches and warnings for each station")
+	flag.StringVar(&me, "me", "", "Measure distances from here instead of the config's me, ala 29.13,-80.95")
+	flag.StringVar(&meAddress, "me-address", "", "Measure distances from this address instead of the config's me, ala \"123 Ocean Ave, Daytona Beach FL\"")
+	flag.StringVar(&locate, "locate", "", "Find me instead of using the config's me: ip or gpsd")
+	flag.StringVar(&group, "group", "", "Ask about the stations of this group in the config, shown its way")
+	flag.StringVar(&only, "stations", "", "Ask about only these stations, by handle or alias, ala ponceinlet,Beachside")
+	flag.IntVar(&sample, "sample", 0, "Ask about only this many of the stations, picked at random")
+	flag.Int64Var(&seed, "seed", 0, "Seed for -sample, to get the same pick every time")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 0, "Use the cached API results instead of calling, if younger than this")
+	flag.BoolVar(&noCache, "no-cache", false, "Neither use nor keep cached API results, even when the API fails")
+	flag.BoolVar(&stream, "stream", false, "Show each station as the API sends it, with only what takes one station at a time")
+	flag.BoolVar(&quotaStatus, "quota-status", false, "Output the API calls made today and in the last hour")
+	flag.BoolVar(&normalize, "normalize", false, "Convert all stations to the first station's units")
+	flag.StringVar(&proxy, "proxy", "", "Call the API through this proxy, ala http://proxy.example.com:3128")
+	flag.BoolVar(&version, "version", false, "Output the version, build and config file version")
+	flag.BoolVar(&verbose, "v", false, "Log the debug details too")
+	flag.BoolVar(&quiet, "q", false, "Log only errors")
+	flag.StringVar(&logFormat, "log-format", "text", "Log as text or json")
+	flag.StringVar(&logFile, "log-file", "", "Log to this file instead of stderr")
+	flag.BoolVar(&debugHTTP, "debug-http", false, "Log each HTTP call: the request with the key blanked, status, time and size")
+	flag.StringVar(&debugHTTPDir, "debug-http-dir", "", "Also dump each HTTP call's request and response to files in this directory")
+	flag.StringVar(&injectedFault, "inject-fault", "", "Pretend the API fails: api-timeout, bad-json or partial")
+	flag.Usage = usage
 	flag.Parse()
 
-	if flag.NArg() > 0 {
-		fmt.Println("Current WBGT flags:")
-		fmt.Println("   <82°F       - normal")
-		fmt.Println(" ⚊ 82°F - 87°F - Level 1")
-		fmt.Println(" ⚌ 87°F - 90°F - Level 2")
-		fmt.Println(" ☰ 90°F - 92°F - Level 3")
-		fmt.Println(" ⚑ >92°F       - Level 4")
-		os.Exit(0)
+	if version {
+		PrintVersion()
+		os.Exit(exitOK)
+	}
+
+	if err = setupLogging(verbose, quiet, logFormat, logFile); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(exitUsage)
+	}
+	if lang != "" {
+		if err = setLanguage(lang); err != nil {
+			fmt.Fprintln(flag.CommandLine.Output(), err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	if err = checkDebugHTTP(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(exitUsage)
+	}
+
+	if err = checkInjectedFault(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+
+	if me != "" {
+		if _, _, err = parseMe(me); err != nil {
+			fmt.Fprintln(flag.CommandLine.Output(), err)
+			os.Exit(exitUsage)
+		}
+	}
+	if locate != "" && locate != "ip" && locate != "gpsd" {
+		fmt.Fprintln(flag.CommandLine.Output(), "locate is ip or gpsd, not", locate)
+		os.Exit(exitUsage)
+	}
+
+	if _, err = settleUnits("", units, kilo, mile); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(exitUsage)
+	}
+	if _, err = settlePrecision(nil, decimalPlaces); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(exitUsage)
+	}
+	if sortBy != "" && !contains(stationSorts, sortBy) {
+		fmt.Fprintf(flag.CommandLine.Output(), "sort is %s, not %q\n", strings.Join(stationSorts, ", "), sortBy)
+		os.Exit(exitUsage)
+	}
+	structuredOutput = outputJSON || ndjson || jsonArray || outputYAML || outputTOML || geojson || kml || outputOrig
+	if err = checkStream(stream, table || summary || here || jsonArray || geojson || kml || outputYAML || outputTOML || markdown || outputHTML || statusbar || diff, sortBy); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(exitUsage)
+	}
+	if err = checkFields(fields); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(exitUsage)
+	}
+	switch jsonUnits {
+	case "separate":
+	case "inline":
+		inlineUnits = true
+	default:
+		fmt.Fprintln(flag.CommandLine.Output(), "json-units is separate or inline, not", jsonUnits)
+		os.Exit(exitUsage)
+	}
+
+	if flag.NArg() > 0 && subcommands[flag.Arg(0)] == nil {
+		fmt.Println(tr("Current WBGT flags:"))
+		fmt.Println("   <82°F       -", tr("normal"))
+		fmt.Println(" ⚊ 82°F - 87°F -", tr("Level"), 1)
+		fmt.Println(" ⚌ 87°F - 90°F -", tr("Level"), 2)
+		fmt.Println(" ☰ 90°F - 92°F -", tr("Level"), 3)
+		fmt.Println(" ⚑ >92°F       -", tr("Level"), 4)
+		os.Exit(exitOK)
+	}
+
+	// The doctor finds the config itself, to say what is wrong with it, config to change it,
+	// and the mock needs none
+	if flag.NArg() > 0 && (flag.Arg(0) == "doctor" || flag.Arg(0) == "config" || flag.Arg(0) == "mock") {
+		if err = subcommands[flag.Arg(0)](&myConfig, flag.Args()[1:]); err != nil {
+			logError(err)
+			os.Exit(exitFailure)
+		}
+		os.Exit(exitOK)
 	}
 
 	// Get API and stations from the configuration file in the current directory or HOME directory
 	err = findConfigSettings(&myConfig)
-	if err != nil {
-		log.Println("Config file not found. It should look like this and be in 'weatherstem.json', either in the current or in your $HOME/.config directory.")
-		log.Println(`{"version":"3.0","api_url":"https://api.weatherstem.com/api","api_key":"yourApiKey","stations":["station1@domain.weatherstem.com","stationX@domain.weatherstem.com"],"me":{"lat":43.14,"lon":-111.275}}`)
-		os.Exit(3)
+	if err != nil && !os.IsNotExist(err) {
+		logError(err)
+		os.Exit(exitConfig)
+	} else if err != nil && !(flag.NArg() > 0 && flag.Arg(0) == "completion") {
+		// Completion scripts can be made without a config
+		logError("Config file not found. It should look like this and be in 'weatherstem.json', either in the current or in your $HOME/.config directory.")
+		logError(`{"version":"3.0","api_url":"https://api.weatherstem.com/api","api_key":"yourApiKey","stations":["station1@domain.weatherstem.com","stationX@domain.weatherstem.com"],"me":{"lat":43.14,"lon":-111.275}}`)
+		os.Exit(exitConfig)
+	}
+
+	if proxy != "" {
+		myConfig.Proxy = proxy
+		myConfig.registerSecrets()
+	}
+	if myConfig.tables.sensorTypes, err = sensorRegistry(myConfig.Sensors); err != nil {
+		logError("Bad sensors in the config.", err)
+		os.Exit(exitConfig)
+	}
+	myConfig.settleEndpoints()
+	if err = settleMe(&myConfig, me, meAddress, locate); err != nil {
+		logError("Cannot work out where I am.", err)
+		os.Exit(exitFailure)
+	}
+	if err = selectGroup(&myConfig, group); err != nil {
+		logError(err)
+		os.Exit(exitUsage)
+	}
+	unitsFlag := units
+	if units, err = settleUnits(myConfig.Units, units, kilo, mile); err != nil {
+		logError(err)
+		os.Exit(exitConfig)
+	}
+	myConfig.Units = units
+	if myConfig.tables.precision, err = settlePrecision(myConfig.Precision, decimalPlaces); err != nil {
+		logError(err)
+		os.Exit(exitConfig)
+	}
+	myConfig.useTables()
+	if lang == "" && myConfig.Lang != "" {
+		if err = setLanguage(myConfig.Lang); err != nil {
+			logError(err)
+			os.Exit(exitConfig)
+		}
+	}
+	// The wind directions speak the same language, unless told otherwise
+	if roseLanguage == "" && myConfig.Rose.Language == "" && compassLanguages[language] != nil {
+		roseLanguage = language
+	}
+	if err = settleCompass(myConfig.Rose, rosePoints, roseStyle, roseLanguage, rose); err != nil {
+		logError(err)
+		os.Exit(exitUsage)
+	}
+
+	// How much of the API budget is left
+	if quotaStatus {
+		PrintQuotaStatus(&myConfig)
+		os.Exit(exitOK)
+	}
+
+	// serve reloads the config on SIGHUP, settled as above but put in force by serve
+	reloadConfig = func() (*configSettings, error) {
+		var fresh configSettings
+		if err := findConfigSettings(&fresh); err != nil {
+			return nil, err
+		}
+		if proxy != "" {
+			fresh.Proxy = proxy
+		}
+		var err error
+		if fresh.tables.sensorTypes, err = sensorRegistry(fresh.Sensors); err != nil {
+			return nil, err
+		}
+		fresh.settleEndpoints()
+		if err := settleMe(&fresh, me, meAddress, locate); err != nil {
+			return nil, err
+		}
+		if err := selectGroup(&fresh, group); err != nil {
+			return nil, err
+		}
+		if fresh.Units, err = settleUnits(fresh.Units, unitsFlag, kilo, mile); err != nil {
+			return nil, err
+		}
+		if fresh.tables.precision, err = settlePrecision(fresh.Precision, decimalPlaces); err != nil {
+			return nil, err
+		}
+		return &fresh, nil
+	}
+
+	// Run a subcommand instead, if asked
+	if flag.NArg() > 0 {
+		err = subcommands[flag.Arg(0)](&myConfig, flag.Args()[1:])
+		if err != nil {
+			logError(err)
+			os.Exit(exitFailure)
+		}
+		os.Exit(exitOK)
+	}
+
+	if sample < 0 {
+		logError("Cannot sample", sample, "stations.")
+		os.Exit(exitUsage)
+	}
+	if err = selectStations(&myConfig, only); err != nil {
+		logError(err)
+		os.Exit(exitUsage)
+	}
+	sampleStations(&myConfig, sample, seed)
+
+	// Each station as it comes, for the outputs that take one at a time
+	if stream {
+		shown, failed, err := streamStations(&myConfig, units, func(info *WeatherInfo, data *WeatherData, wu *WeatherUnits) {
+			switch {
+			case outputOrig:
+				PrintWeatherInfoJSON(info)
+			case ndjson:
+				PrintNDJSON(stationDocuments([]WeatherData{*data}, []WeatherUnits{*wu}))
+			case outputJSON:
+				data.PrintWeatherDataJSON(wu)
+			case lite:
+				data.PrintWeatherData(wu)
+			default:
+				data.PrintWeatherDataUnits(wu)
+			}
+		})
+		if errors.Is(err, errNotStations) {
+			logError("Cannot unmarshal API results.", err)
+			os.Exit(exitParse)
+		} else if err != nil {
+			logError("Call to API failed.", err)
+			os.Exit(exitCodeFor(err))
+		}
+		if ndjson || outputJSON {
+			for i := range failed {
+				failed[i].PrintStationErrorJSON()
+			}
+		} else {
+			logStationErrors(failed)
+		}
+		if shown == 0 && len(failed) > 0 {
+			os.Exit(exitParse)
+		}
+		os.Exit(exitOK)
 	}
 
 	// Get local WeatherSTEM data
-	weatherBytes, err = getWeatherInfoFromWeb(&myConfig)
+	weatherBytes, fetched, stale, err = fetchWeatherInfo(&myConfig, cacheTTL, noCache)
 	if err != nil {
-		log.Println("Call to API failed.", err)
-		os.Exit(1)
+		logError("Call to API failed.", err)
+		os.Exit(exitCodeFor(err))
 	}
 
-	// Parse returned data into basic structs
-	err = json.Unmarshal(weatherBytes, &weatherArr)
+	// Parse returned data into basic structs, a station at a time
+	weatherArr, failed, err = unmarshalWeatherInfo(weatherBytes, myConfig.Stations)
 	if err != nil {
-		log.Println("Cannot unmarshal API results.")
-		log.Println(string(weatherBytes))
-		os.Exit(2)
+		logError("Cannot unmarshal API results.")
+		logError(string(weatherBytes))
+		os.Exit(exitParse)
+	}
+	logStationErrors(failed)
+	if len(weatherArr) == 0 && len(failed) > 0 {
+		os.Exit(exitParse)
+	}
+	if err = updateStationCache(&myConfig, weatherArr, fetched); err != nil {
+		logWarn("Cannot keep the station cache.", err)
+	}
+
+	// Whatever gets shown, cached data is not all well
+	done := exitOK
+	if stale {
+		done = exitStale
 	}
 
 	// Convert stringy structs into scalars
-	dataArr := make([]WeatherData, len(weatherArr))
-	unitArr := make([]WeatherUnits, len(weatherArr))
-	for idx, stationData := range weatherArr {
-		dataArr[idx], unitArr[idx] = PopulateWeatherData(&stationData, rose)
-		if kilo {
-			dataArr[idx].StationDist = haversine.DistanceKm(myConfig.Me, dataArr[idx].StationTopo)
-			unitArr[idx].StationDist = "km"
-		} else if mile {
-			dataArr[idx].StationDist = haversine.DistanceMi(myConfig.Me, dataArr[idx].StationTopo)
-			unitArr[idx].StationDist = "mi"
+	dataArr, unitArr := cookWeatherInfo(weatherArr, myConfig.Me.Coord, units)
+	applyAliases(&myConfig, dataArr, unitArr)
+	stampProvenance(&myConfig, dataArr, weatherArr, fetched)
+	convertToSystem(units, dataArr, unitArr)
+	AssessUpwind(dataArr, unitArr)
+	if err = sortStations(sortBy, dataArr, unitArr); err != nil {
+		logError(err)
+		os.Exit(exitUsage)
+	}
+	if stale {
+		cacheAge = time.Since(fetched).Round(time.Second)
+		for i := range dataArr {
+			dataArr[i].noteTransformation("served from cache, " + cacheAge.String() + " old, as the API call failed")
+		}
+	}
+
+	// Keep a record of this run for the history subcommands
+	recorded := time.Now()
+	if myConfig.History.File != "" {
+		err = appendHistory(&myConfig, recorded, dataArr, unitArr)
+		if err != nil {
+			logError("Cannot record history.", err)
+		}
+		if err = compactHistory(&myConfig, time.Now()); err != nil {
+			logError("Cannot compact history.", err)
+		}
+	}
+
+	// Work out what the history has to say: the three hour pressure tendency, with a
+	// warning if it is falling fast, the humidity and dewpoint tendency, the rain totals
+	// and the readings before this run's
+	previous := make(map[string]*historyRecord)
+	if myConfig.History.File != "" {
+		now := time.Now()
+		recent, _ := readHistory(&myConfig, now.Add(-rainHistoryWindow))
+		_, byStation := historyByStation(recent)
+		for i := range dataArr {
+			stationHistory := byStation[dataArr[i].Station[0]]
+			if tendency, ok := AssessPressureTendency(&dataArr[i], &unitArr[i], stationHistory, now); ok {
+				dataArr[i].PressureTendency = &tendency
+				if event, raised := pressureAlert(&dataArr[i], &unitArr[i]); raised {
+					alerts = append(alerts, event)
+				}
+			}
+			if tendency, ok := AssessMoistureTendency(&dataArr[i], &unitArr[i], stationHistory, now); ok {
+				dataArr[i].Moisture = &tendency
+			}
+			previous[dataArr[i].Station[0]] = previousReading(stationHistory, recorded)
+			rain := AccumulateRain(&unitArr[i], stationHistory, now)
+			dataArr[i].RainTotals = &rain
+			if stats {
+				windStats := AssessWindStatistics(&unitArr[i], stationHistory, now)
+				dataArr[i].WindStats = &windStats
+			}
+		}
+	}
+
+	// Trends of the last few hours for the text output
+	if sparkHours > 0 && myConfig.History.File != "" {
+		now := time.Now()
+		since := now.Add(-time.Duration(sparkHours) * time.Hour)
+		recent, _ := readHistory(&myConfig, since)
+		_, byStation := historyByStation(recent)
+		for i := range dataArr {
+			dataArr[i].sparks = newStationSparks(byStation[dataArr[i].Station[0]], since, now)
+		}
+	}
+
+	// Get every station onto the same units, warning about each conversion
+	if normalize {
+		for _, warning := range harmonizeUnits(dataArr, unitArr) {
+			logWarn(warning)
+		}
+	}
+
+	// Work out the overnight frost risk, with the recent history for the trend
+	if frost {
+		var recent []historyRecord
+		if myConfig.History.File != "" {
+			recent, _ = readHistory(&myConfig, time.Now().Add(-frostTrendHours*time.Hour))
+		}
+		_, byStation := historyByStation(recent)
+		for i := range dataArr {
+			risk := AssessFrost(&dataArr[i], &unitArr[i], byStation[dataArr[i].Station[0]], time.Now())
+			dataArr[i].Frost = &risk
+			if event, raised := frostAlert(&dataArr[i], &unitArr[i]); raised {
+				alerts = append(alerts, event)
+			}
+		}
+	}
+
+	// Fire weather from temperature, humidity and wind
+	if fire {
+		for i := range dataArr {
+			index := FosbergIndex(&dataArr[i], &unitArr[i])
+			dataArr[i].Fire = &index
+			if event, raised := fireAlert(&dataArr[i]); raised {
+				alerts = append(alerts, event)
+			}
+		}
+	}
+
+	// Cloud base for the glider and drone pilots
+	if aviation {
+		for i := range dataArr {
+			base := EstimateCloudBase(&dataArr[i], &unitArr[i])
+			dataArr[i].CloudBase = &base
+		}
+	}
+
+	// Whether to fly, and a NO-GO anywhere for the exit code
+	if drone {
+		for i := range dataArr {
+			verdict := AssessDrone(&myConfig, &dataArr[i], &unitArr[i])
+			dataArr[i].Drone = &verdict
+			if verdict.Verdict == "NO-GO" {
+				done = exitNoGo
+			}
+		}
+	}
+
+	// Wind chill advisories and how soon frostbite sets in
+	if cold {
+		for i := range dataArr {
+			stress := AssessCold(&myConfig, &dataArr[i], &unitArr[i])
+			dataArr[i].Cold = &stress
+			if event, raised := coldAlert(&dataArr[i], &unitArr[i]); raised {
+				alerts = append(alerts, event)
+			}
+		}
+	}
+
+	// Spraying conditions from the delta-T
+	if spray {
+		for i := range dataArr {
+			conditions := AssessSpray(&dataArr[i], &unitArr[i])
+			dataArr[i].Spray = &conditions
+		}
+	}
+
+	// Lightning close enough to clear the pool, always
+	for i := range dataArr {
+		if event, raised := lightningAlert(&dataArr[i], myConfig.lightningRadius()); raised {
+			alerts = append(alerts, event)
+		}
+	}
+
+	// Watches and warnings from the NWS, the severe ones raising alerts of our own
+	if nwsAlerts {
+		annotateNWSAlerts(&myConfig, dataArr)
+		for i := range dataArr {
+			alerts = append(alerts, nwsAlertEvents(&dataArr[i])...)
+		}
+	}
+
+	// The next tides, for the stations on the coast
+	if tides {
+		annotateTides(&myConfig, dataArr, unitArr)
+	}
+
+	// What moved since the last run, for -diff and for mail sent only then
+	var changes runDiff
+	if diff || myConfig.SMTP.When == "changes" {
+		if changes, err = diffLastRun(&myConfig, time.Now(), dataArr, unitArr); err != nil {
+			logError("Cannot keep the last run.", err)
+		}
+	}
+
+	// Rain starting or stopping since the reading before, from the history or else the last run
+	if myConfig.History.File == "" {
+		for i := range changes.last {
+			previous[changes.last[i].Data.Station[0]] = &changes.last[i]
+		}
+	}
+	for i := range dataArr {
+		if event, raised := rainEventAlert(&dataArr[i], &unitArr[i], previous[dataArr[i].Station[0]]); raised {
+			alerts = append(alerts, event)
+		}
+	}
+
+	// Squalls: the wind swinging round, and with history a sharp pressure drop or gusts
+	// well over the last hour's average
+	var lastHour map[string][]historyRecord
+	if myConfig.History.File != "" {
+		recent, _ := readHistory(&myConfig, recorded.Add(-squallWindow))
+		_, lastHour = historyByStation(recent)
+	}
+	for i := range dataArr {
+		handle := dataArr[i].Station[0]
+		if event, raised := windShiftAlert(&dataArr[i], &unitArr[i], previous[handle]); raised {
+			alerts = append(alerts, event)
+		}
+		if event, raised := pressureDropAlert(&dataArr[i], &unitArr[i], lastHour[handle], recorded); raised {
+			alerts = append(alerts, event)
+		}
+		if event, raised := gustSpikeAlert(&dataArr[i], &unitArr[i], lastHour[handle], recorded); raised {
+			alerts = append(alerts, event)
+		}
+	}
+
+	// Hand it all to the sinks: the config's, -sink's and the shorthand flags
+	sinkSettings := append(myConfig.Sinks, sinks...)
+	if graphite != "" {
+		sinkSettings = append(sinkSettings, sinkSetting{Name: "graphite", Target: graphite})
+	}
+	if otlp != "" {
+		sinkSettings = append(sinkSettings, sinkSetting{Name: "otlp", Target: otlp})
+	}
+	if redis != "" {
+		sinkSettings = append(sinkSettings, sinkSetting{Name: "redis", Target: redis})
+	}
+	if graphitePrefix != "" {
+		myConfig.GraphitePrefix = graphitePrefix
+	}
+	if rssFile != "" {
+		sinkSettings = append(sinkSettings, sinkSetting{Name: "rss", Target: rssFile})
+	}
+	if email != "" {
+		sinkSettings = append(sinkSettings, sinkSetting{Name: "email", Target: email})
+	}
+	if hook != "" && hookOnce {
+		sinkSettings = append(sinkSettings, sinkSetting{Name: "exec-once", Target: hook})
+	} else if hook != "" {
+		sinkSettings = append(sinkSettings, sinkSetting{Name: "exec", Target: hook})
+	}
+
+	// Daily graphs for -plot-png, and for the HTML report and the email to embed
+	chartsWanted := plotPNG != "" || outputHTML
+	for _, setting := range sinkSettings {
+		chartsWanted = chartsWanted || setting.Name == "email"
+	}
+	if chartsWanted && myConfig.History.File != "" {
+		if err = chartStations(&myConfig, dataArr, strings.Split(plotMetrics, ","), plotSince, plotPNG); err != nil {
+			logError("Cannot draw the charts.", err)
+		}
+	}
+
+	batch := sinkBatch{Time: time.Now(), Data: dataArr, Units: unitArr, Orig: weatherArr, Alerts: alerts, Changes: changes.Stations}
+	for _, err = range runSinks(&myConfig, sinkSettings, &batch) {
+		logError(err)
+	}
+
+	// Only the alerts, for cron jobs which should keep quiet otherwise
+	if alertsOnly {
+		if outputJSON {
+			PrintAlertsJSON(alerts)
+		} else {
+			PrintAlerts(alerts)
+		}
+		if len(alerts) > 0 {
+			os.Exit(exitAlert)
+		}
+		os.Exit(done)
+	}
+
+	// Only what changed, so cron mails nothing when nothing did
+	if diff {
+		if outputJSON {
+			changes.PrintDiffJSON()
+		} else {
+			changes.PrintDiff()
+		}
+		os.Exit(done)
+	}
+
+	// Two stations side by side
+	if compare != "" {
+		pair := strings.Split(compare, ",")
+		if len(pair) != 2 {
+			logError("Compare needs two stations, ala stationA,stationB")
+			os.Exit(exitUsage)
+		}
+		a, b := findStation(dataArr, pair[0]), findStation(dataArr, pair[1])
+		if a < 0 || b < 0 {
+			logError("Cannot find both stations to compare:", compare)
+			os.Exit(exitUsage)
+		}
+		comparison := CompareStations(&dataArr[a], &dataArr[b], &unitArr[a], &unitArr[b])
+		if outputJSON {
+			comparison.PrintStationComparisonJSON()
+		} else {
+			comparison.PrintStationComparison()
+		}
+		os.Exit(done)
+	}
+
+	// The closest station against the official airport observation
+	if compareMETAR != "" {
+		metar, err := fetchMETAR(&myConfig, compareMETAR)
+		if err != nil {
+			logError("Cannot get the METAR.", err)
+			os.Exit(exitFailure)
+		}
+		comparison, closest, km, err := CompareMETAR(&metar, dataArr, unitArr)
+		if err != nil {
+			logError("Cannot compare with the METAR.", err)
+			os.Exit(exitFailure)
+		}
+		if outputJSON {
+			comparison.PrintStationComparisonJSON()
 		} else {
-			dataArr[idx].StationDist = haversine.DistanceNM(myConfig.Me, dataArr[idx].StationTopo)
-			unitArr[idx].StationDist = "NM"
+			fmt.Printf("%s\n%s (%s) is %.1fkm away\n", metar.Raw, dataArr[closest].Station[1], dataArr[closest].Station[0], km)
+			comparison.PrintStationComparison()
+		}
+		os.Exit(done)
+	}
+
+	// Heat stress and nothing else, for the coaches
+	if heatReport {
+		var byStation map[string][]historyRecord
+		if myConfig.History.File != "" {
+			recent, _ := readHistory(&myConfig, time.Now().Add(-24*time.Hour))
+			_, byStation = historyByStation(recent)
+		}
+		for i := range dataArr {
+			report := NewHeatReport(&myConfig, &dataArr[i], &unitArr[i], byStation[dataArr[i].Station[0]], time.Now())
+			if outputJSON {
+				report.PrintHeatReportJSON()
+			} else {
+				report.PrintHeatReport()
+			}
+		}
+		os.Exit(done)
+	}
+
+	// The wind and little else, for the sailors and surfers
+	if marine {
+		var byStation map[string][]historyRecord
+		if myConfig.History.File != "" {
+			recent, _ := readHistory(&myConfig, recorded.Add(-marineTrendWindow))
+			_, byStation = historyByStation(recent)
+		}
+		for i := range dataArr {
+			report := NewMarineReport(&myConfig, &dataArr[i], &unitArr[i], byStation[dataArr[i].Station[0]], recorded)
+			if outputJSON {
+				report.PrintMarineReportJSON()
+			} else {
+				report.PrintMarineReport()
+			}
+		}
+		os.Exit(done)
+	}
+
+	// Just the one station on one line
+	if statusbar {
+		if policy == "" {
+			policy = myConfig.StatusbarPolicy
 		}
+		if pick := pickStatusbarStation(dataArr, policy, time.Now()); pick >= 0 {
+			dataArr[pick].PrintStatusbar(&unitArr[pick], cacheAge)
+		}
+		os.Exit(done)
+	}
+
+	// A report to paste or post
+	if markdown {
+		PrintReportMarkdown(os.Stdout, dataArr, unitArr, weatherArr)
+		os.Exit(done)
+	}
+	if outputHTML {
+		if err = PrintReportHTML(os.Stdout, dataArr, unitArr, weatherArr); err != nil {
+			logError("Cannot write the report.", err)
+			os.Exit(exitFailure)
+		}
+		os.Exit(done)
+	}
+
+	if kml {
+		if err = PrintKML(os.Stdout, dataArr, unitArr, weatherArr); err != nil {
+			logError("Cannot write the KML.", err)
+			os.Exit(exitFailure)
+		}
+		os.Exit(done)
+	}
+
+	// Every reading, raw, in a table
+	if sensors {
+		PrintSensorReadings(weatherArr)
+		os.Exit(done)
 	}
 
 	// Show the original raw info
 	if outputOrig {
 		for _, origInfo := range weatherArr {
-			origInfo.PrintWeatherInfoJSON()
+			PrintWeatherInfoJSON(&origInfo)
 		}
 	} else {
 
 		// Show the cooked data
-		for i := 0; i < len(dataArr); i++ {
+		show := func(data *WeatherData, units *WeatherUnits) {
 			if outputJSON {
-				dataArr[i].PrintWeatherDataJSON(&unitArr[i])
+				data.PrintWeatherDataJSON(units)
 			} else {
 				if lite {
-					dataArr[i].PrintWeatherData()
+					data.PrintWeatherData(units)
 				} else {
-					dataArr[i].PrintWeatherDataUnits(&unitArr[i])
+					data.PrintWeatherDataUnits(units)
+				}
+				if soil && lite {
+					data.PrintSoilLite(units)
+				} else if soil {
+					data.PrintSoil(units)
 				}
+				if windCompass {
+					data.PrintWindCompass(units)
+				}
+			}
+		}
+		shownData, shownUnits := dataArr, unitArr
+
+		// My best guess at the weather right here
+		if here {
+			hereData, hereUnits := InterpolateHere(dataArr, unitArr, myConfig.Me.Coord)
+			shownData = append(dataArr[:len(dataArr):len(dataArr)], hereData)
+			shownUnits = append(unitArr[:len(unitArr):len(unitArr)], hereUnits)
+		}
+
+		if outputYAML || outputTOML {
+			doc := markupDocument{Stations: stationDocuments(shownData, shownUnits), Errors: failed}
+			if summary {
+				area := AggregateArea(dataArr, unitArr)
+				doc.Area = &area
+			}
+			if outputYAML {
+				err = PrintYAML(os.Stdout, doc)
+			} else {
+				err = PrintTOML(os.Stdout, doc)
+			}
+			if err != nil {
+				logError("Cannot write the weather data.", err)
+				os.Exit(exitFailure)
+			}
+			os.Exit(done)
+		}
+
+		if geojson {
+			PrintGeoJSON(shownData, shownUnits)
+			os.Exit(done)
+		}
+
+		if ndjson || jsonArray {
+			// Data and units together, with the area tacked on the end
+			docs := stationDocuments(shownData, shownUnits)
+			for i := range failed {
+				docs = append(docs, &failed[i])
+			}
+			if summary {
+				area := AggregateArea(dataArr, unitArr)
+				docs = append(docs, areaDocument(&area))
+			}
+			if ndjson {
+				PrintNDJSON(docs)
+			} else {
+				PrintJSONArray(docs)
+			}
+			os.Exit(done)
+		}
+
+		if table && !outputJSON {
+			PrintWeatherTable(shownData, shownUnits)
+		} else {
+			for i := 0; i < len(shownData); i++ {
+				show(&shownData[i], &shownUnits[i])
+			}
+		}
+		if outputJSON {
+			for i := range failed {
+				failed[i].PrintStationErrorJSON()
+			}
+		}
+
+		// And the area as a whole
+		if summary {
+			area := AggregateArea(dataArr, unitArr)
+			if outputJSON {
+				area.PrintAreaConditionsJSON()
+			} else if lite {
+				area.PrintAreaConditionsLite()
+			} else {
+				area.PrintAreaConditions()
+			}
+		}
+
+		// And what is coming, here
+		if forecast {
+			outlook, err := fetchForecast(&myConfig, myConfig.Me.Lat, myConfig.Me.Lon, forecastReference(myConfig.Units, unitArr))
+			switch {
+			case err != nil:
+				logWarn("Cannot get the forecast.", err)
+			case outputJSON:
+				outlook.PrintForecastJSON()
+			default:
+				outlook.PrintForecast()
 			}
 		}
 	}
 
 	// Add your other fun stuff here.
+	os.Exit(done)
 }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/loraxipam/compassrose"
+)
+
+const (
+	// Number of direction sectors in a wind rose
+	windroseSectors = 16
+	// Anything slower than this is counted as calm
+	windroseCalm = 1.0
+	// Radius of the terminal plot in rows
+	windroseRadius = 10
+)
+
+// Upper edges of the wind rose speed bins, in the station's windspeed unit.
+// Anything above the last edge falls in the last bin.
+var windroseBins = [...]float64{5, 10, 15, 20}
+
+// Fill characters and SVG colors for each speed bin, slowest first
+var windroseGlyphs = []rune("·░▒▓█")
+var windroseColors = [...]string{"#c6dbef", "#6baed6", "#2171b5", "#fd8d3c", "#d94801"}
+
+// windRose is the directional frequency and speed distribution for one station.
+// Counts are indexed by sector (0 is North, going clockwise) then by speed bin.
+type windRose struct {
+	Handle string
+	Name   string
+	Unit   string
+	Total  int
+	Calm   int
+	Counts [windroseSectors][len(windroseBins) + 1]int
+}
+
+// newWindRose tallies the wind readings from the history of one station
+func newWindRose(records []historyRecord) (rose windRose) {
+	for _, rec := range records {
+		rose.Handle = rec.Data.Station[0]
+		rose.Name = rec.Data.Station[1]
+		if rec.Units.Windspeed[0] != "" {
+			rose.Unit = rec.Units.Windspeed[0]
+		}
+		rose.Total++
+		speed := rec.Data.Windspeed[0]
+		if speed < windroseCalm {
+			rose.Calm++
+			continue
+		}
+		// A vane reading below zero is taken round the compass, not off the rose
+		sector := int(math.Mod(math.Mod(rec.Data.Windspeed[2]+180.0/windroseSectors, 360)+360, 360) / (360.0 / windroseSectors))
+		bin := len(windroseBins)
+		for i, edge := range windroseBins {
+			if speed < edge {
+				bin = i
+				break
+			}
+		}
+		rose.Counts[sector%windroseSectors][bin]++
+	}
+	return rose
+}
+
+// sectorTotal is the number of non-calm readings blowing from a sector
+func (rose *windRose) sectorTotal(sector int) (total int) {
+	for _, count := range rose.Counts[sector] {
+		total += count
+	}
+	return total
+}
+
+// maxSector is the reading count of the busiest sector
+func (rose *windRose) maxSector() (most int) {
+	for s := 0; s < windroseSectors; s++ {
+		if t := rose.sectorTotal(s); t > most {
+			most = t
+		}
+	}
+	return most
+}
+
+// percent returns a count as a percentage of all the readings
+func (rose *windRose) percent(count int) float64 {
+	if rose.Total == 0 {
+		return 0
+	}
+	return 100.0 * float64(count) / float64(rose.Total)
+}
+
+// sectorName is the 16 point compass name for the middle of a sector
+func sectorName(sector int) string {
+	short, _ := compassrose.DegreeToHeading(float32(sector)*360.0/windroseSectors, compassrose.SixteenPoints, true)
+	return short
+}
+
+// binLabels describes each speed bin, ala "5-10"
+func binLabels() (labels []string) {
+	low := windroseCalm
+	for _, edge := range windroseBins {
+		labels = append(labels, fmt.Sprintf("%g-%g", low, edge))
+		low = edge
+	}
+	return append(labels, fmt.Sprintf("%g+", low))
+}
+
+// PrintWindRose draws the rose as a polar plot followed by the frequency table
+func (rose *windRose) PrintWindRose() {
+	fmt.Printf("%s (%s) %d readings, %.1f%% calm\n", rose.Name, rose.Handle, rose.Total, rose.percent(rose.Calm))
+	most := rose.maxSector()
+	if most == 0 {
+		fmt.Println("  No wind to speak of.")
+		return
+	}
+
+	// Each cell is about twice as tall as it is wide, so use two columns per unit of radius
+	for row := -windroseRadius - 1; row <= windroseRadius+1; row++ {
+		var line strings.Builder
+		for col := -2 * (windroseRadius + 1); col <= 2*(windroseRadius+1); col++ {
+			line.WriteRune(rose.cell(row, col, most))
+		}
+		fmt.Println(strings.TrimRight(line.String(), " "))
+	}
+
+	labels := binLabels()
+	fmt.Printf("  %-4s %6s", "from", "total")
+	for i, label := range labels {
+		fmt.Printf(" %c %-6s", windroseGlyphs[i], label)
+	}
+	fmt.Printf("  (%s)\n", rose.Unit)
+	for s := 0; s < windroseSectors; s++ {
+		fmt.Printf("  %-4s %5.1f%%", sectorName(s), rose.percent(rose.sectorTotal(s)))
+		for _, count := range rose.Counts[s] {
+			fmt.Printf(" %7.1f%%", rose.percent(count))
+		}
+		fmt.Println()
+	}
+}
+
+// cell picks the character for one position of the terminal polar plot
+func (rose *windRose) cell(row, col, most int) rune {
+	x := float64(col) / 2
+	y := float64(-row)
+	r := math.Hypot(x, y)
+	switch {
+	case row == 0 && col == 0:
+		return '+'
+	case row == -windroseRadius-1 && col == 0:
+		return 'N'
+	case row == windroseRadius+1 && col == 0:
+		return 'S'
+	case row == 0 && col == 2*(windroseRadius+1):
+		return 'E'
+	case row == 0 && col == -2*(windroseRadius+1):
+		return 'W'
+	}
+
+	// Compass bearing of the cell, clockwise from North
+	angle := math.Mod(math.Atan2(x, y)*180/math.Pi+360, 360)
+	width := 360.0 / windroseSectors
+	sector := int(math.Mod(angle+width/2, 360) / width)
+	// Leave a little gap between petals
+	if offset := math.Abs(math.Mod(angle+width/2, width) - width/2); offset > width*0.4 {
+		return ' '
+	}
+
+	total := rose.sectorTotal(sector)
+	length := float64(windroseRadius) * float64(total) / float64(most)
+	if r > length || total == 0 {
+		return ' '
+	}
+	// Slower bins make up the inner part of each petal
+	cumulative := 0
+	for bin, count := range rose.Counts[sector] {
+		cumulative += count
+		if r <= length*float64(cumulative)/float64(total) {
+			return windroseGlyphs[bin]
+		}
+	}
+	return windroseGlyphs[len(windroseGlyphs)-1]
+}
+
+// WindRoseSVG renders the rose as a standalone SVG document
+func (rose *windRose) WindRoseSVG() string {
+	const size, center, radius = 400.0, 200.0, 160.0
+	var svg strings.Builder
+
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif" font-size="11">`+"\n", size, size+60, size, size+60)
+	fmt.Fprintf(&svg, `<text x="%g" y="16" text-anchor="middle" font-size="14">%s (%s) %.1f%% calm</text>`+"\n", center, html.EscapeString(rose.Name), html.EscapeString(rose.Handle), rose.percent(rose.Calm))
+
+	most := rose.maxSector()
+	// Frequency rings at quarters of the busiest sector
+	for i := 1; i <= 4; i++ {
+		r := radius * float64(i) / 4
+		fmt.Fprintf(&svg, `<circle cx="%g" cy="%g" r="%.1f" fill="none" stroke="#ccc"/>`+"\n", center, center+20, r)
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" fill="#888">%.1f%%</text>`+"\n", center+2, center+20-r-2, rose.percent(most)*float64(i)/4)
+	}
+	for s, label := range []string{"N", "E", "S", "W"} {
+		a := float64(s) * math.Pi / 2
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", center+(radius+14)*math.Sin(a), center+20-(radius+14)*math.Cos(a)+4, label)
+	}
+
+	width := 2 * math.Pi / windroseSectors
+	for s := 0; s < windroseSectors && most > 0; s++ {
+		total := rose.sectorTotal(s)
+		if total == 0 {
+			continue
+		}
+		// Draw the fastest bin first so the slower ones stack inside it
+		cumulative := total
+		for bin := len(rose.Counts[s]) - 1; bin >= 0; bin-- {
+			r := radius * float64(cumulative) / float64(most)
+			a0 := float64(s)*width - width*0.4
+			a1 := float64(s)*width + width*0.4
+			fmt.Fprintf(&svg, `<path d="M%g,%g L%.1f,%.1f A%.1f,%.1f 0 0,1 %.1f,%.1f Z" fill="%s" stroke="#fff" stroke-width="0.5"/>`+"\n",
+				center, center+20,
+				center+r*math.Sin(a0), center+20-r*math.Cos(a0),
+				r, r,
+				center+r*math.Sin(a1), center+20-r*math.Cos(a1),
+				windroseColors[bin])
+			cumulative -= rose.Counts[s][bin]
+		}
+	}
+
+	for i, label := range binLabels() {
+		x := 20.0 + float64(i)*75
+		fmt.Fprintf(&svg, `<rect x="%g" y="%g" width="12" height="12" fill="%s"/>`+"\n", x, size+30, windroseColors[i])
+		fmt.Fprintf(&svg, `<text x="%g" y="%g">%s %s</text>`+"\n", x+16, size+40, label, html.EscapeString(html.UnescapeString(rose.Unit)))
+	}
+	svg.WriteString("</svg>\n")
+
+	return svg.String()
+}
+
+// windroseCommand builds wind roses for each station from the history file
+func windroseCommand(config *configSettings, args []string) (err error) {
+	var (
+		days        int
+		station     string
+		svgFilename string
+	)
+	flags := flag.NewFlagSet("windrose", flag.ExitOnError)
+	flags.IntVar(&days, "days", 30, "Days of history to include")
+	flags.StringVar(&station, "station", "", "Only this station, by handle or alias")
+	flags.StringVar(&svgFilename, "svg", "", "Write the rose to this SVG file instead of the terminal")
+	flags.Parse(args)
+	station = config.resolveStation(station)
+
+	if config.History.File == "" {
+		return errNoHistory
+	}
+
+	records, err := readHistory(config, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+
+	handles, byStation := historyByStation(records)
+	if station != "" {
+		handles = []string{station}
+	}
+
+	for _, handle := range handles {
+		if len(byStation[handle]) == 0 {
+			return fmt.Errorf("No history for station %s in the last %d days", handle, days)
+		}
+		rose := newWindRose(byStation[handle])
+		if svgFilename == "" {
+			rose.PrintWindRose()
+			continue
+		}
+		outputFile := svgFilename
+		if len(handles) > 1 {
+			ext := filepath.Ext(svgFilename)
+			outputFile = strings.TrimSuffix(svgFilename, ext) + "-" + handle + ext
+		}
+		if err = ioutil.WriteFile(outputFile, []byte(rose.WindRoseSVG()), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"html"
+	"math"
+	"strings"
+	"time"
+)
+
+// Gaps in the history longer than this are left out of the wind run rather than guessed at
+const windStatsMaxGap = time.Hour
+
+// WindStatistics are today's wind figures for a station, from the history. Run is the
+// wind run, how far the air went past the anemometer, in RunUnit.
+type WindStatistics struct {
+	Run      float64   `json:"run"`
+	RunUnit  string    `json:"run_unit"`
+	Average  float64   `json:"average"`
+	PeakGust float64   `json:"peak_gust"`
+	PeakTime time.Time `json:"peak_time"`
+}
+
+// windRunUnit is the distance unit a windspeed unit covers in an hour, and the factor to get there
+func windRunUnit(speedUnit string) (unit string, factor float64) {
+	switch strings.ToLower(html.UnescapeString(speedUnit)) {
+	case "mph":
+		return "mi", 1
+	case "km/h", "kph", "kmh":
+		return "km", 1
+	case "kt", "kts", "knots":
+		return "NM", 1
+	case "m/s":
+		return "km", 3.6
+	default:
+		return speedUnit + "·h", 1
+	}
+}
+
+// AssessWindStatistics works out the wind run, time weighted average and peak gust
+// since midnight from a station's history, oldest first
+func AssessWindStatistics(units *WeatherUnits, records []historyRecord, now time.Time) (stats WindStatistics) {
+	local := now.Local()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+
+	var hours float64
+	var previous *historyRecord
+	for i := range records {
+		rec := &records[i]
+		if rec.Time.Before(midnight) {
+			continue
+		}
+		speed, _ := convertUnit(rec.Data.Windspeed[0], rec.Units.Windspeed[0], units.Windspeed[0])
+		gust, _ := convertUnit(rec.Data.Windspeed[1], rec.Units.Windspeed[1], units.Windspeed[1])
+		if gust > stats.PeakGust {
+			stats.PeakGust, stats.PeakTime = gust, rec.Time
+		}
+		if previous != nil {
+			if gap := rec.Time.Sub(previous.Time); gap > 0 && gap <= windStatsMaxGap {
+				before, _ := convertUnit(previous.Data.Windspeed[0], previous.Units.Windspeed[0], units.Windspeed[0])
+				// Trapezoids, speed times time
+				stats.Run += (before + speed) / 2 * gap.Hours()
+				hours += gap.Hours()
+			}
+		}
+		previous = rec
+	}
+
+	if hours > 0 {
+		stats.Average = stats.Run / hours
+	}
+	unit, factor := windRunUnit(units.Windspeed[0])
+	stats.Run = math.Round(stats.Run*factor*10) / 10
+	stats.RunUnit = unit
+	return stats
+}